@@ -0,0 +1,13 @@
+package app
+
+import (
+	"e-cart/app/internal"
+	"e-cart/app/service"
+
+	"gorm.io/gorm"
+)
+
+// CatalogService wires the catalog import and export for the command line
+func CatalogService(db *gorm.DB) service.CatalogService {
+	return service.NewCatalogService(internal.NewCatalogRepo(db))
+}
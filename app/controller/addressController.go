@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type AddressController interface {
+	SaveAddress(w http.ResponseWriter, r *http.Request)
+	ListAddresses(w http.ResponseWriter, r *http.Request)
+	GetAddress(w http.ResponseWriter, r *http.Request)
+	UpdateAddress(w http.ResponseWriter, r *http.Request)
+	DeleteAddress(w http.ResponseWriter, r *http.Request)
+}
+
+type AddressControllerImpl struct {
+	addressService service.AddressService
+}
+
+func NewAddressController(addressService service.AddressService) AddressController {
+	return &AddressControllerImpl{
+		addressService: addressService,
+	}
+}
+
+func (c *AddressControllerImpl) SaveAddress(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.addressService.SaveAddress(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to save the address")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *AddressControllerImpl) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.addressService.ListAddresses(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the addresses")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *AddressControllerImpl) GetAddress(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.addressService.GetAddress(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the address")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *AddressControllerImpl) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.addressService.UpdateAddress(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the address")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *AddressControllerImpl) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	err := c.addressService.DeleteAddress(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete the address")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted address")
+}
@@ -14,6 +14,7 @@ type AdminController interface {
 	CustomerOrderHistoryById(w http.ResponseWriter, r *http.Request)
 	GetAllBlockedUserDetail(w http.ResponseWriter, r *http.Request)
 	CustomerOrderHistory(w http.ResponseWriter, r *http.Request)
+	UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 }
 
 type AdminControlImpl struct {
@@ -93,3 +94,13 @@ func (c *AdminControlImpl) CustomerOrderHistory(w http.ResponseWriter, r *http.R
 	}
 	api.Success(w, http.StatusOK, resp)
 }
+
+func (c *AdminControlImpl) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.adminService.UpdateOrderStatus(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the order status")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type CatalogController interface {
+	ImportCatalog(w http.ResponseWriter, r *http.Request)
+	ExportCatalog(w http.ResponseWriter, r *http.Request)
+}
+
+type CatalogControllerImpl struct {
+	catalogService service.CatalogService
+}
+
+func NewCatalogController(catalogService service.CatalogService) CatalogController {
+	return &CatalogControllerImpl{
+		catalogService: catalogService,
+	}
+}
+
+// ImportCatalog reads the file from the request body, files over dto.MaxCatalogImportBytes are refused
+func (c *CatalogControllerImpl) ImportCatalog(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, dto.MaxCatalogImportBytes)
+	resp, err := c.catalogService.ImportCatalog(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to import catalog")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+// ExportCatalog sends the catalog as a file download instead of the json envelope
+func (c *CatalogControllerImpl) ExportCatalog(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.catalogService.ExportCatalog(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to export catalog")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", resp.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+resp.FileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp.Data)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type CouponController interface {
+	CreateCoupon(w http.ResponseWriter, r *http.Request)
+	GetCoupon(w http.ResponseWriter, r *http.Request)
+	ListCoupons(w http.ResponseWriter, r *http.Request)
+	UpdateCoupon(w http.ResponseWriter, r *http.Request)
+	DeleteCoupon(w http.ResponseWriter, r *http.Request)
+}
+
+type CouponControllerImpl struct {
+	couponService service.CouponService
+}
+
+func NewCouponController(couponService service.CouponService) CouponController {
+	return &CouponControllerImpl{
+		couponService: couponService,
+	}
+}
+
+func (c *CouponControllerImpl) CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.couponService.CreateCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to create the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *CouponControllerImpl) GetCoupon(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.couponService.GetCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *CouponControllerImpl) ListCoupons(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.couponService.ListCoupons(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list the coupons")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *CouponControllerImpl) UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.couponService.UpdateCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *CouponControllerImpl) DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	err := c.couponService.DeleteCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted coupon")
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type InventoryController interface {
+	AdjustStock(w http.ResponseWriter, r *http.Request)
+	StockHistory(w http.ResponseWriter, r *http.Request)
+	ReconcileStock(w http.ResponseWriter, r *http.Request)
+	LowStock(w http.ResponseWriter, r *http.Request)
+}
+
+type InventoryControllerImpl struct {
+	inventoryService service.InventoryService
+}
+
+func NewInventoryController(inventoryService service.InventoryService) InventoryController {
+	return &InventoryControllerImpl{
+		inventoryService: inventoryService,
+	}
+}
+
+func (c *InventoryControllerImpl) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.inventoryService.AdjustStock(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to adjust stock")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *InventoryControllerImpl) StockHistory(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.inventoryService.StockHistory(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get stock history")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *InventoryControllerImpl) ReconcileStock(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.inventoryService.ReconcileStock(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to reconcile stock")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *InventoryControllerImpl) LowStock(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.inventoryService.LowStock(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list low stock products")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
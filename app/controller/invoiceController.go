@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type InvoiceController interface {
+	UserInvoice(w http.ResponseWriter, r *http.Request)
+	AdminInvoice(w http.ResponseWriter, r *http.Request)
+}
+
+type InvoiceControllerImpl struct {
+	invoiceService service.InvoiceService
+}
+
+func NewInvoiceController(invoiceService service.InvoiceService) InvoiceController {
+	return &InvoiceControllerImpl{
+		invoiceService: invoiceService,
+	}
+}
+
+func (c *InvoiceControllerImpl) UserInvoice(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.invoiceService.UserInvoice(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the invoice")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	writeInvoice(w, resp)
+}
+
+func (c *InvoiceControllerImpl) AdminInvoice(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.invoiceService.AdminInvoice(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the invoice")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	writeInvoice(w, resp)
+}
+
+// writeInvoice sends the invoice as a file, a pdf is downloaded and the html variant opens in the browser
+func writeInvoice(w http.ResponseWriter, file *dto.InvoiceFile) {
+	disposition := "attachment"
+	if file.ContentType != "application/pdf" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Content-Disposition", disposition+`; filename="`+file.FileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(file.Data)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type PaymentController interface {
+	CreatePayment(w http.ResponseWriter, r *http.Request)
+	PaymentWebhook(w http.ResponseWriter, r *http.Request)
+}
+
+type PaymentControllerImpl struct {
+	paymentService service.PaymentService
+}
+
+func NewPaymentController(paymentService service.PaymentService) PaymentController {
+	return &PaymentControllerImpl{
+		paymentService: paymentService,
+	}
+}
+
+func (c *PaymentControllerImpl) CreatePayment(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.paymentService.CreatePayment(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to start the payment")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *PaymentControllerImpl) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.paymentService.HandleWebhook(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to process the payment webhook")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
@@ -13,8 +13,15 @@ type ProductController interface {
 	GetCatagoryById(w http.ResponseWriter, r *http.Request)
 	GetCatagoryByName(w http.ResponseWriter, r *http.Request)
 	ListAllBrand(w http.ResponseWriter, r *http.Request)
+	SearchProducts(w http.ResponseWriter, r *http.Request)
 	GetBrandByID(w http.ResponseWriter, r *http.Request)
 	GetCatagoryDetailsById(w http.ResponseWriter, r *http.Request)
+	UpdateCategory(w http.ResponseWriter, r *http.Request)
+	UpdateBrand(w http.ResponseWriter, r *http.Request)
+	DeleteCategory(w http.ResponseWriter, r *http.Request)
+	RestoreCategory(w http.ResponseWriter, r *http.Request)
+	DeleteBrand(w http.ResponseWriter, r *http.Request)
+	RestoreBrand(w http.ResponseWriter, r *http.Request)
 }
 
 type ProductControllerImpl struct {
@@ -38,13 +45,13 @@ func (c *ProductControllerImpl) CreateProduct(w http.ResponseWriter, r *http.Req
 }
 
 func (c *ProductControllerImpl) ListAllProduct(w http.ResponseWriter, r *http.Request) {
-	resp, err := c.productService.ListAllProduct(r)
+	resp, meta, err := c.productService.ListAllProduct(r)
 	if err != nil {
 		apiErr := e.NewAPIError(err, "failed to list all product")
 		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
 		return
 	}
-	api.Success(w, http.StatusOK, resp)
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
 }
 
 func (c *ProductControllerImpl) GetCatagoryById(w http.ResponseWriter, r *http.Request) {
@@ -78,13 +85,23 @@ func (c *ProductControllerImpl) GetCatagoryByName(w http.ResponseWriter, r *http
 }
 
 func (c *ProductControllerImpl) ListAllBrand(w http.ResponseWriter, r *http.Request) {
-	resp, err := c.productService.ListAllBrands(r)
+	resp, meta, err := c.productService.ListAllBrands(r)
 	if err != nil {
 		apiErr := e.NewAPIError(err, "failed to get brand details")
 		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
 		return
 	}
-	api.Success(w, http.StatusOK, resp)
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *ProductControllerImpl) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.productService.SearchProducts(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to search products")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
 }
 
 func (c *ProductControllerImpl) GetBrandByID(w http.ResponseWriter, r *http.Request) {
@@ -97,6 +114,66 @@ func (c *ProductControllerImpl) GetBrandByID(w http.ResponseWriter, r *http.Requ
 	api.Success(w, http.StatusOK, resp)
 }
 
+func (c *ProductControllerImpl) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.productService.UpdateCategory(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update category")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ProductControllerImpl) UpdateBrand(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.productService.UpdateBrand(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update brand")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ProductControllerImpl) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	err := c.productService.DeleteCategory(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete category")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted category")
+}
+
+func (c *ProductControllerImpl) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	err := c.productService.RestoreCategory(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to restore category")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully restored category")
+}
+
+func (c *ProductControllerImpl) DeleteBrand(w http.ResponseWriter, r *http.Request) {
+	err := c.productService.DeleteBrand(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete brand")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted brand")
+}
+
+func (c *ProductControllerImpl) RestoreBrand(w http.ResponseWriter, r *http.Request) {
+	err := c.productService.RestoreBrand(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to restore brand")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully restored brand")
+}
+
 // func (c *ProductControllerImpl) UpdateCatagoryById(w http.ResponseWriter, r *http.Request) {
 // 	resp, err := c.productService.ListAllBrands(r)
 // 	if err != nil {
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type RefundController interface {
+	CancelOrder(w http.ResponseWriter, r *http.Request)
+	RefundOrder(w http.ResponseWriter, r *http.Request)
+}
+
+type RefundControllerImpl struct {
+	refundService service.RefundService
+}
+
+func NewRefundController(refundService service.RefundService) RefundController {
+	return &RefundControllerImpl{
+		refundService: refundService,
+	}
+}
+
+func (c *RefundControllerImpl) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.refundService.CancelOrder(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to cancel the order")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *RefundControllerImpl) RefundOrder(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.refundService.RefundOrder(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to refund the order")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
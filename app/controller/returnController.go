@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type ReturnController interface {
+	CreateReturn(w http.ResponseWriter, r *http.Request)
+	ListUserReturns(w http.ResponseWriter, r *http.Request)
+	ListReturns(w http.ResponseWriter, r *http.Request)
+	ApproveReturn(w http.ResponseWriter, r *http.Request)
+	RejectReturn(w http.ResponseWriter, r *http.Request)
+	ReceiveReturn(w http.ResponseWriter, r *http.Request)
+	RefundReturn(w http.ResponseWriter, r *http.Request)
+}
+
+type ReturnControllerImpl struct {
+	returnService service.ReturnService
+}
+
+func NewReturnController(returnService service.ReturnService) ReturnController {
+	return &ReturnControllerImpl{
+		returnService: returnService,
+	}
+}
+
+func (c *ReturnControllerImpl) CreateReturn(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.returnService.CreateReturn(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to request the return")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ReturnControllerImpl) ListUserReturns(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.returnService.ListUserReturns(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list the returns")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *ReturnControllerImpl) ListReturns(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.returnService.ListReturns(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list the returns")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *ReturnControllerImpl) ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.returnService.ApproveReturn(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to approve the return")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ReturnControllerImpl) RejectReturn(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.returnService.RejectReturn(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to reject the return")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ReturnControllerImpl) ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.returnService.ReceiveReturn(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to receive the return")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ReturnControllerImpl) RefundReturn(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.returnService.RefundReturn(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to refund the return")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
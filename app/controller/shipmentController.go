@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type ShipmentController interface {
+	CreateShipment(w http.ResponseWriter, r *http.Request)
+	ListShipments(w http.ResponseWriter, r *http.Request)
+	CancelShipment(w http.ResponseWriter, r *http.Request)
+	TrackOrder(w http.ResponseWriter, r *http.Request)
+}
+
+type ShipmentControllerImpl struct {
+	shipmentService service.ShipmentService
+}
+
+func NewShipmentController(shipmentService service.ShipmentService) ShipmentController {
+	return &ShipmentControllerImpl{
+		shipmentService: shipmentService,
+	}
+}
+
+func (c *ShipmentControllerImpl) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shipmentService.CreateShipment(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to create the shipment")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShipmentControllerImpl) ListShipments(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shipmentService.ListShipments(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the shipments")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShipmentControllerImpl) CancelShipment(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shipmentService.CancelShipment(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to cancel the shipment")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShipmentControllerImpl) TrackOrder(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shipmentService.TrackOrder(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to track the order")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type ShippingController interface {
+	CreateZone(w http.ResponseWriter, r *http.Request)
+	GetZone(w http.ResponseWriter, r *http.Request)
+	ListZones(w http.ResponseWriter, r *http.Request)
+	UpdateZone(w http.ResponseWriter, r *http.Request)
+	DeleteZone(w http.ResponseWriter, r *http.Request)
+	CheckPincode(w http.ResponseWriter, r *http.Request)
+}
+
+type ShippingControllerImpl struct {
+	shippingService service.ShippingService
+}
+
+func NewShippingController(shippingService service.ShippingService) ShippingController {
+	return &ShippingControllerImpl{
+		shippingService: shippingService,
+	}
+}
+
+func (c *ShippingControllerImpl) CreateZone(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shippingService.CreateZone(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to create the shipping zone")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShippingControllerImpl) GetZone(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shippingService.GetZone(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the shipping zone")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShippingControllerImpl) ListZones(w http.ResponseWriter, r *http.Request) {
+	resp, meta, err := c.shippingService.ListZones(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list the shipping zones")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.SuccessWithMeta(w, http.StatusOK, resp, meta)
+}
+
+func (c *ShippingControllerImpl) UpdateZone(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shippingService.UpdateZone(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the shipping zone")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *ShippingControllerImpl) DeleteZone(w http.ResponseWriter, r *http.Request) {
+	err := c.shippingService.DeleteZone(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete the shipping zone")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted shipping zone")
+}
+
+func (c *ShippingControllerImpl) CheckPincode(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.shippingService.CheckPincode(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to check the pincode")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
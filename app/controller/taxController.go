@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"e-cart/app/service"
+	"e-cart/pkg/api"
+	"e-cart/pkg/e"
+	"net/http"
+)
+
+type TaxController interface {
+	CreateTaxClass(w http.ResponseWriter, r *http.Request)
+	GetTaxClass(w http.ResponseWriter, r *http.Request)
+	ListTaxClasses(w http.ResponseWriter, r *http.Request)
+	UpdateTaxClass(w http.ResponseWriter, r *http.Request)
+	DeleteTaxClass(w http.ResponseWriter, r *http.Request)
+}
+
+type TaxControllerImpl struct {
+	taxService service.TaxService
+}
+
+func NewTaxController(taxService service.TaxService) TaxController {
+	return &TaxControllerImpl{
+		taxService: taxService,
+	}
+}
+
+func (c *TaxControllerImpl) CreateTaxClass(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.taxService.CreateTaxClass(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to create the tax class")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *TaxControllerImpl) GetTaxClass(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.taxService.GetTaxClass(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to get the tax class")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *TaxControllerImpl) ListTaxClasses(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.taxService.ListTaxClasses(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to list the tax classes")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *TaxControllerImpl) UpdateTaxClass(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.taxService.UpdateTaxClass(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the tax class")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *TaxControllerImpl) DeleteTaxClass(w http.ResponseWriter, r *http.Request) {
+	err := c.taxService.DeleteTaxClass(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to delete the tax class")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "Successfully deleted tax class")
+}
@@ -9,13 +9,20 @@ import (
 
 type UserController interface {
 	LoginUser(w http.ResponseWriter, r *http.Request)
+	RefreshToken(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	LogoutAll(w http.ResponseWriter, r *http.Request)
 	UserDetails(w http.ResponseWriter, r *http.Request)
 	GetUserDetails(w http.ResponseWriter, r *http.Request)
 	UpdateUserDetails(w http.ResponseWriter, r *http.Request)
 	ChangePassword(w http.ResponseWriter, r *http.Request)
 	ViewUserCart(w http.ResponseWriter, r *http.Request)
+	ApplyCoupon(w http.ResponseWriter, r *http.Request)
+	RemoveCoupon(w http.ResponseWriter, r *http.Request)
 	ClearCart(w http.ResponseWriter, r *http.Request)
 	AddItemsToCart(w http.ResponseWriter, r *http.Request)
+	UpdateCartItem(w http.ResponseWriter, r *http.Request)
+	RemoveCartItem(w http.ResponseWriter, r *http.Request)
 	PlaceOrder(w http.ResponseWriter, r *http.Request)
 	OrderHistory(w http.ResponseWriter, r *http.Request)
 	AddItemsToFavourites(w http.ResponseWriter, r *http.Request)
@@ -52,6 +59,36 @@ func (c *UserControllerImpl) LoginUser(w http.ResponseWriter, r *http.Request) {
 	api.Success(w, http.StatusOK, resp)
 }
 
+func (c *UserControllerImpl) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.userService.RefreshToken(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to refresh token")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *UserControllerImpl) Logout(w http.ResponseWriter, r *http.Request) {
+	err := c.userService.Logout(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to logout user")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "success")
+}
+
+func (c *UserControllerImpl) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	err := c.userService.LogoutAll(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to logout user from all devices")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "success")
+}
+
 func (c *UserControllerImpl) UpdateUserDetails(w http.ResponseWriter, r *http.Request) {
 
 	err := c.userService.UpdateUserDetails(r)
@@ -94,6 +131,26 @@ func (c *UserControllerImpl) AddItemsToCart(w http.ResponseWriter, r *http.Reque
 	api.Success(w, http.StatusOK, resp)
 }
 
+func (c *UserControllerImpl) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.userService.UpdateCartItem(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to update the cart item")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *UserControllerImpl) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	err := c.userService.RemoveCartItem(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to remove the cart item")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, "success")
+}
+
 func (c *UserControllerImpl) PlaceOrder(w http.ResponseWriter, r *http.Request) {
 	resp, err := c.userService.PlaceOrder(r)
 	if err != nil {
@@ -114,6 +171,26 @@ func (c *UserControllerImpl) ViewUserCart(w http.ResponseWriter, r *http.Request
 	api.Success(w, http.StatusOK, resp)
 }
 
+func (c *UserControllerImpl) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.userService.ApplyCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to apply the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+func (c *UserControllerImpl) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	resp, err := c.userService.RemoveCoupon(r)
+	if err != nil {
+		apiErr := e.NewAPIError(err, "failed to remove the coupon")
+		api.Fail(w, apiErr.StatusCode, apiErr.Code, apiErr.Message, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
 func (c *UserControllerImpl) ClearCart(w http.ResponseWriter, r *http.Request) {
 	err := c.userService.ClearCart(r)
 	if err != nil {
@@ -0,0 +1,149 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// SaveAddressRequest adds an address to the address book, the first address is the default for both
+type SaveAddressRequest struct {
+	Name              string `json:"name" validate:"required,max=100"`
+	Line1             string `json:"line1" validate:"required,max=200"`
+	Line2             string `json:"line2" validate:"max=200"`
+	City              string `json:"city" validate:"required,max=100"`
+	State             string `json:"state" validate:"required,max=100"`
+	Pincode           int64  `json:"pincode" validate:"required,gt=0"`
+	Phone             int64  `json:"phonenumber" validate:"required,gt=0"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+// UpdateAddressRequest edits an address, only the fields present in the body are changed
+type UpdateAddressRequest struct {
+	AddressID         int64   `json:"address_id"`
+	Name              *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Line1             *string `json:"line1" validate:"omitempty,min=1,max=200"`
+	Line2             *string `json:"line2" validate:"omitempty,max=200"`
+	City              *string `json:"city" validate:"omitempty,min=1,max=100"`
+	State             *string `json:"state" validate:"omitempty,min=1,max=100"`
+	Pincode           *int64  `json:"pincode" validate:"omitempty,gt=0"`
+	Phone             *int64  `json:"phonenumber" validate:"omitempty,gt=0"`
+	IsDefaultShipping *bool   `json:"is_default_shipping"`
+	IsDefaultBilling  *bool   `json:"is_default_billing"`
+}
+
+// AddressIDRequest reads the address id of the URL
+type AddressIDRequest struct {
+	AddressID int64 `json:"address_id" validate:"required,gt=0"`
+}
+
+type AddressResponse struct {
+	AddressID         int64     `json:"address_id"`
+	Name              string    `json:"name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2,omitempty"`
+	City              string    `json:"city"`
+	State             string    `json:"state"`
+	Pincode           int64     `json:"pincode"`
+	PhoneNumber       int64     `json:"phone_number"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// OrderAddressResponse is the address kept on an order when it was placed
+type OrderAddressResponse struct {
+	Name        string `json:"name"`
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2,omitempty"`
+	City        string `json:"city,omitempty"`
+	State       string `json:"state,omitempty"`
+	Pincode     int64  `json:"pincode"`
+	PhoneNumber int64  `json:"phone_number"`
+}
+
+func (args *SaveAddressRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *SaveAddressRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *UpdateAddressRequest) Parse(r *http.Request) error {
+	addressID, err := parseAddressIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+
+	// the id in the URL wins over one in the body
+	args.AddressID = addressID
+	return nil
+}
+
+func (args *UpdateAddressRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	if args.Name == nil && args.Line1 == nil && args.Line2 == nil && args.City == nil && args.State == nil &&
+		args.Pincode == nil && args.Phone == nil && args.IsDefaultShipping == nil && args.IsDefaultBilling == nil {
+		return errors.New("at least one field has to be updated")
+	}
+	return nil
+}
+
+func (args *AddressIDRequest) Parse(r *http.Request) error {
+	addressID, err := parseAddressIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.AddressID = addressID
+	return nil
+}
+
+func (args *AddressIDRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseAddressIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "addressid")
+	if strID == "" {
+		return 0, fmt.Errorf("addressid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address id: %v", err)
+	}
+	return int64(intID), nil
+}
@@ -1,5 +1,13 @@
 package dto
 
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator"
+)
+
 type BrandDetailResponse struct {
 	BrandName string  `json:"brandname"`
 	BrandId   int64   `json:"brandid"`
@@ -9,4 +17,77 @@ type BrandDetailResponse struct {
 	// CategoryID   int64   `json:"category_id"`
 	CategoryName string `json:"categoryname"`
 	Model        string `json:"model"`
+	IsDeleted    bool   `json:"is_deleted"`
+}
+
+// ListBrandsRequest is read from the query params of the brand listing
+type ListBrandsRequest struct {
+	Pagination
+	Sort       string   `json:"sort" validate:"omitempty,oneof=price release_date name"`
+	Order      string   `json:"order" validate:"omitempty,oneof=asc desc"`
+	CategoryID int64    `json:"category_id" validate:"omitempty,gt=0"`
+	MinPrice   *float64 `json:"min_price" validate:"omitempty,gte=0"`
+	MaxPrice   *float64 `json:"max_price" validate:"omitempty,gte=0"`
+	InStock    bool     `json:"in_stock"`
+	Model      string   `json:"model"`
+	// IncludeDeleted lists deleted brands too, admins only
+	IncludeDeleted bool `json:"include_deleted"`
+}
+
+func (args *ListBrandsRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	args.Sort = query.Get("sort")
+	args.Order = query.Get("order")
+	args.Model = query.Get("model")
+
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		intID, err := strconv.Atoi(categoryID)
+		if err != nil {
+			return fmt.Errorf("invalid category_id: %v", err)
+		}
+		args.CategoryID = int64(intID)
+	}
+	if minPrice := query.Get("min_price"); minPrice != "" {
+		price, err := strconv.ParseFloat(minPrice, 64)
+		if err != nil {
+			return fmt.Errorf("invalid min_price: %v", err)
+		}
+		args.MinPrice = &price
+	}
+	if maxPrice := query.Get("max_price"); maxPrice != "" {
+		price, err := strconv.ParseFloat(maxPrice, 64)
+		if err != nil {
+			return fmt.Errorf("invalid max_price: %v", err)
+		}
+		args.MaxPrice = &price
+	}
+	if inStock := query.Get("in_stock"); inStock != "" {
+		args.InStock, err = strconv.ParseBool(inStock)
+		if err != nil {
+			return fmt.Errorf("invalid in_stock: %v", err)
+		}
+	}
+
+	args.IncludeDeleted, err = parseIncludeDeleted(r)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ListBrandsRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	if args.MinPrice != nil && args.MaxPrice != nil && *args.MinPrice > *args.MaxPrice {
+		return fmt.Errorf("min_price cannot be greater than max_price")
+	}
+	return nil
 }
@@ -4,24 +4,35 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type BrandFullDetailByIdRequest struct {
 	BrandId int64 `json:"brandid"`
+	// IncludeDeleted shows the brand even when it is deleted, admins only
+	IncludeDeleted bool `json:"include_deleted"`
 }
 
 type BrandFullDetailByIdResponse struct {
-	BrandId          int64    `json:"brandid"`
-	BrandName        string   `json:"brandname"`
-	Price            float64  `json:"price"`
-	StockCount       int64    `json:"stockcount"`
-	ImageLink        string   `json:"imagelink"`
-	GalleryLinks     []string `json:"gallerylinks"`
-	BrandDescription string   `json:"branddescription"`
-	CategoryID       int64    `json:"categoryid"`
-	CategoryName     string   `json:"categoryname"`
+	BrandId          int64     `json:"brandid"`
+	BrandName        string    `json:"brandname"`
+	Price            float64   `json:"price"`
+	StockCount       int64     `json:"stockcount"`
+	ReservedStock    int64     `json:"reservedstock"`
+	AvailableStock   int64     `json:"availablestock"` // stock count minus what carts reserved
+	ReorderThreshold int64     `json:"reorderthreshold"`
+	WeightGrams      int64     `json:"weightgrams"`
+	TaxClassID       *int64    `json:"taxclassid,omitempty"`
+	ImageLink        string    `json:"imagelink"`
+	GalleryLinks     []string  `json:"gallerylinks"`
+	BrandDescription string    `json:"branddescription"`
+	Model            string    `json:"model"`
+	ReleaseDate      time.Time `json:"releasedate"`
+	CategoryID       int64     `json:"categoryid"`
+	CategoryName     string    `json:"categoryname"`
+	IsDeleted        bool      `json:"is_deleted"`
 }
 
 func (args *BrandFullDetailByIdRequest) Parse(r *http.Request) error {
@@ -34,5 +45,10 @@ func (args *BrandFullDetailByIdRequest) Parse(r *http.Request) error {
 		return err
 	}
 	args.BrandId = int64(intID)
+
+	args.IncludeDeleted, err = parseIncludeDeleted(r)
+	if err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,166 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// Catalog file formats
+const (
+	CatalogFormatCSV   = "csv"
+	CatalogFormatJSONL = "jsonl"
+)
+
+// MaxCatalogImportBytes is the largest catalog file that is imported, 10 MB
+const MaxCatalogImportBytes = 10 << 20
+
+// CatalogDateLayout is the layout of the release date in catalog files
+const CatalogDateLayout = "2006-01-02"
+
+// Import actions reported for every row
+const (
+	CatalogActionCreated   = "created"
+	CatalogActionUpdated   = "updated"
+	CatalogActionUnchanged = "unchanged"
+	CatalogActionFailed    = "failed"
+)
+
+// CatalogColumns is the header of the csv files, jsonl files use the same keys
+var CatalogColumns = []string{
+	"category_name", "category_description", "brand_name", "model", "price",
+	"stock_count", "description", "image_link", "gallery_links", "release_date",
+}
+
+// CatalogRow is one line of an import or export file. A row without brand_name only
+// creates or updates the category
+type CatalogRow struct {
+	CategoryName        string   `json:"category_name" validate:"required,max=100"`
+	CategoryDescription string   `json:"category_description" validate:"max=2000"`
+	BrandName           string   `json:"brand_name" validate:"max=100"`
+	Model               string   `json:"model" validate:"max=100"`
+	Price               float64  `json:"price" validate:"gte=0"`
+	StockCount          *int64   `json:"stock_count" validate:"omitempty,gte=0"` // nil keeps the stock of an existing brand
+	Description         string   `json:"description" validate:"max=2000"`
+	ImageLink           string   `json:"image_link" validate:"omitempty,url"`
+	GalleryLinks        []string `json:"gallery_links" validate:"omitempty,dive,url"`
+	// ReleaseDate is a YYYY-MM-DD date, new brands without one are released today
+	ReleaseDate string `json:"release_date"`
+}
+
+// CatalogImportRequest is read from the query params of the import, the file is the request body
+type CatalogImportRequest struct {
+	Format string `json:"format" validate:"required,oneof=csv jsonl"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// CatalogExportRequest is read from the query params of the export
+type CatalogExportRequest struct {
+	Format string `json:"format" validate:"required,oneof=csv jsonl"`
+}
+
+// CatalogImportRowResult is the outcome of a single row, line is the line number in the file
+type CatalogImportRowResult struct {
+	Line            int    `json:"line"`
+	CategoryName    string `json:"category_name"`
+	BrandName       string `json:"brand_name,omitempty"`
+	Model           string `json:"model,omitempty"`
+	Action          string `json:"action"`
+	CategoryCreated bool   `json:"category_created,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+type CatalogImportResponse struct {
+	DryRun    bool                     `json:"dry_run"`
+	Total     int                      `json:"total"`
+	Created   int                      `json:"created"`
+	Updated   int                      `json:"updated"`
+	Unchanged int                      `json:"unchanged"`
+	Failed    int                      `json:"failed"`
+	Rows      []CatalogImportRowResult `json:"rows"`
+}
+
+func (args *CatalogRow) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	if args.BrandName == "" {
+		if args.Model != "" || args.Price != 0 || args.StockCount != nil || args.Description != "" ||
+			args.ImageLink != "" || len(args.GalleryLinks) > 0 || args.ReleaseDate != "" {
+			return errors.New("brand_name is required when brand details are given")
+		}
+		return nil
+	}
+	if args.Model == "" {
+		return errors.New("model is required along with brand_name")
+	}
+	if args.Price <= 0 {
+		return errors.New("price has to be greater than 0")
+	}
+	if args.ReleaseDate != "" {
+		if _, err := time.Parse(CatalogDateLayout, args.ReleaseDate); err != nil {
+			return fmt.Errorf("invalid release_date, expected YYYY-MM-DD: %v", err)
+		}
+	}
+	return nil
+}
+
+func (args *CatalogImportRequest) Parse(r *http.Request) error {
+	query := r.URL.Query()
+	args.Format = strings.ToLower(query.Get("format"))
+
+	if dryRun := query.Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return fmt.Errorf("invalid dry_run: %v", err)
+		}
+		args.DryRun = value
+	}
+	return nil
+}
+
+func (args *CatalogImportRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *CatalogExportRequest) Parse(r *http.Request) error {
+	args.Format = strings.ToLower(r.URL.Query().Get("format"))
+	if args.Format == "" {
+		args.Format = CatalogFormatCSV
+	}
+	return nil
+}
+
+func (args *CatalogExportRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CatalogLine is a row read from an import file along with its line number
+type CatalogLine struct {
+	Line int
+	Row  CatalogRow
+}
+
+// CatalogExport is the exported file sent back as a download
+type CatalogExport struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
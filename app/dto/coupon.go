@@ -0,0 +1,214 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// CreateCouponRequest creates a coupon. Value is the percentage or the amount off, depending on the type.
+// Zero limits mean there is no limit, a coupon is active unless told otherwise
+type CreateCouponRequest struct {
+	Code         string     `json:"code" validate:"required,min=3,max=32,alphanum"`
+	Description  string     `json:"description" validate:"max=500"`
+	Type         string     `json:"type" validate:"required,oneof=percentage fixed buy_x_get_y free_shipping"`
+	Value        float64    `json:"value" validate:"gte=0"`
+	MaxDiscount  float64    `json:"max_discount" validate:"gte=0"`
+	BuyQuantity  int64      `json:"buy_quantity" validate:"gte=0"`
+	GetQuantity  int64      `json:"get_quantity" validate:"gte=0"`
+	CategoryID   *int64     `json:"category_id" validate:"omitempty,gt=0"`
+	BrandID      *int64     `json:"brand_id" validate:"omitempty,gt=0"`
+	MinCartValue float64    `json:"min_cart_value" validate:"gte=0"`
+	UsageLimit   int64      `json:"usage_limit" validate:"gte=0"`
+	PerUserLimit int64      `json:"per_user_limit" validate:"gte=0"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       *bool      `json:"active"`
+}
+
+// UpdateCouponRequest edits a coupon, only the fields present in the body are changed.
+// The code and type cannot be changed, a category_id or brand_id of 0 removes that limit
+type UpdateCouponRequest struct {
+	CouponID     int64      `json:"coupon_id"`
+	Description  *string    `json:"description" validate:"omitempty,max=500"`
+	Value        *float64   `json:"value" validate:"omitempty,gte=0"`
+	MaxDiscount  *float64   `json:"max_discount" validate:"omitempty,gte=0"`
+	BuyQuantity  *int64     `json:"buy_quantity" validate:"omitempty,gte=0"`
+	GetQuantity  *int64     `json:"get_quantity" validate:"omitempty,gte=0"`
+	CategoryID   *int64     `json:"category_id" validate:"omitempty,gte=0"`
+	BrandID      *int64     `json:"brand_id" validate:"omitempty,gte=0"`
+	MinCartValue *float64   `json:"min_cart_value" validate:"omitempty,gte=0"`
+	UsageLimit   *int64     `json:"usage_limit" validate:"omitempty,gte=0"`
+	PerUserLimit *int64     `json:"per_user_limit" validate:"omitempty,gte=0"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	Active       *bool      `json:"active"`
+}
+
+// CouponIDRequest reads the coupon id of the URL
+type CouponIDRequest struct {
+	CouponID int64 `json:"coupon_id" validate:"required,gt=0"`
+}
+
+type ListCouponsRequest struct {
+	Pagination
+	Active *bool  `json:"active"`
+	Code   string `json:"code" validate:"max=32"`
+}
+
+// ApplyCouponRequest applies a coupon code to the cart
+type ApplyCouponRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type CouponResponse struct {
+	CouponID     int64      `json:"coupon_id"`
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type"`
+	Value        float64    `json:"value"`
+	MaxDiscount  float64    `json:"max_discount"`
+	BuyQuantity  int64      `json:"buy_quantity"`
+	GetQuantity  int64      `json:"get_quantity"`
+	CategoryID   *int64     `json:"category_id,omitempty"`
+	BrandID      *int64     `json:"brand_id,omitempty"`
+	MinCartValue float64    `json:"min_cart_value"`
+	UsageLimit   int64      `json:"usage_limit"`
+	PerUserLimit int64      `json:"per_user_limit"`
+	UsedCount    int64      `json:"used_count"`
+	StartsAt     *time.Time `json:"starts_at,omitempty"`
+	EndsAt       *time.Time `json:"ends_at,omitempty"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (args *CreateCouponRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *CreateCouponRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *UpdateCouponRequest) Parse(r *http.Request) error {
+	couponID, err := parseCouponIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+
+	// the id in the URL wins over one in the body
+	args.CouponID = couponID
+	return nil
+}
+
+func (args *UpdateCouponRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	if args.Description == nil && args.Value == nil && args.MaxDiscount == nil && args.BuyQuantity == nil &&
+		args.GetQuantity == nil && args.CategoryID == nil && args.BrandID == nil && args.MinCartValue == nil &&
+		args.UsageLimit == nil && args.PerUserLimit == nil && args.StartsAt == nil && args.EndsAt == nil && args.Active == nil {
+		return errors.New("at least one field has to be updated")
+	}
+	return nil
+}
+
+func (args *CouponIDRequest) Parse(r *http.Request) error {
+	couponID, err := parseCouponIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.CouponID = couponID
+	return nil
+}
+
+func (args *CouponIDRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ListCouponsRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	if active := query.Get("active"); active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return fmt.Errorf("invalid active: %v", err)
+		}
+		args.Active = &value
+	}
+	args.Code = query.Get("code")
+	return nil
+}
+
+func (args *ListCouponsRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ApplyCouponRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ApplyCouponRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseCouponIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "couponid")
+	if strID == "" {
+		return 0, fmt.Errorf("couponid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coupon id: %v", err)
+	}
+	return int64(intID), nil
+}
@@ -0,0 +1,200 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// StockAdjustmentRequest posts a manual stock movement. Receipts and returns add stock,
+// adjustments can go either way
+type StockAdjustmentRequest struct {
+	BrandID  int64  `json:"brand_id"`
+	Type     string `json:"type" validate:"required,oneof=receipt return adjustment"`
+	Quantity int64  `json:"quantity" validate:"required"`
+	Reason   string `json:"reason" validate:"required,max=500"`
+}
+
+type StockHistoryRequest struct {
+	Pagination
+	BrandID int64 `json:"brand_id"`
+}
+
+// ReconcileStockRequest with apply sets the stock counts to the ledger, otherwise only reports
+type ReconcileStockRequest struct {
+	Apply bool `json:"apply"`
+}
+
+// Stock levels of the low stock listing
+const (
+	StockStatusLow = "low"
+	StockStatusOut = "out"
+)
+
+// LowStockRequest lists the brands at or below their reorder threshold, status narrows it
+// to the brands still in stock (low) or sold out (out)
+type LowStockRequest struct {
+	Pagination
+	Status     string `json:"status" validate:"omitempty,oneof=low out"`
+	CategoryID int64  `json:"category_id" validate:"omitempty,gt=0"`
+}
+
+type LowStockResponse struct {
+	BrandID          int64  `json:"brand_id"`
+	BrandName        string `json:"brand_name"`
+	Model            string `json:"model"`
+	CategoryID       int64  `json:"category_id"`
+	CategoryName     string `json:"category_name"`
+	StockCount       int64  `json:"stock_count"`
+	ReorderThreshold int64  `json:"reorder_threshold"`
+	Status           string `json:"status"`
+}
+
+// LowStockEvent is the payload of the low stock notification
+type LowStockEvent struct {
+	BrandID          int64  `json:"brand_id"`
+	BrandName        string `json:"brand_name"`
+	Model            string `json:"model"`
+	PreviousStock    int64  `json:"previous_stock"`
+	StockCount       int64  `json:"stock_count"`
+	ReorderThreshold int64  `json:"reorder_threshold"`
+	Status           string `json:"status"`
+	OrderID          int64  `json:"order_id,omitempty"`
+}
+
+type InventoryMovementResponse struct {
+	ID            int64     `json:"id"`
+	BrandID       int64     `json:"brand_id"`
+	Type          string    `json:"type"`
+	Quantity      int64     `json:"quantity"`
+	BalanceAfter  int64     `json:"balance_after"`
+	Reason        string    `json:"reason,omitempty"`
+	ReferenceType string    `json:"reference_type,omitempty"`
+	ReferenceID   int64     `json:"reference_id,omitempty"`
+	ActorID       int64     `json:"actor_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type StockDiscrepancyResponse struct {
+	BrandID     int64  `json:"brand_id"`
+	BrandName   string `json:"brand_name"`
+	StockCount  int64  `json:"stock_count"`
+	LedgerStock int64  `json:"ledger_stock"`
+	Reconciled  bool   `json:"reconciled"`
+}
+
+func (args *StockAdjustmentRequest) Parse(r *http.Request) error {
+	brandID, err := parseBrandIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.BrandID = brandID
+
+	return nil
+}
+
+func (args *StockAdjustmentRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	if args.Type != "adjustment" && args.Quantity < 0 {
+		return errors.New("receipts and returns need a positive quantity, use an adjustment to take stock out")
+	}
+	return nil
+}
+
+func (args *StockHistoryRequest) Parse(r *http.Request) error {
+	brandID, err := parseBrandIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.BrandID = brandID
+
+	return args.Pagination.Parse(r)
+}
+
+func (args *StockHistoryRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ReconcileStockRequest) Parse(r *http.Request) error {
+	apply := r.URL.Query().Get("apply")
+	if apply == "" {
+		return nil
+	}
+	value, err := strconv.ParseBool(apply)
+	if err != nil {
+		return fmt.Errorf("invalid apply: %v", err)
+	}
+	args.Apply = value
+	return nil
+}
+
+func (args *LowStockRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	args.Status = query.Get("status")
+	if categoryID := query.Get("category_id"); categoryID != "" {
+		intID, err := strconv.Atoi(categoryID)
+		if err != nil {
+			return fmt.Errorf("invalid category_id: %v", err)
+		}
+		args.CategoryID = int64(intID)
+	}
+	return nil
+}
+
+func (args *LowStockRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// StockStatus names the stock level of a count against its reorder threshold, empty when it is fine
+func StockStatus(stockCount, reorderThreshold int64) string {
+	switch {
+	case stockCount <= 0:
+		return StockStatusOut
+	case stockCount <= reorderThreshold:
+		return StockStatusLow
+	}
+	return ""
+}
+
+func parseBrandIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "brandid")
+	if strID == "" {
+		return 0, fmt.Errorf("brandid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid brand id: %v", err)
+	}
+	return int64(intID), nil
+}
@@ -0,0 +1,50 @@
+package dto
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+// Invoice formats
+const (
+	InvoiceFormatPDF  = "pdf"
+	InvoiceFormatHTML = "html"
+)
+
+// InvoiceRequest reads the order id of the URL and the format query param, pdf when not given
+type InvoiceRequest struct {
+	OrderID int64  `json:"order_id" validate:"required,gt=0"`
+	Format  string `json:"format" validate:"required,oneof=pdf html"`
+}
+
+// InvoiceFile is a rendered invoice, sent as is instead of the json envelope
+type InvoiceFile struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+func (args *InvoiceRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.OrderID = orderID
+
+	args.Format = strings.ToLower(r.URL.Query().Get("format"))
+	if args.Format == "" {
+		args.Format = InvoiceFormatPDF
+	}
+	return nil
+}
+
+func (args *InvoiceRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,7 +1,50 @@
 package dto
 
+import (
+	"net/http"
+
+	"github.com/go-playground/validator"
+)
+
 type CatagoryListResponse struct {
 	CatagoryID   int64  `json:"catagoryid"`
 	CatagoryName string `json:"catagoryname"`
 	Description  string `json:"description"`
+	IsDeleted    bool   `json:"is_deleted"`
+	TaxClassID   *int64 `json:"tax_class_id,omitempty"`
+}
+
+// ListCategoriesRequest is read from the query params of the category listing
+type ListCategoriesRequest struct {
+	Pagination
+	Sort  string `json:"sort" validate:"omitempty,oneof=name created_at"`
+	Order string `json:"order" validate:"omitempty,oneof=asc desc"`
+	// IncludeDeleted lists deleted categories too, admins only
+	IncludeDeleted bool `json:"include_deleted"`
+}
+
+func (args *ListCategoriesRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	args.Sort = query.Get("sort")
+	args.Order = query.Get("order")
+
+	args.IncludeDeleted, err = parseIncludeDeleted(r)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ListCategoriesRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
 }
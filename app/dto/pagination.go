@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Pagination is the offset based paging read from the page and page_size query params
+type Pagination struct {
+	Page     int `json:"page" validate:"gte=1"`
+	PageSize int `json:"page_size" validate:"gte=1,lte=100"`
+}
+
+// PageMeta is sent in the meta of list responses
+type PageMeta struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+func (p *Pagination) Parse(r *http.Request) error {
+	query := r.URL.Query()
+
+	p.Page = 1
+	p.PageSize = DefaultPageSize
+
+	if page := query.Get("page"); page != "" {
+		intPage, err := strconv.Atoi(page)
+		if err != nil {
+			return fmt.Errorf("invalid page: %v", err)
+		}
+		p.Page = intPage
+	}
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		intPageSize, err := strconv.Atoi(pageSize)
+		if err != nil {
+			return fmt.Errorf("invalid page_size: %v", err)
+		}
+		p.PageSize = intPageSize
+	}
+	return nil
+}
+
+// Offset is the number of rows to skip for the page
+func (p *Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// NewPageMeta builds the paging details for a page out of total rows
+func NewPageMeta(p Pagination, total int64) *PageMeta {
+	pageSize := int64(p.PageSize)
+	return &PageMeta{
+		Page:       p.Page,
+		PageSize:   p.PageSize,
+		Total:      total,
+		TotalPages: (total + pageSize - 1) / pageSize,
+	}
+}
+
+// parseIncludeDeleted reads the include_deleted query param
+func parseIncludeDeleted(r *http.Request) (bool, error) {
+	includeDeleted := r.URL.Query().Get("include_deleted")
+	if includeDeleted == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(includeDeleted)
+	if err != nil {
+		return false, fmt.Errorf("invalid include_deleted: %v", err)
+	}
+	return value, nil
+}
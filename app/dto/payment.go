@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxWebhookBody bounds the size of a payment webhook body
+const maxWebhookBody = 1 << 20
+
+type CreatePaymentRequest struct {
+	OrderID int64 `json:"order_id"`
+}
+
+// PaymentWebhookRequest is the raw body of a provider webhook with its signature, the body
+// is kept as it is since the signature is over the exact bytes
+type PaymentWebhookRequest struct {
+	Payload   []byte
+	Signature string
+}
+
+type PaymentResponse struct {
+	PaymentID     int64      `json:"payment_id"`
+	OrderID       int64      `json:"order_id"`
+	Provider      string     `json:"provider"`
+	IntentID      string     `json:"intent_id"`
+	ClientSecret  string     `json:"client_secret,omitempty"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Status        string     `json:"status"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	CapturedAt    *time.Time `json:"captured_at,omitempty"`
+}
+
+func (args *CreatePaymentRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.OrderID = orderID
+	return nil
+}
+
+func (args *PaymentWebhookRequest) Parse(r *http.Request, signatureHeader string) error {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxWebhookBody {
+		return errors.New("webhook body is too large")
+	}
+	args.Payload = payload
+	args.Signature = r.Header.Get(signatureHeader)
+	return nil
+}
+
+func (args *PaymentWebhookRequest) Validate() error {
+	if len(args.Payload) == 0 {
+		return errors.New("webhook body is empty")
+	}
+	if args.Signature == "" {
+		return errors.New("webhook signature is missing")
+	}
+	return nil
+}
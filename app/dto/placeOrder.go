@@ -2,14 +2,22 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator"
 )
 
+// PlaceOrderFromCart checks out every open line of the cart, or only the lines of ProductIDs when given.
+// ConfirmedTotal is the total the client accepted after being told the prices changed.
+// The default addresses of the address book are used when no address is picked
 type PlaceOrderFromCart struct {
-	//UserID int64 `json:"userid"`
-	CartID int64 `json:""cartid"`
+	ProductIDs        []int64  `json:"product_ids" validate:"omitempty,unique,dive,gt=0"`
+	ConfirmedTotal    *float64 `json:"confirmed_total" validate:"omitempty,gte=0"`
+	ShippingAddressID *int64   `json:"shipping_address_id" validate:"omitempty,gt=0"`
+	BillingAddressID  *int64   `json:"billing_address_id" validate:"omitempty,gt=0"`
 }
 
 // type ItemOrderedResponse struct {
@@ -35,24 +43,46 @@ type UserDetailsResponse struct {
 }
 
 type OrderItemResponse struct {
-	ProductID  int64   `json:"product_id"`
-	Quantity   int64   `json:"quantity"`
-	CategoryID int64   `json:"category_id"`
-	BrandName  string  `json:"brand_name"`
-	Price      float64 `json:"price"`
+	OrderItemID      int64   `json:"order_item_id"`
+	ProductID        int64   `json:"product_id"`
+	Quantity         int64   `json:"quantity"`
+	RefundedQuantity int64   `json:"refunded_quantity"`
+	CategoryID       int64   `json:"category_id"`
+	BrandName        string  `json:"brand_name"`
+	Price            float64 `json:"price"`
+	Discount         float64 `json:"discount"`
+	TaxRate          float64 `json:"tax_rate"` // percent
+	TaxAmount        float64 `json:"tax_amount"`
+	TaxInclusive     bool    `json:"tax_inclusive"` // the tax is part of the price
 }
 
 type ItemOrderedResponse struct {
-	OrderID     int64               `json:"order_id"`
-	TotalPrice  float64             `json:"total_price"`
-	UserDetails UserDetailsResponse `json:"user_details"`
-	Items       []OrderItemResponse `json:"items"`
+	OrderID        int64   `json:"order_id"`
+	Status         string  `json:"status"`
+	Subtotal       float64 `json:"subtotal"` // the items at their price
+	Discount       float64 `json:"discount"`
+	Tax            float64 `json:"tax"`         // all tax of the items, also the part included in their price
+	TotalPrice     float64 `json:"total_price"` // subtotal less the discount with the added tax and shipping
+	CouponCode     string  `json:"coupon_code,omitempty"`
+	FreeShipping   bool    `json:"free_shipping"`
+	ShippingCharge float64 `json:"shipping_charge"`
+	// latest day the order is expected, nil when the zone gives no estimate
+	EstimatedDeliveryAt *time.Time          `json:"estimated_delivery_at,omitempty"`
+	RefundedAmount      float64             `json:"refunded_amount"`
+	NetTotal            float64             `json:"net_total"` // total price less the refunds
+	UserDetails         UserDetailsResponse `json:"user_details"`
+	// nil for orders placed before addresses were kept on the order
+	ShippingAddress *OrderAddressResponse `json:"shipping_address,omitempty"`
+	BillingAddress  *OrderAddressResponse `json:"billing_address,omitempty"`
+	Items           []OrderItemResponse   `json:"items"`
+	Refunds         []RefundResponse      `json:"refunds,omitempty"`
 }
 
 func (args *PlaceOrderFromCart) Parse(r *http.Request) error {
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&args)
-	if err != nil {
+	// body is optional, without it the whole cart is ordered
+	if err != nil && !errors.Is(err, io.EOF) {
 		return err
 	}
 	return nil
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator"
+)
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest carries the optional refresh token of the session being logged out
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (args *RefreshTokenRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *RefreshTokenRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *LogoutRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	// body is optional for logout
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// CancelOrderRequest cancels an order of the customer before it is shipped
+type CancelOrderRequest struct {
+	OrderID int64  `json:"order_id"`
+	Reason  string `json:"reason" validate:"max=500"`
+}
+
+// RefundOrderRequest refunds items of an order, without items everything not refunded yet is refunded
+type RefundOrderRequest struct {
+	OrderID int64               `json:"order_id"`
+	Items   []RefundItemRequest `json:"items" validate:"dive"`
+	Reason  string              `json:"reason" validate:"required,max=500"`
+}
+
+type RefundItemRequest struct {
+	OrderItemID int64 `json:"order_item_id" validate:"required,gt=0"`
+	Quantity    int64 `json:"quantity" validate:"required,gt=0"`
+}
+
+type RefundItemResponse struct {
+	OrderItemID int64   `json:"order_item_id"`
+	Quantity    int64   `json:"quantity"`
+	Amount      float64 `json:"amount"`
+}
+
+type RefundResponse struct {
+	RefundID         int64                `json:"refund_id"`
+	PaymentID        int64                `json:"payment_id"`
+	ProviderRefundID string               `json:"provider_refund_id,omitempty"`
+	Amount           float64              `json:"amount"`
+	Reason           string               `json:"reason,omitempty"`
+	Status           string               `json:"status"`
+	ActorRole        string               `json:"actor_role"`
+	Items            []RefundItemResponse `json:"items"`
+	CreatedAt        time.Time            `json:"created_at"`
+}
+
+// OrderRefundResponse is the order after a cancellation or refund, Refund is empty when nothing was paid
+type OrderRefundResponse struct {
+	OrderID        int64           `json:"order_id"`
+	Status         string          `json:"status"`
+	TotalPrice     float64         `json:"total_price"`
+	RefundedAmount float64         `json:"refunded_amount"`
+	NetTotal       float64         `json:"net_total"`
+	Refund         *RefundResponse `json:"refund,omitempty"`
+}
+
+func (args *CancelOrderRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	// the reason is optional, so is the body
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	args.OrderID = orderID
+
+	return nil
+}
+
+func (args *CancelOrderRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *RefundOrderRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.OrderID = orderID
+
+	return nil
+}
+
+func (args *RefundOrderRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int64]bool, len(args.Items))
+	for _, item := range args.Items {
+		if seen[item.OrderItemID] {
+			return fmt.Errorf("order item %d is listed more than once", item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+	}
+	return nil
+}
+
+func parseOrderIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "id")
+	if strID == "" {
+		return 0, fmt.Errorf("id parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid order id: %v", err)
+	}
+	return int64(intID), nil
+}
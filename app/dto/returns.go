@@ -0,0 +1,163 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// CreateReturnRequest asks to send back units of a delivered order item
+type CreateReturnRequest struct {
+	OrderID     int64  `json:"order_id"`
+	OrderItemID int64  `json:"order_item_id" validate:"required,gt=0"`
+	Quantity    int64  `json:"quantity" validate:"required,gt=0"`
+	Reason      string `json:"reason" validate:"required,max=500"`
+}
+
+// ReturnActionRequest moves a return on, the note is kept on the return
+type ReturnActionRequest struct {
+	ReturnID int64  `json:"return_id"`
+	Note     string `json:"note" validate:"max=500"`
+}
+
+// RejectReturnRequest rejects a return, the customer is told why
+type RejectReturnRequest struct {
+	ReturnID int64  `json:"return_id"`
+	Note     string `json:"note" validate:"required,max=500"`
+}
+
+type ListReturnsRequest struct {
+	Pagination
+	Status string `json:"status" validate:"omitempty,oneof=requested approved rejected received refunded"`
+	// UserID limits the list to the returns of a customer, set from the token and not the request
+	UserID int64 `json:"-"`
+}
+
+type ReturnResponse struct {
+	ReturnID    int64      `json:"return_id"`
+	OrderID     int64      `json:"order_id"`
+	OrderItemID int64      `json:"order_item_id"`
+	ProductID   int64      `json:"product_id"`
+	BrandName   string     `json:"brand_name"`
+	Quantity    int64      `json:"quantity"`
+	Reason      string     `json:"reason"`
+	Status      string     `json:"status"`
+	AdminNote   string     `json:"admin_note,omitempty"`
+	RefundID    *int64     `json:"refund_id,omitempty"`
+	ReceivedAt  *time.Time `json:"received_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (args *CreateReturnRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.OrderID = orderID
+
+	return nil
+}
+
+func (args *CreateReturnRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ReturnActionRequest) Parse(r *http.Request) error {
+	returnID, err := parseReturnIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	// the note is optional, so is the body
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	args.ReturnID = returnID
+
+	return nil
+}
+
+func (args *ReturnActionRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *RejectReturnRequest) Parse(r *http.Request) error {
+	returnID, err := parseReturnIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.ReturnID = returnID
+
+	return nil
+}
+
+func (args *RejectReturnRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ListReturnsRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+	args.Status = r.URL.Query().Get("status")
+	return nil
+}
+
+func (args *ListReturnsRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseReturnIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "returnid")
+	if strID == "" {
+		return 0, fmt.Errorf("returnid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid return id: %v", err)
+	}
+	return int64(intID), nil
+}
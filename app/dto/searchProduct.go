@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+// SearchProductRequest is read from the q query param along with the paging
+type SearchProductRequest struct {
+	Pagination
+	Query string `json:"q" validate:"required,max=100"`
+}
+
+func (args *SearchProductRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+	args.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+	return nil
+}
+
+func (args *SearchProductRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
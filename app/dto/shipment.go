@@ -0,0 +1,143 @@
+package dto
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// CreateShipmentRequest books a parcel with the carrier for items of an order, without items
+// everything not shipped yet goes in it
+type CreateShipmentRequest struct {
+	OrderID int64                 `json:"order_id"`
+	Items   []ShipmentItemRequest `json:"items" validate:"dive"`
+}
+
+type ShipmentItemRequest struct {
+	OrderItemID int64 `json:"order_item_id" validate:"required,gt=0"`
+	Quantity    int64 `json:"quantity" validate:"required,gt=0"`
+}
+
+type OrderShipmentsRequest struct {
+	OrderID int64 `json:"order_id" validate:"required,gt=0"`
+}
+
+type ShipmentIDRequest struct {
+	ShipmentID int64 `json:"shipment_id" validate:"required,gt=0"`
+}
+
+type ShipmentItemResponse struct {
+	OrderItemID int64  `json:"order_item_id"`
+	ProductID   int64  `json:"product_id"`
+	BrandName   string `json:"brand_name"`
+	Quantity    int64  `json:"quantity"`
+}
+
+type TrackingEventResponse struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description,omitempty"`
+	Location    string    `json:"location,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+type ShipmentResponse struct {
+	ShipmentID     int64                   `json:"shipment_id"`
+	OrderID        int64                   `json:"order_id"`
+	Carrier        string                  `json:"carrier"`
+	TrackingNumber string                  `json:"tracking_number"`
+	LabelURL       string                  `json:"label_url,omitempty"`
+	Status         string                  `json:"status"`
+	Items          []ShipmentItemResponse  `json:"items"`
+	Events         []TrackingEventResponse `json:"events"` // the timeline, oldest first
+	DeliveredAt    *time.Time              `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
+}
+
+// OrderTrackingResponse is where the parcels of an order are
+type OrderTrackingResponse struct {
+	OrderID             int64              `json:"order_id"`
+	Status              string             `json:"status"`
+	EstimatedDeliveryAt *time.Time         `json:"estimated_delivery_at,omitempty"`
+	Shipments           []ShipmentResponse `json:"shipments"`
+}
+
+func (args *CreateShipmentRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	// the items are optional, so is the body
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	args.OrderID = orderID
+
+	return nil
+}
+
+func (args *CreateShipmentRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int64]bool, len(args.Items))
+	for _, item := range args.Items {
+		if seen[item.OrderItemID] {
+			return fmt.Errorf("order item %d is listed more than once", item.OrderItemID)
+		}
+		seen[item.OrderItemID] = true
+	}
+	return nil
+}
+
+func (args *OrderShipmentsRequest) Parse(r *http.Request) error {
+	orderID, err := parseOrderIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.OrderID = orderID
+	return nil
+}
+
+func (args *OrderShipmentsRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ShipmentIDRequest) Parse(r *http.Request) error {
+	strID := chi.URLParam(r, "shipmentid")
+	if strID == "" {
+		return fmt.Errorf("shipmentid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return fmt.Errorf("invalid shipment id: %v", err)
+	}
+	args.ShipmentID = int64(intID)
+	return nil
+}
+
+func (args *ShipmentIDRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
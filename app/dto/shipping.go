@@ -0,0 +1,200 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// SaveShippingZoneRequest creates a shipping zone or replaces all settings of one. A zone covers a pincode range,
+// pincode prefixes or both. Tiers are needed by the weight (min_value in grams) and value rate types
+type SaveShippingZoneRequest struct {
+	ZoneID          int64                     `json:"zone_id"`
+	Name            string                    `json:"name" validate:"required,max=100"`
+	PincodeFrom     int64                     `json:"pincode_from" validate:"gte=0"`
+	PincodeTo       int64                     `json:"pincode_to" validate:"gte=0"`
+	PincodePrefixes []string                  `json:"pincode_prefixes" validate:"omitempty,unique,dive,numeric,max=6"`
+	RateType        string                    `json:"rate_type" validate:"required,oneof=flat weight value"`
+	BaseRate        float64                   `json:"base_rate" validate:"gte=0"`
+	FreeAbove       float64                   `json:"free_above" validate:"gte=0"`
+	MinDays         int                       `json:"min_days" validate:"gte=0"`
+	MaxDays         int                       `json:"max_days" validate:"gte=0"`
+	Active          *bool                     `json:"active"`
+	Tiers           []ShippingRateTierRequest `json:"tiers" validate:"omitempty,dive"`
+}
+
+type ShippingRateTierRequest struct {
+	MinValue float64 `json:"min_value" validate:"gte=0"`
+	Rate     float64 `json:"rate" validate:"gte=0"`
+}
+
+// ShippingZoneIDRequest reads the zone id of the URL
+type ShippingZoneIDRequest struct {
+	ZoneID int64 `json:"zone_id" validate:"required,gt=0"`
+}
+
+type ListShippingZonesRequest struct {
+	Pagination
+	Active *bool `json:"active"`
+}
+
+// ShippingCheckRequest asks if a pincode is served, the order value and weight price the shipping
+type ShippingCheckRequest struct {
+	Pincode     int64   `json:"pincode" validate:"required,gt=0"`
+	OrderValue  float64 `json:"order_value" validate:"gte=0"`
+	WeightGrams int64   `json:"weight_grams" validate:"gte=0"`
+}
+
+type ShippingZoneResponse struct {
+	ZoneID          int64                      `json:"zone_id"`
+	Name            string                     `json:"name"`
+	PincodeFrom     int64                      `json:"pincode_from,omitempty"`
+	PincodeTo       int64                      `json:"pincode_to,omitempty"`
+	PincodePrefixes []string                   `json:"pincode_prefixes,omitempty"`
+	RateType        string                     `json:"rate_type"`
+	BaseRate        float64                    `json:"base_rate"`
+	FreeAbove       float64                    `json:"free_above"`
+	MinDays         int                        `json:"min_days"`
+	MaxDays         int                        `json:"max_days"`
+	Active          bool                       `json:"active"`
+	Tiers           []ShippingRateTierResponse `json:"tiers,omitempty"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	UpdatedAt       time.Time                  `json:"updated_at"`
+}
+
+type ShippingRateTierResponse struct {
+	MinValue float64 `json:"min_value"`
+	Rate     float64 `json:"rate"`
+}
+
+// ShippingQuoteResponse is the shipping of an order to a pincode, Reason tells why it is not served
+type ShippingQuoteResponse struct {
+	Pincode               int64      `json:"pincode"`
+	Serviceable           bool       `json:"serviceable"`
+	Reason                string     `json:"reason,omitempty"`
+	Zone                  string     `json:"zone,omitempty"`
+	Charge                float64    `json:"charge"`
+	Free                  bool       `json:"free"`
+	EstimatedDeliveryFrom *time.Time `json:"estimated_delivery_from,omitempty"`
+	EstimatedDeliveryTo   *time.Time `json:"estimated_delivery_to,omitempty"`
+}
+
+func (args *SaveShippingZoneRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+
+	// the zone is replaced when the URL names one
+	if chi.URLParam(r, "zoneid") != "" {
+		zoneID, err := parseZoneIDParam(r)
+		if err != nil {
+			return err
+		}
+		args.ZoneID = zoneID
+	}
+	return nil
+}
+
+func (args *SaveShippingZoneRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ShippingZoneIDRequest) Parse(r *http.Request) error {
+	zoneID, err := parseZoneIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.ZoneID = zoneID
+	return nil
+}
+
+func (args *ShippingZoneIDRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ListShippingZonesRequest) Parse(r *http.Request) error {
+	err := args.Pagination.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	if active := r.URL.Query().Get("active"); active != "" {
+		value, err := strconv.ParseBool(active)
+		if err != nil {
+			return fmt.Errorf("invalid active: %v", err)
+		}
+		args.Active = &value
+	}
+	return nil
+}
+
+func (args *ListShippingZonesRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *ShippingCheckRequest) Parse(r *http.Request) error {
+	query := r.URL.Query()
+
+	pincode, err := strconv.ParseInt(query.Get("pincode"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid pincode: %v", err)
+	}
+	args.Pincode = pincode
+
+	if value := query.Get("order_value"); value != "" {
+		args.OrderValue, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid order_value: %v", err)
+		}
+	}
+	if weight := query.Get("weight_grams"); weight != "" {
+		args.WeightGrams, err = strconv.ParseInt(weight, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid weight_grams: %v", err)
+		}
+	}
+	return nil
+}
+
+func (args *ShippingCheckRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseZoneIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "zoneid")
+	if strID == "" {
+		return 0, fmt.Errorf("zoneid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid zone id: %v", err)
+	}
+	return int64(intID), nil
+}
@@ -0,0 +1,105 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// SaveTaxClassRequest creates a tax class or replaces all settings of one. A rule without a state
+// applies to every state that has no rule of its own. With prices_include_tax the tax is taken out
+// of the price, otherwise it is added on top
+type SaveTaxClassRequest struct {
+	TaxClassID       int64            `json:"tax_class_id"`
+	Name             string           `json:"name" validate:"required,max=100"`
+	Description      string           `json:"description" validate:"max=500"`
+	PricesIncludeTax bool             `json:"prices_include_tax"`
+	Rules            []TaxRuleRequest `json:"rules" validate:"required,min=1,dive"`
+}
+
+type TaxRuleRequest struct {
+	State string  `json:"state" validate:"max=100"`
+	Rate  float64 `json:"rate" validate:"gte=0,lte=100"` // percent
+}
+
+// TaxClassIDRequest reads the tax class id of the URL
+type TaxClassIDRequest struct {
+	TaxClassID int64 `json:"tax_class_id" validate:"required,gt=0"`
+}
+
+type TaxClassResponse struct {
+	TaxClassID       int64             `json:"tax_class_id"`
+	Name             string            `json:"name"`
+	Description      string            `json:"description"`
+	PricesIncludeTax bool              `json:"prices_include_tax"`
+	Rules            []TaxRuleResponse `json:"rules"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+type TaxRuleResponse struct {
+	State string  `json:"state,omitempty"`
+	Rate  float64 `json:"rate"`
+}
+
+func (args *SaveTaxClassRequest) Parse(r *http.Request) error {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+
+	// the class is replaced when the URL names one
+	if chi.URLParam(r, "taxclassid") != "" {
+		taxClassID, err := parseTaxClassIDParam(r)
+		if err != nil {
+			return err
+		}
+		args.TaxClassID = taxClassID
+	}
+	return nil
+}
+
+func (args *SaveTaxClassRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *TaxClassIDRequest) Parse(r *http.Request) error {
+	taxClassID, err := parseTaxClassIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.TaxClassID = taxClassID
+	return nil
+}
+
+func (args *TaxClassIDRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseTaxClassIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "taxclassid")
+	if strID == "" {
+		return 0, fmt.Errorf("taxclassid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tax class id: %v", err)
+	}
+	return int64(intID), nil
+}
@@ -2,18 +2,31 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-playground/validator"
 )
 
+// UpdateBrand edits a brand, only the fields present in the body are changed
 type UpdateBrand struct {
-	BrandId   int64   `json:"brand_id"`
-	BrandName string  `json:"brand_name"`
-	Price     float64 `json:"price"`
+	BrandId          int64      `json:"brand_id"`
+	BrandName        *string    `json:"brand_name" validate:"omitempty,min=1,max=100"`
+	CategoryID       *int64     `json:"category_id" validate:"omitempty,gt=0"`
+	Price            *float64   `json:"price" validate:"omitempty,gt=0"`
+	StockCount       *int64     `json:"stock_count" validate:"omitempty,gte=0"`
+	BrandDescription *string    `json:"description" validate:"omitempty,max=2000"`
+	Model            *string    `json:"model" validate:"omitempty,min=1,max=100"`
+	ImageLink        *string    `json:"image_link" validate:"omitempty,url"`
+	GalleryLinks     *[]string  `json:"gallery_links" validate:"omitempty,dive,url"`
+	ReleaseDate      *time.Time `json:"release_date"`
+	ReorderThreshold *int64     `json:"reorder_threshold" validate:"omitempty,gte=0"`
+	WeightGrams      *int64     `json:"weight_grams" validate:"omitempty,gte=0"`
+	TaxClassID       *int64     `json:"tax_class_id" validate:"omitempty,gte=0"` // 0 falls back to the tax class of the category
 }
 
 func (args *UpdateBrand) Parse(r *http.Request) error {
@@ -29,13 +42,14 @@ func (args *UpdateBrand) Parse(r *http.Request) error {
 		return fmt.Errorf("invalid id: %v", err)
 	}
 
-	// Store the parsed ID into your struct if needed
-	args.BrandId = int64(intID)
 	decoder := json.NewDecoder(r.Body)
 	err = decoder.Decode(&args)
 	if err != nil {
 		return err
 	}
+
+	// the id in the URL wins over one in the body
+	args.BrandId = int64(intID)
 	return nil
 }
 
@@ -45,5 +59,13 @@ func (args *UpdateBrand) Validate() error {
 	if err != nil {
 		return err
 	}
+	if args.BrandName == nil && args.CategoryID == nil && args.Price == nil && args.StockCount == nil &&
+		args.BrandDescription == nil && args.Model == nil && args.ImageLink == nil && args.GalleryLinks == nil &&
+		args.ReleaseDate == nil && args.ReorderThreshold == nil && args.WeightGrams == nil && args.TaxClassID == nil {
+		return errors.New("at least one field has to be updated")
+	}
+	if args.ReleaseDate != nil && args.ReleaseDate.IsZero() {
+		return errors.New("release_date cannot be empty")
+	}
 	return nil
 }
@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+type UpdateCartItemRequest struct {
+	ProductID int64 `json:"productid"`
+	Quantity  int64 `json:"quantity" validate:"required,gt=0"`
+}
+
+type RemoveCartItemRequest struct {
+	ProductID int64 `json:"productid"`
+}
+
+func (args *UpdateCartItemRequest) Parse(r *http.Request) error {
+	productID, err := parseProductIDParam(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.ProductID = productID
+
+	return nil
+}
+
+func (args *UpdateCartItemRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (args *RemoveCartItemRequest) Parse(r *http.Request) error {
+	productID, err := parseProductIDParam(r)
+	if err != nil {
+		return err
+	}
+	args.ProductID = productID
+	return nil
+}
+
+func parseProductIDParam(r *http.Request) (int64, error) {
+	strID := chi.URLParam(r, "productid")
+	if strID == "" {
+		return 0, fmt.Errorf("productid parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid product id: %v", err)
+	}
+	return int64(intID), nil
+}
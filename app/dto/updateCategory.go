@@ -2,6 +2,7 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -10,9 +11,13 @@ import (
 	"github.com/go-playground/validator"
 )
 
+// UpdateCategory edits a category, only the fields present in the body are changed.
+// A tax_class_id of 0 removes the tax class
 type UpdateCategory struct {
-	CategoryID   int64  `json:"category_id"`
-	CategoryName string `json:"categoryname"`
+	CategoryID   int64   `json:"category_id"`
+	CategoryName *string `json:"categoryname" validate:"omitempty,min=1,max=100"`
+	Description  *string `json:"description" validate:"omitempty,min=1,max=2000"`
+	TaxClassID   *int64  `json:"tax_class_id" validate:"omitempty,gte=0"`
 }
 
 func (args *UpdateCategory) Parse(r *http.Request) error {
@@ -28,13 +33,14 @@ func (args *UpdateCategory) Parse(r *http.Request) error {
 		return fmt.Errorf("invalid id: %v", err)
 	}
 
-	// Store the parsed ID into your struct if needed
-	args.CategoryID = int64(intID)
 	decoder := json.NewDecoder(r.Body)
 	err = decoder.Decode(&args)
 	if err != nil {
 		return err
 	}
+
+	// the id in the URL wins over one in the body
+	args.CategoryID = int64(intID)
 	return nil
 }
 
@@ -44,5 +50,8 @@ func (args *UpdateCategory) Validate() error {
 	if err != nil {
 		return err
 	}
+	if args.CategoryName == nil && args.Description == nil && args.TaxClassID == nil {
+		return errors.New("at least one field has to be updated")
+	}
 	return nil
 }
@@ -0,0 +1,62 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+type UpdateOrderStatusRequest struct {
+	OrderID int64  `json:"order_id"`
+	Status  string `json:"status" validate:"required,oneof=pending paid packed shipped delivered cancelled refunded"`
+	Note    string `json:"note"`
+}
+
+type OrderStatusHistoryResponse struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    int64     `json:"actor_id"`
+	ActorRole  string    `json:"actor_role"`
+	Note       string    `json:"note"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type OrderStatusResponse struct {
+	OrderID int64                        `json:"order_id"`
+	Status  string                       `json:"status"`
+	History []OrderStatusHistoryResponse `json:"history"`
+}
+
+func (args *UpdateOrderStatusRequest) Parse(r *http.Request) error {
+	strID := chi.URLParam(r, "id")
+	if strID == "" {
+		return fmt.Errorf("id parameter is missing or empty")
+	}
+	intID, err := strconv.Atoi(strID)
+	if err != nil {
+		return fmt.Errorf("invalid order id: %v", err)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&args)
+	if err != nil {
+		return err
+	}
+	args.OrderID = int64(intID)
+
+	return nil
+}
+
+func (args *UpdateOrderStatusRequest) Validate() error {
+	validate := validator.New()
+	err := validate.Struct(args)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,9 +1,42 @@
 package dto
 
 type ViewCart struct {
-	ProductID   int64   `json:"product_id"`
-	Quantity    int64   `json:"quantity"`
-	Price       float64 `json:"price"`
-	BrandName   string  `json:"brandname"`
-	TotalAmount float64 `json:"totalamount"`
+	ProductID      int64   `json:"product_id"`
+	Quantity       int64   `json:"quantity"`
+	Price          float64 `json:"price"`
+	CurrentPrice   float64 `json:"current_price"`
+	PriceChanged   bool    `json:"price_changed"`
+	AvailableStock int64   `json:"available_stock"`
+	StockChanged   bool    `json:"stock_changed"`
+	Available      bool    `json:"available"`
+	BrandName      string  `json:"brandname"`
+	TotalAmount    float64 `json:"totalamount"`
+	Discount       float64 `json:"discount"` // share of the coupon discount
+	TaxRate        float64 `json:"tax_rate"`
+	TaxAmount      float64 `json:"tax_amount"`
+}
+
+// ViewCartResponse is the cart with the discount of the applied coupon and the shipping
+// to the default shipping address
+type ViewCartResponse struct {
+	Items          []*ViewCart            `json:"items"`
+	Subtotal       float64                `json:"subtotal"`
+	Coupon         *CartCouponResponse    `json:"coupon,omitempty"`
+	Discount       float64                `json:"discount"`
+	Tax            float64                `json:"tax"`            // added tax is part of the total, included tax is part of the prices
+	StateRequired  bool                   `json:"state_required"` // the tax depends on the state, an address with a state is needed to order
+	Shipping       *ShippingQuoteResponse `json:"shipping"`
+	ShippingCharge float64                `json:"shipping_charge"`
+	Total          float64                `json:"total"`
+}
+
+// CartCouponResponse is the coupon applied to the cart, Reason tells why it does not apply to the cart as it is
+type CartCouponResponse struct {
+	Code         string  `json:"code"`
+	Type         string  `json:"type"`
+	Description  string  `json:"description"`
+	Applicable   bool    `json:"applicable"`
+	Reason       string  `json:"reason,omitempty"`
+	Discount     float64 `json:"discount"`
+	FreeShipping bool    `json:"free_shipping"`
 }
@@ -27,12 +27,84 @@ func Automigration(db *gorm.DB) error {
 	if err := db.AutoMigrate(&internal.OrderItem{}); err != nil {
 		log.Fatalf("migration failed for order item : %v", err)
 	}
+	if err := db.AutoMigrate(&internal.OrderStatusHistory{}); err != nil {
+		log.Fatalf("migration failed for order status history : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Payment{}); err != nil {
+		log.Fatalf("migration failed for payment : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Refund{}); err != nil {
+		log.Fatalf("migration failed for refund : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.RefundItem{}); err != nil {
+		log.Fatalf("migration failed for refund item : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.ReturnRequest{}); err != nil {
+		log.Fatalf("migration failed for return request : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Coupon{}); err != nil {
+		log.Fatalf("migration failed for coupon : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.CartCoupon{}); err != nil {
+		log.Fatalf("migration failed for cart coupon : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.CouponRedemption{}); err != nil {
+		log.Fatalf("migration failed for coupon redemption : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Address{}); err != nil {
+		log.Fatalf("migration failed for address : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.ShippingZone{}); err != nil {
+		log.Fatalf("migration failed for shipping zone : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.ShippingRateTier{}); err != nil {
+		log.Fatalf("migration failed for shipping rate tier : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.TaxClass{}); err != nil {
+		log.Fatalf("migration failed for tax class : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.TaxRule{}); err != nil {
+		log.Fatalf("migration failed for tax rule : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Invoice{}); err != nil {
+		log.Fatalf("migration failed for invoice : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.InvoiceCounter{}); err != nil {
+		log.Fatalf("migration failed for invoice counter : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.Shipment{}); err != nil {
+		log.Fatalf("migration failed for shipment : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.ShipmentItem{}); err != nil {
+		log.Fatalf("migration failed for shipment item : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.ShipmentEvent{}); err != nil {
+		log.Fatalf("migration failed for shipment event : %v", err)
+	}
 	if err := db.AutoMigrate(&internal.UserFavoriteBrand{}); err != nil {
 		log.Fatalf("migration failed for favorite brand : %v", err)
 	}
 	if err := db.AutoMigrate(&internal.ActiveToken{}); err != nil {
 		log.Fatalf("migration failed for active token : %v", err)
 	}
+	if err := db.AutoMigrate(&internal.RefreshToken{}); err != nil {
+		log.Fatalf("migration failed for refresh token : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.InventoryMovement{}); err != nil {
+		log.Fatalf("migration failed for inventory movement : %v", err)
+	}
+	if err := db.AutoMigrate(&internal.StockReservation{}); err != nil {
+		log.Fatalf("migration failed for stock reservation : %v", err)
+	}
+	if err := internal.SeedOpeningBalances(db); err != nil {
+		log.Fatalf("failed to record opening stock balances : %v", err)
+	}
+	if err := internal.IssueMissingInvoices(db); err != nil {
+		log.Fatalf("failed to issue the invoices of paid orders : %v", err)
+	}
+	if err := internal.MigrateBrandSearch(db); err != nil {
+		log.Fatalf("migration failed for brand search index : %v", err)
+	}
 	log.Println("Migration success")
 	return nil
 }
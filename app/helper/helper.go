@@ -9,6 +9,8 @@ import (
 type ContextHelper interface {
 	GetUserID(ctx context.Context) (int64, error)
 	GetUsername(ctx context.Context) (string, error)
+	GetToken(ctx context.Context) (string, error)
+	IsAdmin(ctx context.Context) bool
 }
 
 type contextHelperImpl struct{}
@@ -32,3 +34,17 @@ func (h *contextHelperImpl) GetUsername(ctx context.Context) (string, error) {
 	}
 	return username, nil
 }
+
+func (h *contextHelperImpl) GetToken(ctx context.Context) (string, error) {
+	token, ok := ctx.Value(middleware.TokenKey).(string)
+	if !ok {
+		return "", errors.New("token not found in context")
+	}
+	return token, nil
+}
+
+// IsAdmin tells if the logged in user is an admin, false when the flag is missing
+func (h *contextHelperImpl) IsAdmin(ctx context.Context) bool {
+	isAdmin, ok := ctx.Value(middleware.IsAdminKey).(bool)
+	return ok && isAdmin
+}
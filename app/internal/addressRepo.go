@@ -0,0 +1,266 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Address is an entry of the address book of a user, a user has at most one default
+// shipping and one default billing address
+type Address struct {
+	ID                int64     `gorm:"primaryKey"`
+	UserID            int64     `gorm:"column:user_id;index;not null"`
+	Name              string    `gorm:"column:name;not null"`
+	Line1             string    `gorm:"column:line1;not null"`
+	Line2             string    `gorm:"column:line2"`
+	City              string    `gorm:"column:city;not null"`
+	State             string    `gorm:"column:state;not null"`
+	Pincode           int64     `gorm:"column:pincode;not null"`
+	Phonenumber       int64     `gorm:"column:phone_number;not null"`
+	IsDefaultShipping bool      `gorm:"column:is_default_shipping;not null;default:false"`
+	IsDefaultBilling  bool      `gorm:"column:is_default_billing;not null;default:false"`
+	CreatedAt         time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// AddressSnapshot is a copy of an address kept on an order, later edits of the address book do not change it
+type AddressSnapshot struct {
+	Name        string `gorm:"column:name"`
+	Line1       string `gorm:"column:line1"`
+	Line2       string `gorm:"column:line2"`
+	City        string `gorm:"column:city"`
+	State       string `gorm:"column:state"`
+	Pincode     int64  `gorm:"column:pincode"`
+	Phonenumber int64  `gorm:"column:phone_number"`
+}
+
+// Snapshot copies the address for an order
+func (a *Address) Snapshot() AddressSnapshot {
+	return AddressSnapshot{
+		Name:        a.Name,
+		Line1:       a.Line1,
+		Line2:       a.Line2,
+		City:        a.City,
+		State:       a.State,
+		Pincode:     a.Pincode,
+		Phonenumber: a.Phonenumber,
+	}
+}
+
+// IsEmpty tells if no address was kept, orders placed before the address book have none
+func (s AddressSnapshot) IsEmpty() bool {
+	return s.Line1 == ""
+}
+
+// ProfileAddress is the address of the user profile, used when the address book is empty. The profile has
+// no state, carts taxed by state need an address book entry to be ordered
+func (u *Userdetail) ProfileAddress() AddressSnapshot {
+	return AddressSnapshot{
+		Name:        u.Username,
+		Line1:       u.Address,
+		Pincode:     u.Pincode,
+		Phonenumber: u.Phonenumber,
+	}
+}
+
+type AddressRepo interface {
+	SaveAddress(userID int64, args *dto.SaveAddressRequest) (*Address, error)
+	ListAddresses(userID int64) ([]Address, error)
+	GetAddress(userID, addressID int64) (*Address, error)
+	UpdateAddress(userID int64, args *dto.UpdateAddressRequest) (*Address, error)
+	DeleteAddress(userID, addressID int64) error
+}
+
+type AddressRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewAddressRepo(db *gorm.DB) AddressRepo {
+	return &AddressRepoImpl{
+		db: db,
+	}
+}
+
+// SaveAddress adds an address to the book, the first address of a user is the default for both
+func (r *AddressRepoImpl) SaveAddress(userID int64, args *dto.SaveAddressRequest) (*Address, error) {
+	address := &Address{
+		UserID:            userID,
+		Name:              args.Name,
+		Line1:             args.Line1,
+		Line2:             args.Line2,
+		City:              args.City,
+		State:             args.State,
+		Pincode:           args.Pincode,
+		Phonenumber:       args.Phone,
+		IsDefaultShipping: args.IsDefaultShipping,
+		IsDefaultBilling:  args.IsDefaultBilling,
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Address{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			return err
+		}
+		return tx.Create(address).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (r *AddressRepoImpl) ListAddresses(userID int64) ([]Address, error) {
+	var addresses []Address
+	err := r.db.Where("user_id = ?", userID).
+		Order("is_default_shipping DESC, created_at DESC, id DESC").
+		Find(&addresses).Error
+	if err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// GetAddress returns an address of the user, addresses of other users are not found
+func (r *AddressRepoImpl) GetAddress(userID, addressID int64) (*Address, error) {
+	return getUserAddress(r.db, userID, addressID)
+}
+
+// UpdateAddress changes the fields present in args, making it a default takes the flag off the previous default
+func (r *AddressRepoImpl) UpdateAddress(userID int64, args *dto.UpdateAddressRequest) (*Address, error) {
+	var address *Address
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		address, err = getUserAddress(tx, userID, args.AddressID)
+		if err != nil {
+			return err
+		}
+
+		if args.Name != nil {
+			address.Name = *args.Name
+		}
+		if args.Line1 != nil {
+			address.Line1 = *args.Line1
+		}
+		if args.Line2 != nil {
+			address.Line2 = *args.Line2
+		}
+		if args.City != nil {
+			address.City = *args.City
+		}
+		if args.State != nil {
+			address.State = *args.State
+		}
+		if args.Pincode != nil {
+			address.Pincode = *args.Pincode
+		}
+		if args.Phone != nil {
+			address.Phonenumber = *args.Phone
+		}
+		if args.IsDefaultShipping != nil {
+			address.IsDefaultShipping = *args.IsDefaultShipping
+		}
+		if args.IsDefaultBilling != nil {
+			address.IsDefaultBilling = *args.IsDefaultBilling
+		}
+
+		if err := clearDefaultAddresses(tx, address); err != nil {
+			return err
+		}
+		return tx.Select("*").Omit("id", "user_id", "created_at").Updates(address).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// DeleteAddress removes an address, the newest of the other addresses takes over the defaults it had.
+// Orders keep their own copy of the address
+func (r *AddressRepoImpl) DeleteAddress(userID, addressID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		address, err := getUserAddress(tx, userID, addressID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefaultShipping && !address.IsDefaultBilling {
+			return nil
+		}
+
+		var next Address
+		err = tx.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(1).Find(&next).Error
+		if err != nil || next.ID == 0 {
+			return err
+		}
+		updates := map[string]interface{}{}
+		if address.IsDefaultShipping {
+			updates["is_default_shipping"] = true
+		}
+		if address.IsDefaultBilling {
+			updates["is_default_billing"] = true
+		}
+		return tx.Model(&next).Updates(updates).Error
+	})
+}
+
+// clearDefaultAddresses takes the default flags the address has off the other addresses of the user
+func clearDefaultAddresses(tx *gorm.DB, address *Address) error {
+	query := tx.Model(&Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID)
+	if address.IsDefaultShipping {
+		if err := query.Session(&gorm.Session{}).Update("is_default_shipping", false).Error; err != nil {
+			return err
+		}
+	}
+	if address.IsDefaultBilling {
+		if err := query.Session(&gorm.Session{}).Update("is_default_billing", false).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getUserAddress(db *gorm.DB, userID, addressID int64) (*Address, error) {
+	var address Address
+	if err := db.Where("id = ? AND user_id = ?", addressID, userID).First(&address).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetUserAddress returns an address of the user for checkout
+func (r *UserRepoImpl) GetUserAddress(userID, addressID int64) (*Address, error) {
+	return getUserAddress(r.db, userID, addressID)
+}
+
+// GetDefaultAddresses returns the default shipping and billing address of the user, nil when there is none
+func (r *UserRepoImpl) GetDefaultAddresses(userID int64) (*Address, *Address, error) {
+	var addresses []Address
+	err := r.db.Where("user_id = ? AND (is_default_shipping = ? OR is_default_billing = ?)", userID, true, true).
+		Find(&addresses).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var shipping, billing *Address
+	for i := range addresses {
+		if addresses[i].IsDefaultShipping {
+			shipping = &addresses[i]
+		}
+		if addresses[i].IsDefaultBilling {
+			billing = &addresses[i]
+		}
+	}
+	return shipping, billing, nil
+}
@@ -99,7 +99,7 @@ func (r *AdminRepoImpl) GetAllBlockedUsers() ([]Userdetail, error) {
 func (r *AdminRepoImpl) GetAllOrders() ([]Order, error) {
 	var orders []Order
 
-	err := r.db.Preload("Items").Preload("Items.Product").Preload("User").Order("created_at DESC").Find(&orders).Error
+	err := r.db.Preload("Items").Preload("Items.Product").Preload("Refunds.Items").Preload("User").Order("created_at DESC").Find(&orders).Error
 
 	if err != nil {
 		return nil, err
@@ -0,0 +1,242 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrImportDeleted fails the rows of deleted categories and brands, the import does not bring them back
+var ErrImportDeleted = errors.New("deleted, restore it before importing")
+
+// errDryRun rolls back the import transaction once every row of a dry run has been tried
+var errDryRun = errors.New("dry run")
+
+type CatalogRepo interface {
+	ImportRows(lines []dto.CatalogLine, dryRun bool) ([]dto.CatalogImportRowResult, error)
+	ExportRows() ([]dto.CatalogRow, error)
+}
+
+type CatalogRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewCatalogRepo(db *gorm.DB) CatalogRepo {
+	return &CatalogRepoImpl{
+		db: db,
+	}
+}
+
+// ImportRows upserts every line in its own savepoint so a failing line does not undo the others.
+// A dry run goes through the same steps and rolls everything back at the end
+func (r *CatalogRepoImpl) ImportRows(lines []dto.CatalogLine, dryRun bool) ([]dto.CatalogImportRowResult, error) {
+	results := make([]dto.CatalogImportRowResult, 0, len(lines))
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range lines {
+			result := dto.CatalogImportRowResult{
+				Line:         line.Line,
+				CategoryName: line.Row.CategoryName,
+				BrandName:    line.Row.BrandName,
+				Model:        line.Row.Model,
+			}
+
+			err := tx.Transaction(func(sp *gorm.DB) error {
+				action, categoryCreated, err := importCatalogRow(sp, &line.Row)
+				result.Action = action
+				result.CategoryCreated = categoryCreated
+				return err
+			})
+			if err != nil {
+				result.Action = dto.CatalogActionFailed
+				result.CategoryCreated = false
+				result.Error = err.Error()
+			}
+			results = append(results, result)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return results, nil
+}
+
+// importCatalogRow creates or updates the category and brand of the row. Price and stock are set to the
+// values in the file so importing the same file twice changes nothing, empty optional fields are left as they are.
+// A row without stock_count keeps the stock of an existing brand and creates a new one without stock
+func importCatalogRow(tx *gorm.DB, row *dto.CatalogRow) (string, bool, error) {
+	var category Category
+	categoryCreated := false
+	categoryUpdated := false
+
+	err := findCategoryByName(tx, row.CategoryName, &category)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		category = Category{
+			Categoryname: strings.Title(strings.ToLower(row.CategoryName)), // Normalize case
+			Description:  row.CategoryDescription,
+		}
+		if err := tx.Create(&category).Error; err != nil {
+			return "", false, err
+		}
+		categoryCreated = true
+	case err != nil:
+		return "", false, err
+	case category.IsDeleted:
+		return "", false, fmt.Errorf("category %s is %w", category.Categoryname, ErrImportDeleted)
+	case row.CategoryDescription != "" && row.CategoryDescription != category.Description:
+		if err := tx.Model(&category).Update("description", row.CategoryDescription).Error; err != nil {
+			return "", false, err
+		}
+		categoryUpdated = true
+	}
+
+	// category only row
+	if row.BrandName == "" {
+		switch {
+		case categoryCreated:
+			return dto.CatalogActionCreated, true, nil
+		case categoryUpdated:
+			return dto.CatalogActionUpdated, false, nil
+		}
+		return dto.CatalogActionUnchanged, false, nil
+	}
+
+	var releaseDate time.Time
+	if row.ReleaseDate != "" {
+		releaseDate, err = time.Parse(dto.CatalogDateLayout, row.ReleaseDate)
+		if err != nil {
+			return "", categoryCreated, err
+		}
+	}
+
+	var brand Brand
+	err = findBrandByNameAndModel(tx, category.ID, row.BrandName, row.Model, &brand)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if releaseDate.IsZero() {
+			releaseDate = time.Now()
+		}
+		brand = Brand{
+			CategoryID:       category.ID,
+			BrandName:        strings.ToUpper(row.BrandName),
+			BrandModel:       row.Model,
+			Price:            row.Price,
+			ImageLink:        row.ImageLink,
+			GalleryLinks:     models.StringArray(row.GalleryLinks),
+			BrandDescription: row.Description,
+			ReleaseDate:      releaseDate,
+		}
+		if err := tx.Omit("Category").Create(&brand).Error; err != nil {
+			return "", categoryCreated, err
+		}
+		var stock int64
+		if row.StockCount != nil {
+			stock = *row.StockCount
+		}
+		if err := receiveCatalogStock(tx, brand.ID, stock); err != nil {
+			return "", categoryCreated, err
+		}
+		return dto.CatalogActionCreated, categoryCreated, nil
+	}
+	if err != nil {
+		return "", categoryCreated, err
+	}
+	if brand.IsDeleted {
+		return "", categoryCreated, fmt.Errorf("brand %s %s is %w", brand.BrandName, brand.BrandModel, ErrImportDeleted)
+	}
+
+	updates := map[string]interface{}{}
+	if brand.Price != row.Price {
+		updates["price"] = row.Price
+	}
+	if row.ImageLink != "" && brand.ImageLink != row.ImageLink {
+		updates["image_link"] = row.ImageLink
+	}
+	if row.Description != "" && brand.BrandDescription != row.Description {
+		updates["brand_description"] = row.Description
+	}
+	if len(row.GalleryLinks) > 0 && strings.Join(brand.GalleryLinks, "\n") != strings.Join(row.GalleryLinks, "\n") {
+		updates["gallery_links"] = models.StringArray(row.GalleryLinks)
+	}
+	if !releaseDate.IsZero() && brand.ReleaseDate.Format(dto.CatalogDateLayout) != row.ReleaseDate {
+		updates["release_date"] = releaseDate
+	}
+
+	stockChanged := row.StockCount != nil && brand.StockCount != *row.StockCount
+
+	if len(updates) == 0 && !stockChanged {
+		if categoryUpdated {
+			return dto.CatalogActionUpdated, false, nil
+		}
+		return dto.CatalogActionUnchanged, false, nil
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&brand).Updates(updates).Error; err != nil {
+			return "", categoryCreated, err
+		}
+	}
+	// the file has the stock to end up with, the difference is recorded as an adjustment
+	if stockChanged {
+		err := applyStockMovement(tx, &InventoryMovement{
+			BrandID:       brand.ID,
+			Type:          MovementAdjustment,
+			Quantity:      *row.StockCount - brand.StockCount,
+			Reason:        "catalog import",
+			ReferenceType: MovementRefCatalog,
+		})
+		if err != nil {
+			return "", categoryCreated, err
+		}
+	}
+	return dto.CatalogActionUpdated, categoryCreated, nil
+}
+
+// ExportRows returns the catalog that is not deleted as import rows, a category without
+// brands gives a category only row
+func (r *CatalogRepoImpl) ExportRows() ([]dto.CatalogRow, error) {
+	var categories []Category
+	err := r.db.Preload("Brands", func(db *gorm.DB) *gorm.DB {
+		return activeBrands(db).Order("brandname, brandmodel, id")
+	}).Where("is_deleted = ?", false).Order("categoryname").Find(&categories).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []dto.CatalogRow
+	for _, category := range categories {
+		if len(category.Brands) == 0 {
+			rows = append(rows, dto.CatalogRow{
+				CategoryName:        category.Categoryname,
+				CategoryDescription: category.Description,
+			})
+			continue
+		}
+
+		for _, brand := range category.Brands {
+			stock := brand.StockCount
+			rows = append(rows, dto.CatalogRow{
+				CategoryName:        category.Categoryname,
+				CategoryDescription: category.Description,
+				BrandName:           brand.BrandName,
+				Model:               brand.BrandModel,
+				Price:               brand.Price,
+				StockCount:          &stock,
+				Description:         brand.BrandDescription,
+				ImageLink:           brand.ImageLink,
+				GalleryLinks:        []string(brand.GalleryLinks),
+				ReleaseDate:         brand.ReleaseDate.Format(dto.CatalogDateLayout),
+			})
+		}
+	}
+	return rows, nil
+}
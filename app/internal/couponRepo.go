@@ -0,0 +1,497 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Coupon types
+const (
+	CouponTypePercentage   = "percentage"    // Value percent off, capped at MaxDiscount when set
+	CouponTypeFixed        = "fixed"         // Value off, never more than the eligible lines cost
+	CouponTypeBuyXGetY     = "buy_x_get_y"   // for every BuyQuantity units of a line GetQuantity more are free
+	CouponTypeFreeShipping = "free_shipping" // nothing off the items, the order ships for free
+)
+
+// ErrCouponNotApplicable is returned when a coupon cannot be used on a cart, the error tells why
+var ErrCouponNotApplicable = errors.New("coupon not applicable")
+
+// ErrInvalidCoupon is returned when the coupon settings do not fit its type
+var ErrInvalidCoupon = errors.New("invalid coupon")
+
+// ErrDuplicateCoupon is returned when a coupon code is already taken
+var ErrDuplicateCoupon = errors.New("coupon code already exists")
+
+// ErrCouponInUse is returned when a coupon that was already redeemed is deleted
+var ErrCouponInUse = errors.New("coupon already redeemed")
+
+// Coupon is an admin managed discount code. A zero limit, value or quantity means there is none,
+// CategoryID and BrandID limit the discount to the matching cart lines
+type Coupon struct {
+	ID           int64      `gorm:"primaryKey"`
+	Code         string     `gorm:"column:code;uniqueIndex;not null"`
+	Description  string     `gorm:"column:description"`
+	Type         string     `gorm:"column:type;not null"`
+	Value        float64    `gorm:"column:value;not null;default:0"`
+	MaxDiscount  float64    `gorm:"column:max_discount;not null;default:0"`
+	BuyQuantity  int64      `gorm:"column:buy_quantity;not null;default:0"`
+	GetQuantity  int64      `gorm:"column:get_quantity;not null;default:0"`
+	CategoryID   *int64     `gorm:"column:category_id"`
+	BrandID      *int64     `gorm:"column:brand_id"`
+	MinCartValue float64    `gorm:"column:min_cart_value;not null;default:0"`
+	UsageLimit   int64      `gorm:"column:usage_limit;not null;default:0"`
+	PerUserLimit int64      `gorm:"column:per_user_limit;not null;default:0"`
+	UsedCount    int64      `gorm:"column:used_count;not null;default:0"`
+	StartsAt     *time.Time `gorm:"column:starts_at"`
+	EndsAt       *time.Time `gorm:"column:ends_at"`
+	Active       bool       `gorm:"column:active;not null;default:true"`
+	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// CartCoupon is the coupon a user applied to the cart, used by the next order
+type CartCoupon struct {
+	ID        int64     `gorm:"primaryKey"`
+	UserID    int64     `gorm:"column:user_id;uniqueIndex;not null"`
+	CouponID  int64     `gorm:"column:coupon_id;not null"`
+	Coupon    Coupon    `gorm:"foreignKey:CouponID"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+// CouponRedemption records a coupon used by an order
+type CouponRedemption struct {
+	ID        int64     `gorm:"primaryKey"`
+	CouponID  int64     `gorm:"column:coupon_id;index;not null"`
+	UserID    int64     `gorm:"column:user_id;index;not null"`
+	OrderID   int64     `gorm:"column:order_id;uniqueIndex;not null"`
+	Discount  float64   `gorm:"column:discount;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+// CouponDiscount is what a coupon takes off the cart lines
+type CouponDiscount struct {
+	Subtotal     float64
+	Discount     float64
+	FreeShipping bool
+	Lines        map[int64]float64 // discount of each cart line by product id
+}
+
+// NormalizeCouponCode is the form coupon codes are stored and looked up in
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validate checks the settings of the coupon fit its type
+func (c *Coupon) validate() error {
+	switch c.Type {
+	case CouponTypePercentage:
+		if c.Value <= 0 || c.Value > 100 {
+			return fmt.Errorf("%w: a percentage coupon takes a value above 0 and up to 100", ErrInvalidCoupon)
+		}
+	case CouponTypeFixed:
+		if c.Value <= 0 {
+			return fmt.Errorf("%w: a fixed coupon takes a value above 0", ErrInvalidCoupon)
+		}
+	case CouponTypeBuyXGetY:
+		if c.BuyQuantity <= 0 || c.GetQuantity <= 0 {
+			return fmt.Errorf("%w: a buy x get y coupon takes a buy and a get quantity", ErrInvalidCoupon)
+		}
+	case CouponTypeFreeShipping:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidCoupon, c.Type)
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return fmt.Errorf("%w: ends_at has to be after starts_at", ErrInvalidCoupon)
+	}
+	return nil
+}
+
+// Usable checks the coupon can still be redeemed by a user who already used it usedByUser times
+func (c *Coupon) Usable(usedByUser int64, now time.Time) error {
+	if !c.Active {
+		return fmt.Errorf("%w: coupon %s is not active", ErrCouponNotApplicable, c.Code)
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return fmt.Errorf("%w: coupon %s is valid from %s", ErrCouponNotApplicable, c.Code, c.StartsAt.Format(time.RFC3339))
+	}
+	if c.EndsAt != nil && !now.Before(*c.EndsAt) {
+		return fmt.Errorf("%w: coupon %s expired on %s", ErrCouponNotApplicable, c.Code, c.EndsAt.Format(time.RFC3339))
+	}
+	if c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit {
+		return fmt.Errorf("%w: coupon %s is used up", ErrCouponNotApplicable, c.Code)
+	}
+	if c.PerUserLimit > 0 && usedByUser >= c.PerUserLimit {
+		return fmt.Errorf("%w: coupon %s can be used %d times per user", ErrCouponNotApplicable, c.Code, c.PerUserLimit)
+	}
+	return nil
+}
+
+// eligible tells if the coupon covers the cart line
+func (c *Coupon) eligible(line *Cart) bool {
+	if c.CategoryID != nil && line.Brand.CategoryID != *c.CategoryID {
+		return false
+	}
+	if c.BrandID != nil && line.ProductID != *c.BrandID {
+		return false
+	}
+	return true
+}
+
+// Price works out the discount of the coupon on the cart lines at their current prices, the brands of the lines
+// have to be loaded
+func (c *Coupon) Price(lines []Cart, usedByUser int64, now time.Time) (*CouponDiscount, error) {
+	if err := c.Usable(usedByUser, now); err != nil {
+		return nil, err
+	}
+
+	discount := &CouponDiscount{Lines: map[int64]float64{}}
+	var eligible []*Cart
+	var eligibleTotal float64
+	for i := range lines {
+		lineTotal := lines[i].Brand.Price * float64(lines[i].Quantity)
+		discount.Subtotal += lineTotal
+		if c.eligible(&lines[i]) {
+			eligible = append(eligible, &lines[i])
+			eligibleTotal += lineTotal
+		}
+	}
+	discount.Subtotal = roundAmount(discount.Subtotal)
+
+	if discount.Subtotal < c.MinCartValue {
+		return nil, fmt.Errorf("%w: coupon %s needs a cart of at least %.2f", ErrCouponNotApplicable, c.Code, c.MinCartValue)
+	}
+	if len(eligible) == 0 {
+		return nil, fmt.Errorf("%w: coupon %s does not cover any item of the cart", ErrCouponNotApplicable, c.Code)
+	}
+
+	switch c.Type {
+	case CouponTypePercentage:
+		amount := eligibleTotal * c.Value / 100
+		if c.MaxDiscount > 0 {
+			amount = math.Min(amount, c.MaxDiscount)
+		}
+		spreadDiscount(discount, eligible, eligibleTotal, roundAmount(amount))
+	case CouponTypeFixed:
+		spreadDiscount(discount, eligible, eligibleTotal, roundAmount(math.Min(c.Value, eligibleTotal)))
+	case CouponTypeBuyXGetY:
+		for _, line := range eligible {
+			free := line.Quantity / (c.BuyQuantity + c.GetQuantity) * c.GetQuantity
+			if free == 0 {
+				continue
+			}
+			amount := roundAmount(line.Brand.Price * float64(free))
+			discount.Lines[line.ProductID] = amount
+			discount.Discount += amount
+		}
+		if discount.Discount == 0 {
+			return nil, fmt.Errorf("%w: coupon %s needs %d units of an item", ErrCouponNotApplicable, c.Code, c.BuyQuantity+c.GetQuantity)
+		}
+	case CouponTypeFreeShipping:
+		discount.FreeShipping = true
+	}
+	discount.Discount = roundAmount(discount.Discount)
+	return discount, nil
+}
+
+// spreadDiscount splits the discount over the lines by their share of the total, the last line takes the rounding
+func spreadDiscount(discount *CouponDiscount, lines []*Cart, total, amount float64) {
+	left := amount
+	for i, line := range lines {
+		share := left
+		if i < len(lines)-1 {
+			share = roundAmount(amount * line.Brand.Price * float64(line.Quantity) / total)
+		}
+		discount.Lines[line.ProductID] = share
+		left = roundAmount(left - share)
+	}
+	discount.Discount = amount
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+type CouponRepo interface {
+	CreateCoupon(args *dto.CreateCouponRequest) (*Coupon, error)
+	GetCouponByID(couponID int64) (*Coupon, error)
+	ListCoupons(args *dto.ListCouponsRequest) ([]Coupon, int64, error)
+	UpdateCoupon(args *dto.UpdateCouponRequest) (*Coupon, error)
+	DeleteCoupon(couponID int64) error
+}
+
+type CouponRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewCouponRepo(db *gorm.DB) CouponRepo {
+	return &CouponRepoImpl{
+		db: db,
+	}
+}
+
+func (r *CouponRepoImpl) CreateCoupon(args *dto.CreateCouponRequest) (*Coupon, error) {
+	coupon := &Coupon{
+		Code:         NormalizeCouponCode(args.Code),
+		Description:  args.Description,
+		Type:         args.Type,
+		Value:        args.Value,
+		MaxDiscount:  args.MaxDiscount,
+		BuyQuantity:  args.BuyQuantity,
+		GetQuantity:  args.GetQuantity,
+		CategoryID:   args.CategoryID,
+		BrandID:      args.BrandID,
+		MinCartValue: args.MinCartValue,
+		UsageLimit:   args.UsageLimit,
+		PerUserLimit: args.PerUserLimit,
+		StartsAt:     args.StartsAt,
+		EndsAt:       args.EndsAt,
+		Active:       args.Active == nil || *args.Active,
+	}
+	if err := coupon.validate(); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := r.db.Model(&Coupon{}).Where("code = ?", coupon.Code).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("coupon code '%s': %w", coupon.Code, ErrDuplicateCoupon)
+	}
+
+	// Select keeps an inactive coupon inactive, gorm skips false with a default
+	if err := r.db.Select("*").Omit("id").Create(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+func (r *CouponRepoImpl) GetCouponByID(couponID int64) (*Coupon, error) {
+	var coupon Coupon
+	if err := r.db.First(&coupon, couponID).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *CouponRepoImpl) ListCoupons(args *dto.ListCouponsRequest) ([]Coupon, int64, error) {
+	query := r.db.Model(&Coupon{})
+	if args.Active != nil {
+		query = query.Where("active = ?", *args.Active)
+	}
+	if args.Code != "" {
+		query = query.Where("code LIKE ?", "%"+NormalizeCouponCode(args.Code)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var coupons []Coupon
+	err := query.Order("created_at DESC, id DESC").
+		Offset(args.Offset()).Limit(args.PageSize).
+		Find(&coupons).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return coupons, total, nil
+}
+
+// UpdateCoupon changes the fields present in args, a category or brand id of 0 removes that limit
+func (r *CouponRepoImpl) UpdateCoupon(args *dto.UpdateCouponRequest) (*Coupon, error) {
+	coupon := &Coupon{}
+	if err := r.db.First(coupon, args.CouponID).Error; err != nil {
+		return nil, err
+	}
+
+	if args.Description != nil {
+		coupon.Description = *args.Description
+	}
+	if args.Value != nil {
+		coupon.Value = *args.Value
+	}
+	if args.MaxDiscount != nil {
+		coupon.MaxDiscount = *args.MaxDiscount
+	}
+	if args.BuyQuantity != nil {
+		coupon.BuyQuantity = *args.BuyQuantity
+	}
+	if args.GetQuantity != nil {
+		coupon.GetQuantity = *args.GetQuantity
+	}
+	if args.CategoryID != nil {
+		coupon.CategoryID = optionalID(*args.CategoryID)
+	}
+	if args.BrandID != nil {
+		coupon.BrandID = optionalID(*args.BrandID)
+	}
+	if args.MinCartValue != nil {
+		coupon.MinCartValue = *args.MinCartValue
+	}
+	if args.UsageLimit != nil {
+		coupon.UsageLimit = *args.UsageLimit
+	}
+	if args.PerUserLimit != nil {
+		coupon.PerUserLimit = *args.PerUserLimit
+	}
+	if args.StartsAt != nil {
+		coupon.StartsAt = args.StartsAt
+	}
+	if args.EndsAt != nil {
+		coupon.EndsAt = args.EndsAt
+	}
+	if args.Active != nil {
+		coupon.Active = *args.Active
+	}
+	if err := coupon.validate(); err != nil {
+		return nil, err
+	}
+
+	if err := r.db.Select("*").Omit("id", "code", "type", "used_count", "created_at").Updates(coupon).Error; err != nil {
+		return nil, err
+	}
+	return coupon, nil
+}
+
+// DeleteCoupon removes a coupon that was never redeemed, redeemed coupons can only be deactivated
+func (r *CouponRepoImpl) DeleteCoupon(couponID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		coupon := &Coupon{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(coupon, couponID).Error; err != nil {
+			return err
+		}
+		if coupon.UsedCount > 0 {
+			return fmt.Errorf("coupon %s was used %d times: %w", coupon.Code, coupon.UsedCount, ErrCouponInUse)
+		}
+		if err := tx.Where("coupon_id = ?", coupon.ID).Delete(&CartCoupon{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(coupon).Error
+	})
+}
+
+func optionalID(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// GetCouponByCode finds a coupon by its code
+func (r *UserRepoImpl) GetCouponByCode(code string) (*Coupon, error) {
+	var coupon Coupon
+	if err := r.db.Where("code = ?", NormalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// GetCartCoupon returns the coupon applied to the cart of the user, nil when there is none
+func (r *UserRepoImpl) GetCartCoupon(userID int64) (*Coupon, error) {
+	var cartCoupon CartCoupon
+	err := r.db.Preload("Coupon").Where("user_id = ?", userID).Limit(1).Find(&cartCoupon).Error
+	if err != nil {
+		return nil, err
+	}
+	if cartCoupon.ID == 0 {
+		return nil, nil
+	}
+	return &cartCoupon.Coupon, nil
+}
+
+// SetCartCoupon applies the coupon to the cart of the user, replacing the one applied before
+func (r *UserRepoImpl) SetCartCoupon(userID, couponID int64) error {
+	cartCoupon := CartCoupon{UserID: userID, CouponID: couponID}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"coupon_id", "created_at"}),
+	}).Create(&cartCoupon).Error
+}
+
+// RemoveCartCoupon takes the coupon off the cart of the user
+func (r *UserRepoImpl) RemoveCartCoupon(userID int64) error {
+	return r.db.Where("user_id = ?", userID).Delete(&CartCoupon{}).Error
+}
+
+// CountCouponRedemptions is the number of orders of the user that used the coupon
+func (r *UserRepoImpl) CountCouponRedemptions(couponID, userID int64) (int64, error) {
+	var count int64
+	err := r.db.Model(&CouponRedemption{}).Where("coupon_id = ? AND user_id = ?", couponID, userID).Count(&count).Error
+	return count, err
+}
+
+// releaseCouponRedemption gives back the use of the coupon the order redeemed, if it did. The discount
+// stays on the order and its items
+func releaseCouponRedemption(tx *gorm.DB, orderID int64) error {
+	var redemption CouponRedemption
+	if err := tx.Where("order_id = ?", orderID).Limit(1).Find(&redemption).Error; err != nil {
+		return err
+	}
+	if redemption.ID == 0 {
+		return nil
+	}
+
+	err := tx.Model(&Coupon{}).
+		Where("id = ? AND used_count > 0", redemption.CouponID).
+		Update("used_count", gorm.Expr("used_count - 1")).Error
+	if err != nil {
+		return err
+	}
+	return tx.Delete(&redemption).Error
+}
+
+// RedeemCoupon uses the coupon for the order: the limits are checked again with the coupon locked,
+// the discount is stored on the order and its items and the coupon is taken off the cart
+func (r *UserRepoImpl) RedeemCoupon(order *Order, orderItems []OrderItem, couponID int64, discount *CouponDiscount) error {
+	coupon := &Coupon{}
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(coupon, couponID).Error; err != nil {
+		return err
+	}
+	usedByUser, err := r.CountCouponRedemptions(coupon.ID, order.UserID)
+	if err != nil {
+		return err
+	}
+	if err := coupon.Usable(usedByUser, time.Now()); err != nil {
+		return err
+	}
+
+	if err := r.db.Model(coupon).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return err
+	}
+	redemption := CouponRedemption{CouponID: coupon.ID, UserID: order.UserID, OrderID: order.ID, Discount: discount.Discount}
+	if err := r.db.Create(&redemption).Error; err != nil {
+		return err
+	}
+
+	for i := range orderItems {
+		lineDiscount := discount.Lines[orderItems[i].ProductID]
+		if lineDiscount == 0 {
+			continue
+		}
+		if err := r.db.Model(&orderItems[i]).Update("discount", lineDiscount).Error; err != nil {
+			return err
+		}
+		orderItems[i].Discount = lineDiscount
+	}
+
+	order.CouponCode = coupon.Code
+	order.Discount = discount.Discount
+	order.FreeShipping = discount.FreeShipping
+	err = r.db.Model(order).Updates(map[string]interface{}{
+		"coupon_code":   order.CouponCode,
+		"discount":      order.Discount,
+		"free_shipping": order.FreeShipping,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return r.RemoveCartCoupon(order.UserID)
+}
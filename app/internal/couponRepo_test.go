@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCouponPrice(t *testing.T) {
+	phone := Cart{ProductID: 1, Quantity: 2, Brand: Brand{ID: 1, CategoryID: 10, Price: 100}}
+	cover := Cart{ProductID: 2, Quantity: 3, Brand: Brand{ID: 2, CategoryID: 20, Price: 10}}
+	lines := []Cart{phone, cover}
+	categoryID := int64(20)
+
+	cases := []struct {
+		name     string
+		coupon   Coupon
+		discount float64
+		lines    map[int64]float64
+	}{
+		{"percentage", Coupon{Type: CouponTypePercentage, Value: 10}, 23, map[int64]float64{1: 20, 2: 3}},
+		{"percentage capped", Coupon{Type: CouponTypePercentage, Value: 50, MaxDiscount: 23}, 23, map[int64]float64{1: 20, 2: 3}},
+		{"fixed", Coupon{Type: CouponTypeFixed, Value: 50}, 50, map[int64]float64{1: 43.48, 2: 6.52}},
+		{"fixed on a category", Coupon{Type: CouponTypeFixed, Value: 50, CategoryID: &categoryID}, 30, map[int64]float64{2: 30}},
+		{"buy 2 get 1", Coupon{Type: CouponTypeBuyXGetY, BuyQuantity: 2, GetQuantity: 1}, 10, map[int64]float64{2: 10}},
+		{"free shipping", Coupon{Type: CouponTypeFreeShipping}, 0, map[int64]float64{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.coupon.Active = true
+			discount, err := tc.coupon.Price(lines, 0, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, 230.0, discount.Subtotal)
+			assert.InDelta(t, tc.discount, discount.Discount, 0.001)
+			assert.InDeltaMapValues(t, tc.lines, discount.Lines, 0.001)
+			assert.Equal(t, tc.coupon.Type == CouponTypeFreeShipping, discount.FreeShipping)
+		})
+	}
+}
+
+func TestCouponNotApplicable(t *testing.T) {
+	lines := []Cart{{ProductID: 1, Quantity: 1, Brand: Brand{ID: 1, CategoryID: 10, Price: 100}}}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	otherBrand := int64(2)
+
+	cases := map[string]Coupon{
+		"inactive":       {Type: CouponTypeFixed, Value: 10},
+		"not started":    {Type: CouponTypeFixed, Value: 10, Active: true, StartsAt: &future},
+		"expired":        {Type: CouponTypeFixed, Value: 10, Active: true, EndsAt: &past},
+		"used up":        {Type: CouponTypeFixed, Value: 10, Active: true, UsageLimit: 5, UsedCount: 5},
+		"used by user":   {Type: CouponTypeFixed, Value: 10, Active: true, PerUserLimit: 1},
+		"minimum value":  {Type: CouponTypeFixed, Value: 10, Active: true, MinCartValue: 150},
+		"other brand":    {Type: CouponTypeFixed, Value: 10, Active: true, BrandID: &otherBrand},
+		"too few to get": {Type: CouponTypeBuyXGetY, BuyQuantity: 1, GetQuantity: 1, Active: true},
+	}
+	for name, coupon := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := coupon.Price(lines, 1, time.Now())
+			assert.ErrorIs(t, err, ErrCouponNotApplicable)
+		})
+	}
+}
+
+func TestOrderItemRefundAmount(t *testing.T) {
+	item := OrderItem{Quantity: 3, Price: 10, Discount: 10}
+	assert.InDelta(t, 6.67, item.RefundAmount(1), 0.001)
+
+	item.RefundedQuantity = 1
+	assert.InDelta(t, 13.33, item.RefundAmount(2), 0.001, "the last units take the rest of the line")
+}
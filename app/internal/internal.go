@@ -12,28 +12,52 @@ import (
 )
 
 type UserRepo interface {
+	Transaction(fn func(txRepo UserRepo) error) error
 	SaveUserDetails(args *dto.UserDetailSaveRequest) (int64, error)
 	GetUserByUsername(username string) (*Userdetail, error)
 	ChangePassword(userID int64, hashedPwd string) error
 	GetUserDetailByID(userId int64) (*Userdetail, error)
 	SaveToken(userId int64, token string, expiry time.Time) error
+	IsTokenActive(userID int64, token string) (bool, error)
+	DeleteToken(userID int64, token string) error
+	DeleteAllTokens(userID int64) error
+	SaveRefreshToken(userID int64, familyID, tokenHash string, expiry time.Time) error
+	GetRefreshToken(tokenHash string) (*RefreshToken, error)
+	RotateRefreshToken(current *RefreshToken, newTokenHash string, expiry time.Time) error
+	RevokeRefreshTokenFamily(familyID string) error
 	UpdateUserDetails(args *dto.UpdateUserDetailRequest, UserId int64) error
 	IsUserActive(userID int64) (bool, error)
 	GetProductDetails(productID, categoryID int64) (*Brand, error)
 	CheckProductInCart(userID, productID int64) (*Cart, error)
-	FetchCartItems(userID, cartID int64) ([]Cart, error)
+	FetchCartItems(userID int64, productIDs []int64) ([]Cart, error)
 	AddOrUpdateCart(userID int64, product *Brand, quantity int64, totalAmount float64) error
 	GetCartWithProductDetails(userID int64, productID int64) (*Cart, error)
-	UpdateCartOrderStatus(userID, orderID, cartID int64) error
-	UpdateStockCount(orderItems []OrderItem) ([]Brand, error)
+	UpdateCartItemQuantity(userID, productID, quantity int64) (*Cart, error)
+	RemoveCartItem(userID, productID int64) error
+	UpdateCartOrderStatus(userID, orderID int64, cartIDs []int64) error
+	UpdateStockCount(userID int64, orderItems []OrderItem) ([]Brand, error)
+	ReserveStock(userID, brandID, quantity int64) error
+	ReleaseReservations(userID int64, brandIDs []int64, reason string) error
+	ExtendReservations(userID int64) error
+	DeleteExpiredReservations() (int64, error)
 	ViewCart(userID int64) ([]Cart, error)
 	ClearCart(userID int64) error
-	CreateOrder(userID int64, totalAmount float64, cartItems []Cart) (*Order, []OrderItem, error)
+	CreateOrder(newOrder *Order, cartItems []Cart, tax *OrderTax) ([]OrderItem, error)
 	GetUserByID(userID int64) (*Userdetail, error)
 	GetOrderHistoryByUserID(userID int64) ([]Order, error)
 	AddOrUpdateFavorite(userID int64, args dto.UserFavoriteBrandRequest) error
 	GetFavoriteBrandIDs(userID int64) ([]int64, error)
 	GetBrandsByIDs(brandIDs []int64) ([]Brand, error)
+	GetCouponByCode(code string) (*Coupon, error)
+	GetCartCoupon(userID int64) (*Coupon, error)
+	SetCartCoupon(userID, couponID int64) error
+	RemoveCartCoupon(userID int64) error
+	CountCouponRedemptions(couponID, userID int64) (int64, error)
+	RedeemCoupon(order *Order, orderItems []OrderItem, couponID int64, discount *CouponDiscount) error
+	GetUserAddress(userID, addressID int64) (*Address, error)
+	GetDefaultAddresses(userID int64) (*Address, *Address, error)
+	GetActiveShippingZones() ([]ShippingZone, error)
+	GetTaxClasses() ([]TaxClass, error)
 }
 
 type UserRepoImpl struct {
@@ -68,6 +92,24 @@ type ActiveToken struct {
 	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
 }
 
+// RefreshToken is an opaque, rotating token, only its hash is stored.
+// Tokens rotated from the same login share the FamilyID
+type RefreshToken struct {
+	ID        int64      `gorm:"primaryKey;column:id"`
+	UserID    int64      `gorm:"column:user_id;index;not null"`
+	FamilyID  string     `gorm:"column:family_id;index;not null"`
+	TokenHash string     `gorm:"column:token_hash;uniqueIndex;not null"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+// ErrInsufficientStock is returned when a product does not have enough stock left for an order
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// ErrRefreshTokenReused is returned when an already rotated refresh token is used again
+var ErrRefreshTokenReused = errors.New("refresh token already used")
+
 func (Userdetail) TableName() string {
 	return "userdetails"
 }
@@ -86,25 +128,45 @@ type Cart struct {
 	Brand       Brand `gorm:"foreignKey:ProductID"` // Relationship to Brand
 }
 type Order struct {
-	ID        int64       `gorm:"primaryKey"`
-	UserID    int64       `gorm:"index;not null"` // Foreign key to Userdetail
-	Total     float64     `gorm:"not null"`
-	CreatedAt time.Time   `gorm:"autoCreateTime"`
-	User      Userdetail  `gorm:"foreignKey:UserID;references:ID"` // Relation to Userdetail table
-	Items     []OrderItem `gorm:"foreignKey:OrderID"`              // One-to-many relation with OrderItem
-	UpdatedAt time.Time   `gorm:"column:updated_at;autoUpdateTime"`
+	ID                  int64      `gorm:"primaryKey"`
+	UserID              int64      `gorm:"index;not null"`                     // Foreign key to Userdetail
+	Total               float64    `gorm:"not null"`                           // amount to pay, after the discount with tax and shipping
+	Subtotal            float64    `gorm:"column:subtotal;not null;default:0"` // the lines at their price, before discount and tax
+	Discount            float64    `gorm:"column:discount;not null;default:0"`
+	Tax                 float64    `gorm:"column:tax;not null;default:0"` // tax of all lines, inclusive tax included
+	CouponCode          string     `gorm:"column:coupon_code"`
+	FreeShipping        bool       `gorm:"column:free_shipping;not null;default:false"`
+	ShippingCharge      float64    `gorm:"column:shipping_charge;not null;default:0"` // part of Total
+	EstimatedDeliveryAt *time.Time `gorm:"column:estimated_delivery_at"`
+	// copies of the addresses picked at checkout
+	ShippingAddress AddressSnapshot      `gorm:"embedded;embeddedPrefix:shipping_"`
+	BillingAddress  AddressSnapshot      `gorm:"embedded;embeddedPrefix:billing_"`
+	RefundedAmount  float64              `gorm:"column:refunded_amount;not null;default:0"`
+	Status          string               `gorm:"column:status;not null;default:pending"` // one of the OrderStatus constants
+	CreatedAt       time.Time            `gorm:"autoCreateTime"`
+	User            Userdetail           `gorm:"foreignKey:UserID;references:ID"` // Relation to Userdetail table
+	Items           []OrderItem          `gorm:"foreignKey:OrderID"`              // One-to-many relation with OrderItem
+	StatusHistory   []OrderStatusHistory `gorm:"foreignKey:OrderID"`
+	Payments        []Payment            `gorm:"foreignKey:OrderID"`
+	Refunds         []Refund             `gorm:"foreignKey:OrderID"`
+	UpdatedAt       time.Time            `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 type OrderItem struct {
-	ID        int64     `gorm:"primaryKey"`
-	OrderID   int64     `gorm:"index;not null"` // Foreign key to Order
-	ProductID int64     `gorm:"not null"`       // Foreign key to Product (Brand)
-	Quantity  int64     `gorm:"not null"`
-	Price     float64   `gorm:"not null"`
-	Order     Order     `gorm:"foreignKey:OrderID;references:ID"`   // Relation to Order
-	Product   Brand     `gorm:"foreignKey:ProductID;references:ID"` // Relation to Brand, orderid is foreign key to order table, a table le primary id anne ivide reference id ayite irikane
-	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	ID               int64     `gorm:"primaryKey"`
+	OrderID          int64     `gorm:"index;not null"` // Foreign key to Order
+	ProductID        int64     `gorm:"not null"`       // Foreign key to Product (Brand)
+	Quantity         int64     `gorm:"not null"`
+	Price            float64   `gorm:"not null"`
+	Discount         float64   `gorm:"column:discount;not null;default:0"` // coupon discount of the whole line
+	TaxRate          float64   `gorm:"column:tax_rate;not null;default:0"` // percent
+	TaxAmount        float64   `gorm:"column:tax_amount;not null;default:0"`
+	TaxInclusive     bool      `gorm:"column:tax_inclusive;not null;default:false"` // the tax is part of the price
+	RefundedQuantity int64     `gorm:"column:refunded_quantity;not null;default:0"`
+	Order            Order     `gorm:"foreignKey:OrderID;references:ID"`   // Relation to Order
+	Product          Brand     `gorm:"foreignKey:ProductID;references:ID"` // Relation to Brand, orderid is foreign key to order table, a table le primary id anne ivide reference id ayite irikane
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 type UserFavoriteBrand struct {
@@ -116,6 +178,13 @@ type UserFavoriteBrand struct {
 	Favorite bool       `gorm:"column:favorite;default:false;not null"`
 }
 
+// Transaction runs fn in a single database transaction, the repo passed to fn uses that transaction
+func (r *UserRepoImpl) Transaction(fn func(txRepo UserRepo) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&UserRepoImpl{db: tx})
+	})
+}
+
 func (r *UserRepoImpl) SaveUserDetails(args *dto.UserDetailSaveRequest) (int64, error) {
 
 	user := Userdetail{
@@ -159,6 +228,90 @@ func (r *UserRepoImpl) SaveToken(userId int64, token string, expiry time.Time) e
 	}).Create(&saveToken).Error
 }
 
+// IsTokenActive checks the token is the current, unexpired login token of the user
+func (r *UserRepoImpl) IsTokenActive(userID int64, token string) (bool, error) {
+	var count int64
+	err := r.db.Model(&ActiveToken{}).
+		Where("user_id = ? AND token = ? AND expires_at > ?", userID, token, time.Now()).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteToken revokes the given login token of the user
+func (r *UserRepoImpl) DeleteToken(userID int64, token string) error {
+	result := r.db.Where("user_id = ? AND token = ?", userID, token).Delete(&ActiveToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no active token found for user with ID %d", userID)
+	}
+	return nil
+}
+
+// DeleteAllTokens revokes every login and refresh token of the user
+func (r *UserRepoImpl) DeleteAllTokens(userID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&ActiveToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+func (r *UserRepoImpl) SaveRefreshToken(userID int64, familyID, tokenHash string, expiry time.Time) error {
+	refreshToken := RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiry,
+	}
+	return r.db.Create(&refreshToken).Error
+}
+
+func (r *UserRepoImpl) GetRefreshToken(tokenHash string) (*RefreshToken, error) {
+	var refreshToken RefreshToken
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error; err != nil {
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+// RotateRefreshToken revokes the current refresh token and saves its replacement in the same family.
+// Only one caller can rotate a token, the others get ErrRefreshTokenReused
+func (r *UserRepoImpl) RotateRefreshToken(current *RefreshToken, newTokenHash string, expiry time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		next := RefreshToken{
+			UserID:    current.UserID,
+			FamilyID:  current.FamilyID,
+			TokenHash: newTokenHash,
+			ExpiresAt: expiry,
+		}
+		return tx.Create(&next).Error
+	})
+}
+
+func (r *UserRepoImpl) RevokeRefreshTokenFamily(familyID string) error {
+	return r.db.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
 func (r *UserRepoImpl) GetUserDetailByID(userId int64) (*Userdetail, error) {
 	var user Userdetail
 	if err := r.db.Table("userdetails").Where("id = ?", userId).First(&user).Error; err != nil {
@@ -218,10 +371,11 @@ func (r *UserRepoImpl) IsUserActive(userID int64) (bool, error) {
 func (r *UserRepoImpl) GetProductDetails(brandID, categoryID int64) (*Brand, error) {
 	var product Brand
 
-	if err := r.db.Table("brands").Where("id = ? AND category_id = ? ", categoryID, brandID).First(&product).Error; err != nil {
+	// deleted products can not be added to the cart
+	if err := r.db.Table("brands").Scopes(activeBrands).Where("id = ? AND category_id = ? ", categoryID, brandID).First(&product).Error; err != nil {
 		// If product not found, then GORM error
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("product with id %d not found", brandID)
+			return nil, fmt.Errorf("product with id %d not found: %w", brandID, err)
 		}
 		return nil, err
 	}
@@ -232,8 +386,8 @@ func (r *UserRepoImpl) GetProductDetails(brandID, categoryID int64) (*Brand, err
 func (r *UserRepoImpl) CheckProductInCart(userID, productID int64) (*Cart, error) {
 	var cart Cart
 
-	// Check if the product exists in the user's cart
-	if err := r.db.Table("carts").Where("user_id = ? AND product_id = ?", userID, productID).First(&cart).Error; err != nil {
+	// Check if the product exists in the user's open cart
+	if err := r.db.Table("carts").Where("user_id = ? AND product_id = ? AND orderstatus = ?", userID, productID, true).First(&cart).Error; err != nil {
 		// If not found, return nil
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -247,8 +401,8 @@ func (r *UserRepoImpl) CheckProductInCart(userID, productID int64) (*Cart, error
 func (r *UserRepoImpl) AddOrUpdateCart(userID int64, product *Brand, quantity int64, totalAmount float64) error {
 	var existingCart Cart
 
-	// Check if the product already exists in the user's cart
-	if err := r.db.Table("carts").Where("user_id = ? AND product_id = ?", userID, product.ID).First(&existingCart).Error; err != nil {
+	// Check if the product already exists in the user's cart, ordered lines are left as they are
+	if err := r.db.Table("carts").Where("user_id = ? AND product_id = ? AND orderstatus = ?", userID, product.ID, true).First(&existingCart).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			// If cart item does not exist, create a new cart item
 			newCart := Cart{
@@ -270,12 +424,43 @@ func (r *UserRepoImpl) AddOrUpdateCart(userID int64, product *Brand, quantity in
 	}
 
 	// If product already exists in the cart, update the quantity
-	existingCart.Quantity += quantity
-	if err := r.db.Table("carts").Save(&existingCart).Error; err != nil {
-		return err
+	return r.setCartQuantity(&existingCart, existingCart.Quantity+quantity)
+}
+
+// UpdateCartItemQuantity sets the quantity of a product in the user's open cart
+func (r *UserRepoImpl) UpdateCartItemQuantity(userID, productID, quantity int64) (*Cart, error) {
+	var cart Cart
+	if err := r.db.Where("user_id = ? AND product_id = ? AND orderstatus = ?", userID, productID, true).First(&cart).Error; err != nil {
+		return nil, err
+	}
+
+	if err := r.setCartQuantity(&cart, quantity); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// setCartQuantity updates the quantity of the cart line and recomputes its total amount
+func (r *UserRepoImpl) setCartQuantity(cart *Cart, quantity int64) error {
+	cart.Quantity = quantity
+	cart.TotalAmount = cart.Price * float64(quantity)
+
+	updates := map[string]interface{}{
+		"quantity":    cart.Quantity,
+		"totalamount": cart.TotalAmount,
 	}
+	return r.db.Model(cart).Updates(updates).Error
+}
 
-	// Return the updated cart item
+// RemoveCartItem deletes a single product from the user's open cart
+func (r *UserRepoImpl) RemoveCartItem(userID, productID int64) error {
+	result := r.db.Where("user_id = ? AND product_id = ? AND orderstatus = ?", userID, productID, true).Delete(&Cart{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	return nil
 }
 
@@ -283,90 +468,95 @@ func (r *UserRepoImpl) GetCartWithProductDetails(userID int64, productID int64)
 	var cart Cart
 
 	// Use Preload to load the related Brand (product) details
-	if err := r.db.Preload("Brand").Where("user_id = ? AND product_id = ?", userID, productID).First(&cart).Error; err != nil {
+	if err := r.db.Preload("Brand.Category").Where("user_id = ? AND product_id = ? AND orderstatus = ?", userID, productID, true).First(&cart).Error; err != nil {
 		return nil, err
 	}
 
 	return &cart, nil
 }
 
-// FetchCartItems retrieves the user's cart items.
-func (r *UserRepoImpl) FetchCartItems(userID, cartID int64) ([]Cart, error) {
+// FetchCartItems retrieves the open cart lines of the user, limited to the given products when any are passed
+func (r *UserRepoImpl) FetchCartItems(userID int64, productIDs []int64) ([]Cart, error) {
 	var cartItems []Cart
 
-	if err := r.db.Preload("Brand").Where("user_id = ? AND id = ?", userID, cartID).Find(&cartItems).Error; err != nil {
+	query := r.db.Preload("Brand.Category").Where("user_id = ? AND orderstatus = ?", userID, true)
+	if len(productIDs) > 0 {
+		query = query.Where("product_id IN ?", productIDs)
+	}
+	if err := query.Order("id").Find(&cartItems).Error; err != nil {
 		return nil, err
 	}
 
 	if len(cartItems) == 0 {
-		return nil, fmt.Errorf("no items in the cart to place an order for the given userID and cartID")
+		return nil, fmt.Errorf("no items in the cart to place an order: %w", gorm.ErrRecordNotFound)
+	}
+
+	// every requested product has to be in the cart
+	if len(productIDs) > 0 {
+		inCart := make(map[int64]bool)
+		for _, item := range cartItems {
+			inCart[item.ProductID] = true
+		}
+		for _, productID := range productIDs {
+			if !inCart[productID] {
+				return nil, fmt.Errorf("product ID %d is not in the cart: %w", productID, gorm.ErrRecordNotFound)
+			}
+		}
 	}
 
 	return cartItems, nil
 }
 
 // UpdateCartOrder updates the order status to false and adds the order ID to orderdetail
-func (r *UserRepoImpl) UpdateCartOrderStatus(userID, orderID, cartID int64) error {
+func (r *UserRepoImpl) UpdateCartOrderStatus(userID, orderID int64, cartIDs []int64) error {
 	// Create a map for fields to update
 	updates := map[string]interface{}{
 		"OrderStatus": false,
 		"OrderDetail": orderID,
 	}
 
-	// Updating
-	result := r.db.Model(&Cart{}).Where("user_id = ? AND id = ?", userID, cartID).Updates(updates)
+	// Updating, only cart lines which are not ordered yet so the same line cannot be ordered twice
+	result := r.db.Model(&Cart{}).Where("user_id = ? AND id IN ? AND orderstatus = ?", userID, cartIDs, true).Updates(updates)
 
 	// Check for errors
 	if result.Error != nil {
 		return result.Error
 	}
 
-	// Check if rows were affected
-	if result.RowsAffected == 0 {
-		return fmt.Errorf("no rows updated")
+	// Check every line was updated
+	if result.RowsAffected != int64(len(cartIDs)) {
+		return fmt.Errorf("cart was changed while placing the order, %d of %d lines updated", result.RowsAffected, len(cartIDs))
 	}
 
 	return nil
 }
 
-func (r *UserRepoImpl) UpdateStockCount(orderItems []OrderItem) ([]Brand, error) {
+// UpdateStockCount records a sale movement for every ordered product. The decrement only
+// happens when enough stock is left after what other carts reserved, so concurrent orders
+// cannot oversell a product
+func (r *UserRepoImpl) UpdateStockCount(userID int64, orderItems []OrderItem) ([]Brand, error) {
 	var updatedBrands []Brand
-	var productIDs []int64
 
-	// Collect all product IDs first
 	for _, item := range orderItems {
-		productIDs = append(productIDs, item.ProductID)
-	}
-
-	// Preload all brands in a single query
-	var brands []Brand
-	if err := r.db.Where("id IN ?", productIDs).Find(&brands).Error; err != nil {
-		return nil, fmt.Errorf("failed to fetch product details: %w", err)
-	}
-
-	// Create a map for quick lookup
-	brandMap := make(map[int64]Brand)
-	for _, brand := range brands {
-		brandMap[brand.ID] = brand
-	}
-
-	// Update stock counts
-	for _, item := range orderItems {
-		brand, exists := brandMap[item.ProductID]
-		if !exists {
-			return nil, fmt.Errorf("product ID %d not found", item.ProductID)
+		movement := InventoryMovement{
+			BrandID:       item.ProductID,
+			Type:          MovementSale,
+			Quantity:      -item.Quantity,
+			ReferenceType: MovementRefOrder,
+			ReferenceID:   item.OrderID,
+			ActorID:       userID,
 		}
-
-		if brand.StockCount < item.Quantity {
-			return nil, fmt.Errorf("insufficient stock for product ID %d (current: %d, required: %d)",
-				item.ProductID, brand.StockCount, item.Quantity)
+		if err := applyStockMovement(r.db, &movement); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("product ID %d not found", item.ProductID)
+			}
+			return nil, err
 		}
 
-		brand.StockCount -= item.Quantity
-		if err := r.db.Save(&brand).Error; err != nil {
-			return nil, fmt.Errorf("failed to update stock for product ID %d: %w", item.ProductID, err)
+		var brand Brand
+		if err := r.db.Where("id = ?", item.ProductID).First(&brand).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch product details: %w", err)
 		}
-
 		updatedBrands = append(updatedBrands, brand)
 	}
 
@@ -376,7 +566,7 @@ func (r *UserRepoImpl) UpdateStockCount(orderItems []OrderItem) ([]Brand, error)
 // ClearCart deletes all items from the cart for the given user.
 func (r *UserRepoImpl) ViewCart(userID int64) ([]Cart, error) {
 	var cartItems []Cart
-	if err := r.db.Preload("Brand").Where("user_id = ? AND orderstatus = ?", userID, true).Find(&cartItems).Error; err != nil {
+	if err := r.db.Preload("Brand.Category").Where("user_id = ? AND orderstatus = ?", userID, true).Find(&cartItems).Error; err != nil {
 		return nil, err
 	}
 	return cartItems, nil
@@ -403,63 +593,72 @@ func (r *UserRepoImpl) GetUserByID(userID int64) (*Userdetail, error) {
 	return &user, nil
 }
 
-func (r *UserRepoImpl) CreateOrder(userID int64, totalAmount float64, cartItems []Cart) (*Order, []OrderItem, error) {
-	tx := r.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Create the order (using tx)
-	newOrder := &Order{
-		UserID: userID,
-		Total:  totalAmount,
-	}
-
-	if err := tx.Create(newOrder).Error; err != nil {
-		tx.Rollback()
-		return nil, nil, err
-	}
-
-	// Create order items (using tx)
+// CreateOrder saves the order with an item for every cart line, the order comes priced and addressed.
+// The items keep the tax of their line
+func (r *UserRepoImpl) CreateOrder(newOrder *Order, cartItems []Cart, tax *OrderTax) ([]OrderItem, error) {
+	newOrder.Status = OrderStatusPending
 	var createdItems []OrderItem
-	for _, item := range cartItems {
-		// Get brand details (using tx)
-		var brand Brand
-		if err := tx.Where("id = ?", item.ProductID).First(&brand).Error; err != nil {
-			tx.Rollback()
-			return nil, nil, fmt.Errorf("failed to get brand details: %w", err)
+
+	// runs as a savepoint when called inside Transaction
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newOrder).Error; err != nil {
+			return err
 		}
 
-		orderItem := OrderItem{
+		// first entry of the status history
+		history := OrderStatusHistory{
 			OrderID:   newOrder.ID,
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     item.Price,
-			Product:   brand,
+			ToStatus:  OrderStatusPending,
+			ActorID:   newOrder.UserID,
+			ActorRole: ActorRoleUser,
 		}
-
-		if err := tx.Create(&orderItem).Error; err != nil {
-			tx.Rollback()
-			return nil, nil, err
+		if err := tx.Create(&history).Error; err != nil {
+			return err
 		}
 
-		createdItems = append(createdItems, orderItem)
-	}
+		// Create order items (using tx)
+		for _, item := range cartItems {
+			// Get brand details (using tx)
+			var brand Brand
+			if err := tx.Where("id = ?", item.ProductID).First(&brand).Error; err != nil {
+				return fmt.Errorf("failed to get brand details: %w", err)
+			}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, nil, err
+			orderItem := OrderItem{
+				OrderID:   newOrder.ID,
+				ProductID: item.ProductID,
+				Quantity:  item.Quantity,
+				Price:     item.Price,
+			}
+			if tax != nil {
+				lineTax := tax.Lines[item.ProductID]
+				orderItem.TaxRate = lineTax.Rate
+				orderItem.TaxAmount = lineTax.Amount
+				orderItem.TaxInclusive = lineTax.Inclusive
+			}
+
+			// associations are omitted so the brand row is not written back
+			if err := tx.Omit(clause.Associations).Create(&orderItem).Error; err != nil {
+				return err
+			}
+
+			orderItem.Product = brand
+			createdItems = append(createdItems, orderItem)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return newOrder, createdItems, nil
+	return createdItems, nil
 }
 
 func (r *UserRepoImpl) GetOrderHistoryByUserID(userID int64) ([]Order, error) {
 	var orders []Order
 
 	err := r.db.
-		Preload("Items").Preload("Items.Product").Where("user_id = ?", userID).
+		Preload("Items").Preload("Items.Product").Preload("Refunds.Items").Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Find(&orders).Error
 
@@ -476,6 +675,17 @@ func (r *UserRepoImpl) GetOrderHistoryByUserID(userID int64) ([]Order, error) {
 func (r *UserRepoImpl) AddOrUpdateFavorite(userID int64, args dto.UserFavoriteBrandRequest) error {
 	var fav UserFavoriteBrand
 
+	// only brands that are not deleted can be marked as favourite
+	if args.Favorite {
+		var count int64
+		if err := r.db.Model(&Brand{}).Scopes(activeBrands).Where("id = ?", args.BrandID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("brand with id %d not found: %w", args.BrandID, gorm.ErrRecordNotFound)
+		}
+	}
+
 	// Check if the favorite entry already exists
 	err := r.db.Table("user_favorite_brands").Where("user_id = ? AND brand_id = ?", userID, args.BrandID).First(&fav).Error
 
@@ -525,7 +735,7 @@ func (r *UserRepoImpl) GetBrandsByIDs(brandIDs []int64) ([]Brand, error) {
 	}
 
 	var brands []Brand
-	err := r.db.Where("id IN ?", brandIDs).Find(&brands).Error
+	err := r.db.Scopes(activeBrands).Where("id IN ?", brandIDs).Find(&brands).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,275 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Inventory movement types
+const (
+	// MovementReceipt : stock received from a supplier or added through the catalog
+	MovementReceipt = "receipt"
+	// MovementSale : stock taken by an order
+	MovementSale = "sale"
+	// MovementReturn : stock put back from a cancelled or returned order
+	MovementReturn = "return"
+	// MovementAdjustment : manual correction, stock counts and catalog imports
+	MovementAdjustment = "adjustment"
+	// MovementReservation : stock held for a cart, it does not change the stock on hand
+	MovementReservation = "reservation"
+)
+
+// References of what caused a movement
+const (
+	MovementRefOrder   = "order"
+	MovementRefCatalog = "catalog"
+	MovementRefAdmin   = "admin"
+)
+
+// ErrInvalidMovementType is returned for a movement type that is not known
+var ErrInvalidMovementType = errors.New("invalid inventory movement type")
+
+// InventoryMovement is a single change of the stock of a brand, the stock on hand is the
+// sum of the quantities of every movement except reservations
+type InventoryMovement struct {
+	ID            int64     `gorm:"primaryKey"`
+	BrandID       int64     `gorm:"column:brand_id;index;not null"` // Foreign key to Brand
+	Type          string    `gorm:"column:type;not null"`
+	Quantity      int64     `gorm:"column:quantity;not null"` // positive adds stock, negative takes it
+	BalanceAfter  int64     `gorm:"column:balance_after;not null"`
+	Reason        string    `gorm:"column:reason"`
+	ReferenceType string    `gorm:"column:reference_type"`
+	ReferenceID   int64     `gorm:"column:reference_id"`
+	ActorID       int64     `gorm:"column:actor_id"` // user or admin id, 0 for system
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+// StockDiscrepancy is a brand whose stock count does not match its movements
+type StockDiscrepancy struct {
+	BrandID     int64
+	BrandName   string
+	StockCount  int64
+	LedgerStock int64
+	Reconciled  bool
+}
+
+// IsValidMovementType checks the type is one of the known movement types
+func IsValidMovementType(movementType string) bool {
+	switch movementType {
+	case MovementReceipt, MovementSale, MovementReturn, MovementAdjustment, MovementReservation:
+		return true
+	}
+	return false
+}
+
+// applyStockMovement changes the stock of the brand by the movement quantity and records the movement.
+// The change is conditional so the stock never goes below zero, every stock change goes through here
+func applyStockMovement(tx *gorm.DB, movement *InventoryMovement) error {
+	if !IsValidMovementType(movement.Type) || movement.Type == MovementReservation {
+		return fmt.Errorf("%w: %q", ErrInvalidMovementType, movement.Type)
+	}
+
+	// a sale cannot take the stock other carts reserved, the buyer's own reservation is released by the order
+	var reserved int64
+	if movement.Type == MovementSale && movement.Quantity < 0 {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Brand{}, movement.BrandID).Error; err != nil {
+			return err
+		}
+		var err error
+		if reserved, err = reservedStock(tx, movement.BrandID, movement.ActorID); err != nil {
+			return err
+		}
+	}
+
+	if movement.Quantity != 0 {
+		result := tx.Model(&Brand{}).
+			Where("id = ? AND stockcount + ? >= ?", movement.BrandID, movement.Quantity, reserved).
+			Update("stockcount", gorm.Expr("stockcount + ?", movement.Quantity))
+		if result.Error != nil {
+			return fmt.Errorf("failed to update stock for product ID %d: %w", movement.BrandID, result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			var brand Brand
+			if err := tx.Select("id", "stockcount").First(&brand, movement.BrandID).Error; err != nil {
+				return err
+			}
+			return fmt.Errorf("%w for product ID %d (current: %d, reserved: %d, required: %d)",
+				ErrInsufficientStock, movement.BrandID, brand.StockCount, reserved, -movement.Quantity)
+		}
+	}
+
+	var brand Brand
+	if err := tx.Select("id", "stockcount").First(&brand, movement.BrandID).Error; err != nil {
+		return err
+	}
+	movement.BalanceAfter = brand.StockCount
+
+	return tx.Create(movement).Error
+}
+
+type InventoryRepo interface {
+	AdjustStock(movement *InventoryMovement) error
+	GetMovements(brandID int64, offset, limit int) ([]InventoryMovement, int64, error)
+	Reconcile(apply bool) ([]StockDiscrepancy, error)
+	GetLowStock(args *dto.LowStockRequest) ([]Brand, int64, error)
+}
+
+type InventoryRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewInventoryRepo(db *gorm.DB) InventoryRepo {
+	return &InventoryRepoImpl{
+		db: db,
+	}
+}
+
+// AdjustStock records a manual movement, the brand row is changed in the same transaction
+func (r *InventoryRepoImpl) AdjustStock(movement *InventoryMovement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return applyStockMovement(tx, movement)
+	})
+}
+
+// GetMovements returns a page of the stock history of a brand, newest first
+func (r *InventoryRepoImpl) GetMovements(brandID int64, offset, limit int) ([]InventoryMovement, int64, error) {
+	if err := r.db.Select("id").First(&Brand{}, brandID).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query := r.db.Model(&InventoryMovement{}).Where("brand_id = ?", brandID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var movements []InventoryMovement
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&movements).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return movements, total, nil
+}
+
+// ledgerStock is the stock on hand of a brand according to its movements
+type ledgerStock struct {
+	BrandID int64
+	Stock   int64
+}
+
+// Reconcile compares the stock count of every brand with the sum of its movements.
+// With apply the stock count is set to the ledger value
+func (r *InventoryRepoImpl) Reconcile(apply bool) ([]StockDiscrepancy, error) {
+	var discrepancies []StockDiscrepancy
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var sums []ledgerStock
+		err := tx.Model(&InventoryMovement{}).
+			Select("brand_id, SUM(quantity) AS stock").
+			Where("type <> ?", MovementReservation).
+			Group("brand_id").
+			Scan(&sums).Error
+		if err != nil {
+			return err
+		}
+		ledger := make(map[int64]int64, len(sums))
+		for _, sum := range sums {
+			ledger[sum.BrandID] = sum.Stock
+		}
+
+		var brands []Brand
+		if err := tx.Select("id", "brandname", "stockcount").Order("id").Find(&brands).Error; err != nil {
+			return err
+		}
+
+		for _, brand := range brands {
+			if brand.StockCount == ledger[brand.ID] {
+				continue
+			}
+			discrepancy := StockDiscrepancy{
+				BrandID:     brand.ID,
+				BrandName:   brand.BrandName,
+				StockCount:  brand.StockCount,
+				LedgerStock: ledger[brand.ID],
+			}
+			if apply {
+				err := tx.Model(&Brand{}).Where("id = ?", brand.ID).Update("stockcount", discrepancy.LedgerStock).Error
+				if err != nil {
+					return err
+				}
+				discrepancy.Reconciled = true
+			}
+			discrepancies = append(discrepancies, discrepancy)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return discrepancies, nil
+}
+
+// SeedOpeningBalances records the stock of brands that have no movements yet as an opening
+// adjustment, so stock that existed before the ledger adds up
+func SeedOpeningBalances(db *gorm.DB) error {
+	var brands []Brand
+	err := db.Select("id", "stockcount").
+		Where("stockcount <> 0 AND id NOT IN (SELECT brand_id FROM inventory_movements)").
+		Find(&brands).Error
+	if err != nil {
+		return err
+	}
+
+	for _, brand := range brands {
+		movement := InventoryMovement{
+			BrandID:      brand.ID,
+			Type:         MovementAdjustment,
+			Quantity:     brand.StockCount,
+			BalanceAfter: brand.StockCount,
+			Reason:       "opening balance",
+		}
+		if err := db.Create(&movement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLowStock returns a page of the brands at or below their reorder threshold, emptiest first.
+// Deleted brands are left out, they are not reordered
+func (r *InventoryRepoImpl) GetLowStock(args *dto.LowStockRequest) ([]Brand, int64, error) {
+	query := r.db.Model(&Brand{}).Scopes(activeBrands)
+	switch args.Status {
+	case dto.StockStatusOut:
+		query = query.Where("brands.stockcount <= 0")
+	case dto.StockStatusLow:
+		query = query.Where("brands.stockcount > 0 AND brands.stockcount <= brands.reorder_threshold")
+	default:
+		query = query.Where("brands.stockcount <= brands.reorder_threshold")
+	}
+	if args.CategoryID > 0 {
+		query = query.Where("brands.category_id = ?", args.CategoryID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var brands []Brand
+	err := query.Preload("Category").
+		Order("brands.stockcount ASC, brands.id ASC").
+		Offset(args.Offset()).Limit(args.PageSize).
+		Find(&brands).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return brands, total, nil
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvoiceNotAvailable is returned when an invoice is asked for an order that is not paid
+var ErrInvoiceNotAvailable = errors.New("invoice not available")
+
+// Invoice numbers an order once, when its payment succeeds. Sequence has no gaps
+type Invoice struct {
+	ID        int64     `gorm:"primaryKey"`
+	OrderID   int64     `gorm:"column:order_id;uniqueIndex;not null"`
+	Sequence  int64     `gorm:"column:sequence;uniqueIndex;not null"`
+	Number    string    `gorm:"column:number;uniqueIndex;not null"`
+	IssuedAt  time.Time `gorm:"column:issued_at;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+// InvoiceCounter holds the last invoice sequence, its single row is locked while a number is taken
+type InvoiceCounter struct {
+	ID   int64 `gorm:"primaryKey"`
+	Last int64 `gorm:"column:last;not null;default:0"`
+}
+
+// invoiceCounterID is the id of the only counter row
+const invoiceCounterID = 1
+
+// invoicedStatuses are the statuses of orders that were paid for, only those get an invoice
+var invoicedStatuses = []string{OrderStatusPaid, OrderStatusPacked, OrderStatusShipped, OrderStatusDelivered, OrderStatusRefunded}
+
+// CanInvoice tells if the order was paid for, only those get an invoice
+func (order *Order) CanInvoice() bool {
+	return slices.Contains(invoicedStatuses, order.Status)
+}
+
+// InvoiceNumber formats the sequence of an invoice
+func InvoiceNumber(sequence int64) string {
+	return fmt.Sprintf("INV-%06d", sequence)
+}
+
+type InvoiceRepo interface {
+	GetOrder(orderID int64) (*Order, error)
+	GetInvoice(orderID int64) (*Invoice, error)
+}
+
+type InvoiceRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepo(db *gorm.DB) InvoiceRepo {
+	return &InvoiceRepoImpl{
+		db: db,
+	}
+}
+
+// GetOrder loads the order with what its invoice shows
+func (r *InvoiceRepoImpl) GetOrder(orderID int64) (*Order, error) {
+	var order Order
+	err := r.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product").Preload("User").
+		First(&order, orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetInvoice returns the invoice issued when the order was paid
+func (r *InvoiceRepoImpl) GetInvoice(orderID int64) (*Invoice, error) {
+	var invoice Invoice
+	err := r.db.Where("order_id = ?", orderID).First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: order %d has no invoice", ErrInvoiceNotAvailable, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// IssueMissingInvoices numbers the orders that were paid for before invoices were issued with the
+// payment, oldest first
+func IssueMissingInvoices(db *gorm.DB) error {
+	var orderIDs []int64
+	err := db.Model(&Order{}).
+		Where("status IN ? AND id NOT IN (SELECT order_id FROM invoices)", invoicedStatuses).
+		Order("id ASC").
+		Pluck("id", &orderIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, orderID := range orderIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := issueInvoice(tx, orderID)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issueInvoice numbers the invoice of the order with the next sequence, an order that has one keeps it.
+// It runs in the transaction that moves the order to paid
+func issueInvoice(tx *gorm.DB, orderID int64) (*Invoice, error) {
+	var invoice Invoice
+	err := tx.Where("order_id = ?", orderID).Limit(1).Find(&invoice).Error
+	if err != nil {
+		return nil, err
+	}
+	if invoice.ID != 0 {
+		return &invoice, nil
+	}
+
+	sequence, err := nextInvoiceSequence(tx)
+	if err != nil {
+		return nil, err
+	}
+	invoice = Invoice{
+		OrderID:  orderID,
+		Sequence: sequence,
+		Number:   InvoiceNumber(sequence),
+		IssuedAt: time.Now(),
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// nextInvoiceSequence takes the next number off the counter, the update locks the row until the
+// transaction ends so numbers are neither skipped nor given twice
+func nextInvoiceSequence(tx *gorm.DB) (int64, error) {
+	counter := InvoiceCounter{ID: invoiceCounterID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&InvoiceCounter{}).Where("id = ?", invoiceCounterID).
+		Update("last", gorm.Expr("last + 1")).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.First(&counter, invoiceCounterID).Error; err != nil {
+		return 0, err
+	}
+	return counter.Last, nil
+}
@@ -7,6 +7,8 @@ import (
 	internal "e-cart/app/internal"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // UserRepo is an autogenerated mock type for the UserRepo type
@@ -50,6 +52,24 @@ func (_m *UserRepo) AddOrUpdateFavorite(userID int64, args dto.UserFavoriteBrand
 	return r0
 }
 
+// ChangePassword provides a mock function with given fields: userID, hashedPwd
+func (_m *UserRepo) ChangePassword(userID int64, hashedPwd string) error {
+	ret := _m.Called(userID, hashedPwd)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, string) error); ok {
+		r0 = rf(userID, hashedPwd)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // CheckProductInCart provides a mock function with given fields: userID, productID
 func (_m *UserRepo) CheckProductInCart(userID int64, productID int64) (*internal.Cart, error) {
 	ret := _m.Called(userID, productID)
@@ -98,48 +118,149 @@ func (_m *UserRepo) ClearCart(userID int64) error {
 	return r0
 }
 
-// CreateOrder provides a mock function with given fields: userID, totalAmount, cartItems
-func (_m *UserRepo) CreateOrder(userID int64, totalAmount float64, cartItems []internal.Cart) (*internal.Order, []internal.OrderItem, error) {
-	ret := _m.Called(userID, totalAmount, cartItems)
+// CountCouponRedemptions provides a mock function with given fields: couponID, userID
+func (_m *UserRepo) CountCouponRedemptions(couponID int64, userID int64) (int64, error) {
+	ret := _m.Called(couponID, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountCouponRedemptions")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64) (int64, error)); ok {
+		return rf(couponID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64) int64); ok {
+		r0 = rf(couponID, userID)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64) error); ok {
+		r1 = rf(couponID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateOrder provides a mock function with given fields: newOrder, cartItems, tax
+func (_m *UserRepo) CreateOrder(newOrder *internal.Order, cartItems []internal.Cart, tax *internal.OrderTax) ([]internal.OrderItem, error) {
+	ret := _m.Called(newOrder, cartItems, tax)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateOrder")
 	}
 
-	var r0 *internal.Order
-	var r1 []internal.OrderItem
-	var r2 error
-	if rf, ok := ret.Get(0).(func(int64, float64, []internal.Cart) (*internal.Order, []internal.OrderItem, error)); ok {
-		return rf(userID, totalAmount, cartItems)
+	var r0 []internal.OrderItem
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*internal.Order, []internal.Cart, *internal.OrderTax) ([]internal.OrderItem, error)); ok {
+		return rf(newOrder, cartItems, tax)
 	}
-	if rf, ok := ret.Get(0).(func(int64, float64, []internal.Cart) *internal.Order); ok {
-		r0 = rf(userID, totalAmount, cartItems)
+	if rf, ok := ret.Get(0).(func(*internal.Order, []internal.Cart, *internal.OrderTax) []internal.OrderItem); ok {
+		r0 = rf(newOrder, cartItems, tax)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).(*internal.Order)
+			r0 = ret.Get(0).([]internal.OrderItem)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(int64, float64, []internal.Cart) []internal.OrderItem); ok {
-		r1 = rf(userID, totalAmount, cartItems)
+	if rf, ok := ret.Get(1).(func(*internal.Order, []internal.Cart, *internal.OrderTax) error); ok {
+		r1 = rf(newOrder, cartItems, tax)
 	} else {
-		if ret.Get(1) != nil {
-			r1 = ret.Get(1).([]internal.OrderItem)
-		}
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteAllTokens provides a mock function with given fields: userID
+func (_m *UserRepo) DeleteAllTokens(userID int64) error {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAllTokens")
 	}
 
-	if rf, ok := ret.Get(2).(func(int64, float64, []internal.Cart) error); ok {
-		r2 = rf(userID, totalAmount, cartItems)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(userID)
 	} else {
-		r2 = ret.Error(2)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1, r2
+	return r0
 }
 
-// FetchCartItems provides a mock function with given fields: userID, cartID
-func (_m *UserRepo) FetchCartItems(userID int64, cartID int64) ([]internal.Cart, error) {
-	ret := _m.Called(userID, cartID)
+// DeleteExpiredReservations provides a mock function with given fields:
+func (_m *UserRepo) DeleteExpiredReservations() (int64, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteExpiredReservations")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (int64, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteToken provides a mock function with given fields: userID, token
+func (_m *UserRepo) DeleteToken(userID int64, token string) error {
+	ret := _m.Called(userID, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, string) error); ok {
+		r0 = rf(userID, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExtendReservations provides a mock function with given fields: userID
+func (_m *UserRepo) ExtendReservations(userID int64) error {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ExtendReservations")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// FetchCartItems provides a mock function with given fields: userID, productIDs
+func (_m *UserRepo) FetchCartItems(userID int64, productIDs []int64) ([]internal.Cart, error) {
+	ret := _m.Called(userID, productIDs)
 
 	if len(ret) == 0 {
 		panic("no return value specified for FetchCartItems")
@@ -147,19 +268,49 @@ func (_m *UserRepo) FetchCartItems(userID int64, cartID int64) ([]internal.Cart,
 
 	var r0 []internal.Cart
 	var r1 error
-	if rf, ok := ret.Get(0).(func(int64, int64) ([]internal.Cart, error)); ok {
-		return rf(userID, cartID)
+	if rf, ok := ret.Get(0).(func(int64, []int64) ([]internal.Cart, error)); ok {
+		return rf(userID, productIDs)
 	}
-	if rf, ok := ret.Get(0).(func(int64, int64) []internal.Cart); ok {
-		r0 = rf(userID, cartID)
+	if rf, ok := ret.Get(0).(func(int64, []int64) []internal.Cart); ok {
+		r0 = rf(userID, productIDs)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]internal.Cart)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func(int64, int64) error); ok {
-		r1 = rf(userID, cartID)
+	if rf, ok := ret.Get(1).(func(int64, []int64) error); ok {
+		r1 = rf(userID, productIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetActiveShippingZones provides a mock function with given fields:
+func (_m *UserRepo) GetActiveShippingZones() ([]internal.ShippingZone, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetActiveShippingZones")
+	}
+
+	var r0 []internal.ShippingZone
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]internal.ShippingZone, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []internal.ShippingZone); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]internal.ShippingZone)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -197,6 +348,36 @@ func (_m *UserRepo) GetBrandsByIDs(brandIDs []int64) ([]internal.Brand, error) {
 	return r0, r1
 }
 
+// GetCartCoupon provides a mock function with given fields: userID
+func (_m *UserRepo) GetCartCoupon(userID int64) (*internal.Coupon, error) {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCartCoupon")
+	}
+
+	var r0 *internal.Coupon
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*internal.Coupon, error)); ok {
+		return rf(userID)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *internal.Coupon); ok {
+		r0 = rf(userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Coupon)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetCartWithProductDetails provides a mock function with given fields: userID, productID
 func (_m *UserRepo) GetCartWithProductDetails(userID int64, productID int64) (*internal.Cart, error) {
 	ret := _m.Called(userID, productID)
@@ -227,6 +408,75 @@ func (_m *UserRepo) GetCartWithProductDetails(userID int64, productID int64) (*i
 	return r0, r1
 }
 
+// GetCouponByCode provides a mock function with given fields: code
+func (_m *UserRepo) GetCouponByCode(code string) (*internal.Coupon, error) {
+	ret := _m.Called(code)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCouponByCode")
+	}
+
+	var r0 *internal.Coupon
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*internal.Coupon, error)); ok {
+		return rf(code)
+	}
+	if rf, ok := ret.Get(0).(func(string) *internal.Coupon); ok {
+		r0 = rf(code)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Coupon)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(code)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDefaultAddresses provides a mock function with given fields: userID
+func (_m *UserRepo) GetDefaultAddresses(userID int64) (*internal.Address, *internal.Address, error) {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDefaultAddresses")
+	}
+
+	var r0 *internal.Address
+	var r1 *internal.Address
+	var r2 error
+	if rf, ok := ret.Get(0).(func(int64) (*internal.Address, *internal.Address, error)); ok {
+		return rf(userID)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *internal.Address); ok {
+		r0 = rf(userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Address)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) *internal.Address); ok {
+		r1 = rf(userID)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*internal.Address)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(int64) error); ok {
+		r2 = rf(userID)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetFavoriteBrandIDs provides a mock function with given fields: userID
 func (_m *UserRepo) GetFavoriteBrandIDs(userID int64) ([]int64, error) {
 	ret := _m.Called(userID)
@@ -317,6 +567,96 @@ func (_m *UserRepo) GetProductDetails(productID int64, categoryID int64) (*inter
 	return r0, r1
 }
 
+// GetRefreshToken provides a mock function with given fields: tokenHash
+func (_m *UserRepo) GetRefreshToken(tokenHash string) (*internal.RefreshToken, error) {
+	ret := _m.Called(tokenHash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRefreshToken")
+	}
+
+	var r0 *internal.RefreshToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*internal.RefreshToken, error)); ok {
+		return rf(tokenHash)
+	}
+	if rf, ok := ret.Get(0).(func(string) *internal.RefreshToken); ok {
+		r0 = rf(tokenHash)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.RefreshToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(tokenHash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTaxClasses provides a mock function with given fields:
+func (_m *UserRepo) GetTaxClasses() ([]internal.TaxClass, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTaxClasses")
+	}
+
+	var r0 []internal.TaxClass
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]internal.TaxClass, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []internal.TaxClass); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]internal.TaxClass)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUserAddress provides a mock function with given fields: userID, addressID
+func (_m *UserRepo) GetUserAddress(userID int64, addressID int64) (*internal.Address, error) {
+	ret := _m.Called(userID, addressID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserAddress")
+	}
+
+	var r0 *internal.Address
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64) (*internal.Address, error)); ok {
+		return rf(userID, addressID)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64) *internal.Address); ok {
+		r0 = rf(userID, addressID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Address)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64) error); ok {
+		r1 = rf(userID, addressID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserByID provides a mock function with given fields: userID
 func (_m *UserRepo) GetUserByID(userID int64) (*internal.Userdetail, error) {
 	ret := _m.Called(userID)
@@ -377,6 +717,64 @@ func (_m *UserRepo) GetUserByUsername(username string) (*internal.Userdetail, er
 	return r0, r1
 }
 
+// GetUserDetailByID provides a mock function with given fields: userId
+func (_m *UserRepo) GetUserDetailByID(userId int64) (*internal.Userdetail, error) {
+	ret := _m.Called(userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserDetailByID")
+	}
+
+	var r0 *internal.Userdetail
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (*internal.Userdetail, error)); ok {
+		return rf(userId)
+	}
+	if rf, ok := ret.Get(0).(func(int64) *internal.Userdetail); ok {
+		r0 = rf(userId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Userdetail)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsTokenActive provides a mock function with given fields: userID, token
+func (_m *UserRepo) IsTokenActive(userID int64, token string) (bool, error) {
+	ret := _m.Called(userID, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IsTokenActive")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, string) (bool, error)); ok {
+		return rf(userID, token)
+	}
+	if rf, ok := ret.Get(0).(func(int64, string) bool); ok {
+		r0 = rf(userID, token)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, string) error); ok {
+		r1 = rf(userID, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // IsUserActive provides a mock function with given fields: userID
 func (_m *UserRepo) IsUserActive(userID int64) (bool, error) {
 	ret := _m.Called(userID)
@@ -405,6 +803,168 @@ func (_m *UserRepo) IsUserActive(userID int64) (bool, error) {
 	return r0, r1
 }
 
+// RedeemCoupon provides a mock function with given fields: order, orderItems, couponID, discount
+func (_m *UserRepo) RedeemCoupon(order *internal.Order, orderItems []internal.OrderItem, couponID int64, discount *internal.CouponDiscount) error {
+	ret := _m.Called(order, orderItems, couponID, discount)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RedeemCoupon")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*internal.Order, []internal.OrderItem, int64, *internal.CouponDiscount) error); ok {
+		r0 = rf(order, orderItems, couponID, discount)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseReservations provides a mock function with given fields: userID, brandIDs, reason
+func (_m *UserRepo) ReleaseReservations(userID int64, brandIDs []int64, reason string) error {
+	ret := _m.Called(userID, brandIDs, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReleaseReservations")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, []int64, string) error); ok {
+		r0 = rf(userID, brandIDs, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveCartCoupon provides a mock function with given fields: userID
+func (_m *UserRepo) RemoveCartCoupon(userID int64) error {
+	ret := _m.Called(userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveCartCoupon")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64) error); ok {
+		r0 = rf(userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveCartItem provides a mock function with given fields: userID, productID
+func (_m *UserRepo) RemoveCartItem(userID int64, productID int64) error {
+	ret := _m.Called(userID, productID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveCartItem")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, int64) error); ok {
+		r0 = rf(userID, productID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReserveStock provides a mock function with given fields: userID, brandID, quantity
+func (_m *UserRepo) ReserveStock(userID int64, brandID int64, quantity int64) error {
+	ret := _m.Called(userID, brandID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReserveStock")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, int64, int64) error); ok {
+		r0 = rf(userID, brandID, quantity)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRefreshTokenFamily provides a mock function with given fields: familyID
+func (_m *UserRepo) RevokeRefreshTokenFamily(familyID string) error {
+	ret := _m.Called(familyID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeRefreshTokenFamily")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(familyID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateRefreshToken provides a mock function with given fields: current, newTokenHash, expiry
+func (_m *UserRepo) RotateRefreshToken(current *internal.RefreshToken, newTokenHash string, expiry time.Time) error {
+	ret := _m.Called(current, newTokenHash, expiry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RotateRefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*internal.RefreshToken, string, time.Time) error); ok {
+		r0 = rf(current, newTokenHash, expiry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveRefreshToken provides a mock function with given fields: userID, familyID, tokenHash, expiry
+func (_m *UserRepo) SaveRefreshToken(userID int64, familyID string, tokenHash string, expiry time.Time) error {
+	ret := _m.Called(userID, familyID, tokenHash, expiry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveRefreshToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, string, string, time.Time) error); ok {
+		r0 = rf(userID, familyID, tokenHash, expiry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveToken provides a mock function with given fields: userId, token, expiry
+func (_m *UserRepo) SaveToken(userId int64, token string, expiry time.Time) error {
+	ret := _m.Called(userId, token, expiry)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, string, time.Time) error); ok {
+		r0 = rf(userId, token, expiry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SaveUserDetails provides a mock function with given fields: args
 func (_m *UserRepo) SaveUserDetails(args *dto.UserDetailSaveRequest) (int64, error) {
 	ret := _m.Called(args)
@@ -433,17 +993,83 @@ func (_m *UserRepo) SaveUserDetails(args *dto.UserDetailSaveRequest) (int64, err
 	return r0, r1
 }
 
-// UpdateCartOrderStatus provides a mock function with given fields: userID, orderID, cartID
-func (_m *UserRepo) UpdateCartOrderStatus(userID int64, orderID int64, cartID int64) error {
-	ret := _m.Called(userID, orderID, cartID)
+// SetCartCoupon provides a mock function with given fields: userID, couponID
+func (_m *UserRepo) SetCartCoupon(userID int64, couponID int64) error {
+	ret := _m.Called(userID, couponID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCartCoupon")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, int64) error); ok {
+		r0 = rf(userID, couponID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Transaction provides a mock function with given fields: fn
+func (_m *UserRepo) Transaction(fn func(internal.UserRepo) error) error {
+	ret := _m.Called(fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Transaction")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(func(internal.UserRepo) error) error); ok {
+		r0 = rf(fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateCartItemQuantity provides a mock function with given fields: userID, productID, quantity
+func (_m *UserRepo) UpdateCartItemQuantity(userID int64, productID int64, quantity int64) (*internal.Cart, error) {
+	ret := _m.Called(userID, productID, quantity)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateCartItemQuantity")
+	}
+
+	var r0 *internal.Cart
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64, int64) (*internal.Cart, error)); ok {
+		return rf(userID, productID, quantity)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64, int64) *internal.Cart); ok {
+		r0 = rf(userID, productID, quantity)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*internal.Cart)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64, int64) error); ok {
+		r1 = rf(userID, productID, quantity)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateCartOrderStatus provides a mock function with given fields: userID, orderID, cartIDs
+func (_m *UserRepo) UpdateCartOrderStatus(userID int64, orderID int64, cartIDs []int64) error {
+	ret := _m.Called(userID, orderID, cartIDs)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateCartOrderStatus")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(int64, int64, int64) error); ok {
-		r0 = rf(userID, orderID, cartID)
+	if rf, ok := ret.Get(0).(func(int64, int64, []int64) error); ok {
+		r0 = rf(userID, orderID, cartIDs)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -451,9 +1077,9 @@ func (_m *UserRepo) UpdateCartOrderStatus(userID int64, orderID int64, cartID in
 	return r0
 }
 
-// UpdateStockCount provides a mock function with given fields: orderItems
-func (_m *UserRepo) UpdateStockCount(orderItems []internal.OrderItem) ([]internal.Brand, error) {
-	ret := _m.Called(orderItems)
+// UpdateStockCount provides a mock function with given fields: userID, orderItems
+func (_m *UserRepo) UpdateStockCount(userID int64, orderItems []internal.OrderItem) ([]internal.Brand, error) {
+	ret := _m.Called(userID, orderItems)
 
 	if len(ret) == 0 {
 		panic("no return value specified for UpdateStockCount")
@@ -461,19 +1087,19 @@ func (_m *UserRepo) UpdateStockCount(orderItems []internal.OrderItem) ([]interna
 
 	var r0 []internal.Brand
 	var r1 error
-	if rf, ok := ret.Get(0).(func([]internal.OrderItem) ([]internal.Brand, error)); ok {
-		return rf(orderItems)
+	if rf, ok := ret.Get(0).(func(int64, []internal.OrderItem) ([]internal.Brand, error)); ok {
+		return rf(userID, orderItems)
 	}
-	if rf, ok := ret.Get(0).(func([]internal.OrderItem) []internal.Brand); ok {
-		r0 = rf(orderItems)
+	if rf, ok := ret.Get(0).(func(int64, []internal.OrderItem) []internal.Brand); ok {
+		r0 = rf(userID, orderItems)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]internal.Brand)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func([]internal.OrderItem) error); ok {
-		r1 = rf(orderItems)
+	if rf, ok := ret.Get(1).(func(int64, []internal.OrderItem) error); ok {
+		r1 = rf(userID, orderItems)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Order statuses, an order starts as pending and moves forward through the lifecycle
+const (
+	OrderStatusPending   = "pending"
+	OrderStatusPaid      = "paid"
+	OrderStatusPacked    = "packed"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// Roles of whoever moved an order to a new status
+const (
+	ActorRoleUser   = "user"
+	ActorRoleAdmin  = "admin"
+	ActorRoleSystem = "system"
+)
+
+// orderStatusTransitions lists the statuses an order can move to from each status
+var orderStatusTransitions = map[string][]string{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusPacked, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusPacked:    {OrderStatusShipped, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+// ErrInvalidStatusTransition is returned when an order cannot move to the requested status
+var ErrInvalidStatusTransition = errors.New("invalid order status transition")
+
+// IsValidOrderStatus checks the status is one of the known order statuses
+func IsValidOrderStatus(status string) bool {
+	switch status {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusPacked, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// CanTransitionOrderStatus checks an order in status from can be moved to status to
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, next := range orderStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderStatusHistory records every status change of an order with who made it
+type OrderStatusHistory struct {
+	ID         int64     `gorm:"primaryKey"`
+	OrderID    int64     `gorm:"column:order_id;index;not null"` // Foreign key to Order
+	FromStatus string    `gorm:"column:from_status"`
+	ToStatus   string    `gorm:"column:to_status;not null"`
+	ActorID    int64     `gorm:"column:actor_id"` // user or admin id, 0 for system
+	ActorRole  string    `gorm:"column:actor_role;not null"`
+	Note       string    `gorm:"column:note"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+type OrderRepo interface {
+	GetOrderByID(orderID int64) (*Order, error)
+	UpdateOrderStatus(orderID int64, status string, actorID int64, actorRole, note string) (*Order, error)
+	GetOrderStatusHistory(orderID int64) ([]OrderStatusHistory, error)
+}
+
+type OrderRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewOrderRepo(db *gorm.DB) OrderRepo {
+	return &OrderRepoImpl{
+		db: db,
+	}
+}
+
+func (r *OrderRepoImpl) GetOrderByID(orderID int64) (*Order, error) {
+	var order Order
+	err := r.db.Preload("Items").Preload("Items.Product").Preload("User").First(&order, orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdateOrderStatus moves the order to the new status, locking the order row so
+// concurrent updates cannot both pass the transition check
+func (r *OrderRepoImpl) UpdateOrderStatus(orderID int64, status string, actorID int64, actorRole, note string) (*Order, error) {
+	var order Order
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			return err
+		}
+		return changeOrderStatus(tx, &order, status, actorID, actorRole, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func (r *OrderRepoImpl) GetOrderStatusHistory(orderID int64) ([]OrderStatusHistory, error) {
+	var history []OrderStatusHistory
+	if err := r.db.Where("order_id = ?", orderID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// changeOrderStatus validates the transition, updates the order and records the history
+// using the given transaction, the caller is expected to hold a lock on the order row
+func changeOrderStatus(tx *gorm.DB, order *Order, status string, actorID int64, actorRole, note string) error {
+	if !CanTransitionOrderStatus(order.Status, status) {
+		return fmt.Errorf("%w: cannot move order %d from %s to %s", ErrInvalidStatusTransition, order.ID, order.Status, status)
+	}
+
+	history := OrderStatusHistory{
+		OrderID:    order.ID,
+		FromStatus: order.Status,
+		ToStatus:   status,
+		ActorID:    actorID,
+		ActorRole:  actorRole,
+		Note:       note,
+	}
+
+	if err := tx.Model(order).Update("status", status).Error; err != nil {
+		return err
+	}
+	order.Status = status
+
+	return tx.Create(&history).Error
+}
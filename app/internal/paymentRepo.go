@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Payment statuses
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCapturing = "capturing" // claimed by a webhook while the provider captures it
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+)
+
+// staleCaptureAfter is how long a capture is left to finish before another webhook may take it over,
+// so a payment is not stuck when the process died while capturing
+const staleCaptureAfter = 5 * time.Minute
+
+// ErrPaymentAlreadyFinal is returned when a payment that already succeeded or failed is changed again
+var ErrPaymentAlreadyFinal = errors.New("payment is already final")
+
+// ErrCaptureInProgress is returned when a payment is confirmed while another webhook is capturing it
+var ErrCaptureInProgress = errors.New("payment capture in progress")
+
+// Payment is an attempt to pay for an order at a payment provider
+type Payment struct {
+	ID            int64      `gorm:"primaryKey"`
+	OrderID       int64      `gorm:"column:order_id;index;not null"` // Foreign key to Order
+	Provider      string     `gorm:"column:provider;not null"`
+	IntentID      string     `gorm:"column:intent_id;uniqueIndex;not null"` // id of the payment at the provider
+	Amount        float64    `gorm:"column:amount;not null"`
+	Currency      string     `gorm:"column:currency;not null"`
+	Status        string     `gorm:"column:status;not null;default:pending"` // one of the PaymentStatus constants
+	FailureReason string     `gorm:"column:failure_reason"`
+	CapturedAt    *time.Time `gorm:"column:captured_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// PaymentAttempt numbers the next payment of the order, a declined payment starts a new attempt while
+// asking again for a pending one stays on the same attempt
+func (order *Order) PaymentAttempt() int {
+	attempt := 1
+	for _, payment := range order.Payments {
+		if payment.Status == PaymentStatusFailed {
+			attempt++
+		}
+	}
+	return attempt
+}
+
+type PaymentRepo interface {
+	GetOrderByID(orderID int64) (*Order, error)
+	CreatePayment(payment *Payment) (*Payment, error)
+	GetPaymentByIntentID(intentID string) (*Payment, error)
+	StartCapture(intentID string) (*Payment, error)
+	ReleaseCapture(intentID string) error
+	MarkPaymentSucceeded(intentID string, note string) (*Payment, *Order, error)
+	MarkPaymentFailed(intentID, reason string) (*Payment, error)
+}
+
+type PaymentRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepo(db *gorm.DB) PaymentRepo {
+	return &PaymentRepoImpl{
+		db: db,
+	}
+}
+
+func (r *PaymentRepoImpl) GetOrderByID(orderID int64) (*Order, error) {
+	var order Order
+	if err := r.db.Preload("Payments").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// CreatePayment stores a new payment, the same intent asked for again gives back the stored payment
+func (r *PaymentRepoImpl) CreatePayment(payment *Payment) (*Payment, error) {
+	err := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "intent_id"}}, DoNothing: true}).
+		Create(payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.GetPaymentByIntentID(payment.IntentID)
+}
+
+func (r *PaymentRepoImpl) GetPaymentByIntentID(intentID string) (*Payment, error) {
+	var payment Payment
+	if err := r.db.Where("intent_id = ?", intentID).First(&payment).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// StartCapture claims a pending payment for capture, so a duplicate webhook does not capture it twice.
+// A payment that already succeeded is returned as it is, and one of an order that is no longer pending
+// is failed rather than charged. Only a payment returned as capturing is to be captured
+func (r *PaymentRepoImpl) StartCapture(intentID string) (*Payment, error) {
+	var payment Payment
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("intent_id = ?", intentID).First(&payment).Error
+		if err != nil {
+			return err
+		}
+
+		switch payment.Status {
+		case PaymentStatusSucceeded:
+			return nil
+		case PaymentStatusFailed:
+			return fmt.Errorf("%w: payment %s failed", ErrPaymentAlreadyFinal, intentID)
+		case PaymentStatusCapturing:
+			if time.Since(payment.UpdatedAt) < staleCaptureAfter {
+				return fmt.Errorf("%w: payment %s", ErrCaptureInProgress, intentID)
+			}
+		}
+
+		// an order cancelled while the customer was paying is not charged
+		var order Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"status": PaymentStatusCapturing}
+		if order.Status != OrderStatusPending {
+			updates = map[string]interface{}{
+				"status":         PaymentStatusFailed,
+				"failure_reason": fmt.Sprintf("order is %s", order.Status),
+			}
+		}
+		if err := tx.Model(&payment).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&payment, payment.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
+
+// ReleaseCapture puts a payment the provider did not capture back to pending, the next webhook tries again
+func (r *PaymentRepoImpl) ReleaseCapture(intentID string) error {
+	return r.db.Model(&Payment{}).
+		Where("intent_id = ? AND status = ?", intentID, PaymentStatusCapturing).
+		Update("status", PaymentStatusPending).Error
+}
+
+// MarkPaymentSucceeded records the captured payment, moves its order to paid as the system and
+// issues the invoice of the order. A payment that already succeeded is returned as it is, so repeated webhooks do no harm
+func (r *PaymentRepoImpl) MarkPaymentSucceeded(intentID string, note string) (*Payment, *Order, error) {
+	var payment Payment
+	var order Order
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("intent_id = ?", intentID).First(&payment).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+
+		switch payment.Status {
+		case PaymentStatusSucceeded:
+			return nil
+		case PaymentStatusFailed:
+			return fmt.Errorf("%w: payment %s failed", ErrPaymentAlreadyFinal, intentID)
+		}
+
+		now := time.Now()
+		err = tx.Model(&payment).Updates(map[string]interface{}{
+			"status":      PaymentStatusSucceeded,
+			"captured_at": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		payment.Status = PaymentStatusSucceeded
+		payment.CapturedAt = &now
+
+		if err := changeOrderStatus(tx, &order, OrderStatusPaid, 0, ActorRoleSystem, note); err != nil {
+			return err
+		}
+		_, err = issueInvoice(tx, order.ID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &payment, &order, nil
+}
+
+// MarkPaymentFailed records a declined payment, or a capture that was given back, the order stays
+// pending so it can be paid again
+func (r *PaymentRepoImpl) MarkPaymentFailed(intentID, reason string) (*Payment, error) {
+	var payment Payment
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("intent_id = ?", intentID).First(&payment).Error
+		if err != nil {
+			return err
+		}
+		if payment.Status != PaymentStatusPending && payment.Status != PaymentStatusCapturing {
+			return fmt.Errorf("%w: payment %s is %s", ErrPaymentAlreadyFinal, intentID, payment.Status)
+		}
+
+		err = tx.Model(&payment).Updates(map[string]interface{}{
+			"status":         PaymentStatusFailed,
+			"failure_reason": reason,
+		}).Error
+		if err != nil {
+			return err
+		}
+		payment.Status = PaymentStatusFailed
+		payment.FailureReason = reason
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &payment, nil
+}
@@ -3,8 +3,10 @@ package internal
 import (
 	"e-cart/app/dto"
 	"e-cart/app/models"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,17 +14,30 @@ import (
 
 type ProductRepo interface {
 	CreateAndUpsertProductDetail(args *dto.CreateCategoryDetailRequest) (*Category, error)
-	GetAllProducts() ([]Category, error)
+	GetAllProducts(args *dto.ListCategoriesRequest) ([]Category, int64, error)
 	GetCategoryByID(categoryID int64) (*Category, error)
 	GetCategoryByName(categoryName string) (*Category, error)
-	GetAllBrands() ([]Brand, error)
-	UpdateCategory(categoryID int64, newCategoryName string) error
-	UpdateBrand(brandID int64, newBrandName string, newPrice float64) error
-	GetBrandByID(id int64) (*Brand, error)
+	GetAllBrands(args *dto.ListBrandsRequest) ([]Brand, int64, error)
+	SearchBrands(args *dto.SearchProductRequest) ([]Brand, int64, error)
+	UpdateCategory(args *dto.UpdateCategory) (*Category, error)
+	UpdateBrand(args *dto.UpdateBrand, actorID int64) (*Brand, error)
+	GetBrandByID(id int64, includeDeleted bool) (*Brand, error)
+	SetCategoryDeleted(categoryID int64, deleted bool) error
+	SetBrandDeleted(brandID int64, deleted bool) error
 }
 
+// ErrDuplicateCategory is returned when a category name is already taken
+var ErrDuplicateCategory = errors.New("category already exists")
+
+// ErrDuplicateBrand is returned when a brand name and model are already taken in the category
+var ErrDuplicateBrand = errors.New("brand already exists")
+
 type ProductRepoImpl struct {
 	db *gorm.DB
+
+	// trigram caches whether the search can use pg_trgm, see trigramSearch
+	trigramMu sync.Mutex
+	trigram   *bool
 }
 
 func NewProductRepo(db *gorm.DB) ProductRepo {
@@ -36,6 +51,7 @@ type Category struct {
 	ID           int64      `gorm:"primaryKey"`
 	Categoryname string     `gorm:"column:categoryname;unique;not null"`
 	Description  string     `gorm:"column:description;not null"`
+	TaxClassID   *int64     `gorm:"column:tax_class_id;index"` // tax of the brands that have no class of their own
 	CreatedAt    time.Time  `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt    time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 	IsDeleted    bool       `gorm:"column:is_deleted;default:false"`
@@ -61,6 +77,62 @@ type Brand struct {
 	UpdatedAt        time.Time          `gorm:"column:updated_at;autoUpdateTime"`
 	IsDeleted        bool               `gorm:"column:is_deleted;default:false"`
 	DeletedAt        *time.Time         `gorm:"column:deleted_at"`
+	ReorderThreshold int64              `gorm:"column:reorder_threshold;default:0;not null"` // low on stock at or below this count
+	WeightGrams      int64              `gorm:"column:weight_grams;default:0;not null"`      // shipping weight of one unit
+	TaxClassID       *int64             `gorm:"column:tax_class_id;index"`                   // overrides the tax class of the category
+	ReservedStock    int64              `gorm:"-"`                                           // held by carts, only loaded by GetBrandByID
+}
+
+// AvailableStock is the stock on hand that is not reserved by any cart
+func (b *Brand) AvailableStock() int64 {
+	if b.StockCount < b.ReservedStock {
+		return 0
+	}
+	return b.StockCount - b.ReservedStock
+}
+
+// IsLowStock tells if the stock count is at or below the reorder threshold
+func (b *Brand) IsLowStock() bool {
+	return b.StockCount <= b.ReorderThreshold
+}
+
+// IsAvailable tells if the brand can still be bought, the category has to be loaded
+func (b *Brand) IsAvailable() bool {
+	return !b.IsDeleted && !b.Category.IsDeleted
+}
+
+// activeBrands keeps the brands that are not deleted and whose category is not deleted either
+func activeBrands(db *gorm.DB) *gorm.DB {
+	return db.Where("brands.is_deleted = ? AND brands.category_id IN (SELECT id FROM categories WHERE is_deleted = ?)", false, false)
+}
+
+// receiveCatalogStock records stock added through the catalog as a receipt
+func receiveCatalogStock(tx *gorm.DB, brandID, quantity int64) error {
+	if quantity == 0 {
+		return nil
+	}
+	return applyStockMovement(tx, &InventoryMovement{
+		BrandID:       brandID,
+		Type:          MovementReceipt,
+		Quantity:      quantity,
+		Reason:        "catalog upsert",
+		ReferenceType: MovementRefCatalog,
+	})
+}
+
+// findCategoryByName looks up a category by its name without regard to case
+func findCategoryByName(db *gorm.DB, name string, category *Category) error {
+	return db.Table("categories").
+		Where("LOWER(categoryname) = ?", strings.ToLower(name)).
+		First(category).Error
+}
+
+// findBrandByNameAndModel looks up a brand of the category by name and model without regard to case,
+// name and model together identify a product
+func findBrandByNameAndModel(db *gorm.DB, categoryID int64, name, model string, brand *Brand) error {
+	return db.Table("brands").
+		Where("category_id = ? AND LOWER(brandname) = ? AND LOWER(brandmodel) = ?", categoryID, strings.ToLower(name), strings.ToLower(model)).
+		First(brand).Error
 }
 
 // To add or update product in to the list
@@ -70,9 +142,7 @@ func (r *ProductRepoImpl) CreateAndUpsertProductDetail(args *dto.CreateCategoryD
 	normalizedCategoryName := strings.ToLower(args.CategoryName)
 
 	// Check if category exists (case-insensitive)
-	err := r.db.Table("categories").
-		Where("LOWER(categoryname) = ?", normalizedCategoryName).
-		First(&category).Error
+	err := findCategoryByName(r.db, normalizedCategoryName, &category)
 
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -101,35 +171,43 @@ func (r *ProductRepoImpl) CreateAndUpsertProductDetail(args *dto.CreateCategoryD
 		normalizedBrandName := strings.ToLower(b.BrandName)
 		normalizedModel := strings.ToLower(b.Model)
 
-		err := r.db.Table("brands").
-			Where("category_id = ? AND LOWER(brandname) = ? AND LOWER(brandmodel) = ?", category.ID, normalizedBrandName, normalizedModel).
-			First(&existingBrand).Error
+		err := findBrandByNameAndModel(r.db, category.ID, normalizedBrandName, normalizedModel, &existingBrand)
 
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				// INSERT new brand with model
+				// INSERT new brand with model, the stock comes in as a receipt
 				newBrand := Brand{
 					CategoryID:  category.ID,
 					BrandName:   strings.ToUpper(b.BrandName),
 					BrandModel:  b.Model,
 					Price:       b.Price,
-					StockCount:  b.StockCount,
 					ImageLink:   b.ImageLink,
 					ReleaseDate: time.Now(),
 				}
-				if err := r.db.Table("brands").Create(&newBrand).Error; err != nil {
+				err := r.db.Transaction(func(tx *gorm.DB) error {
+					if err := tx.Table("brands").Create(&newBrand).Error; err != nil {
+						return err
+					}
+					return receiveCatalogStock(tx, newBrand.ID, b.StockCount)
+				})
+				if err != nil {
 					return nil, err
 				}
 			} else {
 				return nil, err
 			}
 		} else {
-			// UPDATE existing brand (same name + model)
-			existingBrand.StockCount += b.StockCount
+			// UPDATE existing brand (same name + model), the stock is added as a receipt
 			existingBrand.Price = b.Price
 			existingBrand.ImageLink = b.ImageLink
 			existingBrand.UpdatedAt = time.Now()
-			if err := r.db.Table("brands").Save(&existingBrand).Error; err != nil {
+			err := r.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Table("brands").Omit("stockcount").Save(&existingBrand).Error; err != nil {
+					return err
+				}
+				return receiveCatalogStock(tx, existingBrand.ID, b.StockCount)
+			})
+			if err != nil {
 				return nil, err
 			}
 		}
@@ -146,19 +224,61 @@ func (r *ProductRepoImpl) CreateAndUpsertProductDetail(args *dto.CreateCategoryD
 	return &category, nil
 }
 
-func (r *ProductRepoImpl) GetAllProducts() ([]Category, error) {
+// categorySortColumns maps the sort query param to the column it orders by
+var categorySortColumns = map[string]string{
+	"name":       "categoryname",
+	"created_at": "created_at",
+}
+
+// brandSortColumns maps the sort query param to the column it orders by
+var brandSortColumns = map[string]string{
+	"price":        "price",
+	"release_date": "release_date",
+	"name":         "brandname",
+}
+
+// orderByClause builds the order by for a whitelisted sort column, id keeps the pages stable
+func orderByClause(columns map[string]string, sort, order string) string {
+	column, ok := columns[sort]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if order == "desc" {
+		direction = "DESC"
+	}
+	if column == "id" {
+		return "id " + direction
+	}
+	return column + " " + direction + ", id ASC"
+}
+
+// GetAllProducts returns a page of categories along with the total count
+func (r *ProductRepoImpl) GetAllProducts(args *dto.ListCategoriesRequest) ([]Category, int64, error) {
+	query := r.db.Model(&Category{})
+	if !args.IncludeDeleted {
+		query = query.Where("is_deleted = ?", false)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
 
-	// Query the products only
 	var products []Category
-	if err := r.db.Find(&products).Error; err != nil {
-		return nil, err
+	err := query.Order(orderByClause(categorySortColumns, args.Sort, args.Order)).
+		Offset(args.Offset()).
+		Limit(args.PageSize).
+		Find(&products).Error
+	if err != nil {
+		return nil, 0, err
 	}
-	return products, nil
+	return products, total, nil
 }
 
 func (r *ProductRepoImpl) GetCategoryByID(categoryID int64) (*Category, error) {
 	var category Category
-	if err := r.db.Preload("Brands").First(&category, categoryID).Error; err != nil {
+	if err := r.db.Preload("Brands", activeBrands).Where("is_deleted = ?", false).First(&category, categoryID).Error; err != nil {
 		return nil, err
 	}
 	return &category, nil
@@ -166,54 +286,250 @@ func (r *ProductRepoImpl) GetCategoryByID(categoryID int64) (*Category, error) {
 
 func (r *ProductRepoImpl) GetCategoryByName(categoryName string) (*Category, error) {
 	var category Category
-	if err := r.db.Preload("Brands").Where("categoryname = ?", categoryName).First(&category).Error; err != nil {
+	if err := r.db.Preload("Brands", activeBrands).Where("categoryname = ? AND is_deleted = ?", categoryName, false).First(&category).Error; err != nil {
 		return nil, err
 	}
 	return &category, nil
 }
 
-func (r *ProductRepoImpl) GetAllBrands() ([]Brand, error) {
+// GetAllBrands returns a page of brands matching the filters along with the total count
+func (r *ProductRepoImpl) GetAllBrands(args *dto.ListBrandsRequest) ([]Brand, int64, error) {
+	query := r.db.Model(&Brand{})
+
+	if !args.IncludeDeleted {
+		query = query.Scopes(activeBrands)
+	}
+	if args.CategoryID != 0 {
+		query = query.Where("category_id = ?", args.CategoryID)
+	}
+	if args.MinPrice != nil {
+		query = query.Where("price >= ?", *args.MinPrice)
+	}
+	if args.MaxPrice != nil {
+		query = query.Where("price <= ?", *args.MaxPrice)
+	}
+	if args.InStock {
+		query = query.Where("stockcount > ?", 0)
+	}
+	if args.Model != "" {
+		query = query.Where("LOWER(brandmodel) LIKE ?", "%"+strings.ToLower(args.Model)+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
 	var brand []Brand
+	err := query.Preload("Category").
+		Order(orderByClause(brandSortColumns, args.Sort, args.Order)).
+		Offset(args.Offset()).
+		Limit(args.PageSize).
+		Find(&brand).Error
+	if err != nil {
+		return nil, 0, err
+	}
 
-	if err := r.db.Preload("Category").Find(&brand).Error; err != nil {
-		return nil, err
+	return brand, total, nil
+}
+
+// SearchBrands returns a page of brands matching the search query, best match first
+func (r *ProductRepoImpl) SearchBrands(args *dto.SearchProductRequest) ([]Brand, int64, error) {
+	terms := searchTerms(args.Query)
+	if len(terms) == 0 {
+		return []Brand{}, 0, nil
 	}
 
-	return brand, nil
+	if r.db.Dialector.Name() == "postgres" {
+		return r.searchBrandsPostgres(terms, args.Offset(), args.PageSize)
+	}
+	return r.searchBrandsInMemory(terms, args.Offset(), args.PageSize)
 }
 
-// UpdateCategory updates the name of a category by its ID using GORM
-func (r *ProductRepoImpl) UpdateCategory(categoryID int64, newCategoryName string) error {
+// UpdateCategory applies the given fields to the category, the name is normalized like on create
+func (r *ProductRepoImpl) UpdateCategory(args *dto.UpdateCategory) (*Category, error) {
 	category := &Category{}
-	if err := r.db.First(category, categoryID).Error; err != nil {
-		return err
+	if err := r.db.First(category, args.CategoryID).Error; err != nil {
+		return nil, err
 	}
 
-	category.Categoryname = newCategoryName
-	if err := r.db.Save(category).Error; err != nil {
-		return err
+	updates := map[string]interface{}{}
+	if args.CategoryName != nil {
+		normalizedCategoryName := strings.ToLower(strings.TrimSpace(*args.CategoryName))
+
+		// names are unique without regard to case
+		var count int64
+		err := r.db.Model(&Category{}).
+			Where("LOWER(categoryname) = ? AND id <> ?", normalizedCategoryName, category.ID).
+			Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("category name '%s': %w", *args.CategoryName, ErrDuplicateCategory)
+		}
+		updates["categoryname"] = strings.Title(normalizedCategoryName)
+	}
+	if args.Description != nil {
+		updates["description"] = *args.Description
 	}
-	return nil
+	if args.TaxClassID != nil {
+		if err := checkTaxClass(r.db, *args.TaxClassID); err != nil {
+			return nil, err
+		}
+		updates["tax_class_id"] = optionalID(*args.TaxClassID)
+	}
+
+	if err := r.db.Model(category).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return category, nil
 }
 
-// UpdateBrand updates the name of a brand by its ID using GORM
-func (r *ProductRepoImpl) UpdateBrand(brandID int64, newBrandName string, newPrice float64) error {
+// UpdateBrand applies the given fields to the brand, the name is normalized like on create.
+// A new stock count is recorded as an adjustment by the actor
+func (r *ProductRepoImpl) UpdateBrand(args *dto.UpdateBrand, actorID int64) (*Brand, error) {
 	brand := &Brand{}
-	if err := r.db.First(brand, brandID).Error; err != nil {
-		return err
+	if err := r.db.First(brand, args.BrandId).Error; err != nil {
+		return nil, err
 	}
 
-	brand.BrandName = newBrandName
-	if err := r.db.Save(brand).Error; err != nil {
-		return err
+	updates := map[string]interface{}{}
+	if args.BrandName != nil {
+		updates["brandname"] = strings.ToUpper(strings.TrimSpace(*args.BrandName))
+	}
+	if args.CategoryID != nil {
+		updates["category_id"] = *args.CategoryID
+	}
+	if args.Price != nil {
+		updates["price"] = *args.Price
+	}
+	if args.BrandDescription != nil {
+		updates["brand_description"] = *args.BrandDescription
+	}
+	if args.Model != nil {
+		updates["brandmodel"] = *args.Model
+	}
+	if args.ImageLink != nil {
+		updates["image_link"] = *args.ImageLink
+	}
+	if args.GalleryLinks != nil {
+		updates["gallery_links"] = models.StringArray(*args.GalleryLinks)
+	}
+	if args.ReleaseDate != nil {
+		updates["release_date"] = *args.ReleaseDate
+	}
+	if args.ReorderThreshold != nil {
+		updates["reorder_threshold"] = *args.ReorderThreshold
+	}
+	if args.WeightGrams != nil {
+		updates["weight_grams"] = *args.WeightGrams
+	}
+	if args.TaxClassID != nil {
+		if err := checkTaxClass(r.db, *args.TaxClassID); err != nil {
+			return nil, err
+		}
+		updates["tax_class_id"] = optionalID(*args.TaxClassID)
+	}
+
+	// the import finds brands by name and model, so they stay unique in the category
+	if args.BrandName != nil || args.Model != nil || args.CategoryID != nil {
+		name, model, categoryID := brand.BrandName, brand.BrandModel, brand.CategoryID
+		if args.BrandName != nil {
+			name = updates["brandname"].(string)
+		}
+		if args.Model != nil {
+			model = *args.Model
+		}
+		if args.CategoryID != nil {
+			categoryID = *args.CategoryID
+		}
+
+		var count int64
+		err := r.db.Model(&Brand{}).
+			Where("category_id = ? AND LOWER(brandname) = ? AND LOWER(brandmodel) = ? AND is_deleted = ? AND id <> ?",
+				categoryID, strings.ToLower(name), strings.ToLower(model), false, brand.ID).
+			Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("brand '%s %s': %w", name, model, ErrDuplicateBrand)
+		}
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(brand).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if args.StockCount != nil && *args.StockCount != brand.StockCount {
+			return applyStockMovement(tx, &InventoryMovement{
+				BrandID:       brand.ID,
+				Type:          MovementAdjustment,
+				Quantity:      *args.StockCount - brand.StockCount,
+				Reason:        "brand update",
+				ReferenceType: MovementRefAdmin,
+				ActorID:       actorID,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	// reload so the response has the category of the brand
+	return r.GetBrandByID(brand.ID, true)
 }
 
-func (r *ProductRepoImpl) GetBrandByID(id int64) (*Brand, error) {
+// GetBrandByID returns the brand with its category, deleted brands only when asked for
+func (r *ProductRepoImpl) GetBrandByID(id int64, includeDeleted bool) (*Brand, error) {
 	var brand Brand
-	if err := r.db.Preload("Category").First(&brand, "id = ?", id).Error; err != nil {
+	query := r.db.Preload("Category")
+	if !includeDeleted {
+		query = query.Scopes(activeBrands)
+	}
+	if err := query.First(&brand, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
+
+	reserved, err := reservedStock(r.db, brand.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	brand.ReservedStock = reserved
 	return &brand, nil
 }
+
+// SetCategoryDeleted soft deletes or restores a category, its brands are hidden along with it
+func (r *ProductRepoImpl) SetCategoryDeleted(categoryID int64, deleted bool) error {
+	category := &Category{}
+	if err := r.db.First(category, categoryID).Error; err != nil {
+		return err
+	}
+	return r.db.Model(category).Updates(softDeleteUpdates(deleted)).Error
+}
+
+// SetBrandDeleted soft deletes or restores a brand
+func (r *ProductRepoImpl) SetBrandDeleted(brandID int64, deleted bool) error {
+	brand := &Brand{}
+	if err := r.db.First(brand, brandID).Error; err != nil {
+		return err
+	}
+	return r.db.Model(brand).Updates(softDeleteUpdates(deleted)).Error
+}
+
+// softDeleteUpdates sets the deleted flag along with the time it was deleted
+func softDeleteUpdates(deleted bool) map[string]interface{} {
+	var deletedAt *time.Time
+	if deleted {
+		now := time.Now()
+		deletedAt = &now
+	}
+	return map[string]interface{}{
+		"is_deleted": deleted,
+		"deleted_at": deletedAt,
+	}
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Refund statuses
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+)
+
+// ErrRefundExceedsOrder is returned when more of an order item is refunded than is left of it
+var ErrRefundExceedsOrder = errors.New("refund exceeds the ordered quantity")
+
+// Refund is money given back for some or all items of an order, against the payment of the order
+type Refund struct {
+	ID               int64        `gorm:"primaryKey"`
+	OrderID          int64        `gorm:"column:order_id;index;not null"`   // Foreign key to Order
+	PaymentID        int64        `gorm:"column:payment_id;index;not null"` // Foreign key to Payment, the payment refunded
+	ProviderRefundID string       `gorm:"column:provider_refund_id"`
+	Amount           float64      `gorm:"column:amount;not null"`
+	Reason           string       `gorm:"column:reason"`
+	Status           string       `gorm:"column:status;not null;default:pending"` // one of the RefundStatus constants
+	ActorID          int64        `gorm:"column:actor_id"`
+	ActorRole        string       `gorm:"column:actor_role;not null"`
+	Items            []RefundItem `gorm:"foreignKey:RefundID"`
+	CreatedAt        time.Time    `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time    `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// RefundItem is the refunded quantity of a single order item
+type RefundItem struct {
+	ID          int64     `gorm:"primaryKey"`
+	RefundID    int64     `gorm:"column:refund_id;index;not null"`     // Foreign key to Refund
+	OrderItemID int64     `gorm:"column:order_item_id;index;not null"` // Foreign key to OrderItem
+	Quantity    int64     `gorm:"column:quantity;not null"`
+	Amount      float64   `gorm:"column:amount;not null"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+// RemainingQuantity is the quantity of the item that is not refunded yet
+func (item *OrderItem) RemainingQuantity() int64 {
+	return item.Quantity - item.RefundedQuantity
+}
+
+// RefundAmount is what quantity units of the item are refunded for, the price paid less their share of the
+// discount, with their share of tax that was added on top. Refunding the last units gives back the rest
+// of the line so the rounding adds up
+func (item *OrderItem) RefundAmount(quantity int64) float64 {
+	lineTotal := item.Price*float64(item.Quantity) - item.Discount
+	if !item.TaxInclusive {
+		lineTotal += item.TaxAmount
+	}
+	unitPrice := lineTotal / float64(item.Quantity)
+	if quantity >= item.RemainingQuantity() {
+		return roundAmount(lineTotal - roundAmount(unitPrice*float64(item.RefundedQuantity)))
+	}
+	return roundAmount(unitPrice * float64(quantity))
+}
+
+// SucceededPayment returns the payment that paid for the order, the payments have to be loaded
+func (order *Order) SucceededPayment() *Payment {
+	for i := range order.Payments {
+		if order.Payments[i].Status == PaymentStatusSucceeded {
+			return &order.Payments[i]
+		}
+	}
+	return nil
+}
+
+type RefundRepo interface {
+	Transaction(fn func(txRepo RefundRepo) error) error
+	LockOrder(orderID int64) (*Order, error)
+	ChangeOrderStatus(order *Order, status string, actorID int64, actorRole, note string) error
+	RestockOrder(order *Order, actorID int64, reason string) error
+	CreateRefund(order *Order, refund *Refund) error
+	LockRefund(refundID int64) (*Refund, error)
+	MarkRefundSucceeded(refund *Refund, providerRefundID string) error
+	// PendingRefunds lists the refunds created before the time that the provider has not accepted yet
+	PendingRefunds(createdBefore time.Time) ([]Refund, error)
+	GetPayment(paymentID int64) (*Payment, error)
+	// ReleaseCoupon gives back the coupon use of a cancelled or fully refunded order
+	ReleaseCoupon(orderID int64) error
+	// Shipments gives the shipment repo working in the same transaction
+	Shipments() ShipmentRepo
+}
+
+type RefundRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewRefundRepo(db *gorm.DB) RefundRepo {
+	return &RefundRepoImpl{
+		db: db,
+	}
+}
+
+// Transaction runs fn with a repo bound to a single transaction
+func (r *RefundRepoImpl) Transaction(fn func(txRepo RefundRepo) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&RefundRepoImpl{db: tx})
+	})
+}
+
+// LockOrder loads the order with its items and payments, locking the order row until the transaction ends
+func (r *RefundRepoImpl) LockOrder(orderID int64) (*Order, error) {
+	var order Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items").Preload("Items.Product").Preload("Payments").
+		First(&order, orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *RefundRepoImpl) ChangeOrderStatus(order *Order, status string, actorID int64, actorRole, note string) error {
+	return changeOrderStatus(r.db, order, status, actorID, actorRole, note)
+}
+
+func (r *RefundRepoImpl) Shipments() ShipmentRepo {
+	return &ShipmentRepoImpl{db: r.db}
+}
+
+// RestockOrder puts the stock of every item of an unpaid order back, nothing is refunded
+func (r *RefundRepoImpl) RestockOrder(order *Order, actorID int64, reason string) error {
+	for _, item := range order.Items {
+		if err := restockOrderItem(r.db, order.ID, item.ProductID, item.RemainingQuantity(), actorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateRefund stores the refund with its items, adds the refunded quantities to the order items
+// and the amount to the order, and puts the refunded stock back
+func (r *RefundRepoImpl) CreateRefund(order *Order, refund *Refund) error {
+	items := make(map[int64]*OrderItem, len(order.Items))
+	for i := range order.Items {
+		items[order.Items[i].ID] = &order.Items[i]
+	}
+
+	refund.OrderID = order.ID
+	refund.Status = RefundStatusPending
+	if err := r.db.Create(refund).Error; err != nil {
+		return err
+	}
+
+	for _, refundItem := range refund.Items {
+		item, ok := items[refundItem.OrderItemID]
+		if !ok {
+			return fmt.Errorf("%w: item %d is not part of order %d", gorm.ErrRecordNotFound, refundItem.OrderItemID, order.ID)
+		}
+
+		// the guard keeps two refunds from giving back the same units
+		result := r.db.Model(&OrderItem{}).
+			Where("id = ? AND refunded_quantity + ? <= quantity", item.ID, refundItem.Quantity).
+			Update("refunded_quantity", gorm.Expr("refunded_quantity + ?", refundItem.Quantity))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: item %d has %d left, %d asked", ErrRefundExceedsOrder, item.ID, item.RemainingQuantity(), refundItem.Quantity)
+		}
+		item.RefundedQuantity += refundItem.Quantity
+
+		err := restockOrderItem(r.db, order.ID, item.ProductID, refundItem.Quantity, refund.ActorID, fmt.Sprintf("refund %d", refund.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := r.db.Model(order).Update("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Amount)).Error
+	if err != nil {
+		return err
+	}
+	order.RefundedAmount += refund.Amount
+	return nil
+}
+
+// LockRefund loads the refund with its items, locking the refund row until the transaction ends
+func (r *RefundRepoImpl) LockRefund(refundID int64) (*Refund, error) {
+	var refund Refund
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&refund, refundID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *RefundRepoImpl) PendingRefunds(createdBefore time.Time) ([]Refund, error) {
+	var refunds []Refund
+	err := r.db.Where("status = ? AND created_at < ?", RefundStatusPending, createdBefore).
+		Order("id").Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *RefundRepoImpl) GetPayment(paymentID int64) (*Payment, error) {
+	var payment Payment
+	if err := r.db.First(&payment, paymentID).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// MarkRefundSucceeded records the id the provider gave the refund
+func (r *RefundRepoImpl) MarkRefundSucceeded(refund *Refund, providerRefundID string) error {
+	err := r.db.Model(refund).Updates(map[string]interface{}{
+		"status":             RefundStatusSucceeded,
+		"provider_refund_id": providerRefundID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	refund.Status = RefundStatusSucceeded
+	refund.ProviderRefundID = providerRefundID
+	return nil
+}
+
+func (r *RefundRepoImpl) ReleaseCoupon(orderID int64) error {
+	return releaseCouponRedemption(r.db, orderID)
+}
+
+// restockOrderItem records a return movement putting the quantity back on the brand
+func restockOrderItem(tx *gorm.DB, orderID, brandID, quantity, actorID int64, reason string) error {
+	if quantity <= 0 {
+		return nil
+	}
+	return applyStockMovement(tx, &InventoryMovement{
+		BrandID:       brandID,
+		Type:          MovementReturn,
+		Quantity:      quantity,
+		Reason:        reason,
+		ReferenceType: MovementRefOrder,
+		ReferenceID:   orderID,
+		ActorID:       actorID,
+	})
+}
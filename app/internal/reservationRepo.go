@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReservationTTL is how long stock added to a cart stays held without any cart activity
+var ReservationTTL = 15 * time.Minute
+
+// MovementRefCart marks reservation movements made from the cart
+const MovementRefCart = "cart"
+
+// StockReservation holds stock of a brand for the open cart line of a user until it expires
+type StockReservation struct {
+	ID        int64     `gorm:"primaryKey"`
+	UserID    int64     `gorm:"column:user_id;not null;uniqueIndex:idx_reservation_user_brand"`
+	BrandID   int64     `gorm:"column:brand_id;not null;uniqueIndex:idx_reservation_user_brand;index"`
+	Quantity  int64     `gorm:"column:quantity;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null;index"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// reservedStock is the stock of the brand held by unexpired reservations of users other than
+// excludeUserID, pass 0 to count every reservation
+func reservedStock(db *gorm.DB, brandID, excludeUserID int64) (int64, error) {
+	var reserved int64
+	err := db.Model(&StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("brand_id = ? AND user_id <> ? AND expires_at > ?", brandID, excludeUserID, time.Now()).
+		Scan(&reserved).Error
+	return reserved, err
+}
+
+// recordReservationMovement writes the change of a reservation to the ledger, the stock on hand is not touched
+func recordReservationMovement(tx *gorm.DB, brandID, userID, quantity int64, reason string) error {
+	if quantity == 0 {
+		return nil
+	}
+
+	var brand Brand
+	if err := tx.Select("id", "stockcount").First(&brand, brandID).Error; err != nil {
+		return err
+	}
+	return tx.Create(&InventoryMovement{
+		BrandID:       brandID,
+		Type:          MovementReservation,
+		Quantity:      quantity,
+		BalanceAfter:  brand.StockCount,
+		Reason:        reason,
+		ReferenceType: MovementRefCart,
+		ActorID:       userID,
+	}).Error
+}
+
+// ReserveStock holds quantity units of the brand for the user, replacing what was held before.
+// It fails with ErrInsufficientStock when the stock left after the reservations of others is not enough
+func (r *UserRepoImpl) ReserveStock(userID, brandID, quantity int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// the brand row is locked so two carts cannot take the same units
+		var brand Brand
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "stockcount").First(&brand, brandID).Error
+		if err != nil {
+			return err
+		}
+
+		reserved, err := reservedStock(tx, brandID, userID)
+		if err != nil {
+			return err
+		}
+		if brand.StockCount-reserved < quantity {
+			return ErrInsufficientStock
+		}
+
+		var reservation StockReservation
+		err = tx.Where("user_id = ? AND brand_id = ?", userID, brandID).Limit(1).Find(&reservation).Error
+		if err != nil {
+			return err
+		}
+
+		// an expired reservation that is not swept yet is released first, as the sweeper would,
+		// so the ledger keeps the hold and release of it
+		held := reservation.Quantity
+		if reservation.ID != 0 && !reservation.ExpiresAt.After(time.Now()) {
+			if err := recordReservationMovement(tx, brandID, userID, -reservation.Quantity, "expired"); err != nil {
+				return err
+			}
+			held = 0
+		}
+
+		reservation.UserID = userID
+		reservation.BrandID = brandID
+		reservation.Quantity = quantity
+		reservation.ExpiresAt = time.Now().Add(ReservationTTL)
+		if err := tx.Save(&reservation).Error; err != nil {
+			return err
+		}
+		return recordReservationMovement(tx, brandID, userID, quantity-held, "reserved")
+	})
+}
+
+// ReleaseReservations drops the reservations of the user for the given brands, every reservation when none are given
+func (r *UserRepoImpl) ReleaseReservations(userID int64, brandIDs []int64, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("user_id = ?", userID)
+		if len(brandIDs) > 0 {
+			query = query.Where("brand_id IN ?", brandIDs)
+		}
+
+		var reservations []StockReservation
+		if err := query.Find(&reservations).Error; err != nil {
+			return err
+		}
+		return deleteReservations(tx, reservations, reason)
+	})
+}
+
+// ExtendReservations moves the expiry of every reservation of the user forward, called on cart activity
+func (r *UserRepoImpl) ExtendReservations(userID int64) error {
+	return r.db.Model(&StockReservation{}).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Update("expires_at", time.Now().Add(ReservationTTL)).Error
+}
+
+// DeleteExpiredReservations removes the reservations that ran out and returns how many were removed
+func (r *UserRepoImpl) DeleteExpiredReservations() (int64, error) {
+	var count int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var reservations []StockReservation
+		if err := tx.Where("expires_at <= ?", time.Now()).Find(&reservations).Error; err != nil {
+			return err
+		}
+		count = int64(len(reservations))
+		return deleteReservations(tx, reservations, "expired")
+	})
+	return count, err
+}
+
+// deleteReservations deletes the reservations and records the release of what they still held
+func deleteReservations(tx *gorm.DB, reservations []StockReservation, reason string) error {
+	for _, reservation := range reservations {
+		if err := tx.Delete(&reservation).Error; err != nil {
+			return err
+		}
+		err := recordReservationMovement(tx, reservation.BrandID, reservation.UserID, -reservation.Quantity, reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Return statuses, a return is requested by the customer and moved on by an admin
+const (
+	ReturnStatusRequested = "requested"
+	ReturnStatusApproved  = "approved"
+	ReturnStatusRejected  = "rejected"
+	ReturnStatusReceived  = "received"
+	ReturnStatusRefunded  = "refunded"
+)
+
+// returnStatusTransitions lists the statuses a return can move to from each status
+var returnStatusTransitions = map[string][]string{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected},
+	ReturnStatusApproved:  {ReturnStatusReceived, ReturnStatusRejected},
+	ReturnStatusReceived:  {ReturnStatusRefunded},
+}
+
+// openReturnStatuses are the statuses of returns whose quantity is still on its way back
+var openReturnStatuses = []string{ReturnStatusRequested, ReturnStatusApproved, ReturnStatusReceived}
+
+// ErrInvalidReturnStatus is returned when a return cannot move to the requested status
+var ErrInvalidReturnStatus = errors.New("invalid return status transition")
+
+// ReturnRequest is a request of the customer to send back units of a delivered order item
+type ReturnRequest struct {
+	ID          int64      `gorm:"primaryKey"`
+	OrderID     int64      `gorm:"column:order_id;index;not null"`      // Foreign key to Order
+	OrderItemID int64      `gorm:"column:order_item_id;index;not null"` // Foreign key to OrderItem
+	OrderItem   OrderItem  `gorm:"foreignKey:OrderItemID"`
+	UserID      int64      `gorm:"column:user_id;index;not null"` // Foreign key to Userdetail
+	Quantity    int64      `gorm:"column:quantity;not null"`
+	Reason      string     `gorm:"column:reason;not null"`
+	Status      string     `gorm:"column:status;not null;default:requested"` // one of the ReturnStatus constants
+	AdminNote   string     `gorm:"column:admin_note"`
+	HandledBy   int64      `gorm:"column:handled_by"` // admin who last moved the return
+	RefundID    *int64     `gorm:"column:refund_id"`  // Foreign key to Refund, set once refunded
+	ReceivedAt  *time.Time `gorm:"column:received_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// CanTransitionReturnStatus checks a return in status from can be moved to status to
+func CanTransitionReturnStatus(from, to string) bool {
+	for _, next := range returnStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+type ReturnRepo interface {
+	Transaction(fn func(txRepo ReturnRepo) error) error
+	// Refunds gives the refund repo working in the same transaction
+	Refunds() RefundRepo
+	GetDeliveredAt(orderID int64) (*time.Time, error)
+	OpenReturnQuantity(orderItemID int64) (int64, error)
+	CreateReturn(ret *ReturnRequest) error
+	LockReturn(returnID int64) (*ReturnRequest, error)
+	UpdateReturnStatus(ret *ReturnRequest, status string, adminID int64, note string) error
+	SetReturnRefund(ret *ReturnRequest, refundID int64) error
+	ListReturns(args *dto.ListReturnsRequest) ([]ReturnRequest, int64, error)
+}
+
+type ReturnRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewReturnRepo(db *gorm.DB) ReturnRepo {
+	return &ReturnRepoImpl{
+		db: db,
+	}
+}
+
+// Transaction runs fn with a repo bound to a single transaction
+func (r *ReturnRepoImpl) Transaction(fn func(txRepo ReturnRepo) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&ReturnRepoImpl{db: tx})
+	})
+}
+
+func (r *ReturnRepoImpl) Refunds() RefundRepo {
+	return &RefundRepoImpl{db: r.db}
+}
+
+// GetDeliveredAt returns when the order was last delivered, nil when it never was
+func (r *ReturnRepoImpl) GetDeliveredAt(orderID int64) (*time.Time, error) {
+	var history OrderStatusHistory
+	err := r.db.Where("order_id = ? AND to_status = ?", orderID, OrderStatusDelivered).
+		Order("created_at DESC, id DESC").Limit(1).Find(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	if history.ID == 0 {
+		return nil, nil
+	}
+	return &history.CreatedAt, nil
+}
+
+// OpenReturnQuantity is the quantity of the item in returns that are not rejected or refunded yet
+func (r *ReturnRepoImpl) OpenReturnQuantity(orderItemID int64) (int64, error) {
+	var quantity int64
+	err := r.db.Model(&ReturnRequest{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("order_item_id = ? AND status IN ?", orderItemID, openReturnStatuses).
+		Scan(&quantity).Error
+	return quantity, err
+}
+
+func (r *ReturnRepoImpl) CreateReturn(ret *ReturnRequest) error {
+	ret.Status = ReturnStatusRequested
+	return r.db.Omit("OrderItem").Create(ret).Error
+}
+
+// LockReturn loads the return with its order item, locking the return row until the transaction ends
+func (r *ReturnRepoImpl) LockReturn(returnID int64) (*ReturnRequest, error) {
+	var ret ReturnRequest
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("OrderItem.Product").First(&ret, returnID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// UpdateReturnStatus moves the return to the new status, received returns get the time they arrived
+func (r *ReturnRepoImpl) UpdateReturnStatus(ret *ReturnRequest, status string, adminID int64, note string) error {
+	if !CanTransitionReturnStatus(ret.Status, status) {
+		return fmt.Errorf("%w: cannot move return %d from %s to %s", ErrInvalidReturnStatus, ret.ID, ret.Status, status)
+	}
+
+	updates := map[string]interface{}{
+		"status":     status,
+		"handled_by": adminID,
+	}
+	if note != "" {
+		updates["admin_note"] = note
+		ret.AdminNote = note
+	}
+	if status == ReturnStatusReceived {
+		now := time.Now()
+		updates["received_at"] = now
+		ret.ReceivedAt = &now
+	}
+
+	if err := r.db.Model(ret).Updates(updates).Error; err != nil {
+		return err
+	}
+	ret.Status = status
+	ret.HandledBy = adminID
+	return nil
+}
+
+func (r *ReturnRepoImpl) SetReturnRefund(ret *ReturnRequest, refundID int64) error {
+	if err := r.db.Model(ret).Update("refund_id", refundID).Error; err != nil {
+		return err
+	}
+	ret.RefundID = &refundID
+	return nil
+}
+
+// ListReturns returns a page of returns, newest first, limited to a user and status when they are set
+func (r *ReturnRepoImpl) ListReturns(args *dto.ListReturnsRequest) ([]ReturnRequest, int64, error) {
+	query := r.db.Model(&ReturnRequest{})
+	if args.UserID > 0 {
+		query = query.Where("user_id = ?", args.UserID)
+	}
+	if args.Status != "" {
+		query = query.Where("status = ?", args.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var returns []ReturnRequest
+	err := query.Preload("OrderItem.Product").
+		Order("created_at DESC, id DESC").
+		Offset(args.Offset()).Limit(args.PageSize).
+		Find(&returns).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return returns, total, nil
+}
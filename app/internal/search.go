@@ -0,0 +1,299 @@
+package internal
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How the brand search behaves depends on the database.
+//
+// On postgres, which the app runs on, the match and rank are done in SQL. A brand matches when every
+// term is a prefix of a word of its name, model, description or category. With the pg_trgm extension
+// it also matches when the whole query is close enough to its name and model, so a typo is forgiven
+// there only, and only for the query as a whole. The rank is the full text rank plus that similarity,
+// the field weights below do not apply and a hit in the category does not add to it. Without pg_trgm
+// typos are not forgiven at all, see MigrateBrandSearch.
+//
+// Any other database is only used by the tests. There the brands are scored in go, every term has to
+// match a word of a field exactly, as a prefix or with a few typos, and the best match of each term is
+// weighted by its field. Only the first maxInMemorySearchBrands brands are looked at.
+
+// brandSearchDocument is the text the postgres full text index is built on
+const brandSearchDocument = "to_tsvector('simple', coalesce(brands.brandname, '') || ' ' || coalesce(brands.brandmodel, '') || ' ' || coalesce(brands.brand_description, ''))"
+
+// brandSearchTitle is matched with trigrams to tolerate typos in the name and model
+const brandSearchTitle = "lower(coalesce(brands.brandname, '') || ' ' || coalesce(brands.brandmodel, ''))"
+
+// maxInMemorySearchBrands caps the brands the in memory search loads and scores
+const maxInMemorySearchBrands = 1000
+
+// minTrigramSimilarity is the word similarity a misspelled term needs to still match
+const minTrigramSimilarity = 0.4
+
+// Field weights used to rank the matches, a hit in the name counts more than one in the description
+const (
+	weightName        = 3.0
+	weightModel       = 2.0
+	weightCategory    = 1.5
+	weightDescription = 1.0
+)
+
+// Match scores of a single search term against a word
+const (
+	scoreExact  = 1.0
+	scorePrefix = 0.8
+	scoreTypo   = 0.5
+)
+
+// MigrateBrandSearch creates the full text and trigram indexes used by the search on postgres,
+// other databases need nothing. The pg_trgm extension needs more
+// rights than the app user usually has, so it is not created here. It is a one time step for
+// the database owner, run once per database:
+//
+//	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+//
+// Without it the trigram index is skipped and the search ranks on full text alone
+func MigrateBrandSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_brands_search ON brands USING GIN (" + brandSearchDocument + ")",
+	}
+	trigram, err := hasTrigramExtension(db)
+	if err != nil {
+		return err
+	}
+	if trigram {
+		statements = append(statements,
+			"CREATE INDEX IF NOT EXISTS idx_brands_search_trgm ON brands USING GIN (("+brandSearchTitle+") gin_trgm_ops)")
+	} else {
+		log.Println("WARNING: the pg_trgm extension is not installed, the brand search will not forgive typos until it is created")
+	}
+
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasTrigramExtension tells if pg_trgm is installed in the database
+func hasTrigramExtension(db *gorm.DB) (bool, error) {
+	var installed bool
+	err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm')").Scan(&installed).Error
+	return installed, err
+}
+
+// trigramSearch tells if the postgres search can use trigram similarity, it is looked up
+// on the first search and kept once known
+func (r *ProductRepoImpl) trigramSearch() (bool, error) {
+	r.trigramMu.Lock()
+	defer r.trigramMu.Unlock()
+	if r.trigram == nil {
+		installed, err := hasTrigramExtension(r.db)
+		if err != nil {
+			return false, err
+		}
+		r.trigram = &installed
+	}
+	return *r.trigram, nil
+}
+
+// searchTerms splits the query into lower case words, anything other than letters and digits separates words
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
+
+// prefixTSQuery turns the terms into a tsquery where every term has to match as a prefix
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		parts = append(parts, term+":*")
+	}
+	return strings.Join(parts, " & ")
+}
+
+// searchBrandsPostgres ranks the brands with the full text index and falls back on
+// trigram similarity so that misspelled queries still find something
+func (r *ProductRepoImpl) searchBrandsPostgres(terms []string, offset, limit int) ([]Brand, int64, error) {
+	trigram, err := r.trigramSearch()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := brandSearchQuery(r.db, terms, trigram).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var brands []Brand
+	err = brandSearchPage(brandSearchQuery(r.db, terms, trigram), terms, trigram, offset, limit).
+		Preload("Category").
+		Find(&brands).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return brands, total, nil
+}
+
+// brandSearchQuery matches the active brands on postgres, trigram adds the similarity to the name and model
+func brandSearchQuery(db *gorm.DB, terms []string, trigram bool) *gorm.DB {
+	tsQuery := prefixTSQuery(terms)
+	query := db.Model(&Brand{}).
+		Scopes(activeBrands).
+		Joins("JOIN categories ON categories.id = brands.category_id")
+	if !trigram {
+		return query.Where(brandSearchDocument+" @@ to_tsquery('simple', ?) OR to_tsvector('simple', categories.categoryname) @@ to_tsquery('simple', ?)",
+			tsQuery, tsQuery)
+	}
+	return query.Where(brandSearchDocument+" @@ to_tsquery('simple', ?) OR to_tsvector('simple', categories.categoryname) @@ to_tsquery('simple', ?) OR word_similarity(?, "+brandSearchTitle+") >= ?",
+		tsQuery, tsQuery, strings.Join(terms, " "), minTrigramSimilarity)
+}
+
+// brandSearchPage orders the matches best first
+func brandSearchPage(query *gorm.DB, terms []string, trigram bool, offset, limit int) *gorm.DB {
+	// Order only takes an expression wrapped in an OrderBy clause, a bare one is dropped
+	rank := gorm.Expr("ts_rank("+brandSearchDocument+", to_tsquery('simple', ?)) DESC, brands.id ASC", prefixTSQuery(terms))
+	if trigram {
+		rank = gorm.Expr("ts_rank("+brandSearchDocument+", to_tsquery('simple', ?)) + word_similarity(?, "+brandSearchTitle+") DESC, brands.id ASC",
+			prefixTSQuery(terms), strings.Join(terms, " "))
+	}
+	return query.Select("brands.*").
+		Order(clause.OrderBy{Expression: rank}).
+		Offset(offset).
+		Limit(limit)
+}
+
+// searchBrandsInMemory is the search of the test databases, the brands are scored in go
+func (r *ProductRepoImpl) searchBrandsInMemory(terms []string, offset, limit int) ([]Brand, int64, error) {
+	var brands []Brand
+	err := r.db.Preload("Category").
+		Scopes(activeBrands).
+		Order("brands.id").
+		Limit(maxInMemorySearchBrands).
+		Find(&brands).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	type scoredBrand struct {
+		brand Brand
+		score float64
+	}
+	var matches []scoredBrand
+	for _, brand := range brands {
+		if score := scoreBrand(brand, terms); score > 0 {
+			matches = append(matches, scoredBrand{brand: brand, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].brand.ID < matches[j].brand.ID
+	})
+
+	total := int64(len(matches))
+	if offset >= len(matches) {
+		return []Brand{}, total, nil
+	}
+	end := offset + limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	page := make([]Brand, 0, end-offset)
+	for _, match := range matches[offset:end] {
+		page = append(page, match.brand)
+	}
+	return page, total, nil
+}
+
+// scoreBrand gives the relevance of the brand for the terms, every term has to match
+// one of the fields otherwise the score is 0
+func scoreBrand(brand Brand, terms []string) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{searchTerms(brand.BrandName), weightName},
+		{searchTerms(brand.BrandModel), weightModel},
+		{searchTerms(brand.Category.Categoryname), weightCategory},
+		{searchTerms(brand.BrandDescription), weightDescription},
+	}
+
+	var total float64
+	for _, term := range terms {
+		var best float64
+		for _, field := range fields {
+			for _, word := range field.words {
+				if score := matchTerm(term, word) * field.weight; score > best {
+					best = score
+				}
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+// matchTerm scores a single term against a word, exact beats prefix which beats a typo
+func matchTerm(term, word string) float64 {
+	switch {
+	case term == word:
+		return scoreExact
+	case strings.HasPrefix(word, term):
+		return scorePrefix
+	case levenshtein(term, word) <= allowedTypos(term):
+		return scoreTypo
+	}
+	return 0
+}
+
+// allowedTypos is the edit distance tolerated for a term, short terms have to be exact
+func allowedTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein returns the edit distance between two words
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("iphone", "iphone"))
+	assert.Equal(t, 1, levenshtein("iphon", "iphone"))
+	assert.Equal(t, 1, levenshtein("ipone", "iphone"))
+	assert.Equal(t, 2, levenshtein("samsnug", "samsung"))
+	assert.Equal(t, 3, levenshtein("", "abc"))
+}
+
+func TestScoreBrand(t *testing.T) {
+	iphone := Brand{BrandName: "IPHONE", BrandModel: "15 Pro", BrandDescription: "apple flagship", Category: Category{Categoryname: "Mobile"}}
+	galaxy := Brand{BrandName: "SAMSUNG", BrandModel: "Galaxy S24", BrandDescription: "android phone like the iphone", Category: Category{Categoryname: "Mobile"}}
+
+	cases := []struct {
+		query   string
+		matches bool
+	}{
+		{"iphone 15", true},
+		{"IPHONE", true},
+		{"iph", true},
+		{"ipone", true},
+		{"mobile", true},
+		{"flagship", true},
+		{"iphone 16", false},
+		{"laptop", false},
+	}
+	for _, c := range cases {
+		score := scoreBrand(iphone, searchTerms(c.query))
+		assert.Equal(t, c.matches, score > 0, c.query)
+	}
+
+	// the name outranks a mention in the description, exact outranks prefix and typos
+	terms := searchTerms("iphone")
+	assert.Greater(t, scoreBrand(iphone, terms), scoreBrand(galaxy, terms))
+	assert.Greater(t, scoreBrand(iphone, searchTerms("iphone")), scoreBrand(iphone, searchTerms("iphon")))
+	assert.Greater(t, scoreBrand(iphone, searchTerms("iphon")), scoreBrand(iphone, searchTerms("ipone")))
+}
+
+// TestSearchBrandsPostgresSQL checks the statement of the postgres search, which the sqlite tests do not run
+func TestSearchBrandsPostgresSQL(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=e-cart"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	terms := searchTerms("iPhone 15-Pro")
+	count := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var total int64
+		return brandSearchQuery(tx, terms, true).Count(&total)
+	})
+	assert.Contains(t, count, "SELECT count(*) FROM")
+	assert.Contains(t, count, "brands.is_deleted = false")
+	assert.Contains(t, count, "@@ to_tsquery('simple', 'iphone:* & 15:* & pro:*')")
+	assert.Contains(t, count, "to_tsvector('simple', categories.categoryname) @@")
+	assert.Contains(t, count, "word_similarity('iphone 15 pro', lower(coalesce(brands.brandname, '') || ' ' || coalesce(brands.brandmodel, ''))) >= 0.4")
+
+	page := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var brands []Brand
+		return brandSearchPage(brandSearchQuery(tx, terms, true), terms, true, 20, 10).Find(&brands)
+	})
+	assert.Contains(t, page, "SELECT brands.* FROM")
+	assert.Contains(t, page, "ORDER BY ts_rank(to_tsvector('simple', coalesce(brands.brandname, '')")
+	assert.Contains(t, page, "DESC, brands.id ASC")
+	assert.Contains(t, page, "LIMIT 10 OFFSET 20")
+
+	t.Run("without pg_trgm", func(t *testing.T) {
+		page := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			var brands []Brand
+			return brandSearchPage(brandSearchQuery(tx, terms, false), terms, false, 0, 10).Find(&brands)
+		})
+		assert.Contains(t, page, "@@ to_tsquery('simple', 'iphone:* & 15:* & pro:*')")
+		assert.NotContains(t, page, "word_similarity")
+		assert.Contains(t, page, "ORDER BY ts_rank(to_tsvector('simple', coalesce(brands.brandname, '')")
+	})
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Shipment statuses, the status of the last tracking event of the carrier
+const (
+	ShipmentStatusLabelCreated   = "label_created"
+	ShipmentStatusPickedUp       = "picked_up"
+	ShipmentStatusInTransit      = "in_transit"
+	ShipmentStatusOutForDelivery = "out_for_delivery"
+	ShipmentStatusDelivered      = "delivered"
+	ShipmentStatusException      = "exception"
+	ShipmentStatusCancelled      = "cancelled"
+)
+
+// ErrShipmentNotCancellable is returned when a shipment the carrier already has is cancelled
+var ErrShipmentNotCancellable = errors.New("shipment cannot be cancelled")
+
+// Shipment is a parcel of an order booked with a carrier, an order can go out in several of them
+type Shipment struct {
+	ID             int64           `gorm:"primaryKey"`
+	OrderID        int64           `gorm:"column:order_id;index;not null"` // Foreign key to Order
+	Carrier        string          `gorm:"column:carrier;not null"`
+	TrackingNumber string          `gorm:"column:tracking_number;uniqueIndex;not null"`
+	LabelURL       string          `gorm:"column:label_url"`
+	Status         string          `gorm:"column:status;not null;default:label_created"` // one of the ShipmentStatus constants
+	CreatedBy      int64           `gorm:"column:created_by"`                            // admin who booked the shipment
+	Items          []ShipmentItem  `gorm:"foreignKey:ShipmentID"`
+	Events         []ShipmentEvent `gorm:"foreignKey:ShipmentID"`
+	DeliveredAt    *time.Time      `gorm:"column:delivered_at"`
+	CreatedAt      time.Time       `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time       `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// ShipmentItem is the quantity of an order item packed in a shipment
+type ShipmentItem struct {
+	ID          int64     `gorm:"primaryKey"`
+	ShipmentID  int64     `gorm:"column:shipment_id;index;not null"`   // Foreign key to Shipment
+	OrderItemID int64     `gorm:"column:order_item_id;index;not null"` // Foreign key to OrderItem
+	OrderItem   OrderItem `gorm:"foreignKey:OrderItemID"`
+	Quantity    int64     `gorm:"column:quantity;not null"`
+}
+
+// ShipmentEvent is a tracking event of the carrier, kept so the timeline shows without asking the carrier
+type ShipmentEvent struct {
+	ID          int64     `gorm:"primaryKey"`
+	ShipmentID  int64     `gorm:"column:shipment_id;index;not null"` // Foreign key to Shipment
+	Status      string    `gorm:"column:status;not null"`
+	Description string    `gorm:"column:description"`
+	Location    string    `gorm:"column:location"`
+	OccurredAt  time.Time `gorm:"column:occurred_at;not null"`
+}
+
+// IsValidShipmentStatus checks the status is one of the known shipment statuses
+func IsValidShipmentStatus(status string) bool {
+	switch status {
+	case ShipmentStatusLabelCreated, ShipmentStatusPickedUp, ShipmentStatusInTransit, ShipmentStatusOutForDelivery,
+		ShipmentStatusDelivered, ShipmentStatusException, ShipmentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// IsFinal tells if the shipment will not move anymore, its tracking is not asked for again
+func (shipment *Shipment) IsFinal() bool {
+	return shipment.Status == ShipmentStatusDelivered || shipment.Status == ShipmentStatusCancelled
+}
+
+// HandedOver tells if the carrier has the parcel
+func (shipment *Shipment) HandedOver() bool {
+	return shipment.Status != ShipmentStatusLabelCreated && shipment.Status != ShipmentStatusCancelled
+}
+
+type ShipmentRepo interface {
+	Transaction(fn func(txRepo ShipmentRepo) error) error
+	GetOrder(orderID int64) (*Order, error)
+	// LockOrder loads the order with its items, locking the order row until the transaction ends
+	LockOrder(orderID int64) (*Order, error)
+	// ShippedQuantities is the quantity of each order item in the shipments of the order that are not cancelled
+	ShippedQuantities(orderID int64) (map[int64]int64, error)
+	CreateShipment(shipment *Shipment) error
+	GetShipment(shipmentID int64) (*Shipment, error)
+	LockShipment(shipmentID int64) (*Shipment, error)
+	ListShipments(orderID int64) ([]Shipment, error)
+	// OpenShipmentOrders returns the orders with shipments of the carrier that are not delivered or cancelled yet
+	OpenShipmentOrders(carrierName string) ([]int64, error)
+	SaveTracking(shipment *Shipment, events []ShipmentEvent) error
+	ChangeOrderStatus(order *Order, status string, actorID int64, actorRole, note string) error
+}
+
+type ShipmentRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepo(db *gorm.DB) ShipmentRepo {
+	return &ShipmentRepoImpl{
+		db: db,
+	}
+}
+
+// Transaction runs fn with a repo bound to a single transaction
+func (r *ShipmentRepoImpl) Transaction(fn func(txRepo ShipmentRepo) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&ShipmentRepoImpl{db: tx})
+	})
+}
+
+func (r *ShipmentRepoImpl) GetOrder(orderID int64) (*Order, error) {
+	var order Order
+	err := r.db.Preload("Items").Preload("Items.Product").Preload("User").First(&order, orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *ShipmentRepoImpl) LockOrder(orderID int64) (*Order, error) {
+	var order Order
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").Preload("Items.Product").First(&order, orderID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *ShipmentRepoImpl) ShippedQuantities(orderID int64) (map[int64]int64, error) {
+	var rows []struct {
+		OrderItemID int64
+		Quantity    int64
+	}
+	err := r.db.Model(&ShipmentItem{}).
+		Select("shipment_items.order_item_id, SUM(shipment_items.quantity) AS quantity").
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.order_id = ? AND shipments.status <> ?", orderID, ShipmentStatusCancelled).
+		Group("shipment_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shipped := make(map[int64]int64, len(rows))
+	for _, row := range rows {
+		shipped[row.OrderItemID] = row.Quantity
+	}
+	return shipped, nil
+}
+
+// CreateShipment saves the shipment with its items and events
+func (r *ShipmentRepoImpl) CreateShipment(shipment *Shipment) error {
+	if err := r.db.Omit("Items", "Events").Create(shipment).Error; err != nil {
+		return err
+	}
+	for i := range shipment.Items {
+		shipment.Items[i].ShipmentID = shipment.ID
+		if err := r.db.Omit("OrderItem").Create(&shipment.Items[i]).Error; err != nil {
+			return err
+		}
+	}
+	return r.createEvents(shipment, shipment.Events)
+}
+
+func (r *ShipmentRepoImpl) GetShipment(shipmentID int64) (*Shipment, error) {
+	var shipment Shipment
+	if err := r.db.First(&shipment, shipmentID).Error; err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// LockShipment loads the shipment with its items, locking the shipment row until the transaction ends
+func (r *ShipmentRepoImpl) LockShipment(shipmentID int64) (*Shipment, error) {
+	var shipment Shipment
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Items.OrderItem.Product").
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("occurred_at ASC, id ASC") }).
+		First(&shipment, shipmentID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &shipment, nil
+}
+
+// ListShipments returns the shipments of the order, oldest first, with their items and tracking events
+func (r *ShipmentRepoImpl) ListShipments(orderID int64) ([]Shipment, error) {
+	var shipments []Shipment
+	err := r.db.Where("order_id = ?", orderID).
+		Preload("Items.OrderItem.Product").
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("occurred_at ASC, id ASC") }).
+		Order("created_at ASC, id ASC").
+		Find(&shipments).Error
+	if err != nil {
+		return nil, err
+	}
+	return shipments, nil
+}
+
+func (r *ShipmentRepoImpl) OpenShipmentOrders(carrierName string) ([]int64, error) {
+	var orderIDs []int64
+	err := r.db.Model(&Shipment{}).
+		Where("carrier = ? AND status NOT IN ?", carrierName, []string{ShipmentStatusDelivered, ShipmentStatusCancelled}).
+		Distinct("order_id").
+		Order("order_id ASC").
+		Pluck("order_id", &orderIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return orderIDs, nil
+}
+
+// SaveTracking replaces the events of the shipment with the ones of the carrier, the status of the
+// shipment becomes the one of the last event
+func (r *ShipmentRepoImpl) SaveTracking(shipment *Shipment, events []ShipmentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	last := events[len(events)-1]
+	if !IsValidShipmentStatus(last.Status) {
+		return fmt.Errorf("unknown status %s of shipment %d", last.Status, shipment.ID)
+	}
+
+	if err := r.db.Where("shipment_id = ?", shipment.ID).Delete(&ShipmentEvent{}).Error; err != nil {
+		return err
+	}
+	if err := r.createEvents(shipment, events); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"status": last.Status}
+	if last.Status == ShipmentStatusDelivered && shipment.DeliveredAt == nil {
+		updates["delivered_at"] = last.OccurredAt
+		shipment.DeliveredAt = &last.OccurredAt
+	}
+	// the loaded items and events are not saved back
+	if err := r.db.Model(shipment).Omit(clause.Associations).Updates(updates).Error; err != nil {
+		return err
+	}
+	shipment.Status = last.Status
+	shipment.Events = events
+	return nil
+}
+
+func (r *ShipmentRepoImpl) ChangeOrderStatus(order *Order, status string, actorID int64, actorRole, note string) error {
+	return changeOrderStatus(r.db, order, status, actorID, actorRole, note)
+}
+
+func (r *ShipmentRepoImpl) createEvents(shipment *Shipment, events []ShipmentEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		events[i].ID = 0
+		events[i].ShipmentID = shipment.ID
+	}
+	return r.db.Create(&events).Error
+}
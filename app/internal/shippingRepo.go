@@ -0,0 +1,357 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Shipping rate types
+const (
+	ShippingRateFlat   = "flat"   // BaseRate for every order
+	ShippingRateWeight = "weight" // rate of the tier the weight of the order falls in, MinValue is in grams
+	ShippingRateValue  = "value"  // rate of the tier the value of the order falls in, MinValue is an amount
+)
+
+// ErrPincodeNotServiceable is returned when no shipping zone delivers to the pincode
+var ErrPincodeNotServiceable = errors.New("pincode not serviceable")
+
+// ErrInvalidShippingZone is returned when the settings of a zone do not fit its rate type
+var ErrInvalidShippingZone = errors.New("invalid shipping zone")
+
+// ErrDuplicateShippingZone is returned when a zone name is already taken
+var ErrDuplicateShippingZone = errors.New("shipping zone already exists")
+
+// ShippingZone delivers to the pincodes between PincodeFrom and PincodeTo and to the ones starting with
+// one of the PincodePrefixes. When several zones cover a pincode the longest matching prefix wins,
+// ranges come after any prefix
+type ShippingZone struct {
+	ID              int64              `gorm:"primaryKey"`
+	Name            string             `gorm:"column:name;uniqueIndex;not null"`
+	PincodeFrom     int64              `gorm:"column:pincode_from;not null;default:0"`
+	PincodeTo       int64              `gorm:"column:pincode_to;not null;default:0"`
+	PincodePrefixes string             `gorm:"column:pincode_prefixes"` // comma separated
+	RateType        string             `gorm:"column:rate_type;not null"`
+	BaseRate        float64            `gorm:"column:base_rate;not null;default:0"`
+	FreeAbove       float64            `gorm:"column:free_above;not null;default:0"` // orders worth this much ship free, 0 for never
+	MinDays         int                `gorm:"column:min_days;not null;default:0"`
+	MaxDays         int                `gorm:"column:max_days;not null;default:0"`
+	Active          bool               `gorm:"column:active;not null;default:true"`
+	Tiers           []ShippingRateTier `gorm:"foreignKey:ZoneID;constraint:OnDelete:CASCADE"`
+	CreatedAt       time.Time          `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt       time.Time          `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// ShippingRateTier charges Rate from MinValue up to the MinValue of the next tier
+type ShippingRateTier struct {
+	ID       int64   `gorm:"primaryKey"`
+	ZoneID   int64   `gorm:"column:zone_id;index;not null"`
+	MinValue float64 `gorm:"column:min_value;not null"`
+	Rate     float64 `gorm:"column:rate;not null"`
+}
+
+// ShippingQuote is the shipping charge and delivery estimate of an order
+type ShippingQuote struct {
+	Zone          *ShippingZone // nil when no zone is set up and shipping is not charged
+	Charge        float64
+	Free          bool
+	EstimatedFrom *time.Time
+	EstimatedTo   *time.Time
+}
+
+// Prefixes lists the pincode prefixes of the zone
+func (z *ShippingZone) Prefixes() []string {
+	var prefixes []string
+	for _, prefix := range strings.Split(z.PincodePrefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// match tells how closely the zone covers the pincode, the length of the matching prefix,
+// 0 for a range and -1 when it does not cover it
+func (z *ShippingZone) match(pincode int64) int {
+	best := -1
+	code := strconv.FormatInt(pincode, 10)
+	for _, prefix := range z.Prefixes() {
+		if strings.HasPrefix(code, prefix) && len(prefix) > best {
+			best = len(prefix)
+		}
+	}
+	if best < 0 && z.PincodeFrom > 0 && z.PincodeFrom <= pincode && pincode <= z.PincodeTo {
+		best = 0
+	}
+	return best
+}
+
+func (z *ShippingZone) validate() error {
+	if len(z.Prefixes()) == 0 && z.PincodeFrom == 0 {
+		return fmt.Errorf("%w: a zone needs a pincode range or prefixes", ErrInvalidShippingZone)
+	}
+	if z.PincodeFrom > z.PincodeTo {
+		return fmt.Errorf("%w: pincode_from is after pincode_to", ErrInvalidShippingZone)
+	}
+	for _, prefix := range z.Prefixes() {
+		if _, err := strconv.ParseUint(prefix, 10, 64); err != nil {
+			return fmt.Errorf("%w: prefix %q is not a number", ErrInvalidShippingZone, prefix)
+		}
+	}
+	switch z.RateType {
+	case ShippingRateFlat:
+	case ShippingRateWeight, ShippingRateValue:
+		if len(z.Tiers) == 0 {
+			return fmt.Errorf("%w: a %s rate needs tiers", ErrInvalidShippingZone, z.RateType)
+		}
+	default:
+		return fmt.Errorf("%w: unknown rate type %q", ErrInvalidShippingZone, z.RateType)
+	}
+	if z.MinDays > z.MaxDays {
+		return fmt.Errorf("%w: min_days is more than max_days", ErrInvalidShippingZone)
+	}
+	return nil
+}
+
+// Quote works out the shipping charge of an order of orderValue weighing weightGrams
+func (z *ShippingZone) Quote(orderValue float64, weightGrams int64, now time.Time) *ShippingQuote {
+	quote := &ShippingQuote{Zone: z}
+	if z.MaxDays > 0 {
+		from := now.AddDate(0, 0, z.MinDays)
+		to := now.AddDate(0, 0, z.MaxDays)
+		quote.EstimatedFrom, quote.EstimatedTo = &from, &to
+	}
+	if z.FreeAbove > 0 && orderValue >= z.FreeAbove {
+		quote.Free = true
+		return quote
+	}
+
+	switch z.RateType {
+	case ShippingRateFlat:
+		quote.Charge = z.BaseRate
+	case ShippingRateWeight:
+		quote.Charge = z.tierRate(float64(weightGrams))
+	case ShippingRateValue:
+		quote.Charge = z.tierRate(orderValue)
+	}
+	quote.Free = quote.Charge == 0
+	return quote
+}
+
+// tierRate is the rate of the highest tier value reaches, the base rate below the first tier
+func (z *ShippingZone) tierRate(value float64) float64 {
+	rate := z.BaseRate
+	for _, tier := range z.sortedTiers() {
+		if value < tier.MinValue {
+			break
+		}
+		rate = tier.Rate
+	}
+	return rate
+}
+
+func (z *ShippingZone) sortedTiers() []ShippingRateTier {
+	tiers := append([]ShippingRateTier(nil), z.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinValue < tiers[j].MinValue })
+	return tiers
+}
+
+// FindShippingZone picks the zone that covers the pincode most closely, nil when none does
+func FindShippingZone(zones []ShippingZone, pincode int64) *ShippingZone {
+	var found *ShippingZone
+	best := -1
+	for i := range zones {
+		if score := zones[i].match(pincode); score > best {
+			found, best = &zones[i], score
+		}
+	}
+	return found
+}
+
+// QuoteShipping prices shipping to the pincode with the active zones. While no zone is set up
+// shipping is not charged and every pincode is served
+func QuoteShipping(zones []ShippingZone, pincode int64, orderValue float64, weightGrams int64, now time.Time) (*ShippingQuote, error) {
+	if len(zones) == 0 {
+		return &ShippingQuote{Free: true}, nil
+	}
+	zone := FindShippingZone(zones, pincode)
+	if zone == nil {
+		return nil, fmt.Errorf("%w: no delivery to %d", ErrPincodeNotServiceable, pincode)
+	}
+	return zone.Quote(orderValue, weightGrams, now), nil
+}
+
+type ShippingRepo interface {
+	CreateZone(args *dto.SaveShippingZoneRequest) (*ShippingZone, error)
+	ListZones(args *dto.ListShippingZonesRequest) ([]ShippingZone, int64, error)
+	GetZone(zoneID int64) (*ShippingZone, error)
+	UpdateZone(args *dto.SaveShippingZoneRequest) (*ShippingZone, error)
+	DeleteZone(zoneID int64) error
+	GetActiveZones() ([]ShippingZone, error)
+}
+
+type ShippingRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewShippingRepo(db *gorm.DB) ShippingRepo {
+	return &ShippingRepoImpl{
+		db: db,
+	}
+}
+
+func (r *ShippingRepoImpl) CreateZone(args *dto.SaveShippingZoneRequest) (*ShippingZone, error) {
+	zone := &ShippingZone{}
+	applyShippingZone(zone, args)
+	if err := zone.validate(); err != nil {
+		return nil, err
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkZoneName(tx, zone); err != nil {
+			return err
+		}
+		// Select keeps an inactive zone inactive, gorm skips false with a default
+		if err := tx.Select("*").Omit("id", "Tiers").Create(zone).Error; err != nil {
+			return err
+		}
+		return saveRateTiers(tx, zone)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return zone, nil
+}
+
+func (r *ShippingRepoImpl) ListZones(args *dto.ListShippingZonesRequest) ([]ShippingZone, int64, error) {
+	query := r.db.Model(&ShippingZone{})
+	if args.Active != nil {
+		query = query.Where("active = ?", *args.Active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var zones []ShippingZone
+	err := query.Preload("Tiers").Order("name ASC, id ASC").
+		Offset(args.Offset()).Limit(args.PageSize).
+		Find(&zones).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return zones, total, nil
+}
+
+func (r *ShippingRepoImpl) GetZone(zoneID int64) (*ShippingZone, error) {
+	var zone ShippingZone
+	if err := r.db.Preload("Tiers").First(&zone, zoneID).Error; err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+// UpdateZone replaces the settings of a zone, the tiers sent replace the ones it had
+func (r *ShippingRepoImpl) UpdateZone(args *dto.SaveShippingZoneRequest) (*ShippingZone, error) {
+	var zone ShippingZone
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&zone, args.ZoneID).Error; err != nil {
+			return err
+		}
+		applyShippingZone(&zone, args)
+		if err := zone.validate(); err != nil {
+			return err
+		}
+		if err := checkZoneName(tx, &zone); err != nil {
+			return err
+		}
+
+		if err := tx.Where("zone_id = ?", zone.ID).Delete(&ShippingRateTier{}).Error; err != nil {
+			return err
+		}
+		if err := saveRateTiers(tx, &zone); err != nil {
+			return err
+		}
+		return tx.Select("*").Omit("id", "created_at", "Tiers").Updates(&zone).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *ShippingRepoImpl) DeleteZone(zoneID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		zone := &ShippingZone{}
+		if err := tx.First(zone, zoneID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("zone_id = ?", zone.ID).Delete(&ShippingRateTier{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(zone).Error
+	})
+}
+
+func (r *ShippingRepoImpl) GetActiveZones() ([]ShippingZone, error) {
+	return activeShippingZones(r.db)
+}
+
+// GetActiveShippingZones loads the zones used to price shipping at checkout
+func (r *UserRepoImpl) GetActiveShippingZones() ([]ShippingZone, error) {
+	return activeShippingZones(r.db)
+}
+
+func activeShippingZones(db *gorm.DB) ([]ShippingZone, error) {
+	var zones []ShippingZone
+	if err := db.Preload("Tiers").Where("active = ?", true).Order("id ASC").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+func checkZoneName(tx *gorm.DB, zone *ShippingZone) error {
+	var count int64
+	if err := tx.Model(&ShippingZone{}).Where("name = ? AND id <> ?", zone.Name, zone.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("shipping zone '%s': %w", zone.Name, ErrDuplicateShippingZone)
+	}
+	return nil
+}
+
+func saveRateTiers(tx *gorm.DB, zone *ShippingZone) error {
+	if len(zone.Tiers) == 0 {
+		return nil
+	}
+	for i := range zone.Tiers {
+		zone.Tiers[i].ZoneID = zone.ID
+	}
+	return tx.Create(&zone.Tiers).Error
+}
+
+func applyShippingZone(zone *ShippingZone, args *dto.SaveShippingZoneRequest) {
+	zone.Name = strings.TrimSpace(args.Name)
+	zone.PincodeFrom = args.PincodeFrom
+	zone.PincodeTo = args.PincodeTo
+	zone.PincodePrefixes = strings.Join(args.PincodePrefixes, ",")
+	zone.RateType = args.RateType
+	zone.BaseRate = args.BaseRate
+	zone.FreeAbove = args.FreeAbove
+	zone.MinDays = args.MinDays
+	zone.MaxDays = args.MaxDays
+	zone.Active = args.Active == nil || *args.Active
+	zone.Tiers = make([]ShippingRateTier, 0, len(args.Tiers))
+	for _, tier := range args.Tiers {
+		zone.Tiers = append(zone.Tiers, ShippingRateTier{MinValue: tier.MinValue, Rate: tier.Rate})
+	}
+}
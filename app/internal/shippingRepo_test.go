@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShippingZoneQuote(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	weightTiers := []ShippingRateTier{{MinValue: 1000, Rate: 60}, {MinValue: 0, Rate: 30}, {MinValue: 5000, Rate: 120}}
+	valueTiers := []ShippingRateTier{{MinValue: 200, Rate: 25}, {MinValue: 500, Rate: 10}}
+
+	cases := []struct {
+		name   string
+		zone   ShippingZone
+		value  float64
+		weight int64
+		charge float64
+		free   bool
+	}{
+		{"flat", ShippingZone{RateType: ShippingRateFlat, BaseRate: 40}, 300, 2000, 40, false},
+		{"free above", ShippingZone{RateType: ShippingRateFlat, BaseRate: 40, FreeAbove: 300}, 300, 2000, 0, true},
+		{"light parcel", ShippingZone{RateType: ShippingRateWeight, Tiers: weightTiers}, 300, 500, 30, false},
+		{"heavy parcel", ShippingZone{RateType: ShippingRateWeight, Tiers: weightTiers}, 300, 2500, 60, false},
+		{"heaviest tier", ShippingZone{RateType: ShippingRateWeight, Tiers: weightTiers}, 300, 5000, 120, false},
+		{"below the first value tier", ShippingZone{RateType: ShippingRateValue, BaseRate: 50, Tiers: valueTiers}, 100, 0, 50, false},
+		{"value tier", ShippingZone{RateType: ShippingRateValue, BaseRate: 50, Tiers: valueTiers}, 650, 0, 10, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			quote := tc.zone.Quote(tc.value, tc.weight, now)
+			assert.InDelta(t, tc.charge, quote.Charge, 0.001)
+			assert.Equal(t, tc.free, quote.Free)
+		})
+	}
+
+	zone := ShippingZone{RateType: ShippingRateFlat, BaseRate: 40, MinDays: 2, MaxDays: 5}
+	quote := zone.Quote(100, 0, now)
+	require.NotNil(t, quote.EstimatedFrom)
+	require.NotNil(t, quote.EstimatedTo)
+	assert.Equal(t, now.AddDate(0, 0, 2), *quote.EstimatedFrom)
+	assert.Equal(t, now.AddDate(0, 0, 5), *quote.EstimatedTo)
+}
+
+func TestQuoteShippingPicksZone(t *testing.T) {
+	zones := []ShippingZone{
+		{ID: 1, Name: "south", PincodeFrom: 600000, PincodeTo: 699999, RateType: ShippingRateFlat, BaseRate: 80},
+		{ID: 2, Name: "kerala", PincodePrefixes: "67, 68,69", RateType: ShippingRateFlat, BaseRate: 50},
+		{ID: 3, Name: "kochi", PincodePrefixes: "6820", RateType: ShippingRateFlat, BaseRate: 20},
+	}
+
+	cases := map[int64]string{
+		682001: "kochi",
+		683101: "kerala",
+		600001: "south",
+	}
+	for pincode, name := range cases {
+		quote, err := QuoteShipping(zones, pincode, 100, 0, time.Now())
+		require.NoError(t, err)
+		require.NotNil(t, quote.Zone)
+		assert.Equal(t, name, quote.Zone.Name, "pincode %d", pincode)
+	}
+
+	_, err := QuoteShipping(zones, 110001, 100, 0, time.Now())
+	assert.ErrorIs(t, err, ErrPincodeNotServiceable)
+
+	// without any zone shipping is free everywhere
+	quote, err := QuoteShipping(nil, 110001, 100, 0, time.Now())
+	require.NoError(t, err)
+	assert.True(t, quote.Free)
+	assert.Zero(t, quote.Charge)
+}
+
+func TestShippingZoneValidate(t *testing.T) {
+	cases := map[string]ShippingZone{
+		"no pincodes":     {RateType: ShippingRateFlat},
+		"reversed range":  {PincodeFrom: 690000, PincodeTo: 680000, RateType: ShippingRateFlat},
+		"unknown rate":    {PincodePrefixes: "68", RateType: "distance"},
+		"weight no tiers": {PincodePrefixes: "68", RateType: ShippingRateWeight},
+		"reversed days":   {PincodePrefixes: "68", RateType: ShippingRateFlat, MinDays: 5, MaxDays: 2},
+	}
+	for name, zone := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, zone.validate(), ErrInvalidShippingZone)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package internal
+
+import (
+	"e-cart/app/dto"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidTaxClass is returned when the rules of a tax class do not fit together
+var ErrInvalidTaxClass = errors.New("invalid tax class")
+
+// ErrDuplicateTaxClass is returned when a tax class name is already taken
+var ErrDuplicateTaxClass = errors.New("tax class already exists")
+
+// ErrTaxClassInUse is returned when a tax class that categories or brands use is deleted
+var ErrTaxClassInUse = errors.New("tax class in use")
+
+// ErrUnknownTaxClass is returned when a category or brand is given a tax class that does not exist
+var ErrUnknownTaxClass = errors.New("unknown tax class")
+
+// TaxClass is a set of tax rates, like a GST slab, given to categories and brands. The rule of the
+// destination state applies, the rule without a state covers the other states
+type TaxClass struct {
+	ID               int64     `gorm:"primaryKey"`
+	Name             string    `gorm:"column:name;uniqueIndex;not null"`
+	Description      string    `gorm:"column:description"`
+	PricesIncludeTax bool      `gorm:"column:prices_include_tax;not null;default:false"` // the tax is part of the price
+	Rules            []TaxRule `gorm:"foreignKey:TaxClassID;constraint:OnDelete:CASCADE"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TaxRule is the rate of a tax class for orders shipped to State, empty for every other state
+type TaxRule struct {
+	ID         int64   `gorm:"primaryKey"`
+	TaxClassID int64   `gorm:"column:tax_class_id;index;not null"`
+	State      string  `gorm:"column:state"`
+	Rate       float64 `gorm:"column:rate;not null"` // percent
+}
+
+// LineTax is the tax of one order line
+type LineTax struct {
+	Rate      float64
+	Amount    float64
+	Inclusive bool
+}
+
+// OrderTax is the tax of the lines of an order, Added is the part charged on top of the prices
+type OrderTax struct {
+	Tax   float64
+	Added float64
+	Lines map[int64]LineTax // by product id
+	// StateMissing is set when a line has a rate for some states and no state was given, the line is
+	// taxed at the rate for every other state
+	StateMissing bool
+}
+
+// RateFor is the rate of the class for orders shipped to the state
+func (c *TaxClass) RateFor(state string) float64 {
+	state = strings.TrimSpace(state)
+	rate := 0.0
+	for _, rule := range c.Rules {
+		if rule.State == "" {
+			rate = rule.Rate
+		}
+		if state != "" && strings.EqualFold(rule.State, state) {
+			return rule.Rate
+		}
+	}
+	return rate
+}
+
+// HasStateRules tells if the rate of the class depends on the state
+func (c *TaxClass) HasStateRules() bool {
+	for _, rule := range c.Rules {
+		if rule.State != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TaxClass) validate() error {
+	states := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		state := strings.ToLower(rule.State)
+		if states[state] {
+			if state == "" {
+				return fmt.Errorf("%w: only one rule can be without a state", ErrInvalidTaxClass)
+			}
+			return fmt.Errorf("%w: state %s has more than one rule", ErrInvalidTaxClass, rule.State)
+		}
+		states[state] = true
+	}
+	return nil
+}
+
+// ComputeTax works out the tax of the cart lines shipped to the state. The tax class of the brand wins
+// over the one of its category, lines without a class are not taxed. Tax is on what is paid for the line,
+// the current price less the coupon discount of the line
+func ComputeTax(classes []TaxClass, lines []Cart, discounts map[int64]float64, state string) *OrderTax {
+	byID := make(map[int64]*TaxClass, len(classes))
+	for i := range classes {
+		byID[classes[i].ID] = &classes[i]
+	}
+
+	tax := &OrderTax{Lines: make(map[int64]LineTax, len(lines))}
+	for _, line := range lines {
+		classID := line.Brand.TaxClassID
+		if classID == nil {
+			classID = line.Brand.Category.TaxClassID
+		}
+		if classID == nil || byID[*classID] == nil {
+			continue
+		}
+		class := byID[*classID]
+		if strings.TrimSpace(state) == "" && class.HasStateRules() {
+			tax.StateMissing = true
+		}
+
+		lineTax := LineTax{Rate: class.RateFor(state), Inclusive: class.PricesIncludeTax}
+		paid := line.Brand.Price*float64(line.Quantity) - discounts[line.ProductID]
+		if lineTax.Inclusive {
+			lineTax.Amount = roundAmount(paid * lineTax.Rate / (100 + lineTax.Rate))
+		} else {
+			lineTax.Amount = roundAmount(paid * lineTax.Rate / 100)
+			tax.Added = roundAmount(tax.Added + lineTax.Amount)
+		}
+		tax.Lines[line.ProductID] = lineTax
+		tax.Tax = roundAmount(tax.Tax + lineTax.Amount)
+	}
+	return tax
+}
+
+type TaxRepo interface {
+	CreateTaxClass(args *dto.SaveTaxClassRequest) (*TaxClass, error)
+	ListTaxClasses() ([]TaxClass, error)
+	GetTaxClass(taxClassID int64) (*TaxClass, error)
+	UpdateTaxClass(args *dto.SaveTaxClassRequest) (*TaxClass, error)
+	DeleteTaxClass(taxClassID int64) error
+}
+
+type TaxRepoImpl struct {
+	db *gorm.DB
+}
+
+func NewTaxRepo(db *gorm.DB) TaxRepo {
+	return &TaxRepoImpl{
+		db: db,
+	}
+}
+
+func (r *TaxRepoImpl) CreateTaxClass(args *dto.SaveTaxClassRequest) (*TaxClass, error) {
+	class := &TaxClass{}
+	applyTaxClass(class, args)
+	if err := class.validate(); err != nil {
+		return nil, err
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkTaxClassName(tx, class); err != nil {
+			return err
+		}
+		// Select keeps prices_include_tax false, gorm skips false with a default
+		if err := tx.Select("*").Omit("id", "Rules").Create(class).Error; err != nil {
+			return err
+		}
+		return saveTaxRules(tx, class)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return class, nil
+}
+
+func (r *TaxRepoImpl) ListTaxClasses() ([]TaxClass, error) {
+	return taxClasses(r.db)
+}
+
+func (r *TaxRepoImpl) GetTaxClass(taxClassID int64) (*TaxClass, error) {
+	var class TaxClass
+	if err := r.db.Preload("Rules").First(&class, taxClassID).Error; err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// UpdateTaxClass replaces the settings of a tax class, the rules sent replace the ones it had.
+// Orders keep the tax they were placed with
+func (r *TaxRepoImpl) UpdateTaxClass(args *dto.SaveTaxClassRequest) (*TaxClass, error) {
+	var class TaxClass
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&class, args.TaxClassID).Error; err != nil {
+			return err
+		}
+		applyTaxClass(&class, args)
+		if err := class.validate(); err != nil {
+			return err
+		}
+		if err := checkTaxClassName(tx, &class); err != nil {
+			return err
+		}
+
+		if err := tx.Where("tax_class_id = ?", class.ID).Delete(&TaxRule{}).Error; err != nil {
+			return err
+		}
+		if err := saveTaxRules(tx, &class); err != nil {
+			return err
+		}
+		return tx.Select("*").Omit("id", "created_at", "Rules").Updates(&class).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+func (r *TaxRepoImpl) DeleteTaxClass(taxClassID int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		class := &TaxClass{}
+		if err := tx.First(class, taxClassID).Error; err != nil {
+			return err
+		}
+
+		var categories, brands int64
+		if err := tx.Model(&Category{}).Where("tax_class_id = ?", class.ID).Count(&categories).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Brand{}).Where("tax_class_id = ?", class.ID).Count(&brands).Error; err != nil {
+			return err
+		}
+		if categories+brands > 0 {
+			return fmt.Errorf("tax class %s is used by %d categories and %d brands: %w", class.Name, categories, brands, ErrTaxClassInUse)
+		}
+
+		if err := tx.Where("tax_class_id = ?", class.ID).Delete(&TaxRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(class).Error
+	})
+}
+
+// GetTaxClasses loads the tax classes used to tax the cart
+func (r *UserRepoImpl) GetTaxClasses() ([]TaxClass, error) {
+	return taxClasses(r.db)
+}
+
+func taxClasses(db *gorm.DB) ([]TaxClass, error) {
+	var classes []TaxClass
+	if err := db.Preload("Rules").Order("name ASC, id ASC").Find(&classes).Error; err != nil {
+		return nil, err
+	}
+	return classes, nil
+}
+
+// checkTaxClass makes sure a tax class given to a category or brand exists, 0 removes the class
+func checkTaxClass(db *gorm.DB, taxClassID int64) error {
+	if taxClassID == 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&TaxClass{}).Where("id = ?", taxClassID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownTaxClass, taxClassID)
+	}
+	return nil
+}
+
+func checkTaxClassName(tx *gorm.DB, class *TaxClass) error {
+	var count int64
+	if err := tx.Model(&TaxClass{}).Where("name = ? AND id <> ?", class.Name, class.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("tax class '%s': %w", class.Name, ErrDuplicateTaxClass)
+	}
+	return nil
+}
+
+func saveTaxRules(tx *gorm.DB, class *TaxClass) error {
+	for i := range class.Rules {
+		class.Rules[i].TaxClassID = class.ID
+	}
+	return tx.Create(&class.Rules).Error
+}
+
+func applyTaxClass(class *TaxClass, args *dto.SaveTaxClassRequest) {
+	class.Name = strings.TrimSpace(args.Name)
+	class.Description = args.Description
+	class.PricesIncludeTax = args.PricesIncludeTax
+	class.Rules = make([]TaxRule, 0, len(args.Rules))
+	for _, rule := range args.Rules {
+		class.Rules = append(class.Rules, TaxRule{State: strings.TrimSpace(rule.State), Rate: rule.Rate})
+	}
+}
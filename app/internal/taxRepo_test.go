@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeTax(t *testing.T) {
+	gst18, gst5 := int64(1), int64(2)
+	classes := []TaxClass{
+		{ID: gst18, Rules: []TaxRule{{State: "Kerala", Rate: 18}, {Rate: 12}}},
+		{ID: gst5, PricesIncludeTax: true, Rules: []TaxRule{{Rate: 5}}},
+	}
+	phone := Cart{ProductID: 1, Quantity: 2, Brand: Brand{ID: 1, Price: 100, Category: Category{TaxClassID: &gst18}}}
+	book := Cart{ProductID: 2, Quantity: 1, Brand: Brand{ID: 2, Price: 210, TaxClassID: &gst5, Category: Category{TaxClassID: &gst18}}}
+	untaxed := Cart{ProductID: 3, Quantity: 4, Brand: Brand{ID: 3, Price: 10}}
+	lines := []Cart{phone, book, untaxed}
+
+	tax := ComputeTax(classes, lines, nil, "kerala")
+	assert.Equal(t, LineTax{Rate: 18, Amount: 36}, tax.Lines[1])
+	assert.Equal(t, LineTax{Rate: 5, Amount: 10, Inclusive: true}, tax.Lines[2], "the brand class wins over the category")
+	assert.NotContains(t, tax.Lines, int64(3))
+	assert.InDelta(t, 46, tax.Tax, 0.001)
+	assert.InDelta(t, 36, tax.Added, 0.001)
+
+	// other states take the rule without a state, tax is on the price less the discount
+	tax = ComputeTax(classes, lines, map[int64]float64{1: 50}, "Goa")
+	assert.Equal(t, LineTax{Rate: 12, Amount: 18}, tax.Lines[1])
+	assert.InDelta(t, 18, tax.Added, 0.001)
+	assert.False(t, tax.StateMissing)
+
+	// without a state the lines taxed by state are flagged
+	tax = ComputeTax(classes, lines, nil, "")
+	assert.Equal(t, LineTax{Rate: 12, Amount: 24}, tax.Lines[1])
+	assert.True(t, tax.StateMissing)
+	tax = ComputeTax(classes, []Cart{book, untaxed}, nil, "")
+	assert.False(t, tax.StateMissing, "the book is taxed the same everywhere")
+}
+
+func TestTaxClassValidate(t *testing.T) {
+	twoDefaults := TaxClass{Rules: []TaxRule{{Rate: 5}, {Rate: 12}}}
+	assert.ErrorIs(t, twoDefaults.validate(), ErrInvalidTaxClass)
+	sameState := TaxClass{Rules: []TaxRule{{State: "Kerala", Rate: 5}, {State: "kerala", Rate: 12}}}
+	assert.ErrorIs(t, sameState.validate(), ErrInvalidTaxClass)
+	assert.NoError(t, (&TaxClass{Rules: []TaxRule{{State: "Kerala", Rate: 5}, {Rate: 12}}}).validate())
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"e-cart/app/internal"
+	"e-cart/app/service"
+	"e-cart/pkg/payment"
+)
+
+// EnvRefundRetryInterval is how often refunds the payment provider did not accept are sent again, a duration like "5m"
+const EnvRefundRetryInterval = "REFUND_RETRY_INTERVAL"
+
+// defaultRefundRetryInterval is how often pending refunds are retried when not configured
+const defaultRefundRetryInterval = 5 * time.Minute
+
+// StartRefundRetrier sends the pending refunds to the provider again in the background until the context is done
+func StartRefundRetrier(ctx context.Context, refundRepo internal.RefundRepo, provider payment.PaymentProvider) error {
+	interval, err := durationFromEnv(EnvRefundRetryInterval, defaultRefundRetryInterval)
+	if err != nil {
+		return err
+	}
+
+	go service.RetryPendingRefunds(ctx, refundRepo, provider, interval)
+	return nil
+}
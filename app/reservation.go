@@ -0,0 +1,55 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"e-cart/app/internal"
+	"e-cart/app/service"
+
+	"gorm.io/gorm"
+)
+
+// Environment variables of the stock reservations, both take a duration like "15m"
+const (
+	EnvReservationTTL           = "STOCK_RESERVATION_TTL"
+	EnvReservationSweepInterval = "STOCK_RESERVATION_SWEEP_INTERVAL"
+)
+
+// defaultSweepInterval is how often expired reservations are released when not configured
+const defaultSweepInterval = time.Minute
+
+// StartReservationSweeper applies the reservation settings and releases expired reservations in the
+// background until the context is done
+func StartReservationSweeper(ctx context.Context, db *gorm.DB) error {
+	ttl, err := durationFromEnv(EnvReservationTTL, internal.ReservationTTL)
+	if err != nil {
+		return err
+	}
+	interval, err := durationFromEnv(EnvReservationSweepInterval, defaultSweepInterval)
+	if err != nil {
+		return err
+	}
+	internal.ReservationTTL = ttl
+
+	go service.SweepExpiredReservations(ctx, internal.NewUserRepo(db), interval)
+	return nil
+}
+
+// durationFromEnv reads a positive duration from the environment, the fallback is used when it is not set
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", key)
+	}
+	return d, nil
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvReturnWindowDays is the number of days after delivery an item can be returned
+const EnvReturnWindowDays = "RETURN_WINDOW_DAYS"
+
+// defaultReturnWindowDays is used when the return window is not configured
+const defaultReturnWindowDays = 14
+
+// returnWindowFromEnv reads the return window, a whole number of days
+func returnWindowFromEnv() (time.Duration, error) {
+	value := os.Getenv(EnvReturnWindowDays)
+	if value == "" {
+		return defaultReturnWindowDays * 24 * time.Hour, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", EnvReturnWindowDays, err)
+	}
+	if days <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be positive", EnvReturnWindowDays)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
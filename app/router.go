@@ -1,13 +1,21 @@
 package app
 
 import (
+	"context"
 	"e-cart/app/controller"
 	"e-cart/app/helper"
 	"e-cart/app/internal"
 	"e-cart/app/service"
 	api "e-cart/pkg/api"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/invoice"
+	"e-cart/pkg/jwt"
 	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	"e-cart/pkg/payment"
 	"e-cart/pkg/utils"
+	"log"
+	"os"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -21,22 +29,102 @@ func APIRouter(db *gorm.DB) chi.Router {
 	urRepo := internal.NewUserRepo(db)
 	hlRepo := helper.NewContextHelper()
 	hashPkg := utils.NewBcryptPackage()
-	urService := service.NewUserService(urRepo, hlRepo, hashPkg)
+	stockNotifier := notifier.New(os.Getenv(notifier.EnvLowStockWebhookURL))
+	urService := service.NewUserService(urRepo, hlRepo, hashPkg, stockNotifier)
 	urController := controller.NewUserController(urService)
 
 	// Product part
 	proRepo := internal.NewProductRepo(db)
-	proService := service.NewProductService(proRepo)
+	proService := service.NewProductService(proRepo, hlRepo)
 	proController := controller.NewProductController(proService)
 
+	// Catalog import and export
+	catalogRepo := internal.NewCatalogRepo(db)
+	catalogService := service.NewCatalogService(catalogRepo)
+	catalogController := controller.NewCatalogController(catalogService)
+
+	// Inventory part
+	inventoryRepo := internal.NewInventoryRepo(db)
+	inventoryService := service.NewInventoryService(inventoryRepo, hlRepo)
+	inventoryController := controller.NewInventoryController(inventoryService)
+
+	// Payment part
+	paymentProvider, err := payment.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to set up the payment provider: %v", err)
+	}
+	paymentRepo := internal.NewPaymentRepo(db)
+	paymentService := service.NewPaymentService(paymentRepo, paymentProvider, hlRepo)
+	paymentController := controller.NewPaymentController(paymentService)
+
+	// Shipping carrier, cancelled orders void their labels
+	shippingCarrier, err := carrier.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to set up the shipping carrier: %v", err)
+	}
+
+	// Cancellations and refunds
+	refundRepo := internal.NewRefundRepo(db)
+	refundService := service.NewRefundService(refundRepo, paymentProvider, shippingCarrier, hlRepo)
+	if err := StartRefundRetrier(context.Background(), refundRepo, paymentProvider); err != nil {
+		log.Fatalf("failed to start the refund retrier: %v", err)
+	}
+	refundController := controller.NewRefundController(refundService)
+
+	// Returns of delivered items
+	returnWindow, err := returnWindowFromEnv()
+	if err != nil {
+		log.Fatalf("failed to read the return window: %v", err)
+	}
+	returnRepo := internal.NewReturnRepo(db)
+	returnService := service.NewReturnService(returnRepo, paymentProvider, hlRepo, returnWindow)
+	returnController := controller.NewReturnController(returnService)
+
+	// Coupons
+	couponRepo := internal.NewCouponRepo(db)
+	couponService := service.NewCouponService(couponRepo)
+	couponController := controller.NewCouponController(couponService)
+
+	// Address book
+	addressRepo := internal.NewAddressRepo(db)
+	addressService := service.NewAddressService(addressRepo, hlRepo)
+	addressController := controller.NewAddressController(addressService)
+
+	// Shipping zones and rates
+	shippingRepo := internal.NewShippingRepo(db)
+	shippingService := service.NewShippingService(shippingRepo)
+	shippingController := controller.NewShippingController(shippingService)
+
+	// Tax classes of categories and brands
+	taxRepo := internal.NewTaxRepo(db)
+	taxService := service.NewTaxService(taxRepo)
+	taxController := controller.NewTaxController(taxService)
+
+	// Invoices of paid orders
+	invoiceRepo := internal.NewInvoiceRepo(db)
+	invoiceService := service.NewInvoiceService(invoiceRepo, hlRepo, invoice.SellerFromEnv())
+	invoiceController := controller.NewInvoiceController(invoiceService)
+
+	// Shipments and tracking
+	shipmentRepo := internal.NewShipmentRepo(db)
+	shipmentService := service.NewShipmentService(shipmentRepo, shippingCarrier, hlRepo)
+	if err := StartTrackingRefresher(context.Background(), shipmentRepo, shippingCarrier); err != nil {
+		log.Fatalf("failed to start the shipment tracking refresher: %v", err)
+	}
+	shipmentController := controller.NewShipmentController(shipmentService)
+
 	// Admin part
 	adminRepo := internal.NewAdminRepo(db)
-	adminService := service.NewAdminService(adminRepo, urRepo)
+	orderRepo := internal.NewOrderRepo(db)
+	adminService := service.NewAdminService(adminRepo, urRepo, orderRepo, hlRepo)
 	adminController := controller.NewAdminController(adminService)
 
+	// JWT middleware checks the token against the active tokens of the user
+	authMiddleware := middleware.JWTAuthMiddleware(urRepo)
+
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		AllowCredentials: true,
 	}))
@@ -45,29 +133,53 @@ func APIRouter(db *gorm.DB) chi.Router {
 		r.Get("/hello", api.ExampleHamdler)
 		r.Post("/signup", urController.UserDetails)
 		r.Post("/login", urController.LoginUser)
+		r.Post("/token/refresh", urController.RefreshToken)
+		r.Get("/.well-known/jwks.json", jwt.JWKSHandler)
+		// called by the payment provider, trusted through the webhook signature
+		r.Post("/payment/webhook", paymentController.PaymentWebhook)
 	})
 
 	// User routes — JWT middleware applied
 	r.Route("/user", func(r chi.Router) {
-		r.Use(middleware.JWTAuthMiddleware) // All user routes require login
+		r.Use(authMiddleware) // All user routes require login
+		r.Post("/logout", urController.Logout)
+		r.Post("/logout/all", urController.LogoutAll)
 		r.Put("/update/{userid}", urController.UpdateUserDetails)
 		r.Post("/change/pwd", urController.ChangePassword)
 		r.Get("/{userid}", urController.GetUserDetails)
 		r.Post("/cart/additem", urController.AddItemsToCart)
+		r.Put("/cart/item/{productid}", urController.UpdateCartItem)
+		r.Delete("/cart/item/{productid}", urController.RemoveCartItem)
 		r.Get("/cart/view", urController.ViewUserCart)
 		r.Delete("/cart/clear", urController.ClearCart)
+		r.Post("/cart/coupon", urController.ApplyCoupon)
+		r.Delete("/cart/coupon", urController.RemoveCoupon)
 		r.Post("/cart/placeorder", urController.PlaceOrder)
 		r.Get("/order/history", urController.OrderHistory)
+		r.Post("/order/{id}/payment", paymentController.CreatePayment)
+		r.Post("/order/{id}/cancel", refundController.CancelOrder)
+		r.Get("/order/{id}/invoice", invoiceController.UserInvoice) // ?format=pdf|html
+		r.Get("/order/{id}/tracking", shipmentController.TrackOrder)
+		r.Post("/order/{id}/returns", returnController.CreateReturn)
+		r.Get("/returns", returnController.ListUserReturns)
+		r.Get("/addresses", addressController.ListAddresses)
+		r.Post("/addresses", addressController.SaveAddress)
+		r.Get("/addresses/{addressid}", addressController.GetAddress)
+		r.Put("/addresses/{addressid}", addressController.UpdateAddress)
+		r.Patch("/addresses/{addressid}", addressController.UpdateAddress)
+		r.Delete("/addresses/{addressid}", addressController.DeleteAddress)
+		r.Get("/shipping/check", shippingController.CheckPincode)
 		r.Post("/favourite", urController.AddItemsToFavourites)
 		r.Get("/favourite", urController.GetUserFavouriteItems)
 	})
 
 	// Product routes — JWT middleware applied
 	r.Route("/product", func(r chi.Router) {
-		r.Use(middleware.JWTAuthMiddleware) //  All product routes need login
+		r.Use(authMiddleware) //  All product routes need login
 
 		r.Get("/list/catagory", proController.ListAllProduct)
 		r.Get("/list/brand", proController.ListAllBrand)
+		r.Get("/search", proController.SearchProducts)
 		r.Get("/brand/{id}", proController.GetBrandByID)
 		r.Get("/search/catagory/id/{id}", proController.GetCatagoryById)
 		r.Get("/catagory/id/{id}", proController.GetCatagoryDetailsById)
@@ -79,7 +191,7 @@ func APIRouter(db *gorm.DB) chi.Router {
 
 	// Admin routes — JWT and Admin middleware
 	r.Route("/admin", func(r chi.Router) {
-		r.Use(middleware.JWTAuthMiddleware)   //  Require login
+		r.Use(authMiddleware)                 //  Require login
 		r.Use(middleware.AdminOnlyMiddleware) //  Must be admin
 
 		r.Put("/block/{userid}", adminController.BlockUser)     // admin only
@@ -88,6 +200,63 @@ func APIRouter(db *gorm.DB) chi.Router {
 		r.Get("/block/userdetails", adminController.GetAllBlockedUserDetail) //admin only
 		r.Get("/order/history/{id}", adminController.CustomerOrderHistoryById)
 		r.Get("/getall/order/history", adminController.CustomerOrderHistory)
+		r.Put("/order/{id}/status", adminController.UpdateOrderStatus)
+		r.Get("/order/{id}/invoice", invoiceController.AdminInvoice)
+		r.Post("/order/{id}/shipments", shipmentController.CreateShipment) // without items everything not shipped yet
+		r.Get("/order/{id}/shipments", shipmentController.ListShipments)
+		r.Post("/shipments/{shipmentid}/cancel", shipmentController.CancelShipment)
+		r.Post("/order/{id}/refund", refundController.RefundOrder)
+
+		// Catalog editing, only the fields sent in the body are changed
+		r.Put("/category/{id}", proController.UpdateCategory)
+		r.Patch("/category/{id}", proController.UpdateCategory)
+		r.Put("/brand/{id}", proController.UpdateBrand)
+		r.Patch("/brand/{id}", proController.UpdateBrand)
+
+		// Soft delete, deleted items stay in past orders
+		r.Delete("/category/{id}", proController.DeleteCategory)
+		r.Post("/category/{id}/restore", proController.RestoreCategory)
+		r.Delete("/brand/{id}", proController.DeleteBrand)
+		r.Post("/brand/{id}/restore", proController.RestoreBrand)
+
+		// Bulk catalog files, ?format=csv|jsonl and ?dry_run=true on import
+		r.Post("/catalog/import", catalogController.ImportCatalog)
+		r.Get("/catalog/export", catalogController.ExportCatalog)
+
+		// Inventory ledger
+		r.Post("/inventory/{brandid}/adjust", inventoryController.AdjustStock)
+		r.Get("/inventory/{brandid}/movements", inventoryController.StockHistory)
+		r.Post("/inventory/reconcile", inventoryController.ReconcileStock)
+		r.Get("/inventory/low-stock", inventoryController.LowStock)
+
+		// Returns, requested -> approved -> received -> refunded, or rejected before they are received
+		r.Get("/returns", returnController.ListReturns)
+		r.Post("/returns/{returnid}/approve", returnController.ApproveReturn)
+		r.Post("/returns/{returnid}/reject", returnController.RejectReturn)
+		r.Post("/returns/{returnid}/receive", returnController.ReceiveReturn)
+		r.Post("/returns/{returnid}/refund", returnController.RefundReturn)
+
+		// Coupons, redeemed coupons cannot be deleted, only deactivated
+		r.Post("/coupons", couponController.CreateCoupon)
+		r.Get("/coupons", couponController.ListCoupons)
+		r.Get("/coupons/{couponid}", couponController.GetCoupon)
+		r.Put("/coupons/{couponid}", couponController.UpdateCoupon)
+		r.Patch("/coupons/{couponid}", couponController.UpdateCoupon)
+		r.Delete("/coupons/{couponid}", couponController.DeleteCoupon)
+
+		// Shipping zones, a zone covers pincode ranges or prefixes and prices shipping by a flat, weight or value rate
+		r.Post("/shipping/zones", shippingController.CreateZone)
+		r.Get("/shipping/zones", shippingController.ListZones)
+		r.Get("/shipping/zones/{zoneid}", shippingController.GetZone)
+		r.Put("/shipping/zones/{zoneid}", shippingController.UpdateZone)
+		r.Delete("/shipping/zones/{zoneid}", shippingController.DeleteZone)
+
+		// Tax classes, given to categories and brands through their update endpoints
+		r.Post("/tax/classes", taxController.CreateTaxClass)
+		r.Get("/tax/classes", taxController.ListTaxClasses)
+		r.Get("/tax/classes/{taxclassid}", taxController.GetTaxClass)
+		r.Put("/tax/classes/{taxclassid}", taxController.UpdateTaxClass)
+		r.Delete("/tax/classes/{taxclassid}", taxController.DeleteTaxClass)
 	})
 
 	return r
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type AddressService interface {
+	SaveAddress(r *http.Request) (*dto.AddressResponse, error)
+	ListAddresses(r *http.Request) ([]*dto.AddressResponse, error)
+	GetAddress(r *http.Request) (*dto.AddressResponse, error)
+	UpdateAddress(r *http.Request) (*dto.AddressResponse, error)
+	DeleteAddress(r *http.Request) error
+}
+
+type addressServiceImpl struct {
+	addressRepo internal.AddressRepo
+	ctxHelper   helper.ContextHelper
+}
+
+func NewAddressService(addressRepo internal.AddressRepo, ctxHelper helper.ContextHelper) AddressService {
+	return &addressServiceImpl{
+		addressRepo: addressRepo,
+		ctxHelper:   ctxHelper,
+	}
+}
+
+func (s *addressServiceImpl) SaveAddress(r *http.Request) (*dto.AddressResponse, error) {
+	args := &dto.SaveAddressRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	address, err := s.addressRepo.SaveAddress(userID, args)
+	if err != nil {
+		return nil, e.NewError(e.ErrSaveAddress, "failed to save the address", err)
+	}
+	log.Info().Msgf("User %d added address %d", userID, address.ID)
+
+	return addressResponse(address), nil
+}
+
+func (s *addressServiceImpl) ListAddresses(r *http.Request) ([]*dto.AddressResponse, error) {
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	addresses, err := s.addressRepo.ListAddresses(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetAddresses, "failed to get the addresses", err)
+	}
+
+	resp := make([]*dto.AddressResponse, 0, len(addresses))
+	for i := range addresses {
+		resp = append(resp, addressResponse(&addresses[i]))
+	}
+	return resp, nil
+}
+
+func (s *addressServiceImpl) GetAddress(r *http.Request) (*dto.AddressResponse, error) {
+	args := &dto.AddressIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	address, err := s.addressRepo.GetAddress(userID, args.AddressID)
+	if err != nil {
+		return nil, addressError(err, e.ErrGetAddresses, "failed to get the address")
+	}
+	return addressResponse(address), nil
+}
+
+func (s *addressServiceImpl) UpdateAddress(r *http.Request) (*dto.AddressResponse, error) {
+	args := &dto.UpdateAddressRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	address, err := s.addressRepo.UpdateAddress(userID, args)
+	if err != nil {
+		return nil, addressError(err, e.ErrUpdateAddress, "failed to update the address")
+	}
+	log.Info().Msgf("User %d updated address %d", userID, address.ID)
+
+	return addressResponse(address), nil
+}
+
+func (s *addressServiceImpl) DeleteAddress(r *http.Request) error {
+	args := &dto.AddressIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	err = s.addressRepo.DeleteAddress(userID, args.AddressID)
+	if err != nil {
+		return addressError(err, e.ErrDeleteAddress, "failed to delete the address")
+	}
+	log.Info().Msgf("User %d deleted address %d", userID, args.AddressID)
+
+	return nil
+}
+
+func addressError(err error, code int, msg string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrAddressNotFound, "address not found", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func addressResponse(address *internal.Address) *dto.AddressResponse {
+	return &dto.AddressResponse{
+		AddressID:         address.ID,
+		Name:              address.Name,
+		Line1:             address.Line1,
+		Line2:             address.Line2,
+		City:              address.City,
+		State:             address.State,
+		Pincode:           address.Pincode,
+		PhoneNumber:       address.Phonenumber,
+		IsDefaultShipping: address.IsDefaultShipping,
+		IsDefaultBilling:  address.IsDefaultBilling,
+		CreatedAt:         address.CreatedAt,
+		UpdatedAt:         address.UpdatedAt,
+	}
+}
+
+// orderAddressResponse is nil for orders placed before addresses were kept on the order
+func orderAddressResponse(address internal.AddressSnapshot) *dto.OrderAddressResponse {
+	if address.IsEmpty() {
+		return nil
+	}
+	return &dto.OrderAddressResponse{
+		Name:        address.Name,
+		Line1:       address.Line1,
+		Line2:       address.Line2,
+		City:        address.City,
+		State:       address.State,
+		Pincode:     address.Pincode,
+		PhoneNumber: address.Phonenumber,
+	}
+}
+
+// orderUserDetails shows the user of an order with the address it ships to, the current
+// profile address is only used for orders that did not keep one
+func orderUserDetails(order *internal.Order, user *internal.Userdetail) dto.UserDetailsResponse {
+	details := dto.UserDetailsResponse{
+		Username:    user.Username,
+		Email:       user.Mail,
+		PhoneNumber: user.Phonenumber,
+		Address:     user.Address,
+		Pincode:     user.Pincode,
+	}
+	if !order.ShippingAddress.IsEmpty() {
+		details.Address = order.ShippingAddress.Line1
+		if order.ShippingAddress.Line2 != "" {
+			details.Address += ", " + order.ShippingAddress.Line2
+		}
+		if order.ShippingAddress.City != "" {
+			details.Address += ", " + order.ShippingAddress.City + ", " + order.ShippingAddress.State
+		}
+		details.Pincode = order.ShippingAddress.Pincode
+	}
+	return details
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addressRequest(userID, addressID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/user/addresses", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("addressid", fmt.Sprint(addressID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, userID))
+}
+
+const homeAddress = `{"name": "Anu", "line1": "12 MG Road", "city": "Kochi", "state": "Kerala", "pincode": 682016, "phonenumber": 9876543210}`
+const officeAddress = `{"name": "Anu", "line1": "Infopark", "city": "Kochi", "state": "Kerala", "pincode": 682042, "phonenumber": 9876543210, "is_default_billing": true}`
+
+func TestAddressBookDefaults(t *testing.T) {
+	db := newTestDB(t)
+	addresses := NewAddressService(internal.NewAddressRepo(db), helper.NewContextHelper())
+	userID := createTestUser(t, db, "buyer")
+
+	home, err := addresses.SaveAddress(addressRequest(userID, 0, homeAddress))
+	require.NoError(t, err)
+	assert.True(t, home.IsDefaultShipping, "the first address is the default")
+	assert.True(t, home.IsDefaultBilling)
+
+	office, err := addresses.SaveAddress(addressRequest(userID, 0, officeAddress))
+	require.NoError(t, err)
+	assert.False(t, office.IsDefaultShipping)
+	assert.True(t, office.IsDefaultBilling)
+
+	list, err := addresses.ListAddresses(addressRequest(userID, 0, ""))
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, home.AddressID, list[0].AddressID)
+	assert.False(t, list[0].IsDefaultBilling, "the billing default moved to the office")
+
+	otherID := createTestUser(t, db, "other")
+	_, err = addresses.GetAddress(addressRequest(otherID, home.AddressID, ""))
+	assertErrorCode(t, e.ErrAddressNotFound, err)
+
+	require.NoError(t, addresses.DeleteAddress(addressRequest(userID, home.AddressID, "")))
+	office, err = addresses.GetAddress(addressRequest(userID, office.AddressID, ""))
+	require.NoError(t, err)
+	assert.True(t, office.IsDefaultShipping, "the remaining address takes over the defaults")
+}
+
+func TestOrderKeepsAddressSnapshot(t *testing.T) {
+	env := newOrderTestEnv(t)
+	addresses := NewAddressService(internal.NewAddressRepo(env.db), helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+
+	// without an address book the profile address is used
+	legacyID := env.placeOrder(t, userID, brand, 1, false)
+
+	home, err := addresses.SaveAddress(addressRequest(userID, 0, homeAddress))
+	require.NoError(t, err)
+	office, err := addresses.SaveAddress(addressRequest(userID, 0, officeAddress))
+	require.NoError(t, err)
+
+	createTestCartLine(t, env.db, userID, brand, 1)
+	_, err = env.users.PlaceOrder(placeOrderRequest(userID, `{"shipping_address_id": 999}`))
+	assertErrorCode(t, e.ErrAddressNotFound, err)
+
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	require.NotNil(t, order.ShippingAddress)
+	assert.Equal(t, "12 MG Road", order.ShippingAddress.Line1)
+	assert.Equal(t, "Infopark", order.BillingAddress.Line1)
+	assert.Equal(t, int64(682016), order.UserDetails.Pincode)
+
+	_, err = addresses.UpdateAddress(addressRequest(userID, home.AddressID, `{"line1": "7 Marine Drive", "pincode": 682031}`))
+	require.NoError(t, err)
+	require.NoError(t, addresses.DeleteAddress(addressRequest(userID, office.AddressID, "")))
+
+	history, err := env.users.OrderHistory(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	for _, placed := range history {
+		if placed.OrderID == legacyID {
+			assert.Equal(t, "address", placed.ShippingAddress.Line1)
+			continue
+		}
+		assert.Equal(t, "12 MG Road", placed.ShippingAddress.Line1, "edits of the address book do not change placed orders")
+		assert.Equal(t, "Infopark", placed.BillingAddress.Line1)
+		assert.Equal(t, int64(682016), placed.UserDetails.Pincode)
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"e-cart/app/dto"
+	helper "e-cart/app/helper"
 	"e-cart/app/internal"
 	"e-cart/pkg/e"
 	"errors"
@@ -19,17 +20,22 @@ type AdminService interface {
 	CustomerOrderHistoryById(r *http.Request) ([]*dto.ItemOrderedResponse, error)
 	CustomerOrderHistory(r *http.Request) ([]*dto.ItemOrderedResponse, error)
 	GetAllBlockedUserDetail(r *http.Request) ([]*dto.AllUserDetails, error)
+	UpdateOrderStatus(r *http.Request) (*dto.OrderStatusResponse, error)
 }
 
 type AdminServiceImpl struct {
-	adminRepo internal.AdminRepo
-	userRepo  internal.UserRepo
+	adminRepo     internal.AdminRepo
+	userRepo      internal.UserRepo
+	orderRepo     internal.OrderRepo
+	contextHelper helper.ContextHelper
 }
 
-func NewAdminService(adminRepo internal.AdminRepo, userRepo internal.UserRepo) AdminService {
+func NewAdminService(adminRepo internal.AdminRepo, userRepo internal.UserRepo, orderRepo internal.OrderRepo, ctxHelper helper.ContextHelper) AdminService {
 	return &AdminServiceImpl{
-		adminRepo: adminRepo,
-		userRepo:  userRepo,
+		adminRepo:     adminRepo,
+		userRepo:      userRepo,
+		orderRepo:     orderRepo,
+		contextHelper: ctxHelper,
 	}
 }
 
@@ -49,7 +55,14 @@ func (s *AdminServiceImpl) BlockUser(r *http.Request) error {
 		}
 		return e.NewError(e.ErrBlockUser, "failed to block user", err)
 	}
-	log.Info().Msg("user with ID %d has been successfully blocked")
+	log.Info().Msgf("user with ID %d has been successfully blocked", args.UserID)
+
+	// revoke the tokens so the blocked user is signed out immediately
+	err = s.userRepo.DeleteAllTokens(args.UserID)
+	if err != nil {
+		return e.NewError(e.ErrRevokeToken, "failed to revoke tokens of blocked user", err)
+	}
+	log.Info().Msgf("revoked all tokens of user with ID %d", args.UserID)
 
 	return nil
 }
@@ -155,29 +168,26 @@ func (s *AdminServiceImpl) CustomerOrderHistoryById(r *http.Request) ([]*dto.Ite
 	var responses []*dto.ItemOrderedResponse
 
 	for _, order := range orderHistory {
-		var orderItems []dto.OrderItemResponse
-
-		for _, item := range order.Items {
-			orderItems = append(orderItems, dto.OrderItemResponse{
-				ProductID:  item.ProductID,
-				Quantity:   item.Quantity,
-				CategoryID: item.Product.CategoryID,
-				BrandName:  item.Product.BrandName,
-				Price:      item.Price,
-			})
-		}
+		orderItems := orderItemResponses(order.Items)
 
 		response := &dto.ItemOrderedResponse{
-			Items:      orderItems,
-			OrderID:    order.ID,
-			TotalPrice: order.Total,
-			UserDetails: dto.UserDetailsResponse{
-				Username:    userDetails.Username,
-				Email:       userDetails.Mail,
-				PhoneNumber: userDetails.Phonenumber,
-				Address:     userDetails.Address,
-				Pincode:     userDetails.Pincode,
-			},
+			Items:               orderItems,
+			OrderID:             order.ID,
+			Status:              order.Status,
+			Subtotal:            order.Subtotal,
+			Discount:            order.Discount,
+			Tax:                 order.Tax,
+			TotalPrice:          order.Total,
+			CouponCode:          order.CouponCode,
+			FreeShipping:        order.FreeShipping,
+			ShippingCharge:      order.ShippingCharge,
+			EstimatedDeliveryAt: order.EstimatedDeliveryAt,
+			RefundedAmount:      order.RefundedAmount,
+			NetTotal:            order.Total - order.RefundedAmount,
+			Refunds:             refundResponses(order.Refunds),
+			UserDetails:         orderUserDetails(&order, userDetails),
+			ShippingAddress:     orderAddressResponse(order.ShippingAddress),
+			BillingAddress:      orderAddressResponse(order.BillingAddress),
 		}
 		responses = append(responses, response)
 	}
@@ -196,34 +206,97 @@ func (s *AdminServiceImpl) CustomerOrderHistory(r *http.Request) ([]*dto.ItemOrd
 
 	// Process each order
 	for _, order := range orders {
-		var orderItems []dto.OrderItemResponse
-
-		// Process each item in the order
-		for _, item := range order.Items {
-			orderItems = append(orderItems, dto.OrderItemResponse{
-				ProductID:  item.ProductID,
-				Quantity:   item.Quantity,
-				CategoryID: item.Product.CategoryID,
-				BrandName:  item.Product.BrandName,
-				Price:      item.Price,
-			})
-		}
+		orderItems := orderItemResponses(order.Items)
 
 		// Create response for this order
 		response := &dto.ItemOrderedResponse{
-			Items:      orderItems,
-			OrderID:    order.ID,
-			TotalPrice: order.Total,
-			UserDetails: dto.UserDetailsResponse{
-				Username:    order.User.Username,
-				Email:       order.User.Mail,
-				PhoneNumber: order.User.Phonenumber,
-				Address:     order.User.Address,
-				Pincode:     order.User.Pincode,
-			},
+			Items:               orderItems,
+			OrderID:             order.ID,
+			Status:              order.Status,
+			Subtotal:            order.Subtotal,
+			Discount:            order.Discount,
+			Tax:                 order.Tax,
+			TotalPrice:          order.Total,
+			CouponCode:          order.CouponCode,
+			FreeShipping:        order.FreeShipping,
+			ShippingCharge:      order.ShippingCharge,
+			EstimatedDeliveryAt: order.EstimatedDeliveryAt,
+			RefundedAmount:      order.RefundedAmount,
+			NetTotal:            order.Total - order.RefundedAmount,
+			Refunds:             refundResponses(order.Refunds),
+			UserDetails:         orderUserDetails(&order, &order.User),
+			ShippingAddress:     orderAddressResponse(order.ShippingAddress),
+			BillingAddress:      orderAddressResponse(order.BillingAddress),
 		}
 		responses = append(responses, response)
 	}
 
 	return responses, nil
 }
+
+// UpdateOrderStatus moves an order through its lifecycle, illegal transitions are rejected
+func (s *AdminServiceImpl) UpdateOrderStatus(r *http.Request) (*dto.OrderStatusResponse, error) {
+	args := &dto.UpdateOrderStatusRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+	log.Info().Msg("Successfully completed parsing and validation of request body")
+
+	// an order is paid only when the payment provider confirms it, and cancelled or refunded only
+	// through the flows that give the money back and restock the items
+	switch args.Status {
+	case internal.OrderStatusPaid:
+		return nil, e.NewError(e.ErrInvalidOrderStatus, "orders are marked paid by a confirmed payment",
+			fmt.Errorf("order %d cannot be marked paid by an admin", args.OrderID))
+	case internal.OrderStatusCancelled, internal.OrderStatusRefunded:
+		return nil, e.NewError(e.ErrInvalidOrderStatus, "refund the order through /admin/order/{id}/refund, customers cancel through /user/order/{id}/cancel",
+			fmt.Errorf("order %d cannot be marked %s by an admin", args.OrderID, args.Status))
+	}
+
+	adminID, err := s.contextHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	order, err := s.orderRepo.UpdateOrderStatus(args.OrderID, args.Status, adminID, internal.ActorRoleAdmin, args.Note)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		if errors.Is(err, internal.ErrInvalidStatusTransition) {
+			return nil, e.NewError(e.ErrInvalidOrderStatus, "invalid order status transition", err)
+		}
+		return nil, e.NewError(e.ErrUpdateOrderStatus, "failed to update order status", err)
+	}
+	log.Info().Msgf("Order %d moved to status %s by admin %d", order.ID, order.Status, adminID)
+
+	history, err := s.orderRepo.GetOrderStatusHistory(order.ID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetOrderHistory, "failed to get order status history", err)
+	}
+
+	response := &dto.OrderStatusResponse{
+		OrderID: order.ID,
+		Status:  order.Status,
+		History: make([]dto.OrderStatusHistoryResponse, 0, len(history)),
+	}
+	for _, h := range history {
+		response.History = append(response.History, dto.OrderStatusHistoryResponse{
+			FromStatus: h.FromStatus,
+			ToStatus:   h.ToStatus,
+			ActorID:    h.ActorID,
+			ActorRole:  h.ActorRole,
+			Note:       h.Note,
+			ChangedAt:  h.CreatedAt,
+		})
+	}
+
+	return response, nil
+}
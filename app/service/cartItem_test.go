@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	hash "e-cart/pkg/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func updateCartItemRequest(userID, productID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/user/cart/item", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("productid", fmt.Sprint(productID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, userID))
+}
+
+// cartTotals returns the stored quantity and total amount of every cart line of the user by product
+func cartTotals(t *testing.T, repo internal.UserRepo, userID int64) map[int64][2]float64 {
+	lines, err := repo.ViewCart(userID)
+	require.NoError(t, err)
+	totals := map[int64][2]float64{}
+	for _, line := range lines {
+		totals[line.ProductID] = [2]float64{float64(line.Quantity), line.TotalAmount}
+	}
+	return totals
+}
+
+func TestUpdateAndRemoveCartItem(t *testing.T) {
+	db := newTestDB(t)
+	repo := internal.NewUserRepo(db)
+	svc := NewUserService(repo, helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	phone := createTestBrand(t, db, 5)
+	charger := createTestBrand(t, db, 10)
+	userID := createTestUser(t, db, "buyer")
+	otherID := createTestUser(t, db, "other")
+
+	_, err := svc.AddItemToCart(addToCartRequest(userID, phone, 2))
+	require.NoError(t, err)
+	_, err = svc.AddItemToCart(addToCartRequest(userID, charger, 1))
+	require.NoError(t, err)
+
+	// the quantity is set, not added to
+	item, err := svc.UpdateCartItem(updateCartItemRequest(userID, phone.ID, `{"quantity": 4}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), item.Quantity)
+	assert.Equal(t, 400.0, item.TotalPrice)
+	assert.Equal(t, [2]float64{4, 400}, cartTotals(t, repo, userID)[phone.ID])
+
+	// only one unit is left for anyone else while the cart holds four
+	_, err = svc.AddItemToCart(addToCartRequest(otherID, phone, 2))
+	assertErrorCode(t, e.ErrInsufficientStock, err)
+
+	_, err = svc.UpdateCartItem(updateCartItemRequest(userID, phone.ID, `{"quantity": 6}`))
+	assertErrorCode(t, e.ErrInsufficientStock, err)
+	assert.Equal(t, [2]float64{4, 400}, cartTotals(t, repo, userID)[phone.ID], "a rejected update keeps the line")
+
+	_, err = svc.UpdateCartItem(updateCartItemRequest(userID, phone.ID, `{"quantity": 0}`))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+
+	item, err = svc.UpdateCartItem(updateCartItemRequest(userID, phone.ID, `{"quantity": 1}`))
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, item.TotalPrice)
+
+	// lowering the quantity gives the reserved units back
+	_, err = svc.AddItemToCart(addToCartRequest(otherID, phone, 2))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.RemoveCartItem(removeCartItemRequest(userID, phone.ID)))
+	totals := cartTotals(t, repo, userID)
+	assert.NotContains(t, totals, phone.ID)
+	assert.Equal(t, [2]float64{1, 100}, totals[charger.ID], "the other line stays")
+
+	err = svc.RemoveCartItem(removeCartItemRequest(userID, phone.ID))
+	assertErrorCode(t, e.ErrCartNotFound, err)
+
+	_, err = svc.UpdateCartItem(updateCartItemRequest(userID, phone.ID, `{"quantity": 1}`))
+	assertErrorCode(t, e.ErrCartNotFound, err)
+}
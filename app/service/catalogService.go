@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"e-cart/app/dto"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// gallerySeparator separates the gallery links inside a single csv column
+const gallerySeparator = "|"
+
+// maxJSONLineSize is the longest line accepted in a jsonl file
+const maxJSONLineSize = 1024 * 1024
+
+type CatalogService interface {
+	ImportCatalog(r *http.Request) (*dto.CatalogImportResponse, error)
+	ExportCatalog(r *http.Request) (*dto.CatalogExport, error)
+	Import(reader io.Reader, format string, dryRun bool) (*dto.CatalogImportResponse, error)
+	Export(writer io.Writer, format string) error
+}
+
+type catalogServiceImpl struct {
+	catalogRepo internal.CatalogRepo
+}
+
+func NewCatalogService(catalogRepo internal.CatalogRepo) CatalogService {
+	return &catalogServiceImpl{
+		catalogRepo: catalogRepo,
+	}
+}
+
+// ImportCatalog imports the csv or jsonl file sent as the request body
+func (s *catalogServiceImpl) ImportCatalog(r *http.Request) (*dto.CatalogImportResponse, error) {
+	args := &dto.CatalogImportRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing query params", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	return s.Import(r.Body, args.Format, args.DryRun)
+}
+
+// ExportCatalog returns the whole catalog as a csv or jsonl file
+func (s *catalogServiceImpl) ExportCatalog(r *http.Request) (*dto.CatalogExport, error) {
+	args := &dto.CatalogExportRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing query params", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	var buf bytes.Buffer
+	err = s.Export(&buf, args.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "text/csv"
+	if args.Format == dto.CatalogFormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	return &dto.CatalogExport{
+		FileName:    "catalog." + args.Format,
+		ContentType: contentType,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+// Import reads every row of the file, rows that cannot be read or fail validation are reported
+// and the rest are upserted. Nothing is written on a dry run
+func (s *catalogServiceImpl) Import(reader io.Reader, format string, dryRun bool) (*dto.CatalogImportResponse, error) {
+	var lines []dto.CatalogLine
+	var failures []dto.CatalogImportRowResult
+	var err error
+
+	switch format {
+	case dto.CatalogFormatCSV:
+		lines, failures, err = readCatalogCSV(reader)
+	case dto.CatalogFormatJSONL:
+		lines, failures, err = readCatalogJSONL(reader)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, e.NewError(e.ErrValidateRequest, fmt.Sprintf("the catalog file is larger than %d bytes", tooLarge.Limit), err)
+	}
+	if err != nil {
+		return nil, e.NewError(e.ErrImportCatalog, "error while reading the catalog file", err)
+	}
+
+	// rows are validated before anything is written
+	validLines := make([]dto.CatalogLine, 0, len(lines))
+	for _, line := range lines {
+		if err := line.Row.Validate(); err != nil {
+			failures = append(failures, failedCatalogRow(line.Line, &line.Row, err))
+			continue
+		}
+		validLines = append(validLines, line)
+	}
+	if len(validLines) == 0 && len(failures) == 0 {
+		return nil, e.NewError(e.ErrValidateRequest, "the catalog file has no rows", errors.New("empty file"))
+	}
+
+	results, err := s.catalogRepo.ImportRows(validLines, dryRun)
+	if err != nil {
+		return nil, e.NewError(e.ErrImportCatalog, "error while importing the catalog", err)
+	}
+	results = append(results, failures...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Line < results[j].Line
+	})
+
+	resp := &dto.CatalogImportResponse{
+		DryRun: dryRun,
+		Total:  len(results),
+		Rows:   results,
+	}
+	for _, result := range results {
+		switch result.Action {
+		case dto.CatalogActionCreated:
+			resp.Created++
+		case dto.CatalogActionUpdated:
+			resp.Updated++
+		case dto.CatalogActionUnchanged:
+			resp.Unchanged++
+		case dto.CatalogActionFailed:
+			resp.Failed++
+		}
+	}
+	log.Info().Msgf("Catalog import (dry run %t): %d created, %d updated, %d unchanged, %d failed",
+		dryRun, resp.Created, resp.Updated, resp.Unchanged, resp.Failed)
+
+	return resp, nil
+}
+
+// Export writes the catalog that is not deleted in the given format
+func (s *catalogServiceImpl) Export(writer io.Writer, format string) error {
+	if format != dto.CatalogFormatCSV && format != dto.CatalogFormatJSONL {
+		return e.NewError(e.ErrValidateRequest, "unsupported export format", fmt.Errorf("unsupported format %q", format))
+	}
+
+	rows, err := s.catalogRepo.ExportRows()
+	if err != nil {
+		return e.NewError(e.ErrExportCatalog, "error while loading the catalog", err)
+	}
+
+	if format == dto.CatalogFormatJSONL {
+		err = writeCatalogJSONL(writer, rows)
+	} else {
+		err = writeCatalogCSV(writer, rows)
+	}
+	if err != nil {
+		return e.NewError(e.ErrExportCatalog, "error while writing the catalog", err)
+	}
+	log.Info().Msgf("Exported %d catalog rows as %s", len(rows), format)
+
+	return nil
+}
+
+func failedCatalogRow(line int, row *dto.CatalogRow, err error) dto.CatalogImportRowResult {
+	result := dto.CatalogImportRowResult{
+		Line:   line,
+		Action: dto.CatalogActionFailed,
+		Error:  err.Error(),
+	}
+	if row != nil {
+		result.CategoryName = row.CategoryName
+		result.BrandName = row.BrandName
+		result.Model = row.Model
+	}
+	return result
+}
+
+// readCatalogCSV reads a csv file with a header row, the columns can be in any order
+func readCatalogCSV(reader io.Reader) ([]dto.CatalogLine, []dto.CatalogImportRowResult, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	known := make(map[string]bool, len(dto.CatalogColumns))
+	for _, column := range dto.CatalogColumns {
+		known[column] = true
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !known[column] {
+			return nil, nil, fmt.Errorf("unknown column %q", column)
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["category_name"]; !ok {
+		return nil, nil, errors.New("category_name column is required")
+	}
+
+	var lines []dto.CatalogLine
+	var failures []dto.CatalogImportRowResult
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// a row with the wrong number of fields is reported, anything else means the file is broken
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, nil, err
+		}
+		line, _ := csvReader.FieldPos(0)
+		if err != nil {
+			failures = append(failures, failedCatalogRow(line, nil, err))
+			continue
+		}
+
+		row, err := catalogRowFromRecord(record, columns)
+		if err != nil {
+			failures = append(failures, failedCatalogRow(line, row, err))
+			continue
+		}
+		lines = append(lines, dto.CatalogLine{Line: line, Row: *row})
+	}
+	return lines, failures, nil
+}
+
+func catalogRowFromRecord(record []string, columns map[string]int) (*dto.CatalogRow, error) {
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := &dto.CatalogRow{
+		CategoryName:        value("category_name"),
+		CategoryDescription: value("category_description"),
+		BrandName:           value("brand_name"),
+		Model:               value("model"),
+		Description:         value("description"),
+		ImageLink:           value("image_link"),
+		ReleaseDate:         value("release_date"),
+	}
+	for _, link := range strings.Split(value("gallery_links"), gallerySeparator) {
+		if link = strings.TrimSpace(link); link != "" {
+			row.GalleryLinks = append(row.GalleryLinks, link)
+		}
+	}
+
+	var err error
+	if price := value("price"); price != "" {
+		row.Price, err = strconv.ParseFloat(price, 64)
+		if err != nil {
+			return row, fmt.Errorf("invalid price: %v", err)
+		}
+	}
+	if stock := value("stock_count"); stock != "" {
+		count, err := strconv.ParseInt(stock, 10, 64)
+		if err != nil {
+			return row, fmt.Errorf("invalid stock_count: %v", err)
+		}
+		row.StockCount = &count
+	}
+	return row, nil
+}
+
+// readCatalogJSONL reads one json object per line, blank lines are skipped
+func readCatalogJSONL(reader io.Reader) ([]dto.CatalogLine, []dto.CatalogImportRowResult, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLineSize)
+
+	var lines []dto.CatalogLine
+	var failures []dto.CatalogImportRowResult
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var row dto.CatalogRow
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row); err != nil {
+			failures = append(failures, failedCatalogRow(lineNumber, nil, err))
+			continue
+		}
+		lines = append(lines, dto.CatalogLine{Line: lineNumber, Row: row})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return lines, failures, nil
+}
+
+func writeCatalogCSV(writer io.Writer, rows []dto.CatalogRow) error {
+	csvWriter := csv.NewWriter(writer)
+	if err := csvWriter.Write(dto.CatalogColumns); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := []string{
+			row.CategoryName,
+			row.CategoryDescription,
+			row.BrandName,
+			row.Model,
+			"",
+			"",
+			row.Description,
+			row.ImageLink,
+			strings.Join(row.GalleryLinks, gallerySeparator),
+			row.ReleaseDate,
+		}
+		// category only rows leave the brand numbers empty
+		if row.BrandName != "" {
+			record[4] = strconv.FormatFloat(row.Price, 'f', -1, 64)
+			if row.StockCount != nil {
+				record[5] = strconv.FormatInt(*row.StockCount, 10)
+			}
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func writeCatalogJSONL(writer io.Writer, rows []dto.CatalogRow) error {
+	encoder := json.NewEncoder(writer)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"e-cart/app/dto"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCatalogCSV = `category_name,category_description,brand_name,model,price,stock_count,gallery_links,release_date
+mobile,phones,iphone,15,79999,10,https://img.example.com/1.png|https://img.example.com/2.png,2023-09-22
+mobile,,samsung,S24,69999,5,,
+laptop,computers,,,,,,
+mobile,,pixel,8,not a price,3,,
+mobile,,nokia,,1000,3,,
+`
+
+func TestImportCatalogCSV(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewCatalogService(internal.NewCatalogRepo(db))
+
+	// dry run reports the changes without writing anything
+	resp, err := svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, true)
+	require.NoError(t, err)
+	assert.True(t, resp.DryRun)
+	assert.Equal(t, 3, resp.Created)
+	assert.Equal(t, 2, resp.Failed)
+
+	var brands int64
+	require.NoError(t, db.Model(&internal.Brand{}).Count(&brands).Error)
+	assert.Equal(t, int64(0), brands)
+
+	resp, err = svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Created)
+	assert.Equal(t, 2, resp.Failed)
+	require.Len(t, resp.Rows, 5)
+	assert.Equal(t, 5, resp.Rows[3].Line)
+	assert.Contains(t, resp.Rows[3].Error, "invalid price")
+	assert.Contains(t, resp.Rows[4].Error, "model is required")
+
+	// importing the same file again changes nothing
+	resp, err = svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, resp.Created)
+	assert.Equal(t, 0, resp.Updated)
+	assert.Equal(t, 3, resp.Unchanged)
+
+	// stock is set to the value in the file, not added to it
+	update := "category_name,brand_name,model,price,stock_count\nMobile,IPHONE,15,74999,7\n"
+	resp, err = svc.Import(strings.NewReader(update), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Updated)
+
+	var iphone internal.Brand
+	require.NoError(t, db.Where("brandname = ?", "IPHONE").First(&iphone).Error)
+	assert.Equal(t, int64(7), iphone.StockCount)
+	assert.Equal(t, float64(74999), iphone.Price)
+	assert.Len(t, iphone.GalleryLinks, 2)
+}
+
+func TestExportCatalogRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewCatalogService(internal.NewCatalogRepo(db))
+
+	_, err := svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+
+	for _, format := range []string{dto.CatalogFormatCSV, dto.CatalogFormatJSONL} {
+		var buf bytes.Buffer
+		require.NoError(t, svc.Export(&buf, format))
+
+		resp, err := svc.Import(&buf, format, true)
+		require.NoError(t, err, format)
+		assert.Equal(t, 3, resp.Total, format)
+		assert.Equal(t, 3, resp.Unchanged, format)
+	}
+}
+
+func TestImportCatalogDeletedAndOversized(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewCatalogService(internal.NewCatalogRepo(db))
+
+	_, err := svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&internal.Brand{}).Where("brandname = ?", "SAMSUNG").Update("is_deleted", true).Error)
+	require.NoError(t, db.Model(&internal.Category{}).Where("categoryname = ?", "Laptop").Update("is_deleted", true).Error)
+
+	// deleted brands and categories are reported, not written to
+	update := "category_name,brand_name,model,price,stock_count\nmobile,samsung,S24,59999,50\nlaptop,dell,XPS,99999,2\nmobile,iphone,15,79999,10\n"
+	resp, err := svc.Import(strings.NewReader(update), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.Failed)
+	assert.Equal(t, 1, resp.Unchanged)
+	require.Len(t, resp.Rows, 3)
+	assert.Contains(t, resp.Rows[0].Error, "brand SAMSUNG S24 is deleted")
+	assert.Contains(t, resp.Rows[1].Error, "category Laptop is deleted")
+
+	var samsung internal.Brand
+	require.NoError(t, db.Where("brandname = ?", "SAMSUNG").First(&samsung).Error)
+	assert.Equal(t, int64(5), samsung.StockCount)
+	var dells int64
+	require.NoError(t, db.Model(&internal.Brand{}).Where("brandname = ?", "DELL").Count(&dells).Error)
+	assert.Zero(t, dells)
+
+	// the body is cut off at the limit
+	body := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(testCatalogCSV)), 32)
+	_, err = svc.Import(body, dto.CatalogFormatCSV, false)
+	assertErrorCode(t, e.ErrValidateRequest, err)
+}
+
+func TestImportCatalogWithoutStock(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewCatalogService(internal.NewCatalogRepo(db))
+
+	_, err := svc.Import(strings.NewReader(testCatalogCSV), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+
+	// a price sheet without stock_count, or with the cell blank, keeps the stock
+	prices := "category_name,brand_name,model,price\nmobile,iphone,15,74999\nmobile,oneplus,12,49999\n"
+	resp, err := svc.Import(strings.NewReader(prices), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Updated)
+	assert.Equal(t, 1, resp.Created)
+
+	blank := "category_name,brand_name,model,price,stock_count\nmobile,samsung,S24,59999,\n"
+	resp, err = svc.Import(strings.NewReader(blank), dto.CatalogFormatCSV, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Updated)
+
+	jsonl := `{"category_name": "mobile", "brand_name": "samsung", "model": "S24", "price": 59999}` + "\n"
+	resp, err = svc.Import(strings.NewReader(jsonl), dto.CatalogFormatJSONL, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Unchanged)
+
+	stock := map[string]int64{}
+	var brands []internal.Brand
+	require.NoError(t, db.Find(&brands).Error)
+	for _, brand := range brands {
+		stock[brand.BrandName] = brand.StockCount
+	}
+	assert.Equal(t, map[string]int64{"IPHONE": 10, "SAMSUNG": 5, "ONEPLUS": 0}, stock)
+
+	var adjustments int64
+	require.NoError(t, db.Model(&internal.InventoryMovement{}).Where("type = ?", internal.MovementAdjustment).Count(&adjustments).Error)
+	assert.Zero(t, adjustments)
+}
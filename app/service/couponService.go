@@ -0,0 +1,182 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type CouponService interface {
+	CreateCoupon(r *http.Request) (*dto.CouponResponse, error)
+	GetCoupon(r *http.Request) (*dto.CouponResponse, error)
+	ListCoupons(r *http.Request) ([]*dto.CouponResponse, *dto.PageMeta, error)
+	UpdateCoupon(r *http.Request) (*dto.CouponResponse, error)
+	DeleteCoupon(r *http.Request) error
+}
+
+type couponServiceImpl struct {
+	couponRepo internal.CouponRepo
+}
+
+func NewCouponService(couponRepo internal.CouponRepo) CouponService {
+	return &couponServiceImpl{
+		couponRepo: couponRepo,
+	}
+}
+
+func (s *couponServiceImpl) CreateCoupon(r *http.Request) (*dto.CouponResponse, error) {
+	args := &dto.CreateCouponRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	coupon, err := s.couponRepo.CreateCoupon(args)
+	if err != nil {
+		return nil, couponError(err, e.ErrCreateCoupon, "failed to create the coupon")
+	}
+	log.Info().Msgf("Created coupon %s", coupon.Code)
+
+	return couponResponse(coupon), nil
+}
+
+func (s *couponServiceImpl) GetCoupon(r *http.Request) (*dto.CouponResponse, error) {
+	args := &dto.CouponIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	coupon, err := s.couponRepo.GetCouponByID(args.CouponID)
+	if err != nil {
+		return nil, couponError(err, e.ErrListCoupons, "failed to get the coupon")
+	}
+	return couponResponse(coupon), nil
+}
+
+func (s *couponServiceImpl) ListCoupons(r *http.Request) ([]*dto.CouponResponse, *dto.PageMeta, error) {
+	args := &dto.ListCouponsRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	coupons, total, err := s.couponRepo.ListCoupons(args)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrListCoupons, "failed to list the coupons", err)
+	}
+
+	resp := make([]*dto.CouponResponse, 0, len(coupons))
+	for i := range coupons {
+		resp = append(resp, couponResponse(&coupons[i]))
+	}
+	return resp, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+func (s *couponServiceImpl) UpdateCoupon(r *http.Request) (*dto.CouponResponse, error) {
+	args := &dto.UpdateCouponRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	coupon, err := s.couponRepo.UpdateCoupon(args)
+	if err != nil {
+		return nil, couponError(err, e.ErrUpdateCoupon, "failed to update the coupon")
+	}
+	log.Info().Msgf("Updated coupon %s", coupon.Code)
+
+	return couponResponse(coupon), nil
+}
+
+func (s *couponServiceImpl) DeleteCoupon(r *http.Request) error {
+	args := &dto.CouponIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	err = s.couponRepo.DeleteCoupon(args.CouponID)
+	if err != nil {
+		return couponError(err, e.ErrDeleteCoupon, "failed to delete the coupon")
+	}
+	log.Info().Msgf("Deleted coupon %d", args.CouponID)
+
+	return nil
+}
+
+// couponError maps the repo errors of the coupons
+func couponError(err error, code int, msg string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrCouponNotFound, "coupon not found", err)
+	}
+	if errors.Is(err, internal.ErrInvalidCoupon) {
+		return e.NewError(e.ErrValidateRequest, "invalid coupon settings", err)
+	}
+	if errors.Is(err, internal.ErrDuplicateCoupon) {
+		return e.NewError(e.ErrCouponAlreadyExists, "coupon code already exists", err)
+	}
+	if errors.Is(err, internal.ErrCouponInUse) {
+		return e.NewError(e.ErrCouponInUse, "coupon was already used, deactivate it instead", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func couponResponse(coupon *internal.Coupon) *dto.CouponResponse {
+	return &dto.CouponResponse{
+		CouponID:     coupon.ID,
+		Code:         coupon.Code,
+		Description:  coupon.Description,
+		Type:         coupon.Type,
+		Value:        coupon.Value,
+		MaxDiscount:  coupon.MaxDiscount,
+		BuyQuantity:  coupon.BuyQuantity,
+		GetQuantity:  coupon.GetQuantity,
+		CategoryID:   coupon.CategoryID,
+		BrandID:      coupon.BrandID,
+		MinCartValue: coupon.MinCartValue,
+		UsageLimit:   coupon.UsageLimit,
+		PerUserLimit: coupon.PerUserLimit,
+		UsedCount:    coupon.UsedCount,
+		StartsAt:     coupon.StartsAt,
+		EndsAt:       coupon.EndsAt,
+		Active:       coupon.Active,
+		CreatedAt:    coupon.CreatedAt,
+		UpdatedAt:    coupon.UpdatedAt,
+	}
+}
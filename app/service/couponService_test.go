@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func couponRequest(userID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/user/cart/coupon", strings.NewReader(body))
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+}
+
+func TestCouponAppliedAtCheckout(t *testing.T) {
+	env := newOrderTestEnv(t)
+	coupons := NewCouponService(internal.NewCouponRepo(env.db))
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+
+	_, err := coupons.CreateCoupon(couponRequest(1, `{"code": "save10", "type": "percentage", "value": 120}`))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+	created, err := coupons.CreateCoupon(couponRequest(1,
+		`{"code": "save10", "type": "percentage", "value": 10, "min_cart_value": 250, "per_user_limit": 1}`))
+	require.NoError(t, err)
+	assert.Equal(t, "SAVE10", created.Code)
+	assert.True(t, created.Active)
+	_, err = coupons.CreateCoupon(couponRequest(1, `{"code": "SAVE10", "type": "fixed", "value": 5}`))
+	assertErrorCode(t, e.ErrCouponAlreadyExists, err)
+
+	cartID := createTestCartLine(t, env.db, userID, brand, 2)
+	_, err = env.users.ApplyCoupon(couponRequest(userID, `{"code": "nope"}`))
+	assertErrorCode(t, e.ErrCouponNotFound, err)
+	_, err = env.users.ApplyCoupon(couponRequest(userID, `{"code": "save10"}`))
+	assertErrorCode(t, e.ErrCouponNotApplicable, err)
+
+	require.NoError(t, env.db.Model(&internal.Cart{}).Where("id = ?", cartID).Update("quantity", 3).Error)
+	cart, err := env.users.ApplyCoupon(couponRequest(userID, `{"code": "save10"}`))
+	require.NoError(t, err)
+	require.NotNil(t, cart.Coupon)
+	assert.True(t, cart.Coupon.Applicable)
+	assert.InDelta(t, 300, cart.Subtotal, 0.001)
+	assert.InDelta(t, 30, cart.Discount, 0.001)
+	assert.InDelta(t, 270, cart.Total, 0.001)
+
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "SAVE10", order.CouponCode)
+	assert.InDelta(t, 30, order.Discount, 0.001)
+	assert.InDelta(t, 270, order.TotalPrice, 0.001)
+
+	var stored internal.Order
+	require.NoError(t, env.db.Preload("Items").First(&stored, order.OrderID).Error)
+	assert.InDelta(t, 270, stored.Total, 0.001)
+	var itemDiscount float64
+	for _, item := range stored.Items {
+		itemDiscount += item.Discount
+	}
+	assert.InDelta(t, 30, itemDiscount, 0.001)
+
+	used, err := coupons.GetCoupon(couponIDRequest(created.CouponID))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), used.UsedCount)
+	err = coupons.DeleteCoupon(couponIDRequest(created.CouponID))
+	assertErrorCode(t, e.ErrCouponInUse, err)
+
+	// the coupon left the cart with the order and can be used once per user
+	cartAfter, err := env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	assert.Nil(t, cartAfter.Coupon)
+	createTestCartLine(t, env.db, userID, brand, 3)
+	_, err = env.users.ApplyCoupon(couponRequest(userID, `{"code": "save10"}`))
+	assertErrorCode(t, e.ErrCouponNotApplicable, err)
+}
+
+func TestCouponRefundsWhatWasPaid(t *testing.T) {
+	env := newOrderTestEnv(t)
+	coupons := NewCouponService(internal.NewCouponRepo(env.db))
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+
+	created, err := coupons.CreateCoupon(couponRequest(1, `{"code": "FLAT50", "type": "fixed", "value": 50}`))
+	require.NoError(t, err)
+	require.NoError(t, internal.NewUserRepo(env.db).SetCartCoupon(userID, created.CouponID))
+
+	orderID := env.placeOrder(t, userID, brand, 3, true)
+	var order internal.Order
+	require.NoError(t, env.db.First(&order, orderID).Error)
+	assert.InDelta(t, 250, order.Total, 0.001)
+
+	resp, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	require.NotNil(t, resp.Refund)
+	assert.InDelta(t, 250, resp.Refund.Amount, 0.001)
+}
+
+func TestCouponReleasedByCancelAndRefund(t *testing.T) {
+	env := newOrderTestEnv(t)
+	coupons := NewCouponService(internal.NewCouponRepo(env.db))
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+
+	created, err := coupons.CreateCoupon(couponRequest(1, `{"code": "ONCE50", "type": "fixed", "value": 50, "per_user_limit": 1}`))
+	require.NoError(t, err)
+	usedCount := func() int64 {
+		var coupon internal.Coupon
+		require.NoError(t, env.db.First(&coupon, created.CouponID).Error)
+		return coupon.UsedCount
+	}
+
+	// a cancelled order gives the use back
+	require.NoError(t, internal.NewUserRepo(env.db).SetCartCoupon(userID, created.CouponID))
+	orderID := env.placeOrder(t, userID, brand, 2, false)
+	assert.Equal(t, int64(1), usedCount())
+	_, err = env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usedCount())
+
+	// and so does a fully refunded one, a partial refund keeps it
+	require.NoError(t, internal.NewUserRepo(env.db).SetCartCoupon(userID, created.CouponID))
+	orderID = env.placeOrder(t, userID, brand, 2, true)
+	assert.Equal(t, int64(1), usedCount())
+	itemID := orderItemIDs(t, env, orderID)[0]
+	body := fmt.Sprintf(`{"reason": "damaged", "items": [{"order_item_id": %d, "quantity": 1}]}`, itemID)
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, body))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usedCount())
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "out of stock"}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usedCount())
+
+	var redemptions int64
+	require.NoError(t, env.db.Model(&internal.CouponRedemption{}).Where("coupon_id = ?", created.CouponID).Count(&redemptions).Error)
+	assert.Zero(t, redemptions)
+
+	createTestCartLine(t, env.db, userID, brand, 1)
+	cart, err := env.users.ApplyCoupon(couponRequest(userID, `{"code": "once50"}`))
+	require.NoError(t, err)
+	assert.True(t, cart.Coupon.Applicable)
+}
+
+func couponIDRequest(couponID int64) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/admin/coupons", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("couponid", fmt.Sprint(couponID))
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type InventoryService interface {
+	AdjustStock(r *http.Request) (*dto.InventoryMovementResponse, error)
+	StockHistory(r *http.Request) ([]*dto.InventoryMovementResponse, *dto.PageMeta, error)
+	ReconcileStock(r *http.Request) ([]*dto.StockDiscrepancyResponse, error)
+	LowStock(r *http.Request) ([]*dto.LowStockResponse, *dto.PageMeta, error)
+}
+
+type inventoryServiceImpl struct {
+	inventoryRepo internal.InventoryRepo
+	ctxHelper     helper.ContextHelper
+}
+
+func NewInventoryService(inventoryRepo internal.InventoryRepo, ctxHelper helper.ContextHelper) InventoryService {
+	return &inventoryServiceImpl{
+		inventoryRepo: inventoryRepo,
+		ctxHelper:     ctxHelper,
+	}
+}
+
+// AdjustStock posts a manual stock movement with the reason for it
+func (s *inventoryServiceImpl) AdjustStock(r *http.Request) (*dto.InventoryMovementResponse, error) {
+	args := &dto.StockAdjustmentRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+	log.Info().Msg("Successfully completed parsing and validation of request body")
+
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	movement := &internal.InventoryMovement{
+		BrandID:       args.BrandID,
+		Type:          args.Type,
+		Quantity:      args.Quantity,
+		Reason:        args.Reason,
+		ReferenceType: internal.MovementRefAdmin,
+		ActorID:       adminID,
+	}
+	err = s.inventoryRepo.AdjustStock(movement)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrBrandNotFound, "brand not found", err)
+		}
+		if errors.Is(err, internal.ErrInsufficientStock) {
+			return nil, e.NewError(e.ErrInsufficientStock, "stock cannot go below zero", err)
+		}
+		return nil, e.NewError(e.ErrAdjustStock, "failed to adjust the stock", err)
+	}
+	log.Info().Msgf("Admin %d adjusted stock of brand %d by %d, balance %d", adminID, args.BrandID, args.Quantity, movement.BalanceAfter)
+
+	return movementResponse(movement), nil
+}
+
+// StockHistory lists the movements of a brand, newest first
+func (s *inventoryServiceImpl) StockHistory(r *http.Request) ([]*dto.InventoryMovementResponse, *dto.PageMeta, error) {
+	args := &dto.StockHistoryRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	movements, total, err := s.inventoryRepo.GetMovements(args.BrandID, args.Offset(), args.PageSize)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, e.NewError(e.ErrBrandNotFound, "brand not found", err)
+		}
+		return nil, nil, e.NewError(e.ErrGetStockHistory, "failed to get the stock history", err)
+	}
+
+	resp := make([]*dto.InventoryMovementResponse, 0, len(movements))
+	for i := range movements {
+		resp = append(resp, movementResponse(&movements[i]))
+	}
+
+	return resp, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+// ReconcileStock reports the brands whose stock count does not match the ledger, and fixes them when asked to
+func (s *inventoryServiceImpl) ReconcileStock(r *http.Request) ([]*dto.StockDiscrepancyResponse, error) {
+	args := &dto.ReconcileStockRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	discrepancies, err := s.inventoryRepo.Reconcile(args.Apply)
+	if err != nil {
+		return nil, e.NewError(e.ErrReconcileStock, "failed to reconcile the stock", err)
+	}
+	log.Info().Msgf("Stock reconcile found %d mismatches (apply %t)", len(discrepancies), args.Apply)
+
+	resp := make([]*dto.StockDiscrepancyResponse, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		resp = append(resp, &dto.StockDiscrepancyResponse{
+			BrandID:     d.BrandID,
+			BrandName:   d.BrandName,
+			StockCount:  d.StockCount,
+			LedgerStock: d.LedgerStock,
+			Reconciled:  d.Reconciled,
+		})
+	}
+	return resp, nil
+}
+
+// LowStock lists the brands at or below their reorder threshold, sold out ones first
+func (s *inventoryServiceImpl) LowStock(r *http.Request) ([]*dto.LowStockResponse, *dto.PageMeta, error) {
+	args := &dto.LowStockRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	brands, total, err := s.inventoryRepo.GetLowStock(args)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrGetLowStock, "failed to list the brands low on stock", err)
+	}
+
+	resp := make([]*dto.LowStockResponse, 0, len(brands))
+	for _, brand := range brands {
+		resp = append(resp, &dto.LowStockResponse{
+			BrandID:          brand.ID,
+			BrandName:        brand.BrandName,
+			Model:            brand.BrandModel,
+			CategoryID:       brand.CategoryID,
+			CategoryName:     brand.Category.Categoryname,
+			StockCount:       brand.StockCount,
+			ReorderThreshold: brand.ReorderThreshold,
+			Status:           dto.StockStatus(brand.StockCount, brand.ReorderThreshold),
+		})
+	}
+
+	return resp, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+func movementResponse(movement *internal.InventoryMovement) *dto.InventoryMovementResponse {
+	return &dto.InventoryMovementResponse{
+		ID:            movement.ID,
+		BrandID:       movement.BrandID,
+		Type:          movement.Type,
+		Quantity:      movement.Quantity,
+		BalanceAfter:  movement.BalanceAfter,
+		Reason:        movement.Reason,
+		ReferenceType: movement.ReferenceType,
+		ReferenceID:   movement.ReferenceID,
+		ActorID:       movement.ActorID,
+		CreatedAt:     movement.CreatedAt,
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	hash "e-cart/pkg/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func adjustStockRequest(adminID, brandID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/admin/inventory/adjust", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("brandid", fmt.Sprint(brandID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, adminID))
+}
+
+// recordingNotifier hands the events to the test
+type recordingNotifier struct {
+	events chan notifier.Event
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, event notifier.Event) error {
+	n.events <- event
+	return nil
+}
+
+func TestInventoryLedger(t *testing.T) {
+	db := newTestDB(t)
+	inventory := NewInventoryService(internal.NewInventoryRepo(db), helper.NewContextHelper())
+	users := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+
+	brand := createTestBrand(t, db, 5)
+	require.NoError(t, internal.SeedOpeningBalances(db))
+
+	// a sale is recorded when an order is placed
+	userID := createTestUser(t, db, "buyer")
+	createTestCartLine(t, db, userID, brand, 2)
+	_, err := users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+
+	movement, err := inventory.AdjustStock(adjustStockRequest(1, brand.ID, `{"type": "receipt", "quantity": 10, "reason": "supplier delivery"}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), movement.BalanceAfter)
+
+	// stock cannot go below zero
+	_, err = inventory.AdjustStock(adjustStockRequest(1, brand.ID, `{"type": "adjustment", "quantity": -20, "reason": "stock count"}`))
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, e.ErrInsufficientStock, wrapErr.ErrorCode)
+
+	var movements []internal.InventoryMovement
+	require.NoError(t, db.Where("brand_id = ?", brand.ID).Order("id").Find(&movements).Error)
+	require.Len(t, movements, 3)
+	assert.Equal(t, internal.MovementAdjustment, movements[0].Type)
+	assert.Equal(t, internal.MovementSale, movements[1].Type)
+	assert.Equal(t, int64(-2), movements[1].Quantity)
+	assert.Equal(t, int64(1), movements[2].ActorID)
+
+	reconcileReq := httptest.NewRequest(http.MethodPost, "/admin/inventory/reconcile", nil)
+	discrepancies, err := inventory.ReconcileStock(reconcileReq)
+	require.NoError(t, err)
+	assert.Empty(t, discrepancies)
+
+	// a change made outside of the ledger shows up and is put back
+	require.NoError(t, db.Model(&internal.Brand{}).Where("id = ?", brand.ID).Update("stockcount", 50).Error)
+	discrepancies, err = inventory.ReconcileStock(httptest.NewRequest(http.MethodPost, "/admin/inventory/reconcile?apply=true", nil))
+	require.NoError(t, err)
+	require.Len(t, discrepancies, 1)
+	assert.Equal(t, int64(13), discrepancies[0].LedgerStock)
+
+	var stock internal.Brand
+	require.NoError(t, db.First(&stock, brand.ID).Error)
+	assert.Equal(t, int64(13), stock.StockCount)
+}
+
+func TestLowStockAlert(t *testing.T) {
+	db := newTestDB(t)
+	inventory := NewInventoryService(internal.NewInventoryRepo(db), helper.NewContextHelper())
+	alerts := &recordingNotifier{events: make(chan notifier.Event, 10)}
+	users := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), alerts)
+
+	brand := createTestBrand(t, db, 5)
+	require.NoError(t, db.Model(brand).Update("reorder_threshold", 2).Error)
+	createTestBrand(t, db, 10)
+
+	// the order taking the stock from 5 to 2 crosses the threshold
+	first := createTestUser(t, db, "first")
+	createTestCartLine(t, db, first, brand, 3)
+	order, err := users.PlaceOrder(placeOrderRequest(first, ""))
+	require.NoError(t, err)
+
+	select {
+	case event := <-alerts.events:
+		assert.Equal(t, notifier.EventLowStock, event.Type)
+		payload, ok := event.Payload.(dto.LowStockEvent)
+		require.True(t, ok)
+		assert.Equal(t, brand.ID, payload.BrandID)
+		assert.Equal(t, int64(5), payload.PreviousStock)
+		assert.Equal(t, int64(2), payload.StockCount)
+		assert.Equal(t, dto.StockStatusLow, payload.Status)
+		assert.Equal(t, order.OrderID, payload.OrderID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no low stock alert was sent")
+	}
+
+	// already low, the next sale does not alert again
+	second := createTestUser(t, db, "second")
+	createTestCartLine(t, db, second, brand, 2)
+	_, err = users.PlaceOrder(placeOrderRequest(second, ""))
+	require.NoError(t, err)
+	select {
+	case event := <-alerts.events:
+		t.Fatalf("unexpected alert %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	low, meta, err := inventory.LowStock(httptest.NewRequest(http.MethodGet, "/admin/inventory/low-stock", nil))
+	require.NoError(t, err)
+	require.Len(t, low, 1)
+	assert.Equal(t, int64(1), meta.Total)
+	assert.Equal(t, brand.ID, low[0].BrandID)
+	assert.Equal(t, dto.StockStatusOut, low[0].Status)
+
+	low, _, err = inventory.LowStock(httptest.NewRequest(http.MethodGet, "/admin/inventory/low-stock?status=low", nil))
+	require.NoError(t, err)
+	assert.Empty(t, low)
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/invoice"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type InvoiceService interface {
+	UserInvoice(r *http.Request) (*dto.InvoiceFile, error)
+	AdminInvoice(r *http.Request) (*dto.InvoiceFile, error)
+}
+
+type invoiceServiceImpl struct {
+	invoiceRepo internal.InvoiceRepo
+	ctxHelper   helper.ContextHelper
+	seller      invoice.Party
+}
+
+// NewInvoiceService creates the invoice service, seller is printed on every invoice
+func NewInvoiceService(invoiceRepo internal.InvoiceRepo, ctxHelper helper.ContextHelper, seller invoice.Party) InvoiceService {
+	return &invoiceServiceImpl{
+		invoiceRepo: invoiceRepo,
+		ctxHelper:   ctxHelper,
+		seller:      seller,
+	}
+}
+
+// UserInvoice renders the invoice of an order of the user
+func (s *invoiceServiceImpl) UserInvoice(r *http.Request) (*dto.InvoiceFile, error) {
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+	return s.renderInvoice(r, func(order *internal.Order) error {
+		if order.UserID != userID {
+			return e.NewError(e.ErrOrderNotFound, "order not found", fmt.Errorf("order %d does not belong to user %d", order.ID, userID))
+		}
+		return nil
+	})
+}
+
+// AdminInvoice renders the invoice of any order
+func (s *invoiceServiceImpl) AdminInvoice(r *http.Request) (*dto.InvoiceFile, error) {
+	return s.renderInvoice(r, func(*internal.Order) error { return nil })
+}
+
+// renderInvoice renders the invoice issued when the order was paid in the asked format, allowed decides
+// if the caller gets to see the order
+func (s *invoiceServiceImpl) renderInvoice(r *http.Request, allowed func(order *internal.Order) error) (*dto.InvoiceFile, error) {
+	args := &dto.InvoiceRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	order, err := s.invoiceRepo.GetOrder(args.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		return nil, e.NewError(e.ErrGetOrderHistory, "error while getting the order", err)
+	}
+	if err := allowed(order); err != nil {
+		return nil, err
+	}
+
+	if !order.CanInvoice() {
+		return nil, e.NewError(e.ErrInvoiceNotAvailable, "invoices are only issued for paid orders",
+			fmt.Errorf("order %d is %s", order.ID, order.Status))
+	}
+	issued, err := s.invoiceRepo.GetInvoice(order.ID)
+	if err != nil {
+		if errors.Is(err, internal.ErrInvoiceNotAvailable) {
+			return nil, e.NewError(e.ErrInvoiceNotAvailable, "the invoice of the order is not issued yet", err)
+		}
+		return nil, e.NewError(e.ErrRenderInvoice, "error while getting the invoice", err)
+	}
+
+	doc := invoiceDocument(order, issued, s.seller)
+	file := &dto.InvoiceFile{FileName: issued.Number + "." + args.Format}
+	if args.Format == dto.InvoiceFormatHTML {
+		file.ContentType = "text/html; charset=utf-8"
+		file.Data, err = invoice.RenderHTML(doc)
+	} else {
+		file.ContentType = "application/pdf"
+		file.Data, err = invoice.RenderPDF(doc)
+	}
+	if err != nil {
+		return nil, e.NewError(e.ErrRenderInvoice, "error while rendering the invoice", err)
+	}
+	log.Info().Msgf("Rendered invoice %s of order %d as %s", issued.Number, order.ID, args.Format)
+
+	return file, nil
+}
+
+// invoiceDocument lays out what the invoice of the order shows, with the addresses kept on the order
+func invoiceDocument(order *internal.Order, issued *internal.Invoice, seller invoice.Party) *invoice.Document {
+	doc := &invoice.Document{
+		Number:         issued.Number,
+		IssuedAt:       issued.IssuedAt,
+		OrderID:        order.ID,
+		OrderedAt:      order.CreatedAt,
+		Seller:         seller,
+		BillTo:         invoiceParty(order.BillingAddress, &order.User),
+		ShipTo:         invoiceParty(order.ShippingAddress, &order.User),
+		Subtotal:       order.Subtotal,
+		Discount:       order.Discount,
+		CouponCode:     order.CouponCode,
+		Tax:            order.Tax,
+		Shipping:       order.ShippingCharge,
+		GrandTotal:     order.Total,
+		RefundedAmount: order.RefundedAmount,
+	}
+
+	var subtotal float64
+	for _, item := range order.Items {
+		line := invoice.Line{
+			Description:  strings.TrimSpace(item.Product.BrandName + " " + item.Product.BrandModel),
+			Quantity:     item.Quantity,
+			UnitPrice:    item.Price,
+			Discount:     item.Discount,
+			TaxRate:      item.TaxRate,
+			TaxAmount:    item.TaxAmount,
+			TaxInclusive: item.TaxInclusive,
+			Total:        item.Price*float64(item.Quantity) - item.Discount,
+		}
+		if !item.TaxInclusive {
+			line.Total += item.TaxAmount
+		}
+		subtotal += item.Price * float64(item.Quantity)
+		doc.Lines = append(doc.Lines, line)
+	}
+	// orders placed before the subtotal was kept
+	if doc.Subtotal == 0 {
+		doc.Subtotal = subtotal
+	}
+	return doc
+}
+
+// invoiceParty is the buyer at the address of the order, the profile address for orders that did not keep one
+func invoiceParty(address internal.AddressSnapshot, user *internal.Userdetail) invoice.Party {
+	if address.IsEmpty() {
+		address = user.ProfileAddress()
+	}
+	party := invoice.Party{Name: address.Name, Email: user.Mail}
+	if party.Name == "" {
+		party.Name = user.Username
+	}
+	for _, line := range []string{address.Line1, address.Line2} {
+		if line != "" {
+			party.Address = append(party.Address, line)
+		}
+	}
+	place := strings.Trim(strings.Join([]string{address.City, address.State}, ", "), ", ")
+	if address.Pincode > 0 {
+		place = strings.TrimSpace(fmt.Sprintf("%s %d", place, address.Pincode))
+	}
+	if place != "" {
+		party.Address = append(party.Address, place)
+	}
+	if address.Phonenumber > 0 {
+		party.Phone = fmt.Sprint(address.Phonenumber)
+	}
+	return party
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"bytes"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/invoice"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func invoiceRequest(userID, orderID int64, format string) *http.Request {
+	req := orderRequest(http.MethodGet, userID, orderID, "")
+	if format != "" {
+		req.URL.RawQuery = "format=" + format
+	}
+	return req
+}
+
+func TestInvoiceNumbersPaidOrders(t *testing.T) {
+	env := newOrderTestEnv(t)
+	invoices := NewInvoiceService(internal.NewInvoiceRepo(env.db), helper.NewContextHelper(), invoice.Party{Name: "E-Cart"})
+	userID := createTestUser(t, env.db, "buyer")
+	brand := createTestBrand(t, env.db, 10)
+
+	first := env.placeOrder(t, userID, brand, 2, true)
+	second := env.placeOrder(t, userID, brand, 1, true)
+
+	// numbered in the order of payment, whichever invoice is asked for first
+	next, err := invoices.AdminInvoice(invoiceRequest(0, second, "pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000002.pdf", next.FileName)
+
+	pdf, err := invoices.UserInvoice(invoiceRequest(userID, first, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000001.pdf", pdf.FileName)
+	assert.Equal(t, "application/pdf", pdf.ContentType)
+	assert.True(t, bytes.HasPrefix(pdf.Data, []byte("%PDF")))
+
+	html, err := invoices.UserInvoice(invoiceRequest(userID, first, "html"))
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000001.html", html.FileName)
+	assert.Contains(t, string(html.Data), "INV-000001")
+	assert.Contains(t, string(html.Data), brand.BrandName)
+}
+
+func TestInvoiceNotAvailable(t *testing.T) {
+	env := newOrderTestEnv(t)
+	invoices := NewInvoiceService(internal.NewInvoiceRepo(env.db), helper.NewContextHelper(), invoice.Party{Name: "E-Cart"})
+	userID := createTestUser(t, env.db, "buyer")
+	otherID := createTestUser(t, env.db, "other")
+	brand := createTestBrand(t, env.db, 10)
+
+	pending := env.placeOrder(t, userID, brand, 1, false)
+	_, err := invoices.UserInvoice(invoiceRequest(userID, pending, ""))
+	assertErrorCode(t, e.ErrInvoiceNotAvailable, err)
+
+	paid := env.placeOrder(t, userID, brand, 1, true)
+	_, err = invoices.UserInvoice(invoiceRequest(otherID, paid, ""))
+	assertErrorCode(t, e.ErrOrderNotFound, err)
+
+	_, err = invoices.UserInvoice(invoiceRequest(userID, paid, "doc"))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+
+	// a pending order takes no number
+	issued, err := invoices.UserInvoice(invoiceRequest(userID, paid, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000001.pdf", issued.FileName)
+
+	// orders paid before invoices came with the payment are numbered by the migration
+	_, err = env.orders.UpdateOrderStatus(pending, internal.OrderStatusPaid, 1, internal.ActorRoleAdmin, "")
+	require.NoError(t, err)
+	_, err = invoices.UserInvoice(invoiceRequest(userID, pending, ""))
+	assertErrorCode(t, e.ErrInvoiceNotAvailable, err)
+	require.NoError(t, internal.IssueMissingInvoices(env.db))
+	issued, err = invoices.UserInvoice(invoiceRequest(userID, pending, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "INV-000002.pdf", issued.FileName)
+}
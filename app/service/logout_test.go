@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	hash "e-cart/pkg/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loginRequest(username, password string) *http.Request {
+	body := fmt.Sprintf(`{"username": %q, "password": %q}`, username, password)
+	return httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+}
+
+// authorizedRequest carries the token in the context like JWTAuthMiddleware leaves it
+func authorizedRequest(path string, userID int64, token, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	ctx := context.WithValue(req.Context(), middleware.UserIDKey, userID)
+	return req.WithContext(context.WithValue(ctx, middleware.TokenKey, token))
+}
+
+// authStatus sends the token through JWTAuthMiddleware and returns the response status
+func authStatus(repo internal.UserRepo, token string) int {
+	handler := middleware.JWTAuthMiddleware(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/user/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	db := newTestDB(t)
+	repo := internal.NewUserRepo(db)
+	svc := NewUserService(repo, helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	userID := createTestUser(t, db, "alice")
+
+	login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, authStatus(repo, login.Token))
+
+	// a refresh token that is unknown or of someone else fails the logout before anything is revoked
+	createTestUser(t, db, "bob")
+	bob, err := svc.LoginUser(loginRequest("bob", "pwd"))
+	require.NoError(t, err)
+	for _, refreshToken := range []string{"not-a-refresh-token", bob.RefreshToken} {
+		err = svc.Logout(authorizedRequest("/user/logout", userID, login.Token, `{"refresh_token": "`+refreshToken+`"}`))
+		assertErrorCode(t, e.ErrInvalidRefreshToken, err)
+		assert.Equal(t, http.StatusOK, authStatus(repo, login.Token))
+	}
+	_, err = svc.RefreshToken(refreshRequest(bob.RefreshToken))
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Logout(authorizedRequest("/user/logout", userID, login.Token, `{"refresh_token": "`+login.RefreshToken+`"}`)))
+	assert.Equal(t, http.StatusUnauthorized, authStatus(repo, login.Token))
+
+	// the refresh token of the session is gone too, so it cannot bring the session back
+	_, err = svc.RefreshToken(httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token": "`+login.RefreshToken+`"}`)))
+	assertErrorCode(t, e.ErrRefreshTokenReused, err)
+
+	err = svc.Logout(authorizedRequest("/user/logout", userID, login.Token, ""))
+	assertErrorCode(t, e.ErrLogoutUser, err)
+
+	t.Run("newer login replaces the token", func(t *testing.T) {
+		first, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+		second, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+
+		if first.Token != second.Token {
+			assert.Equal(t, http.StatusUnauthorized, authStatus(repo, first.Token))
+		}
+		assert.Equal(t, http.StatusOK, authStatus(repo, second.Token))
+	})
+
+	t.Run("log out everywhere", func(t *testing.T) {
+		login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+
+		require.NoError(t, svc.LogoutAll(authorizedRequest("/user/logout/all", userID, login.Token, "")))
+		assert.Equal(t, http.StatusUnauthorized, authStatus(repo, login.Token))
+
+		_, err = svc.RefreshToken(httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token": "`+login.RefreshToken+`"}`)))
+		assert.Error(t, err)
+	})
+
+	t.Run("blocking the user revokes its tokens", func(t *testing.T) {
+		login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+
+		admin := NewAdminService(internal.NewAdminRepo(db), repo, internal.NewOrderRepo(db), helper.NewContextHelper())
+		req := httptest.NewRequest(http.MethodPut, "/admin/block", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("userid", fmt.Sprint(userID))
+		require.NoError(t, admin.BlockUser(req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))))
+
+		assert.Equal(t, http.StatusUnauthorized, authStatus(repo, login.Token))
+		_, err = svc.LoginUser(loginRequest("alice", "pwd"))
+		assertErrorCode(t, e.ErrUserBlocked, err)
+	})
+}
@@ -62,6 +62,54 @@ func (_m *UserService) AddItemsToFavourites(r *http.Request) error {
 	return r0
 }
 
+// ApplyCoupon provides a mock function with given fields: r
+func (_m *UserService) ApplyCoupon(r *http.Request) (*dto.ViewCartResponse, error) {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ApplyCoupon")
+	}
+
+	var r0 *dto.ViewCartResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.ViewCartResponse, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.ViewCartResponse); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.ViewCartResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ChangePassword provides a mock function with given fields: r
+func (_m *UserService) ChangePassword(r *http.Request) error {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ChangePassword")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*http.Request) error); ok {
+		r0 = rf(r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ClearCart provides a mock function with given fields: r
 func (_m *UserService) ClearCart(r *http.Request) error {
 	ret := _m.Called(r)
@@ -80,6 +128,36 @@ func (_m *UserService) ClearCart(r *http.Request) error {
 	return r0
 }
 
+// GetUserDetails provides a mock function with given fields: r
+func (_m *UserService) GetUserDetails(r *http.Request) (*dto.GetUserDetailsResponse, error) {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUserDetails")
+	}
+
+	var r0 *dto.GetUserDetailsResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.GetUserDetailsResponse, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.GetUserDetailsResponse); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.GetUserDetailsResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserFavouriteBrands provides a mock function with given fields: r
 func (_m *UserService) GetUserFavouriteBrands(r *http.Request) ([]dto.FavoriteBrandResponse, error) {
 	ret := _m.Called(r)
@@ -140,6 +218,42 @@ func (_m *UserService) LoginUser(r *http.Request) (*dto.LoginResponse, error) {
 	return r0, r1
 }
 
+// Logout provides a mock function with given fields: r
+func (_m *UserService) Logout(r *http.Request) error {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Logout")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*http.Request) error); ok {
+		r0 = rf(r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// LogoutAll provides a mock function with given fields: r
+func (_m *UserService) LogoutAll(r *http.Request) error {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LogoutAll")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*http.Request) error); ok {
+		r0 = rf(r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // OrderHistory provides a mock function with given fields: r
 func (_m *UserService) OrderHistory(r *http.Request) ([]*dto.ItemOrderedResponse, error) {
 	ret := _m.Called(r)
@@ -200,6 +314,84 @@ func (_m *UserService) PlaceOrder(r *http.Request) (*dto.ItemOrderedResponse, er
 	return r0, r1
 }
 
+// RefreshToken provides a mock function with given fields: r
+func (_m *UserService) RefreshToken(r *http.Request) (*dto.LoginResponse, error) {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RefreshToken")
+	}
+
+	var r0 *dto.LoginResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.LoginResponse, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.LoginResponse); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.LoginResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveCartItem provides a mock function with given fields: r
+func (_m *UserService) RemoveCartItem(r *http.Request) error {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveCartItem")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*http.Request) error); ok {
+		r0 = rf(r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RemoveCoupon provides a mock function with given fields: r
+func (_m *UserService) RemoveCoupon(r *http.Request) (*dto.ViewCartResponse, error) {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveCoupon")
+	}
+
+	var r0 *dto.ViewCartResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.ViewCartResponse, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.ViewCartResponse); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.ViewCartResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SaveUserDetails provides a mock function with given fields: r
 func (_m *UserService) SaveUserDetails(r *http.Request) (*dto.SaveUserResponse, error) {
 	ret := _m.Called(r)
@@ -230,6 +422,36 @@ func (_m *UserService) SaveUserDetails(r *http.Request) (*dto.SaveUserResponse,
 	return r0, r1
 }
 
+// UpdateCartItem provides a mock function with given fields: r
+func (_m *UserService) UpdateCartItem(r *http.Request) (*dto.CartItemResponse, error) {
+	ret := _m.Called(r)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateCartItem")
+	}
+
+	var r0 *dto.CartItemResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.CartItemResponse, error)); ok {
+		return rf(r)
+	}
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.CartItemResponse); ok {
+		r0 = rf(r)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*dto.CartItemResponse)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*http.Request) error); ok {
+		r1 = rf(r)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateUserDetails provides a mock function with given fields: r
 func (_m *UserService) UpdateUserDetails(r *http.Request) error {
 	ret := _m.Called(r)
@@ -249,23 +471,23 @@ func (_m *UserService) UpdateUserDetails(r *http.Request) error {
 }
 
 // ViewUserCart provides a mock function with given fields: r
-func (_m *UserService) ViewUserCart(r *http.Request) ([]*dto.ViewCart, error) {
+func (_m *UserService) ViewUserCart(r *http.Request) (*dto.ViewCartResponse, error) {
 	ret := _m.Called(r)
 
 	if len(ret) == 0 {
 		panic("no return value specified for ViewUserCart")
 	}
 
-	var r0 []*dto.ViewCart
+	var r0 *dto.ViewCartResponse
 	var r1 error
-	if rf, ok := ret.Get(0).(func(*http.Request) ([]*dto.ViewCart, error)); ok {
+	if rf, ok := ret.Get(0).(func(*http.Request) (*dto.ViewCartResponse, error)); ok {
 		return rf(r)
 	}
-	if rf, ok := ret.Get(0).(func(*http.Request) []*dto.ViewCart); ok {
+	if rf, ok := ret.Get(0).(func(*http.Request) *dto.ViewCartResponse); ok {
 		r0 = rf(r)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*dto.ViewCart)
+			r0 = ret.Get(0).(*dto.ViewCartResponse)
 		}
 	}
 
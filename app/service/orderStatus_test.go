@@ -0,0 +1,65 @@
+package service
+
+import (
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderStatusLifecycle(t *testing.T) {
+	env := newOrderTestEnv(t)
+	admin := NewAdminService(internal.NewAdminRepo(env.db), internal.NewUserRepo(env.db), env.orders, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	adminID := createTestUser(t, env.db, "admin")
+	orderID := env.placeOrder(t, userID, brand, 1, true)
+
+	// a paid order has to be packed and shipped before it can be delivered
+	_, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, adminID, orderID, `{"status": "delivered"}`))
+	assertErrorCode(t, e.ErrInvalidOrderStatus, err)
+
+	for _, status := range []string{internal.OrderStatusPacked, internal.OrderStatusShipped, internal.OrderStatusDelivered} {
+		body := fmt.Sprintf(`{"status": %q, "note": "moved to %s"}`, status, status)
+		resp, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, adminID, orderID, body))
+		require.NoError(t, err, status)
+		assert.Equal(t, status, resp.Status)
+	}
+
+	resp, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, adminID, orderID, `{"status": "packed"}`))
+	assertErrorCode(t, e.ErrInvalidOrderStatus, err)
+	assert.Nil(t, resp)
+
+	history, err := env.orders.GetOrderStatusHistory(orderID)
+	require.NoError(t, err)
+	var steps []string
+	for _, h := range history {
+		steps = append(steps, h.FromStatus+"->"+h.ToStatus)
+	}
+	assert.Equal(t, []string{"->pending", "pending->paid", "paid->packed", "packed->shipped", "shipped->delivered"}, steps)
+
+	delivered := history[len(history)-1]
+	assert.Equal(t, adminID, delivered.ActorID)
+	assert.Equal(t, internal.ActorRoleAdmin, delivered.ActorRole)
+	assert.Equal(t, "moved to delivered", delivered.Note)
+	assert.False(t, delivered.CreatedAt.IsZero())
+
+	// the customer sees the current status in the order history
+	orders, err := env.users.OrderHistory(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, internal.OrderStatusDelivered, orders[0].Status)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, adminID, orderID, `{"status": "lost"}`))
+		assertErrorCode(t, e.ErrValidateRequest, err)
+
+		_, err = admin.UpdateOrderStatus(orderRequest(http.MethodPut, adminID, orderID+100, `{"status": "packed"}`))
+		assertErrorCode(t, e.ErrOrderNotFound, err)
+	})
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/payment"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type PaymentService interface {
+	CreatePayment(r *http.Request) (*dto.PaymentResponse, error)
+	HandleWebhook(r *http.Request) (*dto.PaymentResponse, error)
+}
+
+type paymentServiceImpl struct {
+	paymentRepo internal.PaymentRepo
+	provider    payment.PaymentProvider
+	ctxHelper   helper.ContextHelper
+}
+
+func NewPaymentService(paymentRepo internal.PaymentRepo, provider payment.PaymentProvider, ctxHelper helper.ContextHelper) PaymentService {
+	return &paymentServiceImpl{
+		paymentRepo: paymentRepo,
+		provider:    provider,
+		ctxHelper:   ctxHelper,
+	}
+}
+
+// CreatePayment starts the payment of a pending order of the user at the provider
+func (s *paymentServiceImpl) CreatePayment(r *http.Request) (*dto.PaymentResponse, error) {
+	args := &dto.CreatePaymentRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	order, err := s.paymentRepo.GetOrderByID(args.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		return nil, e.NewError(e.ErrCreatePayment, "error while getting the order", err)
+	}
+	// orders of other users are not found rather than forbidden, so their ids are not given away
+	if order.UserID != userID {
+		return nil, e.NewError(e.ErrOrderNotFound, "order not found", fmt.Errorf("order %d does not belong to user %d", order.ID, userID))
+	}
+	if order.Status != internal.OrderStatusPending {
+		return nil, e.NewError(e.ErrOrderNotPayable, "only pending orders can be paid", fmt.Errorf("order %d is %s", order.ID, order.Status))
+	}
+
+	intent, err := s.provider.CreateIntent(r.Context(), payment.IntentRequest{
+		Amount:    order.Total,
+		Currency:  payment.Currency(),
+		Reference: fmt.Sprintf("order-%d-%d", order.ID, order.PaymentAttempt()),
+	})
+	if err != nil {
+		return nil, e.NewError(e.ErrCreatePayment, "payment provider did not accept the payment", err)
+	}
+
+	stored, err := s.paymentRepo.CreatePayment(&internal.Payment{
+		OrderID:  order.ID,
+		Provider: s.provider.Name(),
+		IntentID: intent.ID,
+		Amount:   intent.Amount,
+		Currency: intent.Currency,
+		Status:   internal.PaymentStatusPending,
+	})
+	if err != nil {
+		return nil, e.NewError(e.ErrCreatePayment, "error while saving the payment", err)
+	}
+	log.Info().Msgf("Started payment %s of %.2f %s for order %d", stored.IntentID, stored.Amount, stored.Currency, order.ID)
+
+	resp := paymentResponse(stored)
+	resp.ClientSecret = intent.ClientSecret
+	return resp, nil
+}
+
+// HandleWebhook processes a notification of the provider. Only a verified authorization
+// captures the payment and marks the order paid
+func (s *paymentServiceImpl) HandleWebhook(r *http.Request) (*dto.PaymentResponse, error) {
+	args := &dto.PaymentWebhookRequest{}
+
+	err := args.Parse(r, payment.SignatureHeader)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while reading the webhook", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidWebhookSignature, "webhook is not signed", err)
+	}
+
+	event, err := s.provider.VerifyWebhook(args.Payload, args.Signature)
+	if err != nil {
+		if errors.Is(err, payment.ErrInvalidSignature) {
+			return nil, e.NewError(e.ErrInvalidWebhookSignature, "webhook signature does not match", err)
+		}
+		return nil, e.NewError(e.ErrHandlePaymentWebhook, "error while reading the webhook", err)
+	}
+	log.Info().Msgf("Received payment webhook %s of type %s for %s", event.ID, event.Type, event.IntentID)
+
+	stored, err := s.paymentRepo.GetPaymentByIntentID(event.IntentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrPaymentNotFound, "payment not found", err)
+		}
+		return nil, e.NewError(e.ErrHandlePaymentWebhook, "error while getting the payment", err)
+	}
+
+	switch event.Type {
+	case payment.EventPaymentAuthorized:
+		stored, err = s.capturePayment(r.Context(), stored, event)
+	case payment.EventPaymentFailed:
+		stored, err = s.paymentRepo.MarkPaymentFailed(stored.IntentID, event.Reason)
+	default:
+		log.Info().Msgf("Ignoring payment webhook of type %s", event.Type)
+		return paymentResponse(stored), nil
+	}
+	if err != nil {
+		if errors.Is(err, internal.ErrPaymentAlreadyFinal) {
+			return nil, e.NewError(e.ErrPaymentAlreadyFinal, "payment is already final", err)
+		}
+		if errors.Is(err, internal.ErrCaptureInProgress) {
+			return nil, e.NewError(e.ErrPaymentCaptureInProgress, "payment is being captured", err)
+		}
+		if errors.Is(err, internal.ErrInvalidStatusTransition) {
+			return nil, e.NewError(e.ErrOrderNotPayable, "order can no longer be paid", err)
+		}
+		var wrapErr *e.WrapError
+		if errors.As(err, &wrapErr) {
+			return nil, wrapErr
+		}
+		return nil, e.NewError(e.ErrHandlePaymentWebhook, "error while updating the payment", err)
+	}
+	log.Info().Msgf("Payment %s of order %d is %s", stored.IntentID, stored.OrderID, stored.Status)
+
+	return paymentResponse(stored), nil
+}
+
+// capturePayment takes the authorized money and marks the order paid, the amount has to be the
+// one the payment was started with. The payment is claimed before the provider is asked, so a
+// duplicate webhook cannot capture it a second time
+func (s *paymentServiceImpl) capturePayment(ctx context.Context, stored *internal.Payment, event *payment.WebhookEvent) (*internal.Payment, error) {
+	if stored.Status == internal.PaymentStatusSucceeded {
+		return stored, nil
+	}
+
+	if !sameAmount(stored.Amount, event.Amount) {
+		return nil, e.NewError(e.ErrPaymentAmountMismatch, "authorized amount does not match the payment",
+			fmt.Errorf("payment %s is %.2f, authorized %.2f", stored.IntentID, stored.Amount, event.Amount))
+	}
+
+	claimed, err := s.paymentRepo.StartCapture(stored.IntentID)
+	if err != nil {
+		return nil, err
+	}
+	// captured by an earlier webhook, or failed as the order is no longer pending
+	if claimed.Status != internal.PaymentStatusCapturing {
+		return claimed, nil
+	}
+
+	if _, err := s.provider.Capture(ctx, claimed.IntentID, claimed.Amount); err != nil {
+		if releaseErr := s.paymentRepo.ReleaseCapture(claimed.IntentID); releaseErr != nil {
+			log.Error().Err(releaseErr).Msgf("failed to release the capture of payment %s", claimed.IntentID)
+		}
+		return nil, e.NewError(e.ErrHandlePaymentWebhook, "payment provider did not capture the payment", err)
+	}
+
+	captured, _, err := s.paymentRepo.MarkPaymentSucceeded(claimed.IntentID, fmt.Sprintf("payment %s captured", claimed.IntentID))
+	if err != nil {
+		s.reverseCapture(ctx, claimed, err)
+		return nil, err
+	}
+	return captured, nil
+}
+
+// reverseCapture gives back money that was captured but could not be recorded, eg: the order was
+// cancelled during the capture. The payment is failed so the customer can pay again
+func (s *paymentServiceImpl) reverseCapture(ctx context.Context, captured *internal.Payment, cause error) {
+	if _, err := s.provider.Refund(ctx, captured.IntentID, captured.Amount, "capture-reversal-"+captured.IntentID); err != nil {
+		log.Error().Err(err).Msgf("failed to give back the capture of payment %s, it was not recorded: %v", captured.IntentID, cause)
+		return
+	}
+	if _, err := s.paymentRepo.MarkPaymentFailed(captured.IntentID, "capture given back: "+cause.Error()); err != nil {
+		log.Error().Err(err).Msgf("gave back the capture of payment %s but failed to record it", captured.IntentID)
+		return
+	}
+	log.Warn().Msgf("Gave back the capture of payment %s: %v", captured.IntentID, cause)
+}
+
+func paymentResponse(p *internal.Payment) *dto.PaymentResponse {
+	return &dto.PaymentResponse{
+		PaymentID:     p.ID,
+		OrderID:       p.OrderID,
+		Provider:      p.Provider,
+		IntentID:      p.IntentID,
+		Amount:        p.Amount,
+		Currency:      p.Currency,
+		Status:        p.Status,
+		FailureReason: p.FailureReason,
+		CapturedAt:    p.CapturedAt,
+	}
+}
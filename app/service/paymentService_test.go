@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	"e-cart/pkg/payment"
+	hash "e-cart/pkg/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orderRequest(method string, userID, orderID int64, body string) *http.Request {
+	req := httptest.NewRequest(method, "/user/order/payment", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", fmt.Sprint(orderID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, userID))
+}
+
+func webhookRequest(body []byte, signature string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/payment/webhook", bytes.NewReader(body))
+	req.Header.Set(payment.SignatureHeader, signature)
+	return req
+}
+
+func assertErrorCode(t *testing.T, code int, err error) {
+	t.Helper()
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr), "unexpected error %v", err)
+	assert.Equal(t, code, wrapErr.ErrorCode, err.Error())
+}
+
+func TestPaymentMarksOrderPaid(t *testing.T) {
+	db := newTestDB(t)
+	provider := payment.NewMockProvider("test-secret")
+	payments := NewPaymentService(internal.NewPaymentRepo(db), provider, helper.NewContextHelper())
+	users := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+
+	brand := createTestBrand(t, db, 5)
+	userID := createTestUser(t, db, "buyer")
+	createTestCartLine(t, db, userID, brand, 2)
+	order, err := users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+
+	// someone else's order cannot be paid
+	otherID := createTestUser(t, db, "other")
+	_, err = payments.CreatePayment(orderRequest(http.MethodPost, otherID, order.OrderID, ""))
+	assertErrorCode(t, e.ErrOrderNotFound, err)
+
+	started, err := payments.CreatePayment(orderRequest(http.MethodPost, userID, order.OrderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusPending, started.Status)
+	assert.Equal(t, 200.0, started.Amount)
+	assert.NotEmpty(t, started.ClientSecret)
+
+	// asking again gives the same payment
+	again, err := payments.CreatePayment(orderRequest(http.MethodPost, userID, order.OrderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, started.PaymentID, again.PaymentID)
+
+	// a webhook signed with another secret is rejected
+	forged, signature, err := payment.NewMockProvider("wrong-secret").Webhook(payment.EventPaymentAuthorized, started.IntentID, 200, "")
+	require.NoError(t, err)
+	_, err = payments.HandleWebhook(webhookRequest(forged, signature))
+	assertErrorCode(t, e.ErrInvalidWebhookSignature, err)
+
+	body, signature, err := provider.Webhook(payment.EventPaymentAuthorized, started.IntentID, 200, "")
+	require.NoError(t, err)
+	paid, err := payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusSucceeded, paid.Status)
+	assert.NotNil(t, paid.CapturedAt)
+
+	// a repeated webhook changes nothing
+	_, err = payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+
+	var stored internal.Order
+	require.NoError(t, db.Preload("StatusHistory").First(&stored, order.OrderID).Error)
+	assert.Equal(t, internal.OrderStatusPaid, stored.Status)
+	require.Len(t, stored.StatusHistory, 2, "created and paid")
+	assert.Equal(t, internal.OrderStatusPaid, stored.StatusHistory[1].ToStatus)
+	assert.Equal(t, internal.ActorRoleSystem, stored.StatusHistory[1].ActorRole)
+
+	// a paid order cannot be paid again
+	_, err = payments.CreatePayment(orderRequest(http.MethodPost, userID, order.OrderID, ""))
+	assertErrorCode(t, e.ErrOrderNotPayable, err)
+}
+
+func TestPaymentDeclinedKeepsOrderPending(t *testing.T) {
+	db := newTestDB(t)
+	provider := payment.NewMockProvider("test-secret")
+	payments := NewPaymentService(internal.NewPaymentRepo(db), provider, helper.NewContextHelper())
+	users := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	orders := internal.NewOrderRepo(db)
+	admin := NewAdminService(internal.NewAdminRepo(db), internal.NewUserRepo(db), orders, helper.NewContextHelper())
+
+	brand := createTestBrand(t, db, 5)
+	userID := createTestUser(t, db, "buyer")
+	createTestCartLine(t, db, userID, brand, 1)
+	order, err := users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+
+	started, err := payments.CreatePayment(orderRequest(http.MethodPost, userID, order.OrderID, ""))
+	require.NoError(t, err)
+
+	body, signature, err := provider.Webhook(payment.EventPaymentFailed, started.IntentID, 100, "card declined")
+	require.NoError(t, err)
+	failed, err := payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusFailed, failed.Status)
+	assert.Equal(t, "card declined", failed.FailureReason)
+
+	// admins cannot mark the order paid themselves
+	_, err = admin.UpdateOrderStatus(orderRequest(http.MethodPut, 1, order.OrderID, `{"status": "paid"}`))
+	assertErrorCode(t, e.ErrInvalidOrderStatus, err)
+
+	stored, err := orders.GetOrderByID(order.OrderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPending, stored.Status)
+}
+
+func TestPaymentRetryAfterDecline(t *testing.T) {
+	env := newOrderTestEnv(t)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 1, false)
+
+	declined, err := env.payments.CreatePayment(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	body, signature, err := env.provider.Webhook(payment.EventPaymentFailed, declined.IntentID, 100, "card declined")
+	require.NoError(t, err)
+	_, err = env.payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+
+	// the retry is a new payment at the provider
+	retry, err := env.payments.CreatePayment(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.NotEqual(t, declined.PaymentID, retry.PaymentID)
+	assert.NotEqual(t, declined.IntentID, retry.IntentID)
+	assert.Equal(t, internal.PaymentStatusPending, retry.Status)
+
+	again, err := env.payments.CreatePayment(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, retry.PaymentID, again.PaymentID)
+
+	body, signature, err = env.provider.Webhook(payment.EventPaymentAuthorized, retry.IntentID, 100, "")
+	require.NoError(t, err)
+	paid, err := env.payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusSucceeded, paid.Status)
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPaid, order.Status)
+}
+
+// capturingProvider runs onCapture while the mock provider captures and counts the refunds it gives
+type capturingProvider struct {
+	*payment.MockProvider
+	onCapture func()
+	captures  int
+	refunds   int
+}
+
+func (p *capturingProvider) Capture(ctx context.Context, intentID string, amount float64) (*payment.Intent, error) {
+	p.captures++
+	if p.onCapture != nil {
+		p.onCapture()
+	}
+	return p.MockProvider.Capture(ctx, intentID, amount)
+}
+
+func (p *capturingProvider) Refund(ctx context.Context, intentID string, amount float64, reference string) (*payment.Refund, error) {
+	p.refunds++
+	return p.MockProvider.Refund(ctx, intentID, amount, reference)
+}
+
+func TestPaymentCapturedOnce(t *testing.T) {
+	env := newOrderTestEnv(t)
+	provider := &capturingProvider{MockProvider: env.provider}
+	payments := NewPaymentService(internal.NewPaymentRepo(env.db), provider, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 1, false)
+
+	started, err := payments.CreatePayment(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+
+	body, signature, err := env.provider.Webhook(payment.EventPaymentAuthorized, started.IntentID, 150, "")
+	require.NoError(t, err)
+	_, err = payments.HandleWebhook(webhookRequest(body, signature))
+	assertErrorCode(t, e.ErrPaymentAmountMismatch, err)
+
+	// a duplicate webhook arriving while another one captures leaves the payment alone
+	require.NoError(t, env.db.Model(&internal.Payment{}).Where("id = ?", started.PaymentID).
+		Update("status", internal.PaymentStatusCapturing).Error)
+	body, signature, err = env.provider.Webhook(payment.EventPaymentAuthorized, started.IntentID, 100, "")
+	require.NoError(t, err)
+	_, err = payments.HandleWebhook(webhookRequest(body, signature))
+	assertErrorCode(t, e.ErrPaymentCaptureInProgress, err)
+	assert.Equal(t, 0, provider.captures)
+
+	// a capture that never finished is taken over
+	require.NoError(t, env.db.Model(&internal.Payment{}).Where("id = ?", started.PaymentID).
+		UpdateColumn("updated_at", time.Now().Add(-time.Hour)).Error)
+	paid, err := payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	assert.Equal(t, internal.PaymentStatusSucceeded, paid.Status)
+
+	_, err = payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	assert.Equal(t, 1, provider.captures)
+	assert.Equal(t, 0, provider.refunds)
+}
+
+func TestPaymentCaptureGivenBackWhenNotRecorded(t *testing.T) {
+	env := newOrderTestEnv(t)
+	provider := &capturingProvider{MockProvider: env.provider}
+	payments := NewPaymentService(internal.NewPaymentRepo(env.db), provider, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 1, false)
+
+	started, err := payments.CreatePayment(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+
+	// the customer cancels while the provider captures
+	provider.onCapture = func() {
+		_, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+		require.NoError(t, err)
+	}
+	body, signature, err := env.provider.Webhook(payment.EventPaymentAuthorized, started.IntentID, 100, "")
+	require.NoError(t, err)
+	_, err = payments.HandleWebhook(webhookRequest(body, signature))
+	assertErrorCode(t, e.ErrOrderNotPayable, err)
+	assert.Equal(t, 1, provider.refunds)
+
+	var stored internal.Payment
+	require.NoError(t, env.db.First(&stored, started.PaymentID).Error)
+	assert.Equal(t, internal.PaymentStatusFailed, stored.Status)
+	assert.Contains(t, stored.FailureReason, "capture given back")
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	"e-cart/pkg/payment"
+	hash "e-cart/pkg/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a file backed sqlite db, immediate transactions make concurrent
+// writers wait on each other like row locks would in postgres
+func newTestDB(t *testing.T) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "e-cart.db") + "?_pragma=busy_timeout(10000)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	err = db.AutoMigrate(&internal.Userdetail{}, &internal.ActiveToken{}, &internal.RefreshToken{}, &internal.UserFavoriteBrand{}, &internal.Category{}, &internal.Brand{}, &internal.Cart{},
+		&internal.Order{}, &internal.OrderItem{}, &internal.OrderStatusHistory{}, &internal.InventoryMovement{}, &internal.StockReservation{},
+		&internal.Payment{}, &internal.Refund{}, &internal.RefundItem{},
+		&internal.ReturnRequest{}, &internal.Coupon{}, &internal.CartCoupon{}, &internal.CouponRedemption{},
+		&internal.Address{},
+		&internal.ShippingZone{},
+		&internal.ShippingRateTier{},
+		&internal.TaxClass{},
+		&internal.TaxRule{},
+		&internal.Invoice{},
+		&internal.InvoiceCounter{},
+		&internal.Shipment{},
+		&internal.ShipmentItem{},
+		&internal.ShipmentEvent{})
+	require.NoError(t, err)
+
+	return db
+}
+
+type orderTestEnv struct {
+	db       *gorm.DB
+	provider *payment.MockProvider
+	carrier  *carrier.FakeCarrier
+	users    UserService
+	payments PaymentService
+	refunds  RefundService
+	orders   internal.OrderRepo
+}
+
+// newOrderTestEnv wires the services an order goes through, from checkout to payment and refunds
+func newOrderTestEnv(t *testing.T) *orderTestEnv {
+	db := newTestDB(t)
+	provider := payment.NewMockProvider("test-secret")
+	fake := carrier.NewFakeCarrier()
+	return &orderTestEnv{
+		db:       db,
+		provider: provider,
+		carrier:  fake,
+		users:    NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier()),
+		payments: NewPaymentService(internal.NewPaymentRepo(db), provider, helper.NewContextHelper()),
+		refunds:  NewRefundService(internal.NewRefundRepo(db), provider, fake, helper.NewContextHelper()),
+		orders:   internal.NewOrderRepo(db),
+	}
+}
+
+// placeOrder orders quantity units of the brand for the user, paying for it when asked to
+func (env *orderTestEnv) placeOrder(t *testing.T, userID int64, brand *internal.Brand, quantity int64, pay bool) int64 {
+	createTestCartLine(t, env.db, userID, brand, quantity)
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	if !pay {
+		return order.OrderID
+	}
+
+	started, err := env.payments.CreatePayment(orderRequest(http.MethodPost, userID, order.OrderID, ""))
+	require.NoError(t, err)
+	body, signature, err := env.provider.Webhook(payment.EventPaymentAuthorized, started.IntentID, started.Amount, "")
+	require.NoError(t, err)
+	_, err = env.payments.HandleWebhook(webhookRequest(body, signature))
+	require.NoError(t, err)
+	return order.OrderID
+}
+
+func (env *orderTestEnv) stock(t *testing.T, brandID int64) int64 {
+	var brand internal.Brand
+	require.NoError(t, env.db.First(&brand, brandID).Error)
+	return brand.StockCount
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, name string) int64 {
+	user := internal.Userdetail{Username: name, Password: "pwd", Address: "address", Pincode: 682001, Phonenumber: 9999999999, Mail: name + "@mail.com", Status: true}
+	require.NoError(t, db.Create(&user).Error)
+	return user.ID
+}
+
+func createTestBrand(t *testing.T, db *gorm.DB, stock int64) *internal.Brand {
+	category := internal.Category{Categoryname: fmt.Sprintf("Mobile%d", time.Now().UnixNano()), Description: "phones"}
+	require.NoError(t, db.Create(&category).Error)
+
+	brand := internal.Brand{CategoryID: category.ID, BrandName: "IPHONE", BrandModel: "15", Price: 100, StockCount: stock, ReleaseDate: time.Now()}
+	require.NoError(t, db.Create(&brand).Error)
+	return &brand
+}
+
+func createTestCartLine(t *testing.T, db *gorm.DB, userID int64, brand *internal.Brand, quantity int64) int64 {
+	cart := internal.Cart{UserID: userID, ProductID: brand.ID, Quantity: quantity, Price: brand.Price, TotalAmount: brand.Price * float64(quantity), OrderStatus: true}
+	require.NoError(t, db.Omit("Brand").Create(&cart).Error)
+	return cart.ID
+}
+
+func placeOrderRequest(userID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/user/cart/placeorder", strings.NewReader(body))
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+}
+
+// placeOrdersConcurrently releases every request at the same time and returns the error of each
+func placeOrdersConcurrently(svc UserService, requests []*http.Request) []error {
+	errs := make([]error, len(requests))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.PlaceOrder(req)
+		}(i, req)
+	}
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+func TestPlaceOrderLastUnitRace(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	brand := createTestBrand(t, db, 1)
+
+	const buyers = 5
+	var requests []*http.Request
+	for i := 0; i < buyers; i++ {
+		userID := createTestUser(t, db, fmt.Sprintf("buyer%d", i))
+		createTestCartLine(t, db, userID, brand, 1)
+		requests = append(requests, placeOrderRequest(userID, ""))
+	}
+
+	errs := placeOrdersConcurrently(svc, requests)
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		var wrapErr *e.WrapError
+		require.True(t, errors.As(err, &wrapErr), "unexpected error %v", err)
+		assert.Equal(t, e.ErrInsufficientStock, wrapErr.ErrorCode, err.Error())
+	}
+	assert.Equal(t, 1, succeeded, "only one buyer can get the last unit")
+
+	var stock internal.Brand
+	require.NoError(t, db.First(&stock, brand.ID).Error)
+	assert.Equal(t, int64(0), stock.StockCount)
+
+	var orders, orderedLines int64
+	require.NoError(t, db.Model(&internal.Order{}).Count(&orders).Error)
+	require.NoError(t, db.Model(&internal.Cart{}).Where("orderstatus = ?", false).Count(&orderedLines).Error)
+	assert.Equal(t, int64(1), orders, "failed checkouts must not leave an order behind")
+	assert.Equal(t, int64(1), orderedLines, "failed checkouts must not mark the cart as ordered")
+}
+
+func TestPlaceOrderSameCartTwice(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	brand := createTestBrand(t, db, 10)
+	userID := createTestUser(t, db, "buyer")
+	createTestCartLine(t, db, userID, brand, 2)
+
+	errs := placeOrdersConcurrently(svc, []*http.Request{placeOrderRequest(userID, ""), placeOrderRequest(userID, "")})
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded, "a cart line can only be ordered once")
+
+	var stock internal.Brand
+	require.NoError(t, db.First(&stock, brand.ID).Error)
+	assert.Equal(t, int64(8), stock.StockCount)
+
+	var orders int64
+	require.NoError(t, db.Model(&internal.Order{}).Count(&orders).Error)
+	assert.Equal(t, int64(1), orders)
+}
+
+func TestPlaceOrderWholeCart(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	userID := createTestUser(t, db, "buyer")
+
+	var brands []*internal.Brand
+	for i := 0; i < 3; i++ {
+		brand := createTestBrand(t, db, 10)
+		createTestCartLine(t, db, userID, brand, 2)
+		brands = append(brands, brand)
+	}
+
+	// only the first product
+	resp, err := svc.PlaceOrder(placeOrderRequest(userID, fmt.Sprintf(`{"product_ids": [%d]}`, brands[0].ID)))
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	assert.Equal(t, brands[0].ID, resp.Items[0].ProductID)
+
+	// the remaining lines go into a single order
+	resp, err = svc.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.Len(t, resp.Items, 2)
+	assert.Equal(t, float64(400), resp.TotalPrice)
+
+	// nothing left in the cart
+	_, err = svc.PlaceOrder(placeOrderRequest(userID, ""))
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, e.ErrCartNotFound, wrapErr.ErrorCode)
+}
+
+func TestPlaceOrderPriceChanged(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	brand := createTestBrand(t, db, 10)
+	userID := createTestUser(t, db, "buyer")
+	createTestCartLine(t, db, userID, brand, 2)
+
+	// admin raises the price after the item was added
+	require.NoError(t, db.Model(brand).Update("price", 120).Error)
+
+	_, err := svc.PlaceOrder(placeOrderRequest(userID, ""))
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, e.ErrPriceChanged, wrapErr.ErrorCode)
+	assert.Contains(t, err.Error(), "price changed from 100.00 to 120.00")
+
+	// the old total is not a confirmation of the new prices
+	_, err = svc.PlaceOrder(placeOrderRequest(userID, `{"confirmed_total": 200}`))
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, e.ErrPriceChanged, wrapErr.ErrorCode)
+
+	resp, err := svc.PlaceOrder(placeOrderRequest(userID, `{"confirmed_total": 240}`))
+	require.NoError(t, err)
+	assert.Equal(t, float64(240), resp.TotalPrice)
+	assert.Equal(t, float64(120), resp.Items[0].Price)
+}
@@ -1,12 +1,12 @@
 package service
 
 import (
+	"context"
 	"e-cart/app/dto"
+	"e-cart/app/helper"
 	"e-cart/app/internal"
 	"e-cart/pkg/e"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 
 	"github.com/rs/zerolog/log"
@@ -15,24 +15,41 @@ import (
 
 type ProductService interface {
 	CreateProduct(r *http.Request) (*dto.CreateProductResponds, error)
-	ListAllProduct(r *http.Request) ([]*dto.CatagoryListResponse, error)
+	ListAllProduct(r *http.Request) ([]*dto.CatagoryListResponse, *dto.PageMeta, error)
 	GetCatagoryById(r *http.Request) (*dto.CategoryDetailResponse, error)
 	GetCatagoryByName(r *http.Request) (*dto.CategoryDetailResponse, error)
-	ListAllBrands(r *http.Request) ([]*dto.BrandDetailResponse, error)
+	ListAllBrands(r *http.Request) ([]*dto.BrandDetailResponse, *dto.PageMeta, error)
+	SearchProducts(r *http.Request) ([]*dto.BrandDetailResponse, *dto.PageMeta, error)
 	GetBrandByID(r *http.Request) (*dto.BrandFullDetailByIdResponse, error)
 	GetCatagoryDetailsById(r *http.Request) (*dto.CategoryDetailsResponse, error)
+	UpdateCategory(r *http.Request) (*dto.CatagoryListResponse, error)
+	UpdateBrand(r *http.Request) (*dto.BrandFullDetailByIdResponse, error)
+	DeleteCategory(r *http.Request) error
+	RestoreCategory(r *http.Request) error
+	DeleteBrand(r *http.Request) error
+	RestoreBrand(r *http.Request) error
 }
 
 type ProductServiceImpl struct {
 	productRepo internal.ProductRepo
+	ctxHelper   helper.ContextHelper
 }
 
-func NewProductService(productRepo internal.ProductRepo) ProductService {
+func NewProductService(productRepo internal.ProductRepo, ctxHelper helper.ContextHelper) ProductService {
 	return &ProductServiceImpl{
 		productRepo: productRepo,
+		ctxHelper:   ctxHelper,
 	}
 }
 
+// checkIncludeDeleted makes sure only admins get to see deleted items
+func (s *ProductServiceImpl) checkIncludeDeleted(ctx context.Context, includeDeleted bool) error {
+	if includeDeleted && !s.ctxHelper.IsAdmin(ctx) {
+		return e.NewError(e.ErrForbidden, "only admins can include deleted items", errors.New("admin access required"))
+	}
+	return nil
+}
+
 func (s *ProductServiceImpl) CreateProduct(r *http.Request) (*dto.CreateProductResponds, error) {
 	args := &dto.CreateCategoryDetailRequest{}
 
@@ -74,26 +91,44 @@ func (s *ProductServiceImpl) CreateProduct(r *http.Request) (*dto.CreateProductR
 	}, nil
 }
 
-func (s *ProductServiceImpl) ListAllProduct(r *http.Request) ([]*dto.CatagoryListResponse, error) {
-	allCatagoryLists, err := s.productRepo.GetAllProducts()
+func (s *ProductServiceImpl) ListAllProduct(r *http.Request) ([]*dto.CatagoryListResponse, *dto.PageMeta, error) {
+	args := &dto.ListCategoriesRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing query params", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	err = s.checkIncludeDeleted(r.Context(), args.IncludeDeleted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allCatagoryLists, total, err := s.productRepo.GetAllProducts(args)
 	if err != nil {
-		return nil, e.NewError(e.ErrListProducts, "error while listing all product items", err)
+		return nil, nil, e.NewError(e.ErrListProducts, "error while listing all product items", err)
 	}
-	log.Info().Msgf("Successfully got all product details %v", allCatagoryLists)
+	log.Info().Msgf("Successfully got %d of %d categories", len(allCatagoryLists), total)
 
-	var catagorylists []*dto.CatagoryListResponse
+	catagorylists := make([]*dto.CatagoryListResponse, 0, len(allCatagoryLists))
 
 	for _, pro := range allCatagoryLists {
 		prodlist := dto.CatagoryListResponse{
 			CatagoryID:   pro.ID,
 			CatagoryName: pro.Categoryname,
 			Description:  pro.Description,
+			IsDeleted:    pro.IsDeleted,
+			TaxClassID:   pro.TaxClassID,
 		}
 		catagorylists = append(catagorylists, &prodlist)
-		fmt.Printf("Category ID: %d, Name: %s, Description: %s\n", prodlist.CatagoryID, prodlist.CatagoryName, prodlist.CatagoryName)
 	}
 
-	return catagorylists, nil
+	return catagorylists, dto.NewPageMeta(args.Pagination, total), nil
 }
 
 func (s *ProductServiceImpl) GetCatagoryById(r *http.Request) (*dto.CategoryDetailResponse, error) {
@@ -169,16 +204,31 @@ func (s *ProductServiceImpl) GetCatagoryByName(r *http.Request) (*dto.CategoryDe
 	return &response, nil
 }
 
-func (s *ProductServiceImpl) ListAllBrands(r *http.Request) ([]*dto.BrandDetailResponse, error) {
-	allBrandList, err := s.productRepo.GetAllBrands()
+func (s *ProductServiceImpl) ListAllBrands(r *http.Request) ([]*dto.BrandDetailResponse, *dto.PageMeta, error) {
+	args := &dto.ListBrandsRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing query params", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	err = s.checkIncludeDeleted(r.Context(), args.IncludeDeleted)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allBrandList, total, err := s.productRepo.GetAllBrands(args)
 	if err != nil {
-		return nil, e.NewError(e.ErrGetBrand, "error while getting all brands", err)
+		return nil, nil, e.NewError(e.ErrGetBrand, "error while getting all brands", err)
 	}
-	// log.Info().Msgf("Successfully got all brand details %v \n", allBrandList)
-	jsonData, _ := json.MarshalIndent(allBrandList, "", "  ")
-	log.Info().Msgf("Successfully got all brand details:\n%s", jsonData)
+	log.Info().Msgf("Successfully got %d of %d brands", len(allBrandList), total)
 
-	var brandLists []*dto.BrandDetailResponse
+	brandLists := make([]*dto.BrandDetailResponse, 0, len(allBrandList))
 
 	for _, catBrand := range allBrandList {
 		brandList := dto.BrandDetailResponse{
@@ -190,14 +240,47 @@ func (s *ProductServiceImpl) ListAllBrands(r *http.Request) ([]*dto.BrandDetailR
 			//CategoryID:   catBrand.CategoryID,
 			CategoryName: catBrand.Category.Categoryname,
 			Model:        catBrand.BrandModel,
+			IsDeleted:    catBrand.IsDeleted || catBrand.Category.IsDeleted,
 		}
 		brandLists = append(brandLists, &brandList)
-		//fmt.Printf("brand items %v", brandLists)
-		prettyBrands, _ := json.MarshalIndent(brandLists, "", "  ")
-		fmt.Println("Final brand list:\n", string(prettyBrands))
 	}
 
-	return brandLists, nil
+	return brandLists, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+// SearchProducts finds brands by name, model, description and category name
+func (s *ProductServiceImpl) SearchProducts(r *http.Request) ([]*dto.BrandDetailResponse, *dto.PageMeta, error) {
+	args := &dto.SearchProductRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing query params", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	brands, total, err := s.productRepo.SearchBrands(args)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrSearchProducts, "error while searching products", err)
+	}
+	log.Info().Msgf("Search for %q matched %d brands", args.Query, total)
+
+	results := make([]*dto.BrandDetailResponse, 0, len(brands))
+	for _, brand := range brands {
+		results = append(results, &dto.BrandDetailResponse{
+			BrandName:    brand.BrandName,
+			BrandId:      brand.ID,
+			Price:        brand.Price,
+			PicLink:      brand.ImageLink,
+			CategoryName: brand.Category.Categoryname,
+			Model:        brand.BrandModel,
+		})
+	}
+
+	return results, dto.NewPageMeta(args.Pagination, total), nil
 }
 
 func (s *ProductServiceImpl) GetBrandByID(r *http.Request) (*dto.BrandFullDetailByIdResponse, error) {
@@ -209,84 +292,193 @@ func (s *ProductServiceImpl) GetBrandByID(r *http.Request) (*dto.BrandFullDetail
 		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
 	}
 
-	brand, err := s.productRepo.GetBrandByID(args.BrandId)
+	err = s.checkIncludeDeleted(r.Context(), args.IncludeDeleted)
 	if err != nil {
+		return nil, err
+	}
+
+	brand, err := s.productRepo.GetBrandByID(args.BrandId, args.IncludeDeleted)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrBrandNotFound, "brand not found", err)
+		}
 		return nil, e.NewError(e.ErrGetBrand, "error while getting brand by id", err)
 	}
 
-	// Build detailed DTO
-	brandDetails := &dto.BrandFullDetailByIdResponse{
+	return brandFullDetails(brand), nil
+}
+
+// brandFullDetails builds the detailed DTO of a brand
+func brandFullDetails(brand *internal.Brand) *dto.BrandFullDetailByIdResponse {
+	return &dto.BrandFullDetailByIdResponse{
 		BrandId:          brand.ID,
 		BrandName:        brand.BrandName,
 		Price:            brand.Price,
 		StockCount:       brand.StockCount,
+		ReservedStock:    brand.ReservedStock,
+		AvailableStock:   brand.AvailableStock(),
+		ReorderThreshold: brand.ReorderThreshold,
+		WeightGrams:      brand.WeightGrams,
+		TaxClassID:       brand.TaxClassID,
 		ImageLink:        brand.ImageLink,
 		GalleryLinks:     []string(brand.GalleryLinks), //brand.GalleryLinks,
 		BrandDescription: brand.BrandDescription,
+		Model:            brand.BrandModel,
+		ReleaseDate:      brand.ReleaseDate,
 		CategoryID:       brand.CategoryID,
 		CategoryName:     brand.Category.Categoryname,
+		IsDeleted:        brand.IsDeleted || brand.Category.IsDeleted,
 	}
-
-	return brandDetails, nil
 }
 
-// UpdateCategory updates the category name
-func (s *ProductServiceImpl) UpdateCategory(r *http.Request) error {
+// UpdateCategory edits the name and description of a category
+func (s *ProductServiceImpl) UpdateCategory(r *http.Request) (*dto.CatagoryListResponse, error) {
 	args := &dto.UpdateCategory{}
 
 	err := args.Parse(r)
 	if err != nil {
-		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
 	}
 
 	//validation
 	err = args.Validate()
 	if err != nil {
-		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
 	}
 	log.Info().Msg("Successfully completed parsing and validation of request body")
 
-	if args.CategoryName == "" {
-		return e.NewError(e.ErrValidateRequest, "category name cannot be empty", nil)
-	}
-
-	err = s.productRepo.UpdateCategory(args.CategoryID, args.CategoryName)
+	category, err := s.productRepo.UpdateCategory(args)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return e.NewError(e.ErrCategoryNotFound, "category not found", err)
+			return nil, e.NewError(e.ErrCategoryNotFound, "category not found", err)
+		}
+		if errors.Is(err, internal.ErrDuplicateCategory) {
+			return nil, e.NewError(e.ErrCategoryAlreadyExists, "category name already exists", err)
 		}
-		return e.NewError(e.ErrUpdateCategory, "failed to update category", err)
+		if errors.Is(err, internal.ErrUnknownTaxClass) {
+			return nil, e.NewError(e.ErrTaxClassNotFound, "tax class not found", err)
+		}
+		return nil, e.NewError(e.ErrUpdateCategory, "failed to update category", err)
 	}
-	return nil
+	log.Info().Msgf("Successfully updated category %d", category.ID)
+
+	return &dto.CatagoryListResponse{
+		CatagoryID:   category.ID,
+		CatagoryName: category.Categoryname,
+		Description:  category.Description,
+		IsDeleted:    category.IsDeleted,
+		TaxClassID:   category.TaxClassID,
+	}, nil
 }
 
-// UpdateBrand updates the brand name
-func (s *ProductServiceImpl) UpdateBrand(r *http.Request) error {
+// UpdateBrand edits any of the brand details
+func (s *ProductServiceImpl) UpdateBrand(r *http.Request) (*dto.BrandFullDetailByIdResponse, error) {
 	args := &dto.UpdateBrand{}
 
 	err := args.Parse(r)
 	if err != nil {
-		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
 	}
 
 	//validation
 	err = args.Validate()
 	if err != nil {
-		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
 	}
 	log.Info().Msg("Successfully completed parsing and validation of request body")
 
-	if args.BrandName == "" {
-		return e.NewError(e.ErrValidateRequest, "brand name cannot be empty", nil)
+	// moving the brand needs an existing category
+	if args.CategoryID != nil {
+		_, err = s.productRepo.GetCategoryByID(*args.CategoryID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, e.NewError(e.ErrCategoryNotFound, "category not found", err)
+			}
+			return nil, e.NewError(e.ErrGetCategory, "error while getting category details", err)
+		}
+	}
+
+	// stock changes are recorded against the admin
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "failed to get admin id from context", err)
 	}
 
-	err = s.productRepo.UpdateBrand(args.BrandId, args.BrandName, args.Price)
+	brand, err := s.productRepo.UpdateBrand(args, adminID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrBrandNotFound, "brand not found", err)
+		}
+		if errors.Is(err, internal.ErrDuplicateBrand) {
+			return nil, e.NewError(e.ErrBrandAlreadyExists, "brand name and model already exist in the category", err)
+		}
+		if errors.Is(err, internal.ErrUnknownTaxClass) {
+			return nil, e.NewError(e.ErrTaxClassNotFound, "tax class not found", err)
+		}
+		return nil, e.NewError(e.ErrUpdateBrand, "failed to update brand", err)
+	}
+	log.Info().Msgf("Successfully updated brand %d", brand.ID)
+
+	return brandFullDetails(brand), nil
+}
+
+// DeleteCategory soft deletes a category, it and its brands are hidden from the catalog
+func (s *ProductServiceImpl) DeleteCategory(r *http.Request) error {
+	return s.setCategoryDeleted(r, true)
+}
+
+// RestoreCategory brings back a soft deleted category
+func (s *ProductServiceImpl) RestoreCategory(r *http.Request) error {
+	return s.setCategoryDeleted(r, false)
+}
+
+func (s *ProductServiceImpl) setCategoryDeleted(r *http.Request, deleted bool) error {
+	args := &dto.SearchByCatagoryIdRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = s.productRepo.SetCategoryDeleted(args.CatagoryId, deleted)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.NewError(e.ErrCategoryNotFound, "category not found", err)
+		}
+		return e.NewError(e.ErrUpdateCategory, "failed to update the deleted state of the category", err)
+	}
+	log.Info().Msgf("Set deleted state of category %d to %t", args.CatagoryId, deleted)
+
+	return nil
+}
+
+// DeleteBrand soft deletes a brand, past orders still show it
+func (s *ProductServiceImpl) DeleteBrand(r *http.Request) error {
+	return s.setBrandDeleted(r, true)
+}
+
+// RestoreBrand brings back a soft deleted brand
+func (s *ProductServiceImpl) RestoreBrand(r *http.Request) error {
+	return s.setBrandDeleted(r, false)
+}
+
+func (s *ProductServiceImpl) setBrandDeleted(r *http.Request, deleted bool) error {
+	args := &dto.BrandFullDetailByIdRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = s.productRepo.SetBrandDeleted(args.BrandId, deleted)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return e.NewError(e.ErrBrandNotFound, "brand not found", err)
 		}
-		return e.NewError(e.ErrUpdateBrand, "failed to update brand", err)
+		return e.NewError(e.ErrUpdateBrand, "failed to update the deleted state of the brand", err)
 	}
+	log.Info().Msgf("Set deleted state of brand %d to %t", args.BrandId, deleted)
+
 	return nil
 }
 
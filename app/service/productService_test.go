@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// catalogRequest is an admin request on the catalog item with the id
+func catalogRequest(method string, adminID, id int64, body string) *http.Request {
+	req := httptest.NewRequest(method, "/admin/catalog", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", fmt.Sprint(id))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	ctx = context.WithValue(ctx, middleware.IsAdminKey, true)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, adminID))
+}
+
+func TestUpdateBrandAndCategory(t *testing.T) {
+	db := newTestDB(t)
+	products := NewProductService(internal.NewProductRepo(db), helper.NewContextHelper())
+	adminID := createTestUser(t, db, "admin")
+	brand := createTestBrand(t, db, 5)
+	other := createTestBrand(t, db, 1)
+
+	// only the fields in the body change
+	updated, err := products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, brand.ID, `{"price": 120.5}`))
+	require.NoError(t, err)
+	assert.Equal(t, 120.5, updated.Price)
+	assert.Equal(t, "IPHONE", updated.BrandName)
+	assert.Equal(t, "15", updated.Model)
+	assert.Equal(t, int64(5), updated.StockCount)
+
+	body := `{"brand_name": " iphone pro ", "model": "15 Pro", "description": "titanium", "stock_count": 8,
+		"image_link": "https://img.example.com/15.png", "gallery_links": ["https://img.example.com/15-back.png"],
+		"release_date": "2024-09-20T00:00:00Z", "category_id": ` + fmt.Sprint(other.CategoryID) + `}`
+	updated, err = products.UpdateBrand(catalogRequest(http.MethodPut, adminID, brand.ID, body))
+	require.NoError(t, err)
+	assert.Equal(t, "IPHONE PRO", updated.BrandName)
+	assert.Equal(t, "15 Pro", updated.Model)
+	assert.Equal(t, "titanium", updated.BrandDescription)
+	assert.Equal(t, "https://img.example.com/15.png", updated.ImageLink)
+	assert.Equal(t, []string{"https://img.example.com/15-back.png"}, updated.GalleryLinks)
+	assert.Equal(t, 2024, updated.ReleaseDate.Year())
+	assert.Equal(t, other.CategoryID, updated.CategoryID)
+	assert.Equal(t, 120.5, updated.Price, "kept from the earlier update")
+
+	// the stock change goes through the inventory ledger against the admin
+	assert.Equal(t, int64(8), updated.StockCount)
+	var movement internal.InventoryMovement
+	require.NoError(t, db.Where("brand_id = ?", brand.ID).Last(&movement).Error)
+	assert.Equal(t, int64(3), movement.Quantity)
+	assert.Equal(t, adminID, movement.ActorID)
+
+	t.Run("invalid brand updates", func(t *testing.T) {
+		for _, body := range []string{`{}`, `{"price": -1}`, `{"stock_count": -2}`, `{"image_link": "not a url"}`, `{"gallery_links": ["ftp//x"]}`} {
+			_, err := products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, brand.ID, body))
+			assertErrorCode(t, e.ErrValidateRequest, err)
+		}
+
+		_, err := products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, brand.ID+100, `{"price": 10}`))
+		assertErrorCode(t, e.ErrBrandNotFound, err)
+
+		_, err = products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, brand.ID, `{"category_id": 999}`))
+		assertErrorCode(t, e.ErrCategoryNotFound, err)
+	})
+
+	t.Run("duplicate name and model", func(t *testing.T) {
+		// brand now shares the category of other, which is IPHONE 15
+		_, err := products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, brand.ID, `{"brand_name": "iphone", "model": "15"}`))
+		assertErrorCode(t, e.ErrBrandAlreadyExists, err)
+
+		moved := createTestBrand(t, db, 1)
+		_, err = products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, moved.ID, `{"category_id": `+fmt.Sprint(other.CategoryID)+`}`))
+		assertErrorCode(t, e.ErrBrandAlreadyExists, err)
+
+		// saving the brand under its own name and model is fine
+		_, err = products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, other.ID, `{"brand_name": "iphone", "model": "15"}`))
+		require.NoError(t, err)
+
+		// a deleted brand does not hold on to its name
+		require.NoError(t, products.DeleteBrand(catalogRequest(http.MethodDelete, adminID, other.ID, "")))
+		updated, err := products.UpdateBrand(catalogRequest(http.MethodPatch, adminID, moved.ID, `{"category_id": `+fmt.Sprint(other.CategoryID)+`}`))
+		require.NoError(t, err)
+		assert.Equal(t, other.CategoryID, updated.CategoryID)
+	})
+
+	t.Run("category", func(t *testing.T) {
+		category, err := products.UpdateCategory(catalogRequest(http.MethodPatch, adminID, brand.CategoryID, `{"categoryname": "Phones"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Phones", category.CatagoryName)
+		assert.Equal(t, "phones", category.Description, "description is kept")
+
+		category, err = products.UpdateCategory(catalogRequest(http.MethodPut, adminID, brand.CategoryID, `{"description": "smart phones"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "Phones", category.CatagoryName)
+		assert.Equal(t, "smart phones", category.Description)
+
+		_, err = products.UpdateCategory(catalogRequest(http.MethodPatch, adminID, other.CategoryID, `{"categoryname": "Phones"}`))
+		assertErrorCode(t, e.ErrCategoryAlreadyExists, err)
+
+		_, err = products.UpdateCategory(catalogRequest(http.MethodPatch, adminID, brand.CategoryID, `{"categoryname": ""}`))
+		assertErrorCode(t, e.ErrValidateRequest, err)
+
+		_, err = products.UpdateCategory(catalogRequest(http.MethodPatch, adminID, 999, `{"description": "none"}`))
+		assertErrorCode(t, e.ErrCategoryNotFound, err)
+	})
+}
+
+func favouriteRequest(userID, brandID int64) *http.Request {
+	body := fmt.Sprintf(`{"brandid": %d, "favourite": true}`, brandID)
+	req := httptest.NewRequest(http.MethodPost, "/user/favourite", strings.NewReader(body))
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+}
+
+// brandIDs lists the brands on the page, deleted ones included when the admin asks for them
+func brandIDs(t *testing.T, products ProductService, includeDeleted bool) []int64 {
+	req := catalogRequest(http.MethodGet, 1, 0, "")
+	if includeDeleted {
+		req.URL.RawQuery = "include_deleted=true"
+	}
+	brands, _, err := products.ListAllBrands(req)
+	require.NoError(t, err)
+	var ids []int64
+	for _, b := range brands {
+		ids = append(ids, b.BrandId)
+	}
+	return ids
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	env := newOrderTestEnv(t)
+	products := NewProductService(internal.NewProductRepo(env.db), helper.NewContextHelper())
+	adminID := createTestUser(t, env.db, "admin")
+	userID := createTestUser(t, env.db, "buyer")
+	brand := createTestBrand(t, env.db, 5)
+	other := createTestBrand(t, env.db, 5)
+
+	orderID := env.placeOrder(t, userID, brand, 1, true)
+	require.NoError(t, env.users.AddItemsToFavourites(favouriteRequest(userID, brand.ID)))
+
+	require.NoError(t, products.DeleteBrand(catalogRequest(http.MethodDelete, adminID, brand.ID, "")))
+
+	assert.Equal(t, []int64{other.ID}, brandIDs(t, products, false))
+	assert.ElementsMatch(t, []int64{brand.ID, other.ID}, brandIDs(t, products, true))
+
+	search := catalogRequest(http.MethodGet, adminID, 0, "")
+	search.URL.RawQuery = "q=iphone"
+	found, _, err := products.SearchProducts(search)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, other.ID, found[0].BrandId)
+
+	_, err = products.GetBrandByID(catalogRequest(http.MethodGet, adminID, brand.ID, ""))
+	assertErrorCode(t, e.ErrBrandNotFound, err)
+
+	// the admin can still look at the deleted brand, customers cannot ask for it
+	req := catalogRequest(http.MethodGet, adminID, brand.ID, "")
+	req.URL.RawQuery = "include_deleted=true"
+	deleted, err := products.GetBrandByID(req)
+	require.NoError(t, err)
+	assert.True(t, deleted.IsDeleted)
+
+	req = catalogRequest(http.MethodGet, userID, brand.ID, "")
+	req.URL.RawQuery = "include_deleted=true"
+	_, err = products.GetBrandByID(req.WithContext(context.WithValue(req.Context(), middleware.IsAdminKey, false)))
+	assertErrorCode(t, e.ErrForbidden, err)
+
+	// it cannot be added to carts or favourites and drops out of the favourites list
+	_, err = env.users.AddItemToCart(addToCartRequest(userID, brand, 1))
+	assertErrorCode(t, e.ErrProductNotFound, err)
+	err = env.users.AddItemsToFavourites(favouriteRequest(userID, brand.ID))
+	assertErrorCode(t, e.ErrBrandNotFound, err)
+	favourites, err := env.users.GetUserFavouriteBrands(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.Empty(t, favourites)
+
+	// past orders keep showing it
+	orders, err := env.users.OrderHistory(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	require.Len(t, orders[0].Items, 1)
+	assert.Equal(t, brand.ID, orders[0].Items[0].ProductID)
+	assert.Equal(t, "IPHONE", orders[0].Items[0].BrandName)
+
+	t.Run("category hides its brands", func(t *testing.T) {
+		require.NoError(t, products.DeleteCategory(catalogRequest(http.MethodDelete, adminID, other.CategoryID, "")))
+		assert.Empty(t, brandIDs(t, products, false))
+
+		categories, _, err := products.ListAllProduct(catalogRequest(http.MethodGet, adminID, 0, ""))
+		require.NoError(t, err)
+		for _, c := range categories {
+			assert.NotEqual(t, other.CategoryID, c.CatagoryID)
+		}
+
+		_, err = env.users.AddItemToCart(addToCartRequest(userID, other, 1))
+		assertErrorCode(t, e.ErrProductNotFound, err)
+
+		require.NoError(t, products.RestoreCategory(catalogRequest(http.MethodPut, adminID, other.CategoryID, "")))
+		assert.Equal(t, []int64{other.ID}, brandIDs(t, products, false))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		require.NoError(t, products.RestoreBrand(catalogRequest(http.MethodPut, adminID, brand.ID, "")))
+		assert.ElementsMatch(t, []int64{brand.ID, other.ID}, brandIDs(t, products, false))
+
+		_, err := env.users.AddItemToCart(addToCartRequest(userID, brand, 1))
+		assert.NoError(t, err)
+
+		err = products.DeleteBrand(catalogRequest(http.MethodDelete, adminID, brand.ID+100, ""))
+		assertErrorCode(t, e.ErrBrandNotFound, err)
+	})
+}
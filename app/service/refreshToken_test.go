@@ -0,0 +1,82 @@
+package service
+
+import (
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/jwt"
+	"e-cart/pkg/notifier"
+	hash "e-cart/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refreshRequest(refreshToken string) *http.Request {
+	return httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token": "`+refreshToken+`"}`))
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db := newTestDB(t)
+	repo := internal.NewUserRepo(db)
+	svc := NewUserService(repo, helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	createTestUser(t, db, "alice")
+
+	login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+	require.NoError(t, err)
+	require.NotEmpty(t, login.RefreshToken)
+
+	// only the hash of the refresh token is stored
+	var stored internal.RefreshToken
+	require.NoError(t, db.First(&stored).Error)
+	assert.Equal(t, jwt.HashRefreshToken(login.RefreshToken), stored.TokenHash)
+
+	rotated, err := svc.RefreshToken(refreshRequest(login.RefreshToken))
+	require.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+	assert.Equal(t, http.StatusOK, authStatus(repo, rotated.Token))
+
+	again, err := svc.RefreshToken(refreshRequest(rotated.RefreshToken))
+	require.NoError(t, err)
+
+	// replaying the first, already rotated token revokes the whole family
+	_, err = svc.RefreshToken(refreshRequest(login.RefreshToken))
+	assertErrorCode(t, e.ErrRefreshTokenReused, err)
+
+	_, err = svc.RefreshToken(refreshRequest(again.RefreshToken))
+	assertErrorCode(t, e.ErrRefreshTokenReused, err)
+	assert.Equal(t, http.StatusUnauthorized, authStatus(repo, again.Token))
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := svc.RefreshToken(refreshRequest("not-a-refresh-token"))
+		assertErrorCode(t, e.ErrInvalidRefreshToken, err)
+
+		_, err = svc.RefreshToken(refreshRequest(""))
+		assertErrorCode(t, e.ErrValidateRequest, err)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&internal.RefreshToken{}).
+			Where("token_hash = ?", jwt.HashRefreshToken(login.RefreshToken)).
+			Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+		_, err = svc.RefreshToken(refreshRequest(login.RefreshToken))
+		assertErrorCode(t, e.ErrInvalidRefreshToken, err)
+	})
+
+	t.Run("blocked user", func(t *testing.T) {
+		login, err := svc.LoginUser(loginRequest("alice", "pwd"))
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&internal.Userdetail{}).Where("username = ?", "alice").Update("status", false).Error)
+
+		_, err = svc.RefreshToken(refreshRequest(login.RefreshToken))
+		assertErrorCode(t, e.ErrUserBlocked, err)
+	})
+}
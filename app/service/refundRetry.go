@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"e-cart/app/internal"
+	"e-cart/pkg/payment"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RetryPendingRefunds sends the refunds the provider did not accept again every interval until the context
+// is done. Only refunds older than the interval are sent, newer ones are still being sent by their request
+func RetryPendingRefunds(ctx context.Context, refundRepo internal.RefundRepo, provider payment.PaymentProvider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			settled, err := retryPendingRefunds(ctx, refundRepo, provider, time.Now().Add(-interval))
+			if err != nil {
+				log.Error().Err(err).Msg("failed to retry the pending refunds")
+				continue
+			}
+			if settled > 0 {
+				log.Info().Msgf("Settled %d pending refunds", settled)
+			}
+		}
+	}
+}
+
+// retryPendingRefunds settles the pending refunds created before the time and returns how many the provider
+// accepted, the others stay pending for the next run
+func retryPendingRefunds(ctx context.Context, refundRepo internal.RefundRepo, provider payment.PaymentProvider, createdBefore time.Time) (int, error) {
+	refunds, err := refundRepo.PendingRefunds(createdBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for i := range refunds {
+		if ctx.Err() != nil {
+			return settled, ctx.Err()
+		}
+		paid, err := refundRepo.GetPayment(refunds[i].PaymentID)
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to get the payment of refund %d", refunds[i].ID)
+			continue
+		}
+		if _, err := settleRefund(ctx, provider, refundRepo, &refunds[i], paid.IntentID); err != nil {
+			log.Warn().Err(err).Msgf("refund %d is still pending", refunds[i].ID)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
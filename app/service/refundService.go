@@ -0,0 +1,429 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/e"
+	"e-cart/pkg/payment"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type RefundService interface {
+	CancelOrder(r *http.Request) (*dto.OrderRefundResponse, error)
+	RefundOrder(r *http.Request) (*dto.OrderRefundResponse, error)
+}
+
+type refundServiceImpl struct {
+	refundRepo internal.RefundRepo
+	provider   payment.PaymentProvider
+	carrier    carrier.Carrier
+	ctxHelper  helper.ContextHelper
+}
+
+func NewRefundService(refundRepo internal.RefundRepo, provider payment.PaymentProvider, shippingCarrier carrier.Carrier, ctxHelper helper.ContextHelper) RefundService {
+	return &refundServiceImpl{
+		refundRepo: refundRepo,
+		provider:   provider,
+		carrier:    shippingCarrier,
+		ctxHelper:  ctxHelper,
+	}
+}
+
+// CancelOrder cancels an order of the customer before it is shipped. Labels booked for it are voided,
+// the stock and the coupon use go back and whatever was paid and not refunded yet is refunded
+func (s *refundServiceImpl) CancelOrder(r *http.Request) (*dto.OrderRefundResponse, error) {
+	args := &dto.CancelOrderRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	reason := args.Reason
+	if reason == "" {
+		reason = "cancelled by the customer"
+	}
+
+	var order *internal.Order
+	var refund *internal.Refund
+	var intentID string
+	var voided []int64
+	err = s.refundRepo.Transaction(func(txRepo internal.RefundRepo) error {
+		voided = nil
+		order, err = txRepo.LockOrder(args.OrderID)
+		if err != nil {
+			return err
+		}
+		if order.UserID != userID {
+			return e.NewError(e.ErrOrderNotFound, "order not found", fmt.Errorf("order %d does not belong to user %d", order.ID, userID))
+		}
+		if !internal.CanTransitionOrderStatus(order.Status, internal.OrderStatusCancelled) {
+			return e.NewError(e.ErrOrderNotCancellable, "only orders that are not shipped yet can be cancelled",
+				fmt.Errorf("order %d is %s", order.ID, order.Status))
+		}
+
+		shipments, err := txRepo.Shipments().ListShipments(order.ID)
+		if err != nil {
+			return err
+		}
+		for _, shipment := range shipments {
+			if shipment.HandedOver() {
+				return e.NewError(e.ErrOrderNotCancellable, "the carrier already has part of the order",
+					fmt.Errorf("shipment %d of order %d is %s", shipment.ID, order.ID, shipment.Status))
+			}
+		}
+
+		err = txRepo.ChangeOrderStatus(order, internal.OrderStatusCancelled, userID, internal.ActorRoleUser, reason)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.ReleaseCoupon(order.ID); err != nil {
+			return err
+		}
+
+		// the labels are voided before any money moves, a parcel picked up in the meantime stops the cancel
+		for i := range shipments {
+			if shipments[i].Status != internal.ShipmentStatusLabelCreated {
+				continue
+			}
+			err := cancelShipment(r.Context(), s.carrier, txRepo.Shipments(), &shipments[i], "Order cancelled by the customer")
+			if err != nil {
+				return err
+			}
+			voided = append(voided, shipments[i].ID)
+		}
+
+		paid := order.SucceededPayment()
+		if paid == nil {
+			return txRepo.RestockOrder(order, userID, "order cancelled")
+		}
+
+		refund = &internal.Refund{PaymentID: paid.ID, Reason: reason, ActorID: userID, ActorRole: internal.ActorRoleUser}
+		for _, item := range order.Items {
+			addRefundItem(refund, &item, item.RemainingQuantity())
+		}
+		if len(refund.Items) == 0 {
+			refund = nil
+			return nil
+		}
+		// nothing was shipped, the shipping charge goes back too
+		refund.Amount += order.ShippingCharge
+		intentID = paid.IntentID
+		return txRepo.CreateRefund(order, refund)
+	})
+	if err != nil {
+		s.keepVoidedShipments(voided, userID)
+		return nil, refundTransactionError(err, e.ErrCancelOrder, "failed to cancel the order")
+	}
+	if refund != nil {
+		order = sendRefund(r.Context(), s.provider, s.refundRepo, order, refund, intentID)
+	}
+	log.Info().Msgf("User %d cancelled order %d, refunded %.2f", userID, order.ID, order.RefundedAmount)
+
+	return orderRefundResponse(order, refund), nil
+}
+
+// RefundOrder refunds some or all items of a paid order that are not shipped, the refunded stock goes back.
+// Shipped items come back as returns. Once every item is refunded the order moves to refunded. The refund is
+// recorded before the provider is asked, one the provider does not accept stays pending and is retried
+func (s *refundServiceImpl) RefundOrder(r *http.Request) (*dto.OrderRefundResponse, error) {
+	args := &dto.RefundOrderRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	var order *internal.Order
+	var refund *internal.Refund
+	var intentID string
+	err = s.refundRepo.Transaction(func(txRepo internal.RefundRepo) error {
+		order, err = txRepo.LockOrder(args.OrderID)
+		if err != nil {
+			return err
+		}
+
+		paid := order.SucceededPayment()
+		if paid == nil {
+			return e.NewError(e.ErrOrderNotRefundable, "only paid orders can be refunded", fmt.Errorf("order %d has no captured payment", order.ID))
+		}
+
+		shipped, err := txRepo.Shipments().ShippedQuantities(order.ID)
+		if err != nil {
+			return err
+		}
+
+		refund = &internal.Refund{PaymentID: paid.ID, Reason: args.Reason, ActorID: adminID, ActorRole: internal.ActorRoleAdmin}
+		if err := buildRefundItems(refund, order, shipped, args.Items); err != nil {
+			return err
+		}
+		intentID = paid.IntentID
+		return txRepo.CreateRefund(order, refund)
+	})
+	if err != nil {
+		return nil, refundTransactionError(err, e.ErrRefundOrder, "failed to refund the order")
+	}
+	order = sendRefund(r.Context(), s.provider, s.refundRepo, order, refund, intentID)
+	log.Info().Msgf("Admin %d refunded %.2f of order %d", adminID, refund.Amount, order.ID)
+
+	return orderRefundResponse(order, refund), nil
+}
+
+// keepVoidedShipments records the shipments whose labels the carrier voided as cancelled when the cancel of
+// their order did not go through, their items can be booked again
+func (s *refundServiceImpl) keepVoidedShipments(shipmentIDs []int64, userID int64) {
+	for _, shipmentID := range shipmentIDs {
+		err := s.refundRepo.Transaction(func(txRepo internal.RefundRepo) error {
+			shipment, err := txRepo.Shipments().LockShipment(shipmentID)
+			if err != nil {
+				return err
+			}
+			if shipment.Status != internal.ShipmentStatusLabelCreated {
+				return nil
+			}
+			events := append(shipment.Events, internal.ShipmentEvent{
+				Status:      internal.ShipmentStatusCancelled,
+				Description: fmt.Sprintf("Label voided while user %d cancelled the order", userID),
+				OccurredAt:  time.Now(),
+			})
+			return txRepo.Shipments().SaveTracking(shipment, events)
+		})
+		if err != nil {
+			log.Error().Err(err).Msgf("label of shipment %d is voided but the shipment is not cancelled", shipmentID)
+		}
+	}
+}
+
+// sendRefund gives the money of a refund committed with its order back right away. A refund the provider
+// does not accept is left pending for RetryPendingRefunds, the order is returned as it is then
+func sendRefund(ctx context.Context, provider payment.PaymentProvider, refundRepo internal.RefundRepo, order *internal.Order, refund *internal.Refund, intentID string) *internal.Order {
+	settled, err := settleRefund(ctx, provider, refundRepo, refund, intentID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("refund %d of order %d is left pending for a retry", refund.ID, order.ID)
+		return order
+	}
+	return settled
+}
+
+// settleRefund sends a pending refund to the provider and marks it succeeded. The refund is committed before
+// the provider is asked and its id is the idempotency key, so sending it again after a failure does not give
+// the money twice. An order with everything given back moves to refunded and its coupon can be used again,
+// a cancelled one stays cancelled. The order is returned as the refund leaves it
+func settleRefund(ctx context.Context, provider payment.PaymentProvider, refundRepo internal.RefundRepo, refund *internal.Refund, intentID string) (*internal.Order, error) {
+	providerRefund, err := provider.Refund(ctx, intentID, refund.Amount, fmt.Sprintf("refund-%d", refund.ID))
+	if err != nil {
+		return nil, fmt.Errorf("payment provider did not accept refund %d: %w", refund.ID, err)
+	}
+
+	var order *internal.Order
+	err = refundRepo.Transaction(func(txRepo internal.RefundRepo) error {
+		order, err = txRepo.LockOrder(refund.OrderID)
+		if err != nil {
+			return err
+		}
+		locked, err := txRepo.LockRefund(refund.ID)
+		if err != nil {
+			return err
+		}
+		// settled by a retry in the meantime
+		if locked.Status != internal.RefundStatusPending {
+			*refund = *locked
+			return nil
+		}
+		if err := txRepo.MarkRefundSucceeded(locked, providerRefund.ID); err != nil {
+			return err
+		}
+		*refund = *locked
+
+		if fullyRefunded(order) && internal.CanTransitionOrderStatus(order.Status, internal.OrderStatusRefunded) {
+			err := txRepo.ChangeOrderStatus(order, internal.OrderStatusRefunded, refund.ActorID, refund.ActorRole, refund.Reason)
+			if err != nil {
+				return err
+			}
+			return txRepo.ReleaseCoupon(order.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("refund %d was given by the provider but not recorded: %w", refund.ID, err)
+	}
+	return order, nil
+}
+
+// buildRefundItems adds the requested items to the refund, everything not refunded or shipped yet when none are
+// requested. shipped is the quantity of each item in shipments that are not cancelled
+func buildRefundItems(refund *internal.Refund, order *internal.Order, shipped map[int64]int64, requested []dto.RefundItemRequest) error {
+	if len(requested) == 0 {
+		for _, item := range order.Items {
+			addRefundItem(refund, &item, unshippedQuantity(&item, shipped))
+		}
+		if len(refund.Items) > 0 {
+			return nil
+		}
+		if fullyRefunded(order) {
+			return e.NewError(e.ErrOrderNotRefundable, "order is already fully refunded", fmt.Errorf("order %d has nothing left to refund", order.ID))
+		}
+		return e.NewError(e.ErrOrderNotRefundable, "everything left of the order is shipped, shipped items come back as returns",
+			fmt.Errorf("order %d has nothing left that is not shipped", order.ID))
+	}
+
+	items := make(map[int64]*internal.OrderItem, len(order.Items))
+	for i := range order.Items {
+		items[order.Items[i].ID] = &order.Items[i]
+	}
+	for _, req := range requested {
+		item, ok := items[req.OrderItemID]
+		if !ok {
+			return e.NewError(e.ErrOrderNotFound, "order item not found", fmt.Errorf("item %d is not part of order %d", req.OrderItemID, order.ID))
+		}
+		if req.Quantity > item.RemainingQuantity() {
+			return e.NewError(e.ErrOrderNotRefundable, "refund is more than is left of the item",
+				fmt.Errorf("item %d has %d left to refund, %d asked", item.ID, item.RemainingQuantity(), req.Quantity))
+		}
+		if left := unshippedQuantity(item, shipped); req.Quantity > left {
+			return e.NewError(e.ErrOrderNotRefundable, "refund is more than is left unshipped of the item, cancel its shipment or take it back as a return",
+				fmt.Errorf("item %d has %d left that is not shipped, %d asked", item.ID, left, req.Quantity))
+		}
+		addRefundItem(refund, item, req.Quantity)
+	}
+	return nil
+}
+
+// unshippedQuantity is what is left of the item that is not in a shipment
+func unshippedQuantity(item *internal.OrderItem, shipped map[int64]int64) int64 {
+	return max(item.RemainingQuantity()-shipped[item.ID], 0)
+}
+
+// addRefundItem refunds quantity units of the item at the price that was paid for them
+func addRefundItem(refund *internal.Refund, item *internal.OrderItem, quantity int64) {
+	if quantity <= 0 {
+		return
+	}
+	amount := item.RefundAmount(quantity)
+	refund.Items = append(refund.Items, internal.RefundItem{OrderItemID: item.ID, Quantity: quantity, Amount: amount})
+	refund.Amount += amount
+}
+
+func fullyRefunded(order *internal.Order) bool {
+	for _, item := range order.Items {
+		if item.RemainingQuantity() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// refundTransactionError keeps the error codes set inside the transaction and maps the repo errors
+func refundTransactionError(err error, code int, msg string) error {
+	var wrapErr *e.WrapError
+	if errors.As(err, &wrapErr) {
+		return wrapErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrOrderNotFound, "order not found", err)
+	}
+	if errors.Is(err, internal.ErrRefundExceedsOrder) {
+		return e.NewError(e.ErrOrderNotRefundable, "refund is more than is left of the item", err)
+	}
+	if errors.Is(err, internal.ErrInvalidStatusTransition) {
+		return e.NewError(e.ErrInvalidOrderStatus, "invalid order status transition", err)
+	}
+	if errors.Is(err, carrier.ErrCannotCancel) {
+		return e.NewError(e.ErrOrderNotCancellable, "the carrier already has part of the order", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func orderRefundResponse(order *internal.Order, refund *internal.Refund) *dto.OrderRefundResponse {
+	resp := &dto.OrderRefundResponse{
+		OrderID:        order.ID,
+		Status:         order.Status,
+		TotalPrice:     order.Total,
+		RefundedAmount: order.RefundedAmount,
+		NetTotal:       order.Total - order.RefundedAmount,
+	}
+	if refund != nil {
+		refundResp := refundResponse(refund)
+		resp.Refund = &refundResp
+	}
+	return resp
+}
+
+func refundResponse(refund *internal.Refund) dto.RefundResponse {
+	resp := dto.RefundResponse{
+		RefundID:         refund.ID,
+		PaymentID:        refund.PaymentID,
+		ProviderRefundID: refund.ProviderRefundID,
+		Amount:           refund.Amount,
+		Reason:           refund.Reason,
+		Status:           refund.Status,
+		ActorRole:        refund.ActorRole,
+		Items:            make([]dto.RefundItemResponse, 0, len(refund.Items)),
+		CreatedAt:        refund.CreatedAt,
+	}
+	for _, item := range refund.Items {
+		resp.Items = append(resp.Items, dto.RefundItemResponse{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+			Amount:      item.Amount,
+		})
+	}
+	return resp
+}
+
+func refundResponses(refunds []internal.Refund) []dto.RefundResponse {
+	var resp []dto.RefundResponse
+	for i := range refunds {
+		resp = append(resp, refundResponse(&refunds[i]))
+	}
+	return resp
+}
+
+func orderItemResponses(items []internal.OrderItem) []dto.OrderItemResponse {
+	var resp []dto.OrderItemResponse
+	for _, item := range items {
+		resp = append(resp, dto.OrderItemResponse{
+			OrderItemID:      item.ID,
+			ProductID:        item.ProductID,
+			Quantity:         item.Quantity,
+			RefundedQuantity: item.RefundedQuantity,
+			CategoryID:       item.Product.CategoryID,
+			BrandName:        item.Product.BrandName,
+			Price:            item.Price,
+			Discount:         item.Discount,
+			TaxRate:          item.TaxRate,
+			TaxAmount:        item.TaxAmount,
+			TaxInclusive:     item.TaxInclusive,
+		})
+	}
+	return resp
+}
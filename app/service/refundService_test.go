@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/e"
+	"e-cart/pkg/payment"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelUnpaidOrder(t *testing.T) {
+	env := newOrderTestEnv(t)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 2, false)
+	assert.Equal(t, int64(3), env.stock(t, brand.ID))
+
+	otherID := createTestUser(t, env.db, "other")
+	_, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, otherID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotFound, err)
+
+	resp, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, `{"reason": "changed my mind"}`))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusCancelled, resp.Status)
+	assert.Nil(t, resp.Refund, "nothing was paid")
+	assert.Equal(t, int64(5), env.stock(t, brand.ID))
+
+	_, err = env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotCancellable, err)
+}
+
+func TestPartialRefundThenCancel(t *testing.T) {
+	env := newOrderTestEnv(t)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 3, true)
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	itemID := order.Items[0].ID
+
+	body := fmt.Sprintf(`{"reason": "damaged unit", "items": [{"order_item_id": %d, "quantity": 1}]}`, itemID)
+	resp, err := env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, body))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPaid, resp.Status)
+	assert.Equal(t, 100.0, resp.RefundedAmount)
+	assert.Equal(t, 200.0, resp.NetTotal)
+	require.NotNil(t, resp.Refund)
+	assert.Equal(t, internal.RefundStatusSucceeded, resp.Refund.Status)
+	assert.NotEmpty(t, resp.Refund.ProviderRefundID)
+	assert.Equal(t, int64(3), env.stock(t, brand.ID))
+
+	// more than is left cannot be refunded
+	body = fmt.Sprintf(`{"reason": "too much", "items": [{"order_item_id": %d, "quantity": 3}]}`, itemID)
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, body))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+
+	// cancelling refunds the rest
+	cancelled, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusCancelled, cancelled.Status)
+	assert.Equal(t, 300.0, cancelled.RefundedAmount)
+	assert.Equal(t, 0.0, cancelled.NetTotal)
+	assert.Equal(t, int64(5), env.stock(t, brand.ID))
+
+	history, err := env.users.OrderHistory(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Len(t, history[0].Refunds, 2)
+	assert.Equal(t, int64(3), history[0].Items[0].RefundedQuantity)
+}
+
+func TestRefundShippedOrder(t *testing.T) {
+	env := newOrderTestEnv(t)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 2, true)
+
+	for _, status := range []string{internal.OrderStatusPacked, internal.OrderStatusShipped} {
+		_, err := env.orders.UpdateOrderStatus(orderID, status, 1, internal.ActorRoleAdmin, "")
+		require.NoError(t, err)
+	}
+
+	_, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotCancellable, err)
+
+	resp, err := env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "lost in transit"}`))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusRefunded, resp.Status)
+	assert.Equal(t, 200.0, resp.RefundedAmount)
+
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "again"}`))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+}
+
+func TestAdminStatusCannotCancelOrRefund(t *testing.T) {
+	env := newOrderTestEnv(t)
+	admin := NewAdminService(internal.NewAdminRepo(env.db), internal.NewUserRepo(env.db), env.orders, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 2, true)
+
+	// only the refund and cancel flows give the money back and restock
+	for _, status := range []string{internal.OrderStatusCancelled, internal.OrderStatusRefunded} {
+		_, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, 1, orderID, fmt.Sprintf(`{"status": %q}`, status)))
+		assertErrorCode(t, e.ErrInvalidOrderStatus, err)
+	}
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPaid, order.Status)
+	assert.Equal(t, int64(3), env.stock(t, brand.ID))
+
+	resp, err := admin.UpdateOrderStatus(orderRequest(http.MethodPut, 1, orderID, `{"status": "packed"}`))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPacked, resp.Status)
+}
+
+func TestCancelAndRefundWithShipments(t *testing.T) {
+	env := newOrderTestEnv(t)
+	shipments := NewShipmentService(internal.NewShipmentRepo(env.db), env.carrier, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 3, true)
+	itemID := orderItemIDs(t, env, orderID)[0]
+
+	booked, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID,
+		fmt.Sprintf(`{"items":[{"order_item_id":%d,"quantity":1}]}`, itemID)))
+	require.NoError(t, err)
+
+	// the booked unit is not refunded, the other two are
+	body := fmt.Sprintf(`{"reason": "out of stock", "items": [{"order_item_id": %d, "quantity": 3}]}`, itemID)
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, body))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+	resp, err := env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "out of stock"}`))
+	require.NoError(t, err)
+	assert.Equal(t, 200.0, resp.RefundedAmount)
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "again"}`))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+
+	// cancelling voids the label and refunds the booked unit
+	cancelled, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusCancelled, cancelled.Status)
+	assert.Equal(t, 300.0, cancelled.RefundedAmount)
+	assert.Equal(t, int64(10), env.stock(t, brand.ID))
+
+	tracking, err := shipments.ListShipments(orderRequest(http.MethodGet, 99, orderID, ""))
+	require.NoError(t, err)
+	require.Len(t, tracking.Shipments, 1)
+	assert.Equal(t, internal.ShipmentStatusCancelled, tracking.Shipments[0].Status)
+	events, err := env.carrier.Track(context.Background(), booked.TrackingNumber)
+	require.NoError(t, err)
+	assert.Equal(t, carrier.StatusCancelled, events[len(events)-1].Status)
+
+	// a parcel the carrier picked up before the tracking caught up stops the cancel, nothing is refunded
+	orderID = env.placeOrder(t, userID, brand, 2, true)
+	picked, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID, ""))
+	require.NoError(t, err)
+	require.NoError(t, env.carrier.Advance(picked.TrackingNumber, carrier.StatusPickedUp, "Kochi"))
+	_, err = env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotCancellable, err)
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPacked, order.Status)
+	assert.Equal(t, 0.0, order.RefundedAmount)
+	assert.Equal(t, int64(8), env.stock(t, brand.ID))
+
+	// once the tracking shows it, the cancel is refused up front
+	refreshTracking(t, env, 1)
+	_, err = env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotCancellable, err)
+	_, err = env.refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "lost"}`))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+}
+
+// decliningProvider fails the refunds while decline is set and keeps the reference of every refund asked
+type decliningProvider struct {
+	*payment.MockProvider
+	decline    bool
+	references []string
+}
+
+func (p *decliningProvider) Refund(ctx context.Context, intentID string, amount float64, reference string) (*payment.Refund, error) {
+	p.references = append(p.references, reference)
+	if p.decline {
+		return nil, errors.New("provider unavailable")
+	}
+	return p.MockProvider.Refund(ctx, intentID, amount, reference)
+}
+
+func TestRefundLeftPendingWhenProviderFails(t *testing.T) {
+	env := newOrderTestEnv(t)
+	provider := &decliningProvider{MockProvider: env.provider, decline: true}
+	refundRepo := internal.NewRefundRepo(env.db)
+	refunds := NewRefundService(refundRepo, provider, env.carrier, helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 2, true)
+
+	// the refund and its stock are kept even though the money is not given yet
+	resp, err := refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "out of stock"}`))
+	require.NoError(t, err)
+	require.NotNil(t, resp.Refund)
+	assert.Equal(t, internal.RefundStatusPending, resp.Refund.Status)
+	assert.Empty(t, resp.Refund.ProviderRefundID)
+	assert.Equal(t, internal.OrderStatusPaid, resp.Status, "refunded once the money is given")
+	assert.Equal(t, 200.0, resp.RefundedAmount)
+	assert.Equal(t, int64(5), env.stock(t, brand.ID))
+
+	_, err = refunds.RefundOrder(orderRequest(http.MethodPost, 1, orderID, `{"reason": "again"}`))
+	assertErrorCode(t, e.ErrOrderNotRefundable, err)
+
+	// refunds being sent by their request are left alone
+	settled, err := retryPendingRefunds(context.Background(), refundRepo, provider, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, settled)
+
+	settled, err = retryPendingRefunds(context.Background(), refundRepo, provider, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, settled)
+
+	provider.decline = false
+	settled, err = retryPendingRefunds(context.Background(), refundRepo, provider, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, settled)
+
+	// every attempt used the same idempotency key
+	ref := fmt.Sprintf("refund-%d", resp.Refund.RefundID)
+	assert.Equal(t, []string{ref, ref, ref}, provider.references)
+
+	var stored internal.Refund
+	require.NoError(t, env.db.First(&stored, resp.Refund.RefundID).Error)
+	assert.Equal(t, internal.RefundStatusSucceeded, stored.Status)
+	assert.NotEmpty(t, stored.ProviderRefundID)
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusRefunded, order.Status)
+	assert.Equal(t, 200.0, order.RefundedAmount)
+
+	settled, err = retryPendingRefunds(context.Background(), refundRepo, provider, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, settled)
+}
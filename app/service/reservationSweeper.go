@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"e-cart/app/internal"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SweepExpiredReservations releases the stock of expired cart reservations every interval until the
+// context is done
+func SweepExpiredReservations(ctx context.Context, userRepo internal.UserRepo, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := userRepo.DeleteExpiredReservations()
+			if err != nil {
+				log.Error().Err(err).Msg("failed to release expired stock reservations")
+				continue
+			}
+			if released > 0 {
+				log.Info().Msgf("Released %d expired stock reservations", released)
+			}
+		}
+	}
+}
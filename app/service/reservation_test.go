@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"e-cart/pkg/notifier"
+	hash "e-cart/pkg/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addToCartRequest(userID int64, brand *internal.Brand, quantity int64) *http.Request {
+	body := fmt.Sprintf(`{"category_id": %d, "brandid": %d, "quantity": %d}`, brand.CategoryID, brand.ID, quantity)
+	req := httptest.NewRequest(http.MethodPost, "/user/cart", strings.NewReader(body))
+	return req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+}
+
+func removeCartItemRequest(userID, productID int64) *http.Request {
+	req := httptest.NewRequest(http.MethodDelete, "/user/cart/item", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("productid", fmt.Sprint(productID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, userID))
+}
+
+func TestStockReservation(t *testing.T) {
+	db := newTestDB(t)
+	repo := internal.NewUserRepo(db)
+	svc := NewUserService(repo, helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	brand := createTestBrand(t, db, 3)
+	first := createTestUser(t, db, "first")
+	second := createTestUser(t, db, "second")
+
+	// the first cart holds two of the three units
+	_, err := svc.AddItemToCart(addToCartRequest(first, brand, 2))
+	require.NoError(t, err)
+
+	_, err = svc.AddItemToCart(addToCartRequest(second, brand, 2))
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr), "unexpected error %v", err)
+	assert.Equal(t, e.ErrInsufficientStock, wrapErr.ErrorCode)
+
+	_, err = svc.AddItemToCart(addToCartRequest(second, brand, 1))
+	require.NoError(t, err)
+
+	detail, err := internal.NewProductRepo(db).GetBrandByID(brand.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), detail.StockCount, "reservations do not change the stock on hand")
+	assert.Equal(t, int64(0), detail.AvailableStock())
+
+	// removing the line gives the units back
+	require.NoError(t, svc.RemoveCartItem(removeCartItemRequest(first, brand.ID)))
+	detail, err = internal.NewProductRepo(db).GetBrandByID(brand.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), detail.AvailableStock())
+
+	// an expired reservation is swept away
+	require.NoError(t, db.Model(&internal.StockReservation{}).Where("user_id = ?", second).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	released, err := repo.DeleteExpiredReservations()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), released)
+
+	var reservations int64
+	require.NoError(t, db.Model(&internal.StockReservation{}).Count(&reservations).Error)
+	assert.Equal(t, int64(0), reservations)
+
+	// the ledger has the hold and release of every reservation, the stock on hand is untouched
+	var held int64
+	require.NoError(t, db.Model(&internal.InventoryMovement{}).Where("type = ?", internal.MovementReservation).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&held).Error)
+	assert.Equal(t, int64(0), held)
+}
+
+func TestPlaceOrderSkipsReservedStock(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewUserService(internal.NewUserRepo(db), helper.NewContextHelper(), hash.NewBcryptPackage(), notifier.NewLogNotifier())
+	brand := createTestBrand(t, db, 2)
+	holder := createTestUser(t, db, "holder")
+	buyer := createTestUser(t, db, "buyer")
+
+	_, err := svc.AddItemToCart(addToCartRequest(holder, brand, 1))
+	require.NoError(t, err)
+
+	// a cart line that skipped the reservation cannot take the held unit
+	createTestCartLine(t, db, buyer, brand, 2)
+	_, err = svc.PlaceOrder(placeOrderRequest(buyer, ""))
+	var wrapErr *e.WrapError
+	require.True(t, errors.As(err, &wrapErr), "unexpected error %v", err)
+	assert.Equal(t, e.ErrInsufficientStock, wrapErr.ErrorCode)
+
+	// the holder can still buy the reserved unit
+	_, err = svc.PlaceOrder(placeOrderRequest(holder, ""))
+	require.NoError(t, err)
+
+	var reservations int64
+	require.NoError(t, db.Model(&internal.StockReservation{}).Count(&reservations).Error)
+	assert.Equal(t, int64(0), reservations, "ordering releases the reservation")
+}
+
+func TestReplaceExpiredReservation(t *testing.T) {
+	db := newTestDB(t)
+	repo := internal.NewUserRepo(db)
+	brand := createTestBrand(t, db, 5)
+	userID := createTestUser(t, db, "buyer")
+
+	require.NoError(t, repo.ReserveStock(userID, brand.ID, 2))
+	require.NoError(t, db.Model(&internal.StockReservation{}).Where("user_id = ?", userID).
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	// the cart comes back before the sweeper ran
+	require.NoError(t, repo.ReserveStock(userID, brand.ID, 1))
+
+	ledger := func() int64 {
+		var held int64
+		require.NoError(t, db.Model(&internal.InventoryMovement{}).Where("type = ?", internal.MovementReservation).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&held).Error)
+		return held
+	}
+	assert.Equal(t, int64(1), ledger(), "the ledger holds what the reservation holds")
+
+	require.NoError(t, repo.ReleaseReservations(userID, nil, "removed"))
+	assert.Equal(t, int64(0), ledger())
+}
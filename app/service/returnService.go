@@ -0,0 +1,343 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/payment"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type ReturnService interface {
+	CreateReturn(r *http.Request) (*dto.ReturnResponse, error)
+	ListUserReturns(r *http.Request) ([]*dto.ReturnResponse, *dto.PageMeta, error)
+	ListReturns(r *http.Request) ([]*dto.ReturnResponse, *dto.PageMeta, error)
+	ApproveReturn(r *http.Request) (*dto.ReturnResponse, error)
+	RejectReturn(r *http.Request) (*dto.ReturnResponse, error)
+	ReceiveReturn(r *http.Request) (*dto.ReturnResponse, error)
+	RefundReturn(r *http.Request) (*dto.ReturnResponse, error)
+}
+
+type returnServiceImpl struct {
+	returnRepo   internal.ReturnRepo
+	provider     payment.PaymentProvider
+	ctxHelper    helper.ContextHelper
+	returnWindow time.Duration
+}
+
+// NewReturnService creates the returns service, items can be returned for returnWindow after delivery
+func NewReturnService(returnRepo internal.ReturnRepo, provider payment.PaymentProvider, ctxHelper helper.ContextHelper, returnWindow time.Duration) ReturnService {
+	return &returnServiceImpl{
+		returnRepo:   returnRepo,
+		provider:     provider,
+		ctxHelper:    ctxHelper,
+		returnWindow: returnWindow,
+	}
+}
+
+// CreateReturn requests the return of units of a delivered item, within the return window and
+// no more than what is not refunded or already on its way back
+func (s *returnServiceImpl) CreateReturn(r *http.Request) (*dto.ReturnResponse, error) {
+	args := &dto.CreateReturnRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	var ret *internal.ReturnRequest
+	err = s.returnRepo.Transaction(func(txRepo internal.ReturnRepo) error {
+		order, err := txRepo.Refunds().LockOrder(args.OrderID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return e.NewError(e.ErrOrderNotFound, "order not found", err)
+			}
+			return err
+		}
+		if order.UserID != userID {
+			return e.NewError(e.ErrOrderNotFound, "order not found", fmt.Errorf("order %d does not belong to user %d", order.ID, userID))
+		}
+		if order.Status != internal.OrderStatusDelivered {
+			return e.NewError(e.ErrReturnNotAllowed, "only delivered orders can be returned", fmt.Errorf("order %d is %s", order.ID, order.Status))
+		}
+
+		deliveredAt, err := txRepo.GetDeliveredAt(order.ID)
+		if err != nil {
+			return err
+		}
+		if deliveredAt == nil || time.Since(*deliveredAt) > s.returnWindow {
+			return e.NewError(e.ErrReturnNotAllowed, "the return window has passed",
+				fmt.Errorf("order %d can be returned for %s after delivery", order.ID, s.returnWindow))
+		}
+
+		item := findOrderItem(order, args.OrderItemID)
+		if item == nil {
+			return e.NewError(e.ErrOrderNotFound, "order item not found", fmt.Errorf("item %d is not part of order %d", args.OrderItemID, order.ID))
+		}
+
+		open, err := txRepo.OpenReturnQuantity(item.ID)
+		if err != nil {
+			return err
+		}
+		if left := item.RemainingQuantity() - open; args.Quantity > left {
+			return e.NewError(e.ErrReturnNotAllowed, "return is more than is left of the item",
+				fmt.Errorf("item %d has %d left to return, %d asked", item.ID, left, args.Quantity))
+		}
+
+		ret = &internal.ReturnRequest{
+			OrderID:     order.ID,
+			OrderItemID: item.ID,
+			UserID:      userID,
+			Quantity:    args.Quantity,
+			Reason:      args.Reason,
+		}
+		if err := txRepo.CreateReturn(ret); err != nil {
+			return err
+		}
+		ret.OrderItem = *item
+		return nil
+	})
+	if err != nil {
+		return nil, returnTransactionError(err, e.ErrCreateReturn, "failed to request the return")
+	}
+	log.Info().Msgf("User %d requested return %d of %d units of order item %d", userID, ret.ID, ret.Quantity, ret.OrderItemID)
+
+	return returnResponse(ret), nil
+}
+
+// ListUserReturns lists the returns of the logged in customer
+func (s *returnServiceImpl) ListUserReturns(r *http.Request) ([]*dto.ReturnResponse, *dto.PageMeta, error) {
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+	return s.listReturns(r, userID)
+}
+
+// ListReturns lists the returns of every customer, for the admins
+func (s *returnServiceImpl) ListReturns(r *http.Request) ([]*dto.ReturnResponse, *dto.PageMeta, error) {
+	return s.listReturns(r, 0)
+}
+
+func (s *returnServiceImpl) listReturns(r *http.Request, userID int64) ([]*dto.ReturnResponse, *dto.PageMeta, error) {
+	args := &dto.ListReturnsRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+	args.UserID = userID
+
+	returns, total, err := s.returnRepo.ListReturns(args)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrListReturns, "failed to list the returns", err)
+	}
+
+	resp := make([]*dto.ReturnResponse, 0, len(returns))
+	for i := range returns {
+		resp = append(resp, returnResponse(&returns[i]))
+	}
+	return resp, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+// ApproveReturn accepts a requested return, the customer can send the units back
+func (s *returnServiceImpl) ApproveReturn(r *http.Request) (*dto.ReturnResponse, error) {
+	args := &dto.ReturnActionRequest{}
+	if err := parseReturnAction(r, args); err != nil {
+		return nil, err
+	}
+	return s.moveReturn(r, args.ReturnID, internal.ReturnStatusApproved, args.Note)
+}
+
+// RejectReturn turns down a return that is not received yet
+func (s *returnServiceImpl) RejectReturn(r *http.Request) (*dto.ReturnResponse, error) {
+	args := &dto.RejectReturnRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+	return s.moveReturn(r, args.ReturnID, internal.ReturnStatusRejected, args.Note)
+}
+
+// ReceiveReturn records that the units of an approved return arrived
+func (s *returnServiceImpl) ReceiveReturn(r *http.Request) (*dto.ReturnResponse, error) {
+	args := &dto.ReturnActionRequest{}
+	if err := parseReturnAction(r, args); err != nil {
+		return nil, err
+	}
+	return s.moveReturn(r, args.ReturnID, internal.ReturnStatusReceived, args.Note)
+}
+
+// RefundReturn refunds the units of a received return against the order payment and puts them back in stock.
+// The return is refunded once the refund is recorded, a refund the provider does not accept yet is retried
+func (s *returnServiceImpl) RefundReturn(r *http.Request) (*dto.ReturnResponse, error) {
+	args := &dto.ReturnActionRequest{}
+	if err := parseReturnAction(r, args); err != nil {
+		return nil, err
+	}
+
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	var ret *internal.ReturnRequest
+	var order *internal.Order
+	var refund *internal.Refund
+	var intentID string
+	err = s.returnRepo.Transaction(func(txRepo internal.ReturnRepo) error {
+		ret, err = txRepo.LockReturn(args.ReturnID)
+		if err != nil {
+			return err
+		}
+		if !internal.CanTransitionReturnStatus(ret.Status, internal.ReturnStatusRefunded) {
+			return fmt.Errorf("%w: return %d is %s", internal.ErrInvalidReturnStatus, ret.ID, ret.Status)
+		}
+
+		order, err = txRepo.Refunds().LockOrder(ret.OrderID)
+		if err != nil {
+			return err
+		}
+		paid := order.SucceededPayment()
+		if paid == nil {
+			return e.NewError(e.ErrOrderNotRefundable, "only paid orders can be refunded", fmt.Errorf("order %d has no captured payment", order.ID))
+		}
+		item := findOrderItem(order, ret.OrderItemID)
+		if item == nil {
+			return e.NewError(e.ErrOrderNotFound, "order item not found", fmt.Errorf("item %d is not part of order %d", ret.OrderItemID, order.ID))
+		}
+
+		refund = &internal.Refund{
+			PaymentID: paid.ID,
+			Reason:    fmt.Sprintf("return %d: %s", ret.ID, ret.Reason),
+			ActorID:   adminID,
+			ActorRole: internal.ActorRoleAdmin,
+		}
+		addRefundItem(refund, item, ret.Quantity)
+		if err := txRepo.Refunds().CreateRefund(order, refund); err != nil {
+			return err
+		}
+		intentID = paid.IntentID
+
+		if err := txRepo.SetReturnRefund(ret, refund.ID); err != nil {
+			return err
+		}
+		return txRepo.UpdateReturnStatus(ret, internal.ReturnStatusRefunded, adminID, args.Note)
+	})
+	if err != nil {
+		return nil, returnTransactionError(err, e.ErrUpdateReturn, "failed to refund the return")
+	}
+	sendRefund(r.Context(), s.provider, s.returnRepo.Refunds(), order, refund, intentID)
+	log.Info().Msgf("Admin %d refunded return %d", adminID, ret.ID)
+
+	return returnResponse(ret), nil
+}
+
+// moveReturn moves a return to the new status as the logged in admin
+func (s *returnServiceImpl) moveReturn(r *http.Request, returnID int64, status, note string) (*dto.ReturnResponse, error) {
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	var ret *internal.ReturnRequest
+	err = s.returnRepo.Transaction(func(txRepo internal.ReturnRepo) error {
+		ret, err = txRepo.LockReturn(returnID)
+		if err != nil {
+			return err
+		}
+		return txRepo.UpdateReturnStatus(ret, status, adminID, note)
+	})
+	if err != nil {
+		return nil, returnTransactionError(err, e.ErrUpdateReturn, "failed to update the return")
+	}
+	log.Info().Msgf("Admin %d moved return %d to %s", adminID, ret.ID, ret.Status)
+
+	return returnResponse(ret), nil
+}
+
+func parseReturnAction(r *http.Request, args *dto.ReturnActionRequest) error {
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+	return nil
+}
+
+func findOrderItem(order *internal.Order, orderItemID int64) *internal.OrderItem {
+	for i := range order.Items {
+		if order.Items[i].ID == orderItemID {
+			return &order.Items[i]
+		}
+	}
+	return nil
+}
+
+// returnTransactionError keeps the error codes set inside the transaction and maps the repo errors
+func returnTransactionError(err error, code int, msg string) error {
+	var wrapErr *e.WrapError
+	if errors.As(err, &wrapErr) {
+		return wrapErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrReturnNotFound, "return not found", err)
+	}
+	if errors.Is(err, internal.ErrInvalidReturnStatus) {
+		return e.NewError(e.ErrInvalidReturnStatus, "invalid return status transition", err)
+	}
+	if errors.Is(err, internal.ErrRefundExceedsOrder) {
+		return e.NewError(e.ErrOrderNotRefundable, "refund is more than is left of the item", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func returnResponse(ret *internal.ReturnRequest) *dto.ReturnResponse {
+	return &dto.ReturnResponse{
+		ReturnID:    ret.ID,
+		OrderID:     ret.OrderID,
+		OrderItemID: ret.OrderItemID,
+		ProductID:   ret.OrderItem.ProductID,
+		BrandName:   ret.OrderItem.Product.BrandName,
+		Quantity:    ret.Quantity,
+		Reason:      ret.Reason,
+		Status:      ret.Status,
+		AdminNote:   ret.AdminNote,
+		RefundID:    ret.RefundID,
+		ReceivedAt:  ret.ReceivedAt,
+		CreatedAt:   ret.CreatedAt,
+		UpdatedAt:   ret.UpdatedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func returnRequest(userID, returnID int64, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/admin/returns", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("returnid", fmt.Sprint(returnID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, userID))
+}
+
+func newTestReturnService(env *orderTestEnv) ReturnService {
+	return NewReturnService(internal.NewReturnRepo(env.db), env.provider, helper.NewContextHelper(), 14*24*time.Hour)
+}
+
+func deliverOrder(t *testing.T, env *orderTestEnv, orderID int64) {
+	for _, status := range []string{internal.OrderStatusPacked, internal.OrderStatusShipped, internal.OrderStatusDelivered} {
+		_, err := env.orders.UpdateOrderStatus(orderID, status, 1, internal.ActorRoleAdmin, "")
+		require.NoError(t, err)
+	}
+}
+
+func TestReturnRefundedAndRestocked(t *testing.T) {
+	env := newOrderTestEnv(t)
+	returns := newTestReturnService(env)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 3, true)
+
+	var item internal.OrderItem
+	require.NoError(t, env.db.Where("order_id = ?", orderID).First(&item).Error)
+	body := fmt.Sprintf(`{"order_item_id": %d, "quantity": 2, "reason": "too small"}`, item.ID)
+
+	_, err := returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID, body))
+	assertErrorCode(t, e.ErrReturnNotAllowed, err)
+
+	deliverOrder(t, env, orderID)
+	_, err = returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID,
+		fmt.Sprintf(`{"order_item_id": %d, "quantity": 4, "reason": "too small"}`, item.ID)))
+	assertErrorCode(t, e.ErrReturnNotAllowed, err)
+
+	ret, err := returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID, body))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ReturnStatusRequested, ret.Status)
+
+	// only one unit is left that is not on its way back
+	_, err = returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID, body))
+	assertErrorCode(t, e.ErrReturnNotAllowed, err)
+
+	_, err = returns.RefundReturn(returnRequest(1, ret.ReturnID, ""))
+	assertErrorCode(t, e.ErrInvalidReturnStatus, err)
+
+	_, err = returns.ApproveReturn(returnRequest(1, ret.ReturnID, ""))
+	require.NoError(t, err)
+	_, err = returns.ReceiveReturn(returnRequest(1, ret.ReturnID, `{"note": "box opened"}`))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), env.stock(t, brand.ID), "stock comes back with the refund")
+
+	refunded, err := returns.RefundReturn(returnRequest(1, ret.ReturnID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ReturnStatusRefunded, refunded.Status)
+	require.NotNil(t, refunded.RefundID)
+	assert.NotNil(t, refunded.ReceivedAt)
+	assert.Equal(t, int64(4), env.stock(t, brand.ID))
+
+	var order internal.Order
+	require.NoError(t, env.db.First(&order, orderID).Error)
+	assert.Equal(t, internal.OrderStatusDelivered, order.Status, "one unit was kept")
+	assert.InDelta(t, 200, order.RefundedAmount, 0.001)
+}
+
+func TestReturnRejectedAndOutOfWindow(t *testing.T) {
+	env := newOrderTestEnv(t)
+	returns := newTestReturnService(env)
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+	orderID := env.placeOrder(t, userID, brand, 1, true)
+	deliverOrder(t, env, orderID)
+
+	var item internal.OrderItem
+	require.NoError(t, env.db.Where("order_id = ?", orderID).First(&item).Error)
+	body := fmt.Sprintf(`{"order_item_id": %d, "quantity": 1, "reason": "not as described"}`, item.ID)
+
+	otherID := createTestUser(t, env.db, "other")
+	_, err := returns.CreateReturn(orderRequest(http.MethodPost, otherID, orderID, body))
+	assertErrorCode(t, e.ErrOrderNotFound, err)
+
+	ret, err := returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID, body))
+	require.NoError(t, err)
+
+	_, err = returns.RejectReturn(returnRequest(1, ret.ReturnID, `{}`))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+	rejected, err := returns.RejectReturn(returnRequest(1, ret.ReturnID, `{"note": "item was used"}`))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ReturnStatusRejected, rejected.Status)
+	assert.Equal(t, "item was used", rejected.AdminNote)
+
+	_, err = returns.ReceiveReturn(returnRequest(1, ret.ReturnID, ""))
+	assertErrorCode(t, e.ErrInvalidReturnStatus, err)
+	_, err = returns.ApproveReturn(returnRequest(1, 999, ""))
+	assertErrorCode(t, e.ErrReturnNotFound, err)
+
+	// a rejected return frees the unit, but the window has passed
+	require.NoError(t, env.db.Model(&internal.OrderStatusHistory{}).
+		Where("order_id = ? AND to_status = ?", orderID, internal.OrderStatusDelivered).
+		Update("created_at", time.Now().Add(-15*24*time.Hour)).Error)
+	_, err = returns.CreateReturn(orderRequest(http.MethodPost, userID, orderID, body))
+	assertErrorCode(t, e.ErrReturnNotAllowed, err)
+}
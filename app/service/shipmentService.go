@@ -0,0 +1,443 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/dto"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/e"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type ShipmentService interface {
+	CreateShipment(r *http.Request) (*dto.ShipmentResponse, error)
+	ListShipments(r *http.Request) (*dto.OrderTrackingResponse, error)
+	CancelShipment(r *http.Request) (*dto.ShipmentResponse, error)
+	TrackOrder(r *http.Request) (*dto.OrderTrackingResponse, error)
+}
+
+type shipmentServiceImpl struct {
+	shipmentRepo internal.ShipmentRepo
+	carrier      carrier.Carrier
+	ctxHelper    helper.ContextHelper
+}
+
+func NewShipmentService(shipmentRepo internal.ShipmentRepo, shippingCarrier carrier.Carrier, ctxHelper helper.ContextHelper) ShipmentService {
+	return &shipmentServiceImpl{
+		shipmentRepo: shipmentRepo,
+		carrier:      shippingCarrier,
+		ctxHelper:    ctxHelper,
+	}
+}
+
+// CreateShipment books a parcel with the carrier for items of a paid order. An order can go out in
+// several parcels, no item is shipped more than what is left of it
+func (s *shipmentServiceImpl) CreateShipment(r *http.Request) (*dto.ShipmentResponse, error) {
+	args := &dto.CreateShipmentRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	order, err := s.shipmentRepo.GetOrder(args.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		return nil, e.NewError(e.ErrCreateShipment, "error while getting the order", err)
+	}
+	// checked before the label is bought, and again with the order locked
+	items, err := planShipment(s.shipmentRepo, order, args.Items)
+	if err != nil {
+		return nil, shipmentError(err, e.ErrCreateShipment, "failed to create the shipment")
+	}
+
+	parcel := carrier.LabelRequest{
+		Reference: fmt.Sprintf("order-%d", order.ID),
+		ShipTo:    carrierAddress(order),
+	}
+	for _, item := range items {
+		parcel.Packages += item.Quantity
+		parcel.WeightGrams += item.OrderItem.Product.WeightGrams * item.Quantity
+	}
+	label, err := s.carrier.CreateLabel(r.Context(), parcel)
+	if err != nil {
+		return nil, e.NewError(e.ErrCreateShipment, "carrier did not accept the shipment", err)
+	}
+
+	shipment := &internal.Shipment{
+		OrderID:        order.ID,
+		Carrier:        s.carrier.Name(),
+		TrackingNumber: label.TrackingNumber,
+		LabelURL:       label.URL,
+		Status:         internal.ShipmentStatusLabelCreated,
+		CreatedBy:      adminID,
+		Events: []internal.ShipmentEvent{{
+			Status:      internal.ShipmentStatusLabelCreated,
+			Description: "Label created",
+			OccurredAt:  label.CreatedAt,
+		}},
+	}
+	err = s.shipmentRepo.Transaction(func(txRepo internal.ShipmentRepo) error {
+		order, err := txRepo.LockOrder(order.ID)
+		if err != nil {
+			return err
+		}
+		shipment.Items, err = planShipment(txRepo, order, args.Items)
+		if err != nil {
+			return err
+		}
+		if err := txRepo.CreateShipment(shipment); err != nil {
+			return err
+		}
+		return syncOrderStatus(txRepo, order, adminID, internal.ActorRoleAdmin)
+	})
+	if err != nil {
+		// the label is of no use without the shipment
+		if cancelErr := s.carrier.Cancel(r.Context(), label.TrackingNumber); cancelErr != nil {
+			log.Warn().Err(cancelErr).Msgf("failed to cancel label %s of order %d", label.TrackingNumber, order.ID)
+		}
+		return nil, shipmentError(err, e.ErrCreateShipment, "failed to create the shipment")
+	}
+	log.Info().Msgf("Admin %d created shipment %d (%s) for order %d", adminID, shipment.ID, shipment.TrackingNumber, order.ID)
+
+	return shipmentResponse(shipment), nil
+}
+
+// ListShipments shows the shipments of any order with the tracking of the last refresh, for the admins
+func (s *shipmentServiceImpl) ListShipments(r *http.Request) (*dto.OrderTrackingResponse, error) {
+	args := &dto.OrderShipmentsRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	if _, err := s.shipmentRepo.GetOrder(args.OrderID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		return nil, e.NewError(e.ErrGetShipments, "error while getting the order", err)
+	}
+
+	return s.orderTracking(args.OrderID)
+}
+
+// CancelShipment voids the label of a shipment the carrier has not picked up, its items can be shipped again
+func (s *shipmentServiceImpl) CancelShipment(r *http.Request) (*dto.ShipmentResponse, error) {
+	args := &dto.ShipmentIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	adminID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	shipment, err := s.shipmentRepo.GetShipment(args.ShipmentID)
+	if err != nil {
+		return nil, shipmentError(err, e.ErrCancelShipment, "failed to get the shipment")
+	}
+	// the order is locked before the shipment, the same as when tracking moves the order on
+	err = s.shipmentRepo.Transaction(func(txRepo internal.ShipmentRepo) error {
+		order, err := txRepo.LockOrder(shipment.OrderID)
+		if err != nil {
+			return err
+		}
+		shipment, err = txRepo.LockShipment(args.ShipmentID)
+		if err != nil {
+			return err
+		}
+
+		err = cancelShipment(r.Context(), s.carrier, txRepo, shipment, fmt.Sprintf("Cancelled by admin %d", adminID))
+		if err != nil {
+			return err
+		}
+		return syncOrderStatus(txRepo, order, adminID, internal.ActorRoleAdmin)
+	})
+	if err != nil {
+		return nil, shipmentError(err, e.ErrCancelShipment, "failed to cancel the shipment")
+	}
+	log.Info().Msgf("Admin %d cancelled shipment %d of order %d", adminID, shipment.ID, shipment.OrderID)
+
+	return shipmentResponse(shipment), nil
+}
+
+// TrackOrder shows where the parcels of an order of the logged in customer were at the last tracking refresh
+func (s *shipmentServiceImpl) TrackOrder(r *http.Request) (*dto.OrderTrackingResponse, error) {
+	args := &dto.OrderShipmentsRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	userID, err := s.ctxHelper.GetUserID(r.Context())
+	if err != nil {
+		return nil, e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	order, err := s.shipmentRepo.GetOrder(args.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrOrderNotFound, "order not found", err)
+		}
+		return nil, e.NewError(e.ErrGetShipments, "error while getting the order", err)
+	}
+	if order.UserID != userID {
+		return nil, e.NewError(e.ErrOrderNotFound, "order not found", fmt.Errorf("order %d does not belong to user %d", order.ID, userID))
+	}
+
+	return s.orderTracking(order.ID)
+}
+
+func (s *shipmentServiceImpl) orderTracking(orderID int64) (*dto.OrderTrackingResponse, error) {
+	order, err := s.shipmentRepo.GetOrder(orderID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetShipments, "error while getting the order", err)
+	}
+	shipments, err := s.shipmentRepo.ListShipments(orderID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetShipments, "failed to get the shipments", err)
+	}
+
+	resp := &dto.OrderTrackingResponse{
+		OrderID:             order.ID,
+		Status:              order.Status,
+		EstimatedDeliveryAt: order.EstimatedDeliveryAt,
+		Shipments:           make([]dto.ShipmentResponse, 0, len(shipments)),
+	}
+	for i := range shipments {
+		resp.Shipments = append(resp.Shipments, *shipmentResponse(&shipments[i]))
+	}
+	return resp, nil
+}
+
+// cancelShipment records the shipment as cancelled and then voids its label with the carrier. It runs inside
+// the transaction, so a label the carrier does not void leaves the shipment as it was
+func cancelShipment(ctx context.Context, shippingCarrier carrier.Carrier, txRepo internal.ShipmentRepo, shipment *internal.Shipment, note string) error {
+	if shipment.Status != internal.ShipmentStatusLabelCreated {
+		return fmt.Errorf("%w: shipment %d is %s", internal.ErrShipmentNotCancellable, shipment.ID, shipment.Status)
+	}
+
+	events := append(shipment.Events, internal.ShipmentEvent{
+		Status:      internal.ShipmentStatusCancelled,
+		Description: note,
+		OccurredAt:  time.Now(),
+	})
+	if err := txRepo.SaveTracking(shipment, events); err != nil {
+		return err
+	}
+	return shippingCarrier.Cancel(ctx, shipment.TrackingNumber)
+}
+
+// planShipment works out what goes in a shipment of the order, everything not shipped yet when no items
+// are asked for
+func planShipment(repo internal.ShipmentRepo, order *internal.Order, requested []dto.ShipmentItemRequest) ([]internal.ShipmentItem, error) {
+	if order.Status != internal.OrderStatusPaid && order.Status != internal.OrderStatusPacked {
+		return nil, e.NewError(e.ErrOrderNotShippable, "only paid or packed orders can be shipped", fmt.Errorf("order %d is %s", order.ID, order.Status))
+	}
+
+	shipped, err := repo.ShippedQuantities(order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []internal.ShipmentItem
+	if len(requested) == 0 {
+		for _, item := range order.Items {
+			if left := item.RemainingQuantity() - shipped[item.ID]; left > 0 {
+				items = append(items, internal.ShipmentItem{OrderItemID: item.ID, OrderItem: item, Quantity: left})
+			}
+		}
+		if len(items) == 0 {
+			return nil, e.NewError(e.ErrShipmentQuantity, "every item of the order is shipped", fmt.Errorf("order %d has nothing left to ship", order.ID))
+		}
+		return items, nil
+	}
+
+	for _, req := range requested {
+		item := findOrderItem(order, req.OrderItemID)
+		if item == nil {
+			return nil, e.NewError(e.ErrOrderNotFound, "order item not found", fmt.Errorf("item %d is not part of order %d", req.OrderItemID, order.ID))
+		}
+		if left := item.RemainingQuantity() - shipped[item.ID]; req.Quantity > left {
+			return nil, e.NewError(e.ErrShipmentQuantity, "shipment is more than is left of the item",
+				fmt.Errorf("item %d has %d left to ship, %d asked", item.ID, left, req.Quantity))
+		}
+		items = append(items, internal.ShipmentItem{OrderItemID: item.ID, OrderItem: *item, Quantity: req.Quantity})
+	}
+	return items, nil
+}
+
+// syncOrderStatus moves the order on with its shipments: packed once a parcel is booked, shipped once the
+// carrier has every unit and delivered once every unit arrived. Cancelled shipments do not count
+func syncOrderStatus(txRepo internal.ShipmentRepo, order *internal.Order, actorID int64, actorRole string) error {
+	shipments, err := txRepo.ListShipments(order.ID)
+	if err != nil {
+		return err
+	}
+
+	shipped := make(map[int64]int64)
+	active, handedOver, delivered := 0, true, true
+	for _, shipment := range shipments {
+		if shipment.Status == internal.ShipmentStatusCancelled {
+			continue
+		}
+		active++
+		handedOver = handedOver && shipment.HandedOver()
+		delivered = delivered && shipment.Status == internal.ShipmentStatusDelivered
+		for _, item := range shipment.Items {
+			shipped[item.OrderItemID] += item.Quantity
+		}
+	}
+	if active == 0 {
+		return nil
+	}
+
+	complete := true
+	for _, item := range order.Items {
+		if shipped[item.ID] < item.RemainingQuantity() {
+			complete = false
+		}
+	}
+
+	target := internal.OrderStatusPacked
+	if complete && delivered {
+		target = internal.OrderStatusDelivered
+	} else if complete && handedOver {
+		target = internal.OrderStatusShipped
+	}
+
+	for _, status := range []string{internal.OrderStatusPacked, internal.OrderStatusShipped, internal.OrderStatusDelivered} {
+		if internal.CanTransitionOrderStatus(order.Status, status) {
+			if err := txRepo.ChangeOrderStatus(order, status, actorID, actorRole, "shipment tracking"); err != nil {
+				return err
+			}
+		}
+		if status == target {
+			break
+		}
+	}
+	return nil
+}
+
+// carrierAddress is where the order goes, the profile address for orders that did not keep one
+func carrierAddress(order *internal.Order) carrier.Address {
+	address := order.ShippingAddress
+	if address.IsEmpty() {
+		address = order.User.ProfileAddress()
+	}
+
+	to := carrier.Address{
+		Name:    address.Name,
+		City:    address.City,
+		State:   address.State,
+		Pincode: address.Pincode,
+	}
+	for _, line := range []string{address.Line1, address.Line2} {
+		if line != "" {
+			to.Lines = append(to.Lines, line)
+		}
+	}
+	if address.Phonenumber > 0 {
+		to.Phone = fmt.Sprint(address.Phonenumber)
+	}
+	return to
+}
+
+func shipmentEvents(events []carrier.TrackingEvent) []internal.ShipmentEvent {
+	stored := make([]internal.ShipmentEvent, 0, len(events))
+	for _, event := range events {
+		stored = append(stored, internal.ShipmentEvent{
+			Status:      event.Status,
+			Description: event.Description,
+			Location:    event.Location,
+			OccurredAt:  event.OccurredAt,
+		})
+	}
+	return stored
+}
+
+// shipmentError keeps the error codes set while planning the shipment and maps the repo and carrier errors
+func shipmentError(err error, code int, msg string) error {
+	var wrapErr *e.WrapError
+	if errors.As(err, &wrapErr) {
+		return wrapErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrShipmentNotFound, "shipment not found", err)
+	}
+	if errors.Is(err, internal.ErrShipmentNotCancellable) || errors.Is(err, carrier.ErrCannotCancel) {
+		return e.NewError(e.ErrShipmentNotCancellable, "the carrier already has the shipment", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func shipmentResponse(shipment *internal.Shipment) *dto.ShipmentResponse {
+	resp := &dto.ShipmentResponse{
+		ShipmentID:     shipment.ID,
+		OrderID:        shipment.OrderID,
+		Carrier:        shipment.Carrier,
+		TrackingNumber: shipment.TrackingNumber,
+		LabelURL:       shipment.LabelURL,
+		Status:         shipment.Status,
+		Items:          make([]dto.ShipmentItemResponse, 0, len(shipment.Items)),
+		Events:         make([]dto.TrackingEventResponse, 0, len(shipment.Events)),
+		DeliveredAt:    shipment.DeliveredAt,
+		CreatedAt:      shipment.CreatedAt,
+	}
+	for _, item := range shipment.Items {
+		resp.Items = append(resp.Items, dto.ShipmentItemResponse{
+			OrderItemID: item.OrderItemID,
+			ProductID:   item.OrderItem.ProductID,
+			BrandName:   item.OrderItem.Product.BrandName,
+			Quantity:    item.Quantity,
+		})
+	}
+	for _, event := range shipment.Events {
+		resp.Events = append(resp.Events, dto.TrackingEventResponse{
+			Status:      event.Status,
+			Description: event.Description,
+			Location:    event.Location,
+			OccurredAt:  event.OccurredAt,
+		})
+	}
+	return resp
+}
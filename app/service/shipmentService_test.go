@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+	"e-cart/pkg/e"
+	"e-cart/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shipmentRequest(method string, adminID, shipmentID int64, body string) *http.Request {
+	req := httptest.NewRequest(method, "/admin/shipments", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("shipmentid", fmt.Sprint(shipmentID))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	return req.WithContext(context.WithValue(ctx, middleware.UserIDKey, adminID))
+}
+
+func orderItemIDs(t *testing.T, env *orderTestEnv, orderID int64) []int64 {
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	var ids []int64
+	for _, item := range order.Items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+// refreshTracking runs one pass of the tracking refresher, expecting it to save the tracking of n orders
+func refreshTracking(t *testing.T, env *orderTestEnv, n int) {
+	refreshed, err := refreshOpenShipments(context.Background(), internal.NewShipmentRepo(env.db), env.carrier)
+	require.NoError(t, err)
+	assert.Equal(t, n, refreshed)
+}
+
+func TestSplitShipmentTracking(t *testing.T) {
+	env := newOrderTestEnv(t)
+	fake := env.carrier
+	shipments := NewShipmentService(internal.NewShipmentRepo(env.db), fake, helper.NewContextHelper())
+	userID := createTestUser(t, env.db, "buyer")
+	phone := createTestBrand(t, env.db, 10)
+	cover := createTestBrand(t, env.db, 10)
+
+	createTestCartLine(t, env.db, userID, phone, 1)
+	orderID := env.placeOrder(t, userID, cover, 2, true)
+	items := orderItemIDs(t, env, orderID)
+	require.Len(t, items, 2)
+
+	first, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID,
+		fmt.Sprintf(`{"items":[{"order_item_id":%d,"quantity":1}]}`, items[0])))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ShipmentStatusLabelCreated, first.Status)
+	require.Len(t, first.Items, 1)
+	assert.Equal(t, "IPHONE", first.Items[0].BrandName)
+
+	order, err := env.orders.GetOrderByID(orderID)
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPacked, order.Status)
+
+	// the rest of the order goes in the second parcel
+	second, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID, ""))
+	require.NoError(t, err)
+	require.Len(t, second.Items, 1)
+	assert.Equal(t, int64(2), second.Items[0].Quantity)
+
+	_, err = shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID, ""))
+	assertErrorCode(t, e.ErrShipmentQuantity, err)
+
+	// shipped once the carrier has both parcels, the tracking shows what the last refresh saved
+	require.NoError(t, fake.Advance(first.TrackingNumber, carrier.StatusPickedUp, "Kochi"))
+	tracking, err := shipments.TrackOrder(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ShipmentStatusLabelCreated, tracking.Shipments[0].Status)
+
+	refreshTracking(t, env, 1)
+	tracking, err = shipments.TrackOrder(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusPacked, tracking.Status)
+	require.Len(t, tracking.Shipments, 2)
+	assert.Equal(t, internal.ShipmentStatusPickedUp, tracking.Shipments[0].Status)
+	require.Len(t, tracking.Shipments[0].Events, 2)
+	assert.Equal(t, "Kochi", tracking.Shipments[0].Events[1].Location)
+
+	require.NoError(t, fake.Advance(second.TrackingNumber, carrier.StatusInTransit, "Kochi"))
+	refreshTracking(t, env, 1)
+	tracking, err = shipments.TrackOrder(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusShipped, tracking.Status)
+
+	require.NoError(t, fake.Advance(first.TrackingNumber, carrier.StatusDelivered, "Ernakulam"))
+	require.NoError(t, fake.Advance(second.TrackingNumber, carrier.StatusDelivered, "Ernakulam"))
+	refreshTracking(t, env, 1)
+	tracking, err = shipments.ListShipments(orderRequest(http.MethodGet, 99, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusDelivered, tracking.Status)
+	for _, shipment := range tracking.Shipments {
+		assert.Equal(t, internal.ShipmentStatusDelivered, shipment.Status)
+		assert.NotNil(t, shipment.DeliveredAt)
+	}
+	// delivered parcels are not asked about again
+	refreshTracking(t, env, 0)
+
+	// other customers do not see the order
+	otherID := createTestUser(t, env.db, "other")
+	_, err = shipments.TrackOrder(orderRequest(http.MethodGet, otherID, orderID, ""))
+	assertErrorCode(t, e.ErrOrderNotFound, err)
+}
+
+func TestCancelShipment(t *testing.T) {
+	env := newOrderTestEnv(t)
+	fake := env.carrier
+	shipments := NewShipmentService(internal.NewShipmentRepo(env.db), fake, helper.NewContextHelper())
+	userID := createTestUser(t, env.db, "buyer")
+	brand := createTestBrand(t, env.db, 10)
+
+	pending := env.placeOrder(t, userID, brand, 1, false)
+	_, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, pending, ""))
+	assertErrorCode(t, e.ErrOrderNotShippable, err)
+
+	orderID := env.placeOrder(t, userID, brand, 3, true)
+	shipment, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID, ""))
+	require.NoError(t, err)
+
+	cancelled, err := shipments.CancelShipment(shipmentRequest(http.MethodPost, 99, shipment.ShipmentID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.ShipmentStatusCancelled, cancelled.Status)
+	events, err := fake.Track(context.Background(), shipment.TrackingNumber)
+	require.NoError(t, err)
+	assert.Equal(t, carrier.StatusCancelled, events[len(events)-1].Status)
+
+	// the items of a cancelled shipment can go out again
+	again, err := shipments.CreateShipment(orderRequest(http.MethodPost, 99, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), again.Items[0].Quantity)
+
+	// the carrier has the parcel before the tracking shows it, the shipment stays as it was
+	require.NoError(t, fake.Advance(again.TrackingNumber, carrier.StatusPickedUp, "Kochi"))
+	_, err = shipments.CancelShipment(shipmentRequest(http.MethodPost, 99, again.ShipmentID, ""))
+	assertErrorCode(t, e.ErrShipmentNotCancellable, err)
+	var stored internal.Shipment
+	require.NoError(t, env.db.First(&stored, again.ShipmentID).Error)
+	assert.Equal(t, internal.ShipmentStatusLabelCreated, stored.Status)
+
+	refreshTracking(t, env, 1)
+	tracking, err := shipments.TrackOrder(orderRequest(http.MethodGet, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.Equal(t, internal.OrderStatusShipped, tracking.Status)
+	_, err = shipments.CancelShipment(shipmentRequest(http.MethodPost, 99, again.ShipmentID, ""))
+	assertErrorCode(t, e.ErrShipmentNotCancellable, err)
+
+	_, err = shipments.CancelShipment(shipmentRequest(http.MethodPost, 99, 404, ""))
+	assertErrorCode(t, e.ErrShipmentNotFound, err)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type ShippingService interface {
+	CreateZone(r *http.Request) (*dto.ShippingZoneResponse, error)
+	GetZone(r *http.Request) (*dto.ShippingZoneResponse, error)
+	ListZones(r *http.Request) ([]*dto.ShippingZoneResponse, *dto.PageMeta, error)
+	UpdateZone(r *http.Request) (*dto.ShippingZoneResponse, error)
+	DeleteZone(r *http.Request) error
+	CheckPincode(r *http.Request) (*dto.ShippingQuoteResponse, error)
+}
+
+type shippingServiceImpl struct {
+	shippingRepo internal.ShippingRepo
+}
+
+func NewShippingService(shippingRepo internal.ShippingRepo) ShippingService {
+	return &shippingServiceImpl{
+		shippingRepo: shippingRepo,
+	}
+}
+
+func (s *shippingServiceImpl) CreateZone(r *http.Request) (*dto.ShippingZoneResponse, error) {
+	args := &dto.SaveShippingZoneRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	zone, err := s.shippingRepo.CreateZone(args)
+	if err != nil {
+		return nil, shippingZoneError(err, e.ErrSaveShippingZone, "failed to create the shipping zone")
+	}
+	log.Info().Msgf("Created shipping zone %s", zone.Name)
+
+	return shippingZoneResponse(zone), nil
+}
+
+func (s *shippingServiceImpl) GetZone(r *http.Request) (*dto.ShippingZoneResponse, error) {
+	args := &dto.ShippingZoneIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	zone, err := s.shippingRepo.GetZone(args.ZoneID)
+	if err != nil {
+		return nil, shippingZoneError(err, e.ErrGetShippingZones, "failed to get the shipping zone")
+	}
+	return shippingZoneResponse(zone), nil
+}
+
+func (s *shippingServiceImpl) ListZones(r *http.Request) ([]*dto.ShippingZoneResponse, *dto.PageMeta, error) {
+	args := &dto.ListShippingZonesRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	zones, total, err := s.shippingRepo.ListZones(args)
+	if err != nil {
+		return nil, nil, e.NewError(e.ErrGetShippingZones, "failed to list the shipping zones", err)
+	}
+
+	resp := make([]*dto.ShippingZoneResponse, 0, len(zones))
+	for i := range zones {
+		resp = append(resp, shippingZoneResponse(&zones[i]))
+	}
+	return resp, dto.NewPageMeta(args.Pagination, total), nil
+}
+
+func (s *shippingServiceImpl) UpdateZone(r *http.Request) (*dto.ShippingZoneResponse, error) {
+	args := &dto.SaveShippingZoneRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	zone, err := s.shippingRepo.UpdateZone(args)
+	if err != nil {
+		return nil, shippingZoneError(err, e.ErrSaveShippingZone, "failed to update the shipping zone")
+	}
+	log.Info().Msgf("Updated shipping zone %s", zone.Name)
+
+	return shippingZoneResponse(zone), nil
+}
+
+func (s *shippingServiceImpl) DeleteZone(r *http.Request) error {
+	args := &dto.ShippingZoneIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	err = s.shippingRepo.DeleteZone(args.ZoneID)
+	if err != nil {
+		return shippingZoneError(err, e.ErrDeleteShippingZone, "failed to delete the shipping zone")
+	}
+	log.Info().Msgf("Deleted shipping zone %d", args.ZoneID)
+
+	return nil
+}
+
+// CheckPincode tells if a pincode is served and what shipping an order of the given value and weight costs
+func (s *shippingServiceImpl) CheckPincode(r *http.Request) (*dto.ShippingQuoteResponse, error) {
+	args := &dto.ShippingCheckRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	zones, err := s.shippingRepo.GetActiveZones()
+	if err != nil {
+		return nil, e.NewError(e.ErrQuoteShipping, "failed to get the shipping zones", err)
+	}
+
+	quote, err := internal.QuoteShipping(zones, args.Pincode, args.OrderValue, args.WeightGrams, time.Now())
+	if err != nil && !errors.Is(err, internal.ErrPincodeNotServiceable) {
+		return nil, e.NewError(e.ErrQuoteShipping, "failed to price the shipping", err)
+	}
+	return shippingQuoteResponse(args.Pincode, quote, err), nil
+}
+
+// shippingZoneError maps the repo errors of the shipping zones
+func shippingZoneError(err error, code int, msg string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrShippingZoneNotFound, "shipping zone not found", err)
+	}
+	if errors.Is(err, internal.ErrInvalidShippingZone) {
+		return e.NewError(e.ErrValidateRequest, "invalid shipping zone settings", err)
+	}
+	if errors.Is(err, internal.ErrDuplicateShippingZone) {
+		return e.NewError(e.ErrShippingZoneAlreadyExists, "shipping zone name already exists", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func shippingZoneResponse(zone *internal.ShippingZone) *dto.ShippingZoneResponse {
+	resp := &dto.ShippingZoneResponse{
+		ZoneID:          zone.ID,
+		Name:            zone.Name,
+		PincodeFrom:     zone.PincodeFrom,
+		PincodeTo:       zone.PincodeTo,
+		PincodePrefixes: zone.Prefixes(),
+		RateType:        zone.RateType,
+		BaseRate:        zone.BaseRate,
+		FreeAbove:       zone.FreeAbove,
+		MinDays:         zone.MinDays,
+		MaxDays:         zone.MaxDays,
+		Active:          zone.Active,
+		CreatedAt:       zone.CreatedAt,
+		UpdatedAt:       zone.UpdatedAt,
+	}
+	for _, tier := range zone.Tiers {
+		resp.Tiers = append(resp.Tiers, dto.ShippingRateTierResponse{MinValue: tier.MinValue, Rate: tier.Rate})
+	}
+	return resp
+}
+
+// shippingQuoteResponse shows the quote to the pincode, quoteErr is why the pincode is not served
+func shippingQuoteResponse(pincode int64, quote *internal.ShippingQuote, quoteErr error) *dto.ShippingQuoteResponse {
+	resp := &dto.ShippingQuoteResponse{Pincode: pincode}
+	if quoteErr != nil {
+		resp.Reason = quoteErr.Error()
+		return resp
+	}
+
+	resp.Serviceable = true
+	resp.Charge = quote.Charge
+	resp.Free = quote.Free
+	resp.EstimatedDeliveryFrom = quote.EstimatedFrom
+	resp.EstimatedDeliveryTo = quote.EstimatedTo
+	if quote.Zone != nil {
+		resp.Zone = quote.Zone.Name
+	}
+	return resp
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func shippingZoneRequest(method string, zoneID int64, body string) *http.Request {
+	req := httptest.NewRequest(method, "/admin/shipping/zones", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	if zoneID > 0 {
+		rctx.URLParams.Add("zoneid", fmt.Sprint(zoneID))
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestShippingChargedAtCheckout(t *testing.T) {
+	env := newOrderTestEnv(t)
+	shipping := NewShippingService(internal.NewShippingRepo(env.db))
+	brand := createTestBrand(t, env.db, 20)
+	userID := createTestUser(t, env.db, "buyer")
+
+	_, err := shipping.CreateZone(shippingZoneRequest(http.MethodPost, 0, `{"name": "kerala", "pincode_prefixes": ["68"], "rate_type": "weight"}`))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+	zone, err := shipping.CreateZone(shippingZoneRequest(http.MethodPost, 0,
+		`{"name": "kerala", "pincode_prefixes": ["68", "69"], "rate_type": "flat", "base_rate": 40, "free_above": 500, "min_days": 2, "max_days": 4}`))
+	require.NoError(t, err)
+	assert.True(t, zone.Active)
+	_, err = shipping.CreateZone(shippingZoneRequest(http.MethodPost, 0, `{"name": "kerala", "pincode_from": 110001, "pincode_to": 110099, "rate_type": "flat"}`))
+	assertErrorCode(t, e.ErrShippingZoneAlreadyExists, err)
+
+	cartID := createTestCartLine(t, env.db, userID, brand, 2)
+	cart, err := env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	require.NotNil(t, cart.Shipping)
+	assert.True(t, cart.Shipping.Serviceable)
+	assert.Equal(t, "kerala", cart.Shipping.Zone)
+	assert.InDelta(t, 40, cart.ShippingCharge, 0.001)
+	assert.InDelta(t, 240, cart.Total, 0.001)
+	assert.NotNil(t, cart.Shipping.EstimatedDeliveryTo)
+
+	// orders worth free_above ship free
+	require.NoError(t, env.db.Model(&internal.Cart{}).Where("id = ?", cartID).Update("quantity", 5).Error)
+	cart, err = env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	assert.True(t, cart.Shipping.Free)
+	assert.InDelta(t, 500, cart.Total, 0.001)
+	require.NoError(t, env.db.Model(&internal.Cart{}).Where("id = ?", cartID).Update("quantity", 2).Error)
+
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 40, order.ShippingCharge, 0.001)
+	assert.InDelta(t, 240, order.TotalPrice, 0.001)
+	require.NotNil(t, order.EstimatedDeliveryAt)
+
+	var stored internal.Order
+	require.NoError(t, env.db.First(&stored, order.OrderID).Error)
+	assert.InDelta(t, 240, stored.Total, 0.001)
+	assert.InDelta(t, 40, stored.ShippingCharge, 0.001)
+}
+
+func TestCheckoutBlockedForUnservedPincode(t *testing.T) {
+	env := newOrderTestEnv(t)
+	shipping := NewShippingService(internal.NewShippingRepo(env.db))
+	brand := createTestBrand(t, env.db, 20)
+	userID := createTestUser(t, env.db, "buyer")
+
+	zone, err := shipping.CreateZone(shippingZoneRequest(http.MethodPost, 0,
+		`{"name": "delhi", "pincode_from": 110001, "pincode_to": 110099, "rate_type": "flat", "base_rate": 60}`))
+	require.NoError(t, err)
+
+	check := httptest.NewRequest(http.MethodGet, "/user/shipping/check?pincode=682001&order_value=200", nil)
+	quote, err := shipping.CheckPincode(check)
+	require.NoError(t, err)
+	assert.False(t, quote.Serviceable)
+	assert.NotEmpty(t, quote.Reason)
+
+	createTestCartLine(t, env.db, userID, brand, 1)
+	cart, err := env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	assert.False(t, cart.Shipping.Serviceable)
+
+	_, err = env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	assertErrorCode(t, e.ErrPincodeNotServiceable, err)
+	assert.Equal(t, int64(20), env.stock(t, brand.ID))
+
+	// the zone now reaches the pincode of the user
+	_, err = shipping.UpdateZone(shippingZoneRequest(http.MethodPut, zone.ZoneID,
+		`{"name": "delhi and kochi", "pincode_from": 110001, "pincode_to": 110099, "pincode_prefixes": ["682"], "rate_type": "flat", "base_rate": 60}`))
+	require.NoError(t, err)
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 160, order.TotalPrice, 0.001)
+
+	require.NoError(t, shipping.DeleteZone(shippingZoneRequest(http.MethodDelete, zone.ZoneID, "")))
+	_, err = shipping.GetZone(shippingZoneRequest(http.MethodGet, zone.ZoneID, ""))
+	assertErrorCode(t, e.ErrShippingZoneNotFound, err)
+}
+
+func TestCancelRefundsShipping(t *testing.T) {
+	env := newOrderTestEnv(t)
+	shipping := NewShippingService(internal.NewShippingRepo(env.db))
+	brand := createTestBrand(t, env.db, 5)
+	userID := createTestUser(t, env.db, "buyer")
+
+	_, err := shipping.CreateZone(shippingZoneRequest(http.MethodPost, 0, `{"name": "kerala", "pincode_prefixes": ["68"], "rate_type": "flat", "base_rate": 40}`))
+	require.NoError(t, err)
+	orderID := env.placeOrder(t, userID, brand, 1, true)
+
+	resp, err := env.refunds.CancelOrder(orderRequest(http.MethodPost, userID, orderID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 140, resp.RefundedAmount, 0.001)
+	assert.InDelta(t, 0, resp.NetTotal, 0.001)
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"e-cart/app/dto"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type TaxService interface {
+	CreateTaxClass(r *http.Request) (*dto.TaxClassResponse, error)
+	GetTaxClass(r *http.Request) (*dto.TaxClassResponse, error)
+	ListTaxClasses(r *http.Request) ([]*dto.TaxClassResponse, error)
+	UpdateTaxClass(r *http.Request) (*dto.TaxClassResponse, error)
+	DeleteTaxClass(r *http.Request) error
+}
+
+type taxServiceImpl struct {
+	taxRepo internal.TaxRepo
+}
+
+func NewTaxService(taxRepo internal.TaxRepo) TaxService {
+	return &taxServiceImpl{
+		taxRepo: taxRepo,
+	}
+}
+
+func (s *taxServiceImpl) CreateTaxClass(r *http.Request) (*dto.TaxClassResponse, error) {
+	args := &dto.SaveTaxClassRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	class, err := s.taxRepo.CreateTaxClass(args)
+	if err != nil {
+		return nil, taxClassError(err, e.ErrSaveTaxClass, "failed to create the tax class")
+	}
+	log.Info().Msgf("Created tax class %s", class.Name)
+
+	return taxClassResponse(class), nil
+}
+
+func (s *taxServiceImpl) GetTaxClass(r *http.Request) (*dto.TaxClassResponse, error) {
+	args := &dto.TaxClassIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	class, err := s.taxRepo.GetTaxClass(args.TaxClassID)
+	if err != nil {
+		return nil, taxClassError(err, e.ErrGetTaxClasses, "failed to get the tax class")
+	}
+	return taxClassResponse(class), nil
+}
+
+func (s *taxServiceImpl) ListTaxClasses(r *http.Request) ([]*dto.TaxClassResponse, error) {
+	classes, err := s.taxRepo.ListTaxClasses()
+	if err != nil {
+		return nil, e.NewError(e.ErrGetTaxClasses, "failed to list the tax classes", err)
+	}
+
+	resp := make([]*dto.TaxClassResponse, 0, len(classes))
+	for i := range classes {
+		resp = append(resp, taxClassResponse(&classes[i]))
+	}
+	return resp, nil
+}
+
+func (s *taxServiceImpl) UpdateTaxClass(r *http.Request) (*dto.TaxClassResponse, error) {
+	args := &dto.SaveTaxClassRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	class, err := s.taxRepo.UpdateTaxClass(args)
+	if err != nil {
+		return nil, taxClassError(err, e.ErrSaveTaxClass, "failed to update the tax class")
+	}
+	log.Info().Msgf("Updated tax class %s", class.Name)
+
+	return taxClassResponse(class), nil
+}
+
+func (s *taxServiceImpl) DeleteTaxClass(r *http.Request) error {
+	args := &dto.TaxClassIDRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrInvalidRequest, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+
+	err = s.taxRepo.DeleteTaxClass(args.TaxClassID)
+	if err != nil {
+		return taxClassError(err, e.ErrDeleteTaxClass, "failed to delete the tax class")
+	}
+	log.Info().Msgf("Deleted tax class %d", args.TaxClassID)
+
+	return nil
+}
+
+// taxClassError maps the repo errors of the tax classes
+func taxClassError(err error, code int, msg string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return e.NewError(e.ErrTaxClassNotFound, "tax class not found", err)
+	}
+	if errors.Is(err, internal.ErrInvalidTaxClass) {
+		return e.NewError(e.ErrValidateRequest, "invalid tax rules", err)
+	}
+	if errors.Is(err, internal.ErrDuplicateTaxClass) {
+		return e.NewError(e.ErrTaxClassAlreadyExists, "tax class name already exists", err)
+	}
+	if errors.Is(err, internal.ErrTaxClassInUse) {
+		return e.NewError(e.ErrTaxClassInUse, "tax class is used by categories or brands, move them to another class first", err)
+	}
+	return e.NewError(code, msg, err)
+}
+
+func taxClassResponse(class *internal.TaxClass) *dto.TaxClassResponse {
+	resp := &dto.TaxClassResponse{
+		TaxClassID:       class.ID,
+		Name:             class.Name,
+		Description:      class.Description,
+		PricesIncludeTax: class.PricesIncludeTax,
+		Rules:            make([]dto.TaxRuleResponse, 0, len(class.Rules)),
+		CreatedAt:        class.CreatedAt,
+		UpdatedAt:        class.UpdatedAt,
+	}
+	for _, rule := range class.Rules {
+		resp.Rules = append(resp.Rules, dto.TaxRuleResponse{State: rule.State, Rate: rule.Rate})
+	}
+	return resp
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"e-cart/app/helper"
+	"e-cart/app/internal"
+	"e-cart/pkg/e"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func taxClassRequest(method string, taxClassID int64, body string) *http.Request {
+	req := httptest.NewRequest(method, "/admin/tax/classes", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	if taxClassID > 0 {
+		rctx.URLParams.Add("taxclassid", fmt.Sprint(taxClassID))
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestTaxChargedByDestinationState(t *testing.T) {
+	env := newOrderTestEnv(t)
+	taxes := NewTaxService(internal.NewTaxRepo(env.db))
+	products := NewProductService(internal.NewProductRepo(env.db), helper.NewContextHelper())
+	addresses := NewAddressService(internal.NewAddressRepo(env.db), helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+
+	_, err := taxes.CreateTaxClass(taxClassRequest(http.MethodPost, 0, `{"name": "GST 18", "rules": [{"rate": 18}, {"rate": 12}]}`))
+	assertErrorCode(t, e.ErrValidateRequest, err)
+	class, err := taxes.CreateTaxClass(taxClassRequest(http.MethodPost, 0,
+		`{"name": "GST 18", "rules": [{"state": "Kerala", "rate": 18}, {"rate": 12}]}`))
+	require.NoError(t, err)
+	assert.False(t, class.PricesIncludeTax)
+
+	_, err = products.UpdateCategory(orderRequest(http.MethodPut, 1, brand.CategoryID, `{"tax_class_id": 999}`))
+	assertErrorCode(t, e.ErrTaxClassNotFound, err)
+	category, err := products.UpdateCategory(orderRequest(http.MethodPut, 1, brand.CategoryID, fmt.Sprintf(`{"tax_class_id": %d}`, class.TaxClassID)))
+	require.NoError(t, err)
+	require.NotNil(t, category.TaxClassID)
+	err = taxes.DeleteTaxClass(taxClassRequest(http.MethodDelete, class.TaxClassID, ""))
+	assertErrorCode(t, e.ErrTaxClassInUse, err)
+
+	// the profile has no state, the cart shows the rule without one and cannot be ordered until an
+	// address with a state is saved
+	createTestCartLine(t, env.db, userID, brand, 2)
+	cart, err := env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 24, cart.Tax, 0.001)
+	assert.InDelta(t, 224, cart.Total, 0.001)
+	assert.True(t, cart.StateRequired)
+	_, err = env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	assertErrorCode(t, e.ErrShippingStateRequired, err)
+
+	_, err = addresses.SaveAddress(addressRequest(userID, 0, homeAddress))
+	require.NoError(t, err)
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 200, order.Subtotal, 0.001)
+	assert.InDelta(t, 36, order.Tax, 0.001)
+	assert.InDelta(t, 236, order.TotalPrice, 0.001)
+	require.Len(t, order.Items, 1)
+	assert.Equal(t, 18.0, order.Items[0].TaxRate)
+	assert.InDelta(t, 36, order.Items[0].TaxAmount, 0.001)
+
+	var stored internal.OrderItem
+	require.NoError(t, env.db.First(&stored, order.Items[0].OrderItemID).Error)
+	assert.InDelta(t, 36, stored.TaxAmount, 0.001)
+	assert.False(t, stored.TaxInclusive)
+	assert.InDelta(t, 118, stored.RefundAmount(1), 0.001, "the added tax is refunded with the item")
+}
+
+func TestTaxInclusivePrices(t *testing.T) {
+	env := newOrderTestEnv(t)
+	taxes := NewTaxService(internal.NewTaxRepo(env.db))
+	products := NewProductService(internal.NewProductRepo(env.db), helper.NewContextHelper())
+	brand := createTestBrand(t, env.db, 10)
+	userID := createTestUser(t, env.db, "buyer")
+
+	class, err := taxes.CreateTaxClass(taxClassRequest(http.MethodPost, 0,
+		`{"name": "GST 5", "prices_include_tax": true, "rules": [{"rate": 5}]}`))
+	require.NoError(t, err)
+	updated, err := products.UpdateBrand(orderRequest(http.MethodPut, 1, brand.ID, fmt.Sprintf(`{"tax_class_id": %d}`, class.TaxClassID)))
+	require.NoError(t, err)
+	require.NotNil(t, updated.TaxClassID)
+
+	createTestCartLine(t, env.db, userID, brand, 1)
+	// the rate is the same in every state, the profile address will do
+	cart, err := env.users.ViewUserCart(couponRequest(userID, ""))
+	require.NoError(t, err)
+	assert.False(t, cart.StateRequired)
+	order, err := env.users.PlaceOrder(placeOrderRequest(userID, ""))
+	require.NoError(t, err)
+	assert.InDelta(t, 4.76, order.Tax, 0.001)
+	assert.InDelta(t, 100, order.TotalPrice, 0.001, "the tax is part of the price")
+	assert.True(t, order.Items[0].TaxInclusive)
+
+	// rates changed later do not touch placed orders
+	_, err = taxes.UpdateTaxClass(taxClassRequest(http.MethodPut, class.TaxClassID,
+		`{"name": "GST 12", "prices_include_tax": true, "rules": [{"rate": 12}]}`))
+	require.NoError(t, err)
+	var stored internal.Order
+	require.NoError(t, env.db.First(&stored, order.OrderID).Error)
+	assert.InDelta(t, 4.76, stored.Tax, 0.001)
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"e-cart/app/internal"
+	"e-cart/pkg/carrier"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RefreshShipmentTracking asks the carrier about the parcels that are still moving every interval until
+// the context is done, the orders move on with their shipments
+func RefreshShipmentTracking(ctx context.Context, shipmentRepo internal.ShipmentRepo, shippingCarrier carrier.Carrier, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refreshed, err := refreshOpenShipments(ctx, shipmentRepo, shippingCarrier)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to refresh the shipment tracking")
+				continue
+			}
+			if refreshed > 0 {
+				log.Info().Msgf("Refreshed the tracking of %d orders", refreshed)
+			}
+		}
+	}
+}
+
+// refreshOpenShipments refreshes the tracking of every order with parcels of the carrier that are still
+// moving and returns how many orders got new tracking
+func refreshOpenShipments(ctx context.Context, shipmentRepo internal.ShipmentRepo, shippingCarrier carrier.Carrier) (int, error) {
+	orderIDs, err := shipmentRepo.OpenShipmentOrders(shippingCarrier.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, orderID := range orderIDs {
+		if ctx.Err() != nil {
+			return refreshed, ctx.Err()
+		}
+		if refreshOrderTracking(ctx, shipmentRepo, shippingCarrier, orderID) {
+			refreshed++
+		}
+	}
+	return refreshed, nil
+}
+
+// refreshOrderTracking saves the tracking of the parcels of the order that are still moving and moves the
+// order on with them. A carrier that does not answer is not an error, the last known tracking stays
+func refreshOrderTracking(ctx context.Context, shipmentRepo internal.ShipmentRepo, shippingCarrier carrier.Carrier, orderID int64) bool {
+	shipments, err := shipmentRepo.ListShipments(orderID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to get the shipments of order %d", orderID)
+		return false
+	}
+
+	tracked := make(map[int64][]internal.ShipmentEvent)
+	for _, shipment := range shipments {
+		if shipment.IsFinal() || shipment.Carrier != shippingCarrier.Name() {
+			continue
+		}
+		events, err := shippingCarrier.Track(ctx, shipment.TrackingNumber)
+		if err != nil {
+			log.Warn().Err(err).Msgf("failed to track shipment %d (%s)", shipment.ID, shipment.TrackingNumber)
+			continue
+		}
+		tracked[shipment.ID] = shipmentEvents(events)
+	}
+	if len(tracked) == 0 {
+		return false
+	}
+
+	err = shipmentRepo.Transaction(func(txRepo internal.ShipmentRepo) error {
+		order, err := txRepo.LockOrder(orderID)
+		if err != nil {
+			return err
+		}
+		for shipmentID, events := range tracked {
+			shipment, err := txRepo.LockShipment(shipmentID)
+			if err != nil {
+				return err
+			}
+			if shipment.IsFinal() {
+				continue
+			}
+			if err := txRepo.SaveTracking(shipment, events); err != nil {
+				return err
+			}
+		}
+		return syncOrderStatus(txRepo, order, 0, internal.ActorRoleSystem)
+	})
+	if err != nil {
+		log.Warn().Err(err).Msgf("failed to save the tracking of order %d", orderID)
+		return false
+	}
+	return true
+}
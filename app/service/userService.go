@@ -7,11 +7,15 @@ import (
 	"e-cart/app/internal"
 	"e-cart/pkg/e"
 	"e-cart/pkg/jwt"
+	"e-cart/pkg/notifier"
 	hash "e-cart/pkg/utils"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -22,12 +26,19 @@ import (
 type UserService interface {
 	SaveUserDetails(r *http.Request) (*dto.SaveUserResponse, error)
 	LoginUser(r *http.Request) (*dto.LoginResponse, error)
+	RefreshToken(r *http.Request) (*dto.LoginResponse, error)
+	Logout(r *http.Request) error
+	LogoutAll(r *http.Request) error
 	UpdateUserDetails(r *http.Request) error
 	GetUserDetails(r *http.Request) (*dto.GetUserDetailsResponse, error)
 	ChangePassword(r *http.Request) error
-	ViewUserCart(r *http.Request) ([]*dto.ViewCart, error)
+	ViewUserCart(r *http.Request) (*dto.ViewCartResponse, error)
+	ApplyCoupon(r *http.Request) (*dto.ViewCartResponse, error)
+	RemoveCoupon(r *http.Request) (*dto.ViewCartResponse, error)
 	ClearCart(r *http.Request) error
 	AddItemToCart(r *http.Request) (*dto.CartItemResponse, error)
+	UpdateCartItem(r *http.Request) (*dto.CartItemResponse, error)
+	RemoveCartItem(r *http.Request) error
 	PlaceOrder(r *http.Request) (*dto.ItemOrderedResponse, error)
 	OrderHistory(r *http.Request) ([]*dto.ItemOrderedResponse, error)
 	AddItemsToFavourites(r *http.Request) error
@@ -38,13 +49,15 @@ type userServiceImpl struct {
 	userRepo      internal.UserRepo
 	contextHelper helper.ContextHelper
 	bcryptPackage hash.BcryptPackage
+	stockNotifier notifier.Notifier
 }
 
-func NewUserService(userRepo internal.UserRepo, ctxHelper helper.ContextHelper, hashPassword hash.BcryptPackage) UserService {
+func NewUserService(userRepo internal.UserRepo, ctxHelper helper.ContextHelper, hashPassword hash.BcryptPackage, stockNotifier notifier.Notifier) UserService {
 	return &userServiceImpl{
 		userRepo:      userRepo,
 		contextHelper: ctxHelper,
 		bcryptPackage: hashPassword,
+		stockNotifier: stockNotifier,
 	}
 }
 
@@ -310,6 +323,28 @@ func (s *userServiceImpl) LoginUser(r *http.Request) (*dto.LoginResponse, error)
 		return nil, e.NewError(e.ErrUserBlocked, "user is blocked", err)
 	}
 
+	// Starting a new refresh token family for this login
+	familyID, err := jwt.GenerateTokenFamilyID()
+	if err != nil {
+		return nil, e.NewError(e.ErrGenerateToken, "failed to generate refresh token", err)
+	}
+
+	refreshToken, refreshExpiry, err := jwt.GenerateRefreshToken()
+	if err != nil {
+		return nil, e.NewError(e.ErrGenerateToken, "failed to generate refresh token", err)
+	}
+
+	err = s.userRepo.SaveRefreshToken(user.ID, familyID, jwt.HashRefreshToken(refreshToken), refreshExpiry)
+	if err != nil {
+		return nil, e.NewError(e.ErrGenerateToken, "failed to store refresh token", err)
+	}
+	log.Info().Msg("Generated refresh token saved successfully")
+
+	return s.issueAccessToken(user, refreshToken)
+}
+
+// issueAccessToken generates the JWT access token of the user and saves it as the active token
+func (s *userServiceImpl) issueAccessToken(user *internal.Userdetail, refreshToken string) (*dto.LoginResponse, error) {
 	// Generating JWT Token with isAdmin from database
 	token, expiry, err := jwt.GenerateToken(user.ID, user.Username, user.IsAdmin)
 	if err != nil {
@@ -320,15 +355,158 @@ func (s *userServiceImpl) LoginUser(r *http.Request) (*dto.LoginResponse, error)
 	// Saving generated token details on table
 	err = s.userRepo.SaveToken(user.ID, token, expiry)
 	if err != nil {
-		return nil, e.NewError(e.ErrAddToFavorites, "failed to store login token", err)
+		return nil, e.NewError(e.ErrGenerateToken, "failed to store login token", err)
 	}
 	log.Info().Msg("Generated token saved successfully")
 
 	return &dto.LoginResponse{
-		Token: token,
+		Token:        token,
+		ExpiresAt:    expiry,
+		RefreshToken: refreshToken,
 	}, nil
 }
 
+// RefreshToken rotates the refresh token and issues a new access token.
+// Using an already rotated refresh token revokes the whole token family
+func (s *userServiceImpl) RefreshToken(r *http.Request) (*dto.LoginResponse, error) {
+	args := &dto.RefreshTokenRequest{}
+
+	err := args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error while validating", err)
+	}
+	log.Info().Msg("Successfully completed parsing and validation of request body")
+
+	current, err := s.userRepo.GetRefreshToken(jwt.HashRefreshToken(args.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrInvalidRefreshToken, "invalid refresh token", err)
+		}
+		return nil, e.NewError(e.ErrRefreshToken, "error while getting refresh token", err)
+	}
+
+	if current.RevokedAt != nil {
+		return nil, s.revokeReusedTokenFamily(current)
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		return nil, e.NewError(e.ErrInvalidRefreshToken, "refresh token expired, please log in again", nil)
+	}
+
+	user, err := s.userRepo.GetUserByID(current.UserID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetUserDetails, "error while fetching user details", err)
+	}
+
+	if !user.Status {
+		err := fmt.Errorf("user %s is blocked", user.Username)
+		return nil, e.NewError(e.ErrUserBlocked, "user is blocked", err)
+	}
+
+	refreshToken, refreshExpiry, err := jwt.GenerateRefreshToken()
+	if err != nil {
+		return nil, e.NewError(e.ErrGenerateToken, "failed to generate refresh token", err)
+	}
+
+	err = s.userRepo.RotateRefreshToken(current, jwt.HashRefreshToken(refreshToken), refreshExpiry)
+	if err != nil {
+		if errors.Is(err, internal.ErrRefreshTokenReused) {
+			return nil, s.revokeReusedTokenFamily(current)
+		}
+		return nil, e.NewError(e.ErrRefreshToken, "failed to rotate refresh token", err)
+	}
+	log.Info().Msgf("Rotated refresh token of user %d", user.ID)
+
+	return s.issueAccessToken(user, refreshToken)
+}
+
+// revokeReusedTokenFamily signs out the token family when a rotated refresh token is replayed,
+// as either the client or an attacker holds a stolen copy
+func (s *userServiceImpl) revokeReusedTokenFamily(reused *internal.RefreshToken) error {
+	log.Warn().Msgf("Reuse of rotated refresh token detected for user %d, revoking token family", reused.UserID)
+
+	err := s.userRepo.RevokeRefreshTokenFamily(reused.FamilyID)
+	if err != nil {
+		return e.NewError(e.ErrRevokeToken, "failed to revoke refresh token family", err)
+	}
+
+	err = s.userRepo.DeleteAllTokens(reused.UserID)
+	if err != nil {
+		return e.NewError(e.ErrRevokeToken, "failed to revoke login tokens", err)
+	}
+
+	return e.NewError(e.ErrRefreshTokenReused, "refresh token already used, please log in again", nil)
+}
+
+// Logout revokes the token used for the current request
+func (s *userServiceImpl) Logout(r *http.Request) error {
+	userID, err := s.contextHelper.GetUserID(r.Context())
+	if err != nil {
+		return e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	token, err := s.contextHelper.GetToken(r.Context())
+	if err != nil {
+		return e.NewError(e.ErrContextError, "error while getting token from ctx", err)
+	}
+
+	args := &dto.LogoutRequest{}
+	err = args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	// the refresh token of this session is revoked as well when the client sends it, it is checked
+	// first so that a bad one leaves the session as it is
+	var refreshToken *internal.RefreshToken
+	if args.RefreshToken != "" {
+		refreshToken, err = s.userRepo.GetRefreshToken(jwt.HashRefreshToken(args.RefreshToken))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.NewError(e.ErrRefreshToken, "error while getting refresh token", err)
+		}
+		if err != nil || refreshToken.UserID != userID {
+			return e.NewError(e.ErrInvalidRefreshToken, "invalid refresh token", err)
+		}
+	}
+
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		if err := txRepo.DeleteToken(userID, token); err != nil {
+			return err
+		}
+		if refreshToken != nil {
+			return txRepo.RevokeRefreshTokenFamily(refreshToken.FamilyID)
+		}
+		return nil
+	})
+	if err != nil {
+		return e.NewError(e.ErrLogoutUser, "failed to logout user", err)
+	}
+	log.Info().Msgf("User %d logged out", userID)
+
+	return nil
+}
+
+// LogoutAll revokes every token of the user, signing them out from all devices
+func (s *userServiceImpl) LogoutAll(r *http.Request) error {
+	userID, err := s.contextHelper.GetUserID(r.Context())
+	if err != nil {
+		return e.NewError(e.ErrContextError, "error while getting userId from ctx", err)
+	}
+
+	err = s.userRepo.DeleteAllTokens(userID)
+	if err != nil {
+		return e.NewError(e.ErrLogoutUser, "failed to logout user from all devices", err)
+	}
+	log.Info().Msgf("User %d logged out from all devices", userID)
+
+	return nil
+}
+
 func (s *userServiceImpl) UpdateUserDetails(r *http.Request) error {
 	args := &dto.UpdateUserDetailRequest{}
 
@@ -399,20 +577,40 @@ func (s *userServiceImpl) AddItemToCart(r *http.Request) (*dto.CartItemResponse,
 	}
 	log.Info().Msgf("successfully got product details of brand name %s price %f stock %d", prodDetails.BrandName, prodDetails.Price, prodDetails.StockCount)
 
-	// Check if the requested quantity exceeds available stock
-	if args.Quantity > prodDetails.StockCount {
-		log.Info().Msgf("Only %d units of %s are available, but you requested %d", prodDetails.StockCount, prodDetails.BrandName, args.Quantity)
-		return nil, e.NewError(e.ErrInsufficientStock, "insufficient stock available", errors.New("stock insufficient"))
+	// Quantity already in the cart counts towards the stock as well
+	existingCart, err := s.userRepo.CheckProductInCart(userID, prodDetails.ID)
+	if err != nil {
+		return nil, e.NewError(e.ErrGetCartDetails, "error while checking the cart", err)
+	}
+	requestedQuantity := args.Quantity
+	if existingCart != nil {
+		requestedQuantity += existingCart.Quantity
 	}
-	log.Info().Msg("Requested quantity is available")
 
 	totalAmount := prodDetails.Price * float64(args.Quantity)
 	log.Info().Msgf("totalAmount is %v :", totalAmount)
 
-	// checking product already exist in cart, if not adding those items
-	err = s.userRepo.AddOrUpdateCart(userID, prodDetails, args.Quantity, totalAmount)
+	// The whole line quantity is reserved together with the cart update, so the stock
+	// held for other carts cannot be added
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		err := txRepo.ReserveStock(userID, prodDetails.ID, requestedQuantity)
+		if err != nil {
+			if errors.Is(err, internal.ErrInsufficientStock) {
+				log.Info().Msgf("%d units of %s are not available, stock %d", requestedQuantity, prodDetails.BrandName, prodDetails.StockCount)
+				return e.NewError(e.ErrInsufficientStock, "insufficient stock available", err)
+			}
+			return e.NewError(e.ErrReserveStock, "error while reserving stock", err)
+		}
+
+		// checking product already exist in cart, if not adding those items
+		err = txRepo.AddOrUpdateCart(userID, prodDetails, args.Quantity, totalAmount)
+		if err != nil {
+			return e.NewError(e.ErrAddToCart, "error while adding items to the cart", err)
+		}
+		return txRepo.ExtendReservations(userID)
+	})
 	if err != nil {
-		return nil, e.NewError(e.ErrAddToCart, "error while adding items to the cart", err)
+		return nil, cartTransactionError(err)
 	}
 	log.Info().Msg("Successfully added items to the cart")
 
@@ -430,39 +628,362 @@ func (s *userServiceImpl) AddItemToCart(r *http.Request) (*dto.CartItemResponse,
 		Quantity:   cartData.Quantity,
 		Price:      cartData.Price,
 		BrandName:  cartData.Brand.BrandName,
-		TotalPrice: totalAmount,
+		TotalPrice: cartData.TotalAmount,
 	}
 	log.Info().Msgf("Cart Item Response: %+v", cartItemResponse)
 
 	return &cartItemResponse, nil
 }
 
-func (s *userServiceImpl) ViewUserCart(r *http.Request) ([]*dto.ViewCart, error) {
+// UpdateCartItem sets the quantity of a product already in the cart
+func (s *userServiceImpl) UpdateCartItem(r *http.Request) (*dto.CartItemResponse, error) {
+	args := &dto.UpdateCartItemRequest{}
+
+	userID, err := s.getUserIDAndCheckStatus(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	err = args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+	log.Info().Msg("Successfully completed parsing and validation of request body")
+
+	cartData, err := s.userRepo.GetCartWithProductDetails(userID, args.ProductID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrCartNotFound, "product not found in the cart", err)
+		}
+		return nil, e.NewError(e.ErrGetCartDetails, "error while retrieving cart with product details", err)
+	}
+	if !cartData.Brand.IsAvailable() {
+		return nil, e.NewError(e.ErrProductNotFound, "product is no longer available", fmt.Errorf("product %d is deleted", args.ProductID))
+	}
+
+	var updatedCart *internal.Cart
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		err := txRepo.ReserveStock(userID, args.ProductID, args.Quantity)
+		if err != nil {
+			if errors.Is(err, internal.ErrInsufficientStock) {
+				log.Info().Msgf("%d units of %s are not available, stock %d", args.Quantity, cartData.Brand.BrandName, cartData.Brand.StockCount)
+				return e.NewError(e.ErrInsufficientStock, "insufficient stock available", err)
+			}
+			return e.NewError(e.ErrReserveStock, "error while reserving stock", err)
+		}
+
+		updatedCart, err = txRepo.UpdateCartItemQuantity(userID, args.ProductID, args.Quantity)
+		if err != nil {
+			return e.NewError(e.ErrUpdateCart, "error while updating the cart item", err)
+		}
+		return txRepo.ExtendReservations(userID)
+	})
+	if err != nil {
+		return nil, cartTransactionError(err)
+	}
+	log.Info().Msgf("Updated quantity of product %d in the cart to %d", args.ProductID, args.Quantity)
+
+	return &dto.CartItemResponse{
+		UserID:     updatedCart.UserID,
+		ProductID:  updatedCart.ProductID,
+		Quantity:   updatedCart.Quantity,
+		Price:      updatedCart.Price,
+		BrandName:  cartData.Brand.BrandName,
+		TotalPrice: updatedCart.TotalAmount,
+	}, nil
+}
+
+// RemoveCartItem removes a single product from the cart
+func (s *userServiceImpl) RemoveCartItem(r *http.Request) error {
+	args := &dto.RemoveCartItemRequest{}
+
+	userID, err := s.getUserIDAndCheckStatus(r.Context())
+	if err != nil {
+		return err
+	}
+
+	err = args.Parse(r)
+	if err != nil {
+		return e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		err := txRepo.RemoveCartItem(userID, args.ProductID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return e.NewError(e.ErrCartNotFound, "product not found in the cart", err)
+			}
+			return e.NewError(e.ErrUpdateCart, "error while removing the cart item", err)
+		}
+
+		err = txRepo.ReleaseReservations(userID, []int64{args.ProductID}, "removed from cart")
+		if err != nil {
+			return e.NewError(e.ErrReserveStock, "error while releasing reserved stock", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var wrapErr *e.WrapError
+		if errors.As(err, &wrapErr) {
+			return wrapErr
+		}
+		return e.NewError(e.ErrTransactionError, "failed to remove the cart item", err)
+	}
+	log.Info().Msgf("Removed product %d from the cart", args.ProductID)
+
+	return nil
+}
+
+func (s *userServiceImpl) ViewUserCart(r *http.Request) (*dto.ViewCartResponse, error) {
+	userID, err := s.getUserIDAndCheckStatus(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	cartDetails, err := s.userRepo.ViewCart(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "not able to see the cart associated with the user", err)
+	}
+
+	// Looking at the cart keeps its reservations alive
+	err = s.userRepo.ExtendReservations(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrReserveStock, "error while extending reserved stock", err)
+	}
+
+	coupon, err := s.userRepo.GetCartCoupon(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "error while getting the coupon of the cart", err)
+	}
+
+	resp, err := s.cartResponse(userID, cartDetails, coupon)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "error while pricing the coupon of the cart", err)
+	}
+	return resp, nil
+}
+
+// ApplyCoupon applies a coupon code to the cart, it has to apply to the cart as it is now
+func (s *userServiceImpl) ApplyCoupon(r *http.Request) (*dto.ViewCartResponse, error) {
 	userID, err := s.getUserIDAndCheckStatus(r.Context())
 	if err != nil {
 		return nil, err
 	}
 
+	args := &dto.ApplyCouponRequest{}
+
+	err = args.Parse(r)
+	if err != nil {
+		return nil, e.NewError(e.ErrDecodeRequestBody, "error while parsing", err)
+	}
+
+	err = args.Validate()
+	if err != nil {
+		return nil, e.NewError(e.ErrValidateRequest, "error validating the req.body", err)
+	}
+
+	coupon, err := s.userRepo.GetCouponByCode(args.Code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrCouponNotFound, "coupon not found", err)
+		}
+		return nil, e.NewError(e.ErrApplyCoupon, "error while getting the coupon", err)
+	}
+
 	cartDetails, err := s.userRepo.ViewCart(userID)
 	if err != nil {
 		return nil, e.NewError(e.ErrViewCart, "not able to see the cart associated with the user", err)
 	}
 
-	var cartlists []*dto.ViewCart
+	_, err = s.priceCoupon(userID, coupon, cartDetails)
+	if err != nil {
+		if errors.Is(err, internal.ErrCouponNotApplicable) {
+			return nil, e.NewError(e.ErrCouponNotApplicable, "coupon does not apply to the cart", err)
+		}
+		return nil, e.NewError(e.ErrApplyCoupon, "error while pricing the coupon", err)
+	}
+
+	err = s.userRepo.SetCartCoupon(userID, coupon.ID)
+	if err != nil {
+		return nil, e.NewError(e.ErrApplyCoupon, "error while applying the coupon", err)
+	}
+	log.Info().Msgf("User %d applied coupon %s to the cart", userID, coupon.Code)
+
+	resp, err := s.cartResponse(userID, cartDetails, coupon)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "error while pricing the coupon of the cart", err)
+	}
+	return resp, nil
+}
+
+// RemoveCoupon takes the coupon off the cart
+func (s *userServiceImpl) RemoveCoupon(r *http.Request) (*dto.ViewCartResponse, error) {
+	userID, err := s.getUserIDAndCheckStatus(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepo.RemoveCartCoupon(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrApplyCoupon, "error while removing the coupon", err)
+	}
+	log.Info().Msgf("User %d removed the coupon of the cart", userID)
 
+	cartDetails, err := s.userRepo.ViewCart(userID)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "not able to see the cart associated with the user", err)
+	}
+
+	resp, err := s.cartResponse(userID, cartDetails, nil)
+	if err != nil {
+		return nil, e.NewError(e.ErrViewCart, "error while pricing the cart", err)
+	}
+	return resp, nil
+}
+
+// priceCoupon works out the discount of the coupon on the cart lines for the user
+func (s *userServiceImpl) priceCoupon(userID int64, coupon *internal.Coupon, lines []internal.Cart) (*internal.CouponDiscount, error) {
+	usedByUser, err := s.userRepo.CountCouponRedemptions(coupon.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return coupon.Price(lines, usedByUser, time.Now())
+}
+
+// cartResponse lists the cart lines with the discount of the coupon, a coupon that does not apply
+// to the cart is shown with the reason and takes nothing off
+func (s *userServiceImpl) cartResponse(userID int64, cartDetails []internal.Cart, coupon *internal.Coupon) (*dto.ViewCartResponse, error) {
+	resp := &dto.ViewCartResponse{Items: make([]*dto.ViewCart, 0, len(cartDetails))}
+
+	var discount *internal.CouponDiscount
+	if coupon != nil {
+		var err error
+		discount, err = s.priceCoupon(userID, coupon, cartDetails)
+		if err != nil && !errors.Is(err, internal.ErrCouponNotApplicable) {
+			return nil, err
+		}
+
+		resp.Coupon = &dto.CartCouponResponse{
+			Code:        coupon.Code,
+			Type:        coupon.Type,
+			Description: coupon.Description,
+			Applicable:  err == nil,
+		}
+		if err != nil {
+			resp.Coupon.Reason = err.Error()
+		} else {
+			resp.Coupon.Discount = discount.Discount
+			resp.Coupon.FreeShipping = discount.FreeShipping
+			resp.Discount = discount.Discount
+		}
+	}
+
+	// Price is what the item cost when it was added, totals use the current price
 	for _, carts := range cartDetails {
 		list := dto.ViewCart{
-			ProductID:   carts.ProductID,
-			BrandName:   carts.Brand.BrandName,
-			Quantity:    carts.Quantity,
-			Price:       carts.Price,
-			TotalAmount: carts.Price * float64(carts.Quantity),
+			ProductID:      carts.ProductID,
+			BrandName:      carts.Brand.BrandName,
+			Quantity:       carts.Quantity,
+			Price:          carts.Price,
+			CurrentPrice:   carts.Brand.Price,
+			PriceChanged:   !sameAmount(carts.Price, carts.Brand.Price),
+			AvailableStock: carts.Brand.StockCount,
+			StockChanged:   carts.Quantity > carts.Brand.StockCount,
+			Available:      carts.Brand.IsAvailable(),
+			TotalAmount:    carts.Brand.Price * float64(carts.Quantity),
+		}
+		if discount != nil {
+			list.Discount = discount.Lines[carts.ProductID]
 		}
+		resp.Subtotal += list.TotalAmount
+
+		resp.Items = append(resp.Items, &list)
+	}
+
+	destination, err := s.cartDestination(userID)
+	if err != nil {
+		return nil, err
+	}
+	tax, err := s.computeTax(cartDetails, discount, destination.State)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range resp.Items {
+		item.TaxRate = tax.Lines[item.ProductID].Rate
+		item.TaxAmount = tax.Lines[item.ProductID].Amount
+	}
+	resp.Tax = tax.Tax
+	resp.StateRequired = tax.StateMissing
+
+	quote, err := s.quoteShipping(destination.Pincode, cartDetails, resp.Subtotal-resp.Discount, discount != nil && discount.FreeShipping)
+	if err != nil && !errors.Is(err, internal.ErrPincodeNotServiceable) {
+		return nil, err
+	}
+	resp.Shipping = shippingQuoteResponse(destination.Pincode, quote, err)
+	resp.ShippingCharge = resp.Shipping.Charge
+	resp.Total = resp.Subtotal - resp.Discount + tax.Added + resp.ShippingCharge
+
+	return resp, nil
+}
+
+// cartDestination is where the cart ships to, the default shipping address or the profile address without one.
+// The profile address has no state, the cart then shows the tax at the rates for every other state
+func (s *userServiceImpl) cartDestination(userID int64) (*internal.AddressSnapshot, error) {
+	shipping, _, err := s.userRepo.GetDefaultAddresses(userID)
+	if err != nil {
+		return nil, err
+	}
+	if shipping != nil {
+		destination := shipping.Snapshot()
+		return &destination, nil
+	}
+	user, err := s.userRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	destination := user.ProfileAddress()
+	return &destination, nil
+}
 
-		cartlists = append(cartlists, &list)
+// computeTax works out the tax of the cart lines shipped to the state, on the lines less their coupon discount
+func (s *userServiceImpl) computeTax(lines []internal.Cart, discount *internal.CouponDiscount, state string) (*internal.OrderTax, error) {
+	classes, err := s.userRepo.GetTaxClasses()
+	if err != nil {
+		return nil, err
+	}
+
+	var lineDiscounts map[int64]float64
+	if discount != nil {
+		lineDiscounts = discount.Lines
 	}
+	return internal.ComputeTax(classes, lines, lineDiscounts, state), nil
+}
 
-	return cartlists, nil
+// quoteShipping prices the shipping of the cart lines to the pincode, a free shipping coupon waives the charge
+func (s *userServiceImpl) quoteShipping(pincode int64, lines []internal.Cart, orderValue float64, freeShipping bool) (*internal.ShippingQuote, error) {
+	zones, err := s.userRepo.GetActiveShippingZones()
+	if err != nil {
+		return nil, err
+	}
+
+	var weightGrams int64
+	for _, line := range lines {
+		weightGrams += line.Brand.WeightGrams * line.Quantity
+	}
+	quote, err := internal.QuoteShipping(zones, pincode, orderValue, weightGrams, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if freeShipping {
+		quote.Charge = 0
+		quote.Free = true
+	}
+	return quote, nil
 }
 
 func (s *userServiceImpl) ClearCart(r *http.Request) error {
@@ -471,9 +992,24 @@ func (s *userServiceImpl) ClearCart(r *http.Request) error {
 		return err
 	}
 
-	err = s.userRepo.ClearCart(userID)
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		err := txRepo.ClearCart(userID)
+		if err != nil {
+			return e.NewError(e.ErrClearCart, "failed to clear cart", err)
+		}
+
+		err = txRepo.ReleaseReservations(userID, nil, "cart cleared")
+		if err != nil {
+			return e.NewError(e.ErrReserveStock, "error while releasing reserved stock", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return e.NewError(e.ErrClearCart, "failed to clear cart", err)
+		var wrapErr *e.WrapError
+		if errors.As(err, &wrapErr) {
+			return wrapErr
+		}
+		return e.NewError(e.ErrTransactionError, "failed to clear cart", err)
 	}
 	log.Info().Msg("Cart cleared successfully")
 
@@ -501,7 +1037,7 @@ func (s *userServiceImpl) PlaceOrder(r *http.Request) (*dto.ItemOrderedResponse,
 	log.Info().Msg("Successfully completed parsing and validation of request body")
 
 	// Fetch cart items
-	cartItems, err := s.userRepo.FetchCartItems(userID, args.CartID)
+	cartItems, err := s.userRepo.FetchCartItems(userID, args.ProductIDs)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, e.NewError(e.ErrCartNotFound, "cart not found", err)
@@ -509,19 +1045,45 @@ func (s *userServiceImpl) PlaceOrder(r *http.Request) (*dto.ItemOrderedResponse,
 		return nil, e.NewError(e.ErrGetCartDetails, "error while fetching cart details", err)
 	}
 
-	// Calculate total amount
+	// Calculate total amount with the current prices
 	var totalAmount float64
-	for _, item := range cartItems {
-		totalAmount += item.Price * float64(item.Quantity)
+	var changedLines []string
+	cartIDs := make([]int64, 0, len(cartItems))
+	productIDs := make([]int64, 0, len(cartItems))
+	for i, item := range cartItems {
+		if !item.Brand.IsAvailable() {
+			return nil, e.NewError(e.ErrProductNotFound, "product is no longer available, remove it from the cart",
+				fmt.Errorf("product %d (%s) is deleted", item.ProductID, item.Brand.BrandName))
+		}
+		if !sameAmount(item.Price, item.Brand.Price) {
+			changedLines = append(changedLines, fmt.Sprintf("product %d (%s) price changed from %.2f to %.2f",
+				item.ProductID, item.Brand.BrandName, item.Price, item.Brand.Price))
+		}
+		cartItems[i].Price = item.Brand.Price
+		cartItems[i].TotalAmount = item.Brand.Price * float64(item.Quantity)
+		totalAmount += cartItems[i].TotalAmount
+		cartIDs = append(cartIDs, item.ID)
+		productIDs = append(productIDs, item.ProductID)
 	}
-	log.Info().Msgf("totalAmount is %v :", totalAmount)
+	log.Info().Msgf("Placing order for %d cart lines", len(cartItems))
+	subtotal := totalAmount
 
-	// Create order and items in a single transaction
-	newOrder, orderItems, err := s.userRepo.CreateOrder(userID, totalAmount, cartItems)
+	// The coupon of the cart is priced on the lines being ordered, it has to apply to them
+	coupon, err := s.userRepo.GetCartCoupon(userID)
 	if err != nil {
-		return nil, e.NewError(e.ErrPlaceOrder, "error while creating order", err)
+		return nil, e.NewError(e.ErrGetCartDetails, "error while getting the coupon of the cart", err)
+	}
+	var discount *internal.CouponDiscount
+	if coupon != nil {
+		discount, err = s.priceCoupon(userID, coupon, cartItems)
+		if err != nil {
+			if errors.Is(err, internal.ErrCouponNotApplicable) {
+				return nil, e.NewError(e.ErrCouponNotApplicable, "coupon does not apply to the order, remove it from the cart", err)
+			}
+			return nil, e.NewError(e.ErrApplyCoupon, "error while pricing the coupon", err)
+		}
+		totalAmount -= discount.Discount
 	}
-	log.Info().Msgf("Order ID: %d, Total: %.2f, UserID: %d", newOrder.ID, newOrder.Total, newOrder.UserID)
 
 	// Get user details for response
 	user, err := s.userRepo.GetUserByID(userID)
@@ -529,46 +1091,213 @@ func (s *userServiceImpl) PlaceOrder(r *http.Request) (*dto.ItemOrderedResponse,
 		return nil, e.NewError(e.ErrGetUserDetails, "error while fetching user details", err)
 	}
 
-	// Update stock count
-	_, err = s.userRepo.UpdateStockCount(orderItems)
+	shipping, billing, err := s.orderAddresses(user, &args)
 	if err != nil {
-		return nil, e.NewError(e.ErrUpdateStock, "error while updating stock count", err)
+		return nil, err
 	}
 
-	// Update cart status
-	err = s.userRepo.UpdateCartOrderStatus(userID, newOrder.ID, args.CartID)
+	// Shipping is priced on the order value after the discount, the items have to be deliverable
+	quote, err := s.quoteShipping(shipping.Pincode, cartItems, totalAmount, discount != nil && discount.FreeShipping)
 	if err != nil {
-		return nil, e.NewError(e.ErrUpdateCart, "error while updating cart status", err)
+		if errors.Is(err, internal.ErrPincodeNotServiceable) {
+			return nil, e.NewError(e.ErrPincodeNotServiceable, "items cannot be delivered to the shipping address", err)
+		}
+		return nil, e.NewError(e.ErrQuoteShipping, "error while pricing the shipping", err)
 	}
-	log.Info().Msg("Successfully updated the cart status to false after order being placed")
+
+	// Tax goes by the state the order ships to, only tax that is not part of the prices adds to the total
+	tax, err := s.computeTax(cartItems, discount, shipping.State)
+	if err != nil {
+		return nil, e.NewError(e.ErrComputeTax, "error while computing the tax", err)
+	}
+	if tax.StateMissing {
+		return nil, e.NewError(e.ErrShippingStateRequired, "the tax of the cart depends on the state, ship to an address with a state",
+			fmt.Errorf("shipping address of user %d has no state", userID))
+	}
+	totalAmount += tax.Added + quote.Charge
+	log.Info().Msgf("totalAmount is %v :", totalAmount)
+
+	// Prices changed since the items were added, the client has to confirm the new total
+	if len(changedLines) > 0 && (args.ConfirmedTotal == nil || !sameAmount(*args.ConfirmedTotal, totalAmount)) {
+		log.Info().Msgf("Prices changed for %d cart lines, new total %.2f is not confirmed", len(changedLines), totalAmount)
+		return nil, e.NewError(e.ErrPriceChanged, "cart prices have changed, confirm the new total to place the order",
+			fmt.Errorf("%s; new total is %.2f", strings.Join(changedLines, "; "), totalAmount))
+	}
+
+	// Creating the order, decrementing the stock and updating the cart as one unit,
+	// if any step fails nothing is committed
+	var newOrder *internal.Order
+	var orderItems []internal.OrderItem
+	var soldBrands []internal.Brand
+	err = s.userRepo.Transaction(func(txRepo internal.UserRepo) error {
+		newOrder = &internal.Order{
+			UserID:              userID,
+			Total:               totalAmount,
+			Subtotal:            subtotal,
+			Tax:                 tax.Tax,
+			ShippingCharge:      quote.Charge,
+			EstimatedDeliveryAt: quote.EstimatedTo,
+			ShippingAddress:     shipping,
+			BillingAddress:      billing,
+		}
+		orderItems, err = txRepo.CreateOrder(newOrder, cartItems, tax)
+		if err != nil {
+			return e.NewError(e.ErrPlaceOrder, "error while creating order", err)
+		}
+		log.Info().Msgf("Order ID: %d, Total: %.2f, UserID: %d", newOrder.ID, newOrder.Total, newOrder.UserID)
+
+		if coupon != nil {
+			err = txRepo.RedeemCoupon(newOrder, orderItems, coupon.ID, discount)
+			if err != nil {
+				if errors.Is(err, internal.ErrCouponNotApplicable) {
+					return e.NewError(e.ErrCouponNotApplicable, "coupon does not apply to the order, remove it from the cart", err)
+				}
+				return e.NewError(e.ErrApplyCoupon, "error while redeeming the coupon", err)
+			}
+		}
+
+		// Update stock count
+		soldBrands, err = txRepo.UpdateStockCount(userID, orderItems)
+		if err != nil {
+			if errors.Is(err, internal.ErrInsufficientStock) {
+				return e.NewError(e.ErrInsufficientStock, "insufficient stock available", err)
+			}
+			return e.NewError(e.ErrUpdateStock, "error while updating stock count", err)
+		}
+
+		// Update cart status
+		err = txRepo.UpdateCartOrderStatus(userID, newOrder.ID, cartIDs)
+		if err != nil {
+			return e.NewError(e.ErrUpdateCart, "error while updating cart status", err)
+		}
+
+		// The ordered stock is sold, it is not held for the cart anymore
+		err = txRepo.ReleaseReservations(userID, productIDs, "ordered")
+		if err != nil {
+			return e.NewError(e.ErrReserveStock, "error while releasing reserved stock", err)
+		}
+		return nil
+	})
+	if err != nil {
+		var wrapErr *e.WrapError
+		if errors.As(err, &wrapErr) {
+			return nil, wrapErr
+		}
+		return nil, e.NewError(e.ErrTransactionError, "failed to commit the order", err)
+	}
+	log.Info().Msg("Successfully placed the order and updated the cart status to false")
+
+	// alerts go out only once the stock change is committed, without holding the response
+	go s.notifyLowStock(newOrder.ID, orderItems, soldBrands)
 
 	// Build response
 	itemOrderedResponse := dto.ItemOrderedResponse{
-		OrderID: newOrder.ID,
-		UserDetails: dto.UserDetailsResponse{
-			Username:    user.Username,
-			Address:     user.Address,
-			Pincode:     user.Pincode,
-			PhoneNumber: user.Phonenumber,
-			Email:       user.Mail,
-		},
-		TotalPrice: totalAmount,
-		Items:      make([]dto.OrderItemResponse, 0, len(orderItems)),
-	}
-
-	for _, item := range orderItems {
-		itemOrderedResponse.Items = append(itemOrderedResponse.Items, dto.OrderItemResponse{
-			ProductID:  item.ProductID,
-			Quantity:   item.Quantity,
-			CategoryID: item.Product.CategoryID,
-			BrandName:  item.Product.BrandName,
-			Price:      item.Price,
-		})
+		OrderID:             newOrder.ID,
+		Status:              newOrder.Status,
+		UserDetails:         orderUserDetails(newOrder, user),
+		ShippingAddress:     orderAddressResponse(newOrder.ShippingAddress),
+		BillingAddress:      orderAddressResponse(newOrder.BillingAddress),
+		Subtotal:            newOrder.Subtotal,
+		Discount:            newOrder.Discount,
+		Tax:                 newOrder.Tax,
+		TotalPrice:          totalAmount,
+		CouponCode:          newOrder.CouponCode,
+		FreeShipping:        newOrder.FreeShipping,
+		ShippingCharge:      newOrder.ShippingCharge,
+		EstimatedDeliveryAt: newOrder.EstimatedDeliveryAt,
+		NetTotal:            totalAmount,
+		Items:               orderItemResponses(orderItems),
 	}
 
 	return &itemOrderedResponse, nil
 }
 
+// orderAddresses picks the shipping and billing address of an order, the defaults of the address book are used
+// when none is asked for. Either one stands in for the other, the profile address for both when the book is empty
+func (s *userServiceImpl) orderAddresses(user *internal.Userdetail, args *dto.PlaceOrderFromCart) (internal.AddressSnapshot, internal.AddressSnapshot, error) {
+	var none internal.AddressSnapshot
+	defaultShipping, defaultBilling, err := s.userRepo.GetDefaultAddresses(user.ID)
+	if err != nil {
+		return none, none, e.NewError(e.ErrGetAddresses, "error while getting the addresses", err)
+	}
+
+	shipping, err := s.pickAddress(user.ID, args.ShippingAddressID, defaultShipping)
+	if err != nil {
+		return none, none, err
+	}
+	billing, err := s.pickAddress(user.ID, args.BillingAddressID, defaultBilling)
+	if err != nil {
+		return none, none, err
+	}
+
+	if shipping == nil {
+		shipping = billing
+	}
+	if billing == nil {
+		billing = shipping
+	}
+	if shipping == nil {
+		return user.ProfileAddress(), user.ProfileAddress(), nil
+	}
+	return shipping.Snapshot(), billing.Snapshot(), nil
+}
+
+func (s *userServiceImpl) pickAddress(userID int64, addressID *int64, fallback *internal.Address) (*internal.Address, error) {
+	if addressID == nil {
+		return fallback, nil
+	}
+	address, err := s.userRepo.GetUserAddress(userID, *addressID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, e.NewError(e.ErrAddressNotFound, "address not found", err)
+		}
+		return nil, e.NewError(e.ErrGetAddresses, "error while getting the address", err)
+	}
+	return address, nil
+}
+
+// notifyLowStock sends a low stock event for every sold brand the order took to or below its reorder threshold
+func (s *userServiceImpl) notifyLowStock(orderID int64, orderItems []internal.OrderItem, soldBrands []internal.Brand) {
+	for i, brand := range soldBrands {
+		previousStock := brand.StockCount + orderItems[i].Quantity
+		if !brand.IsLowStock() || previousStock <= brand.ReorderThreshold {
+			continue
+		}
+
+		event := notifier.Event{
+			Type:       notifier.EventLowStock,
+			OccurredAt: time.Now(),
+			Payload: dto.LowStockEvent{
+				BrandID:          brand.ID,
+				BrandName:        brand.BrandName,
+				Model:            brand.BrandModel,
+				PreviousStock:    previousStock,
+				StockCount:       brand.StockCount,
+				ReorderThreshold: brand.ReorderThreshold,
+				Status:           dto.StockStatus(brand.StockCount, brand.ReorderThreshold),
+				OrderID:          orderID,
+			},
+		}
+		if err := s.stockNotifier.Notify(context.Background(), event); err != nil {
+			log.Error().Err(err).Msgf("failed to send the low stock alert of brand %d", brand.ID)
+		}
+	}
+}
+
+// cartTransactionError keeps the error codes set inside a cart transaction
+func cartTransactionError(err error) error {
+	var wrapErr *e.WrapError
+	if errors.As(err, &wrapErr) {
+		return wrapErr
+	}
+	return e.NewError(e.ErrTransactionError, "failed to update the cart", err)
+}
+
+// sameAmount compares two prices to the cent
+func sameAmount(a, b float64) bool {
+	return math.Round(a*100) == math.Round(b*100)
+}
+
 func (s *userServiceImpl) OrderHistory(r *http.Request) ([]*dto.ItemOrderedResponse, error) {
 	userID, err := s.getUserIDAndCheckStatus(r.Context())
 	if err != nil {
@@ -588,29 +1317,26 @@ func (s *userServiceImpl) OrderHistory(r *http.Request) ([]*dto.ItemOrderedRespo
 	var responses []*dto.ItemOrderedResponse
 
 	for _, order := range orderHistory {
-		var orderItems []dto.OrderItemResponse
-
-		for _, item := range order.Items {
-			orderItems = append(orderItems, dto.OrderItemResponse{
-				ProductID:  item.ProductID,
-				Quantity:   item.Quantity,
-				CategoryID: item.Product.CategoryID,
-				BrandName:  item.Product.BrandName,
-				Price:      item.Price,
-			})
-		}
+		orderItems := orderItemResponses(order.Items)
 
 		response := &dto.ItemOrderedResponse{
-			Items:      orderItems,
-			OrderID:    order.ID,
-			TotalPrice: order.Total,
-			UserDetails: dto.UserDetailsResponse{
-				Username:    userDetails.Username,
-				Email:       userDetails.Mail,
-				PhoneNumber: userDetails.Phonenumber,
-				Address:     userDetails.Address,
-				Pincode:     userDetails.Pincode,
-			},
+			Items:               orderItems,
+			OrderID:             order.ID,
+			Status:              order.Status,
+			Subtotal:            order.Subtotal,
+			Discount:            order.Discount,
+			Tax:                 order.Tax,
+			TotalPrice:          order.Total,
+			CouponCode:          order.CouponCode,
+			FreeShipping:        order.FreeShipping,
+			ShippingCharge:      order.ShippingCharge,
+			EstimatedDeliveryAt: order.EstimatedDeliveryAt,
+			RefundedAmount:      order.RefundedAmount,
+			NetTotal:            order.Total - order.RefundedAmount,
+			Refunds:             refundResponses(order.Refunds),
+			UserDetails:         orderUserDetails(&order, userDetails),
+			ShippingAddress:     orderAddressResponse(order.ShippingAddress),
+			BillingAddress:      orderAddressResponse(order.BillingAddress),
 		}
 		responses = append(responses, response)
 	}
@@ -640,6 +1366,9 @@ func (s *userServiceImpl) AddItemsToFavourites(r *http.Request) error {
 
 	err = s.userRepo.AddOrUpdateFavorite(userID, args)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return e.NewError(e.ErrBrandNotFound, "brand not found", err)
+		}
 		return e.NewError(e.ErrAddToFavorites, "failed to update brand to the favourite list", err)
 	}
 	log.Info().Msg("Successfully updated the brand to the user favourite list")
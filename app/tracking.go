@@ -0,0 +1,28 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"e-cart/app/internal"
+	"e-cart/app/service"
+	"e-cart/pkg/carrier"
+)
+
+// EnvTrackingRefreshInterval is how often the tracking of moving parcels is asked from the carrier, a duration like "10m"
+const EnvTrackingRefreshInterval = "SHIPMENT_TRACKING_REFRESH_INTERVAL"
+
+// defaultTrackingRefreshInterval is how often the tracking is refreshed when not configured
+const defaultTrackingRefreshInterval = 10 * time.Minute
+
+// StartTrackingRefresher refreshes the tracking of the shipments that are still moving in the background
+// until the context is done, the tracking endpoints only read what it saved
+func StartTrackingRefresher(ctx context.Context, shipmentRepo internal.ShipmentRepo, shippingCarrier carrier.Carrier) error {
+	interval, err := durationFromEnv(EnvTrackingRefreshInterval, defaultTrackingRefreshInterval)
+	if err != nil {
+		return err
+	}
+
+	go service.RefreshShipmentTracking(ctx, shipmentRepo, shippingCarrier, interval)
+	return nil
+}
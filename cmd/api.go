@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"e-cart/app"
 	gormdb "e-cart/app/gormdb"
 	"e-cart/pkg/api"
+	"e-cart/pkg/jwt"
 	"log"
 
 	"github.com/spf13/cobra"
@@ -32,6 +34,15 @@ func StartAPI(*cobra.Command, []string) {
 		log.Fatalf("failed to connect to the database: %v", err)
 	}
 
+	// keys are loaded after the db connection, as that loads the .env file
+	if err := jwt.LoadKeys(); err != nil {
+		log.Fatalf("failed to load JWT keys: %v", err)
+	}
+
+	if err := app.StartReservationSweeper(context.Background(), db); err != nil {
+		log.Fatalf("failed to start the stock reservation sweeper: %v", err)
+	}
+
 	r := app.APIRouter(db)
 	api.Start(r)
 
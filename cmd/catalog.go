@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"e-cart/app"
+	"e-cart/app/dto"
+	gormdb "e-cart/app/gormdb"
+	"e-cart/app/service"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	catalogFormat string
+	catalogDryRun bool
+)
+
+func init() {
+	catalogCmd.PersistentFlags().StringVar(&catalogFormat, "format", "", "csv or jsonl, taken from the file extension when empty")
+	catalogImportCmd.Flags().BoolVar(&catalogDryRun, "dry-run", false, "validate and report the changes without saving them")
+
+	catalogCmd.AddCommand(catalogImportCmd)
+	catalogCmd.AddCommand(catalogExportCmd)
+	rootCmd.AddCommand(catalogCmd)
+}
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog",
+	Short: "Import and export the product catalog",
+	Long:  "Import and export categories and brands as CSV or JSON Lines files",
+}
+
+var catalogImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import categories and brands from a file",
+	Long:  "Creates or updates categories and brands from a CSV or JSON Lines file, importing the same file again changes nothing",
+	Args:  cobra.ExactArgs(1),
+	Run:   ImportCatalog,
+}
+
+var catalogExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the catalog to a file",
+	Long:  "Writes every category and brand that is not deleted to a CSV or JSON Lines file, - writes to stdout",
+	Args:  cobra.ExactArgs(1),
+	Run:   ExportCatalog,
+}
+
+func ImportCatalog(_ *cobra.Command, args []string) {
+	format, err := catalogFileFormat(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("failed to open the catalog file: %v", err)
+	}
+	defer file.Close()
+
+	resp, err := newCatalogService().Import(file, format, catalogDryRun)
+	if err != nil {
+		log.Fatalf("failed to import the catalog: %v", err)
+	}
+
+	out, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Fatalf("failed to print the import result: %v", err)
+	}
+	fmt.Println(string(out))
+
+	if resp.Failed > 0 {
+		file.Close()
+		os.Exit(1)
+	}
+}
+
+func ExportCatalog(_ *cobra.Command, args []string) {
+	format, err := catalogFileFormat(args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out := os.Stdout
+	if args[0] != "-" {
+		out, err = os.Create(args[0])
+		if err != nil {
+			log.Fatalf("failed to create the catalog file: %v", err)
+		}
+		defer out.Close()
+	}
+
+	if err := newCatalogService().Export(out, format); err != nil {
+		log.Fatalf("failed to export the catalog: %v", err)
+	}
+}
+
+func newCatalogService() service.CatalogService {
+	db, err := gormdb.ConnectDb()
+	if err != nil {
+		log.Fatalf("failed to connect to the database: %v", err)
+	}
+	return app.CatalogService(db)
+}
+
+// catalogFileFormat returns the --format flag or guesses it from the file extension
+func catalogFileFormat(fileName string) (string, error) {
+	if catalogFormat != "" {
+		return strings.ToLower(catalogFormat), nil
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return dto.CatalogFormatCSV, nil
+	case ".jsonl", ".ndjson":
+		return dto.CatalogFormatJSONL, nil
+	}
+	return "", fmt.Errorf("cannot tell the format of %q, use --format csv or --format jsonl", fileName)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"e-cart/pkg/payment"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+)
+
+var (
+	paymentWebhookFailed bool
+	paymentWebhookReason string
+)
+
+func init() {
+	paymentWebhookCmd.Flags().BoolVar(&paymentWebhookFailed, "failed", false, "send a declined payment instead of an authorized one")
+	paymentWebhookCmd.Flags().StringVar(&paymentWebhookReason, "reason", "card declined", "decline reason sent with --failed")
+
+	paymentCmd.AddCommand(paymentWebhookCmd)
+	rootCmd.AddCommand(paymentCmd)
+}
+
+var paymentCmd = &cobra.Command{
+	Use:   "payment",
+	Short: "Payment tools for local development",
+	Long:  "Tools to work with the mock payment provider during local development",
+}
+
+var paymentWebhookCmd = &cobra.Command{
+	Use:   "webhook <intent-id> <amount>",
+	Short: "Print a signed mock provider webhook",
+	Long: "Prints the body and signature of a mock provider webhook for the intent, post the body to /payment/webhook " +
+		"with the signature in the " + payment.SignatureHeader + " header to confirm the payment",
+	Args: cobra.ExactArgs(2),
+	Run:  MockPaymentWebhook,
+}
+
+func MockPaymentWebhook(_ *cobra.Command, args []string) {
+	amount, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		log.Fatalf("invalid amount: %v", err)
+	}
+
+	// the .env file is optional here, the secret may come from the environment
+	_ = godotenv.Load()
+	if name := os.Getenv(payment.EnvProvider); name != payment.MockProviderName {
+		log.Fatalf("webhooks can only be made for the mock provider, %s is %q", payment.EnvProvider, name)
+	}
+	provider, err := payment.NewFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	eventType, reason := payment.EventPaymentAuthorized, ""
+	if paymentWebhookFailed {
+		eventType, reason = payment.EventPaymentFailed, paymentWebhookReason
+	}
+
+	body, signature, err := provider.(*payment.MockProvider).Webhook(eventType, args[0], amount, reason)
+	if err != nil {
+		log.Fatalf("failed to build the webhook: %v", err)
+	}
+	fmt.Printf("%s: %s\n%s\n", payment.SignatureHeader, signature, body)
+}
@@ -14,6 +14,7 @@ type Response struct {
 	Status string          `json:"status"`
 	Error  *ResponseError  `json:"error,omitempty"`
 	Result json.RawMessage `json:"result,omitempty"`
+	Meta   json.RawMessage `json:"meta,omitempty"`
 }
 
 type ResponseError struct {
@@ -32,6 +33,12 @@ func (e ResponseError) Error() string {
 
 // Success sends a successful JSON response with the standared success format
 func Success(w http.ResponseWriter, status int, result interface{}) {
+	SuccessWithMeta(w, status, result, nil)
+}
+
+// SuccessWithMeta sends a successful JSON response along with metadata like paging details,
+// a nil meta gives the plain success response
+func SuccessWithMeta(w http.ResponseWriter, status int, result interface{}, meta interface{}) {
 	resultJson, err := json.Marshal(result)
 	if err != nil {
 		http.Error(
@@ -46,6 +53,19 @@ func Success(w http.ResponseWriter, status int, result interface{}) {
 		Result: resultJson,
 	}
 
+	// without metadata the meta field is left out of the response
+	if meta != nil {
+		r.Meta, err = json.Marshal(meta)
+		if err != nil {
+			http.Error(
+				w,
+				http.StatusText(http.StatusInternalServerError),
+				http.StatusInternalServerError,
+			)
+			return
+		}
+	}
+
 	respJson, err := json.Marshal(r)
 	if err != nil {
 		http.Error(
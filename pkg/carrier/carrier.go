@@ -0,0 +1,98 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// EnvCarrier is the name of the shipping carrier, only "fake" for now. It has to be set, the fake
+// carrier is never picked by default
+const EnvCarrier = "SHIPPING_CARRIER"
+
+// Tracking statuses, a parcel moves forward through them until it is delivered or cancelled
+const (
+	// StatusLabelCreated : the label is printed, the carrier has not picked the parcel up yet
+	StatusLabelCreated = "label_created"
+	// StatusPickedUp : the carrier has the parcel
+	StatusPickedUp = "picked_up"
+	// StatusInTransit : the parcel is on its way
+	StatusInTransit = "in_transit"
+	// StatusOutForDelivery : the parcel is with the courier delivering it
+	StatusOutForDelivery = "out_for_delivery"
+	// StatusDelivered : the parcel reached the customer
+	StatusDelivered = "delivered"
+	// StatusException : a delivery attempt failed or the parcel is held
+	StatusException = "exception"
+	// StatusCancelled : the label was cancelled before pickup
+	StatusCancelled = "cancelled"
+)
+
+// ErrUnknownTrackingNumber is returned when the carrier has no parcel with the tracking number
+var ErrUnknownTrackingNumber = errors.New("unknown tracking number")
+
+// ErrCannotCancel is returned when a parcel the carrier already picked up is cancelled
+var ErrCannotCancel = errors.New("shipment can no longer be cancelled")
+
+// Address is where a parcel goes
+type Address struct {
+	Name    string
+	Lines   []string
+	City    string
+	State   string
+	Pincode int64
+	Phone   string
+}
+
+// LabelRequest asks the carrier to take a parcel
+type LabelRequest struct {
+	// Reference identifies what is shipped, eg: the order id
+	Reference   string
+	ShipTo      Address
+	Packages    int64
+	WeightGrams int64
+}
+
+// Label is a parcel booked with the carrier
+type Label struct {
+	TrackingNumber string
+	// URL is where the printable label is downloaded from
+	URL       string
+	CreatedAt time.Time
+}
+
+// TrackingEvent is a scan of the parcel by the carrier
+type TrackingEvent struct {
+	Status      string
+	Description string
+	Location    string
+	OccurredAt  time.Time
+}
+
+// Carrier is a shipping company parcels are booked with
+type Carrier interface {
+	// Name identifies the carrier on the stored shipments
+	Name() string
+	CreateLabel(ctx context.Context, req LabelRequest) (*Label, error)
+	// Track returns every event of the parcel, oldest first
+	Track(ctx context.Context, trackingNumber string) ([]TrackingEvent, error)
+	// Cancel voids the label of a parcel that is not picked up yet
+	Cancel(ctx context.Context, trackingNumber string) error
+}
+
+// NewFromEnv creates the carrier configured in the environment
+func NewFromEnv() (Carrier, error) {
+	name := os.Getenv(EnvCarrier)
+
+	if name == "" {
+		return nil, fmt.Errorf("%s is not set", EnvCarrier)
+	}
+
+	switch name {
+	case FakeCarrierName:
+		return NewFakeCarrier(), nil
+	}
+	return nil, fmt.Errorf("unknown shipping carrier %q", name)
+}
@@ -0,0 +1,113 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeCarrierName is the name of the in-process carrier used in tests and local development, it
+// forgets its parcels on restart and is only used when SHIPPING_CARRIER names it
+const FakeCarrierName = "fake"
+
+// FakeCarrier keeps its parcels in memory. Labels get sequential tracking numbers and parcels
+// only move when Advance is called, so tests decide what the customer sees
+type FakeCarrier struct {
+	mu      sync.Mutex
+	started int64 // keeps the tracking numbers of a restarted process apart from the earlier ones
+	last    int64
+	parcels map[string][]TrackingEvent
+}
+
+func NewFakeCarrier() *FakeCarrier {
+	return &FakeCarrier{started: time.Now().Unix(), parcels: make(map[string][]TrackingEvent)}
+}
+
+func (c *FakeCarrier) Name() string {
+	return FakeCarrierName
+}
+
+func (c *FakeCarrier) CreateLabel(_ context.Context, req LabelRequest) (*Label, error) {
+	if req.ShipTo.Pincode <= 0 {
+		return nil, errors.New("pincode is required")
+	}
+	if req.Packages <= 0 {
+		return nil, errors.New("a parcel needs at least one package")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.last++
+	label := &Label{
+		TrackingNumber: fmt.Sprintf("FAKE%d%06d", c.started, c.last),
+		CreatedAt:      time.Now().UTC(),
+	}
+	label.URL = "https://carrier.invalid/labels/" + label.TrackingNumber + ".pdf"
+	c.parcels[label.TrackingNumber] = []TrackingEvent{{
+		Status:      StatusLabelCreated,
+		Description: fmt.Sprintf("Label created for %s", req.Reference),
+		OccurredAt:  label.CreatedAt,
+	}}
+	return label, nil
+}
+
+func (c *FakeCarrier) Track(_ context.Context, trackingNumber string) ([]TrackingEvent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events, ok := c.parcels[trackingNumber]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTrackingNumber, trackingNumber)
+	}
+	return append([]TrackingEvent(nil), events...), nil
+}
+
+func (c *FakeCarrier) Cancel(_ context.Context, trackingNumber string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events, ok := c.parcels[trackingNumber]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTrackingNumber, trackingNumber)
+	}
+	if status := events[len(events)-1].Status; status != StatusLabelCreated {
+		return fmt.Errorf("%w: parcel %s is %s", ErrCannotCancel, trackingNumber, status)
+	}
+	c.parcels[trackingNumber] = append(events, TrackingEvent{
+		Status:      StatusCancelled,
+		Description: "Label cancelled",
+		OccurredAt:  time.Now().UTC(),
+	})
+	return nil
+}
+
+// Advance records a new scan of the parcel as the carrier would, used to move parcels in tests
+// and local development
+func (c *FakeCarrier) Advance(trackingNumber, status, location string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events, ok := c.parcels[trackingNumber]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTrackingNumber, trackingNumber)
+	}
+	c.parcels[trackingNumber] = append(events, TrackingEvent{
+		Status:      status,
+		Description: fakeDescriptions[status],
+		Location:    location,
+		OccurredAt:  time.Now().UTC(),
+	})
+	return nil
+}
+
+var fakeDescriptions = map[string]string{
+	StatusPickedUp:       "Picked up by the carrier",
+	StatusInTransit:      "In transit",
+	StatusOutForDelivery: "Out for delivery",
+	StatusDelivered:      "Delivered",
+	StatusException:      "Delivery attempt failed",
+	StatusCancelled:      "Label cancelled",
+}
@@ -129,6 +129,225 @@ const (
 
 	// ErrUpdateUserProfile : error while updating user profile
 	ErrUpdateUserProfile
+
+	// ErrLogoutUser : error while logging out user
+	ErrLogoutUser
+
+	// ErrRevokeToken : error while revoking the login tokens of a user
+	ErrRevokeToken
+
+	// ErrRefreshToken : error while refreshing the login token
+	ErrRefreshToken
+
+	// ErrUpdateOrderStatus : error while updating the order status
+	ErrUpdateOrderStatus
+
+	// ErrInvalidOrderStatus : when the order cannot move to the requested status
+	ErrInvalidOrderStatus
+
+	// ErrSearchProducts : error while searching the products
+	ErrSearchProducts
+
+	// ErrImportCatalog : error while importing the catalog file
+	ErrImportCatalog
+
+	// ErrExportCatalog : error while exporting the catalog
+	ErrExportCatalog
+
+	// ErrAdjustStock : error while posting a stock adjustment
+	ErrAdjustStock
+
+	// ErrGetStockHistory : error while getting the stock movements of a brand
+	ErrGetStockHistory
+
+	// ErrReconcileStock : error while reconciling the stock counts with the ledger
+	ErrReconcileStock
+
+	// ErrReserveStock : error while reserving or releasing stock for a cart
+	ErrReserveStock
+
+	// ErrGetLowStock : error while listing the brands low on stock
+	ErrGetLowStock
+
+	// ErrCreatePayment : error while starting the payment of an order
+	ErrCreatePayment
+
+	// ErrHandlePaymentWebhook : error while processing a webhook of the payment provider
+	ErrHandlePaymentWebhook
+
+	// ErrCancelOrder : error while cancelling an order
+	ErrCancelOrder
+
+	// ErrRefundOrder : error while refunding an order
+	ErrRefundOrder
+
+	// ErrCreateReturn : error while requesting a return
+	ErrCreateReturn
+
+	// ErrListReturns : error while listing returns
+	ErrListReturns
+
+	// ErrUpdateReturn : error while moving a return on
+	ErrUpdateReturn
+
+	// ErrCreateCoupon : error while creating a coupon
+	ErrCreateCoupon
+
+	// ErrListCoupons : error while listing coupons
+	ErrListCoupons
+
+	// ErrUpdateCoupon : error while updating a coupon
+	ErrUpdateCoupon
+
+	// ErrDeleteCoupon : error while deleting a coupon
+	ErrDeleteCoupon
+
+	// ErrApplyCoupon : error while applying a coupon to the cart
+	ErrApplyCoupon
+
+	// ErrSaveAddress : error while adding an address to the address book
+	ErrSaveAddress
+
+	// ErrGetAddresses : error while getting the address book
+	ErrGetAddresses
+
+	// ErrUpdateAddress : error while updating an address
+	ErrUpdateAddress
+
+	// ErrDeleteAddress : error while deleting an address
+	ErrDeleteAddress
+
+	// ErrSaveShippingZone : error while creating or updating a shipping zone
+	ErrSaveShippingZone
+
+	// ErrGetShippingZones : error while getting shipping zones
+	ErrGetShippingZones
+
+	// ErrDeleteShippingZone : error while deleting a shipping zone
+	ErrDeleteShippingZone
+
+	// ErrQuoteShipping : error while pricing the shipping of an order
+	ErrQuoteShipping
+
+	// ErrSaveTaxClass : error while creating or updating a tax class
+	ErrSaveTaxClass
+
+	// ErrGetTaxClasses : error while getting tax classes
+	ErrGetTaxClasses
+
+	// ErrDeleteTaxClass : error while deleting a tax class
+	ErrDeleteTaxClass
+
+	// ErrComputeTax : error while computing the tax of an order
+	ErrComputeTax
+
+	// ErrRenderInvoice : error while numbering or rendering an invoice
+	ErrRenderInvoice
+
+	// ErrCreateShipment : error while booking a shipment with the carrier
+	ErrCreateShipment
+
+	// ErrGetShipments : error while getting the shipments or tracking of an order
+	ErrGetShipments
+
+	// ErrCancelShipment : error while cancelling a shipment
+	ErrCancelShipment
+)
+
+// 401 errors
+const (
+	// ErrUnauthorized : when the request is not authenticated
+	ErrUnauthorized int = 401000 + iota
+
+	// ErrInvalidRefreshToken : when the refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken
+
+	// ErrRefreshTokenReused : when an already rotated refresh token is used again
+	ErrRefreshTokenReused
+
+	// ErrInvalidWebhookSignature : when a payment webhook is not signed by the provider
+	ErrInvalidWebhookSignature
+)
+
+// 409 errors
+const (
+	// ErrConflict : when the request conflicts with the current state of the resource
+	ErrConflict int = 409000 + iota
+
+	// ErrPriceChanged : when cart prices changed since the items were added and the new total is not confirmed
+	ErrPriceChanged
+
+	// ErrCategoryAlreadyExists : when a category is renamed to a name that is already taken
+	ErrCategoryAlreadyExists
+
+	// ErrOrderNotPayable : when an order that is not pending is paid for
+	ErrOrderNotPayable
+
+	// ErrPaymentAlreadyFinal : when a payment that already succeeded or failed is confirmed again
+	ErrPaymentAlreadyFinal
+
+	// ErrOrderNotCancellable : when an order that is already shipped, cancelled or refunded is cancelled
+	ErrOrderNotCancellable
+
+	// ErrOrderNotRefundable : when an order is not paid or the refund is more than is left of it
+	ErrOrderNotRefundable
+
+	// ErrReturnNotAllowed : when the item is not delivered, out of the return window or has nothing left to return
+	ErrReturnNotAllowed
+
+	// ErrInvalidReturnStatus : when a return cannot move to the requested status
+	ErrInvalidReturnStatus
+
+	// ErrCouponAlreadyExists : when a coupon is created with a code that is already taken
+	ErrCouponAlreadyExists
+
+	// ErrCouponNotApplicable : when a coupon is inactive, expired, used up or does not fit the cart
+	ErrCouponNotApplicable
+
+	// ErrCouponInUse : when a coupon that was already redeemed is deleted
+	ErrCouponInUse
+
+	// ErrPincodeNotServiceable : when no shipping zone delivers to the pincode
+	ErrPincodeNotServiceable
+
+	// ErrShippingZoneAlreadyExists : when a shipping zone is saved with a name that is already taken
+	ErrShippingZoneAlreadyExists
+
+	// ErrTaxClassAlreadyExists : when a tax class is saved with a name that is already taken
+	ErrTaxClassAlreadyExists
+
+	// ErrTaxClassInUse : when a tax class that categories or brands use is deleted
+	ErrTaxClassInUse
+
+	// ErrInvoiceNotAvailable : when the invoice of an order that is not paid is asked for
+	ErrInvoiceNotAvailable
+
+	// ErrOrderNotShippable : when a shipment is created for an order that is not paid or packed
+	ErrOrderNotShippable
+
+	// ErrShipmentQuantity : when a shipment holds more of an item than is left to ship
+	ErrShipmentQuantity
+
+	// ErrShipmentNotCancellable : when a shipment the carrier already picked up is cancelled
+	ErrShipmentNotCancellable
+
+	// ErrPaymentAmountMismatch : when a webhook confirms another amount than the payment was started with
+	ErrPaymentAmountMismatch
+
+	// ErrPaymentCaptureInProgress : when a payment is confirmed while an earlier webhook is capturing it
+	ErrPaymentCaptureInProgress
+
+	// ErrShippingStateRequired : when the tax of the cart depends on the state and the shipping address has none
+	ErrShippingStateRequired
+
+	// ErrBrandAlreadyExists : when a brand is renamed or moved to a name and model that is already taken in the category
+	ErrBrandAlreadyExists
+)
+
+// 403 errors
+const (
+	// ErrForbidden : when the user is not allowed to perform the request
+	ErrForbidden int = 403000 + iota
 )
 
 // 404 errors
@@ -153,6 +372,27 @@ const (
 
 	// ErrBrandNotFound : when brand is not found
 	ErrBrandNotFound
+
+	// ErrPaymentNotFound : when payment is not found
+	ErrPaymentNotFound
+
+	// ErrReturnNotFound : when return is not found
+	ErrReturnNotFound
+
+	// ErrCouponNotFound : when coupon is not found
+	ErrCouponNotFound
+
+	// ErrAddressNotFound : when address is not found in the address book of the user
+	ErrAddressNotFound
+
+	// ErrShippingZoneNotFound : when shipping zone is not found
+	ErrShippingZoneNotFound
+
+	// ErrTaxClassNotFound : when tax class is not found
+	ErrTaxClassNotFound
+
+	// ErrShipmentNotFound : when shipment is not found
+	ErrShipmentNotFound
 )
 
 // 500 errors
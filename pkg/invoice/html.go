@@ -0,0 +1,98 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"amount": amount,
+	"inc":    func(i int) int { return i + 1 },
+	"lines":  partyLines,
+	"date":   func(t time.Time) string { return t.Format("02 Jan 2006") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 14px; color: #222; margin: 32px; }
+h1 { margin: 0; }
+.header, .parties { display: flex; justify-content: space-between; margin-bottom: 24px; }
+.meta { text-align: right; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #999; padding: 6px; }
+th { background: #e6e6e6; }
+.num { text-align: right; }
+.totals { width: 40%; margin-left: auto; margin-top: 16px; }
+.totals td { border: none; }
+.strong td { font-weight: bold; }
+.note { font-size: 12px; font-style: italic; margin-top: 24px; }
+</style>
+</head>
+<body>
+<div class="header">
+  <h1>INVOICE</h1>
+  <div class="meta">
+    <div>Invoice no: {{.Number}}</div>
+    <div>Date: {{date .IssuedAt}}</div>
+    <div>Order: #{{.OrderID}} of {{date .OrderedAt}}</div>
+  </div>
+</div>
+<div class="parties">
+  <div><strong>Sold by</strong>{{range lines .Seller}}<br>{{.}}{{end}}</div>
+  <div><strong>Bill to</strong>{{range lines .BillTo}}<br>{{.}}{{end}}</div>
+  <div><strong>Ship to</strong>{{range lines .ShipTo}}<br>{{.}}{{end}}</div>
+</div>
+<table>
+  <thead>
+    <tr><th>#</th><th>Item</th><th class="num">Qty</th><th class="num">Unit price</th><th class="num">Discount</th><th class="num">Tax %</th><th class="num">Tax</th><th class="num">Amount</th></tr>
+  </thead>
+  <tbody>
+  {{range $i, $line := .Lines}}
+    <tr>
+      <td>{{inc $i}}</td>
+      <td>{{$line.Description}}</td>
+      <td class="num">{{$line.Quantity}}</td>
+      <td class="num">{{amount $line.UnitPrice}}</td>
+      <td class="num">{{amount $line.Discount}}</td>
+      <td class="num">{{printf "%.2f" $line.TaxRate}}{{if $line.TaxInclusive}} incl.{{end}}</td>
+      <td class="num">{{amount $line.TaxAmount}}</td>
+      <td class="num">{{amount $line.Total}}</td>
+    </tr>
+  {{end}}
+  </tbody>
+</table>
+<table class="totals">
+{{range .Totals}}
+  <tr{{if .Strong}} class="strong"{{end}}><td>{{.Label}}</td><td class="num">{{amount .Value}}</td></tr>
+{{end}}
+</table>
+<p class="note">Amounts are in INR. Tax marked incl. is part of the item price, other tax is added on top.</p>
+</body>
+</html>
+`))
+
+// RenderHTML renders the invoice as a standalone html page
+func RenderHTML(doc *Document) ([]byte, error) {
+	type htmlTotal struct {
+		Label  string
+		Value  float64
+		Strong bool
+	}
+	data := struct {
+		*Document
+		Totals []htmlTotal
+	}{Document: doc}
+	for _, total := range totals(doc) {
+		data.Totals = append(data.Totals, htmlTotal{Label: total.label, Value: total.value, Strong: total.strong})
+	}
+
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render the invoice: %w", err)
+	}
+	return buf.Bytes(), nil
+}
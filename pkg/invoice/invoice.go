@@ -0,0 +1,126 @@
+package invoice
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Seller details printed on every invoice
+const (
+	EnvSellerName    = "INVOICE_SELLER_NAME"
+	EnvSellerAddress = "INVOICE_SELLER_ADDRESS" // lines separated by ";"
+	EnvSellerTaxID   = "INVOICE_SELLER_TAX_ID"
+	EnvSellerEmail   = "INVOICE_SELLER_EMAIL"
+)
+
+// defaultSellerName is used when the seller is not configured
+const defaultSellerName = "E-Cart"
+
+// Party is the seller or buyer of an invoice
+type Party struct {
+	Name    string
+	Address []string
+	TaxID   string
+	Email   string
+	Phone   string
+}
+
+// Line is one item of the invoice, Total is what is paid for the line
+type Line struct {
+	Description  string
+	Quantity     int64
+	UnitPrice    float64
+	Discount     float64
+	TaxRate      float64
+	TaxAmount    float64
+	TaxInclusive bool
+	Total        float64
+}
+
+// Document is everything an invoice shows, renderers only lay it out
+type Document struct {
+	Number         string
+	IssuedAt       time.Time
+	OrderID        int64
+	OrderedAt      time.Time
+	Seller         Party
+	BillTo         Party
+	ShipTo         Party
+	Lines          []Line
+	Subtotal       float64
+	Discount       float64
+	CouponCode     string
+	Tax            float64
+	Shipping       float64
+	GrandTotal     float64
+	RefundedAmount float64
+}
+
+// SellerFromEnv reads the seller details, only the name has a default
+func SellerFromEnv() Party {
+	seller := Party{
+		Name:  strings.TrimSpace(os.Getenv(EnvSellerName)),
+		TaxID: strings.TrimSpace(os.Getenv(EnvSellerTaxID)),
+		Email: strings.TrimSpace(os.Getenv(EnvSellerEmail)),
+	}
+	if seller.Name == "" {
+		seller.Name = defaultSellerName
+	}
+	for _, line := range strings.Split(os.Getenv(EnvSellerAddress), ";") {
+		if line = strings.TrimSpace(line); line != "" {
+			seller.Address = append(seller.Address, line)
+		}
+	}
+	return seller
+}
+
+type total struct {
+	label  string
+	value  float64
+	strong bool
+}
+
+// totals is the breakdown under the items, the lines that are zero are left out
+func totals(doc *Document) []total {
+	rows := []total{{label: "Subtotal", value: doc.Subtotal}}
+	if doc.Discount > 0 {
+		label := "Discount"
+		if doc.CouponCode != "" {
+			label += " (" + doc.CouponCode + ")"
+		}
+		rows = append(rows, total{label: label, value: -doc.Discount})
+	}
+	rows = append(rows,
+		total{label: "Tax", value: doc.Tax},
+		total{label: "Shipping", value: doc.Shipping},
+		total{label: "Grand total", value: doc.GrandTotal, strong: true},
+	)
+	if doc.RefundedAmount > 0 {
+		rows = append(rows,
+			total{label: "Refunded", value: -doc.RefundedAmount},
+			total{label: "Net paid", value: doc.GrandTotal - doc.RefundedAmount, strong: true},
+		)
+	}
+	return rows
+}
+
+// partyLines are the lines of the address block of a party
+func partyLines(party Party) []string {
+	lines := append([]string{party.Name}, party.Address...)
+	if party.Phone != "" {
+		lines = append(lines, "Phone: "+party.Phone)
+	}
+	if party.Email != "" {
+		lines = append(lines, party.Email)
+	}
+	if party.TaxID != "" {
+		lines = append(lines, "Tax ID: "+party.TaxID)
+	}
+	return lines
+}
+
+func amount(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
@@ -0,0 +1,128 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// column widths of the item table on an A4 page with 15mm margins, 180mm in all
+var pdfColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"#", 8, "C"},
+	{"Item", 64, "L"},
+	{"Qty", 12, "R"},
+	{"Unit price", 22, "R"},
+	{"Discount", 20, "R"},
+	{"Tax %", 14, "R"},
+	{"Tax", 18, "R"},
+	{"Amount", 22, "R"},
+}
+
+// RenderPDF lays the invoice out on A4 pages with the core fonts, text outside of cp1252 is not shown
+func RenderPDF(doc *Document) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle("Invoice "+doc.Number, true)
+	pdf.SetCreator(doc.Seller.Name, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	// heading with the invoice number and dates on the right
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(90, 10, "INVOICE", "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(90, 5, tr("Invoice no: "+doc.Number), "", 2, "R", false, 0, "")
+	pdf.CellFormat(90, 5, "Date: "+doc.IssuedAt.Format("02 Jan 2006"), "", 2, "R", false, 0, "")
+	pdf.CellFormat(90, 5, fmt.Sprintf("Order: #%d of %s", doc.OrderID, doc.OrderedAt.Format("02 Jan 2006")), "", 1, "R", false, 0, "")
+	pdf.Ln(4)
+
+	writePDFParty(pdf, tr, "Sold by", doc.Seller, 15, pdf.GetY())
+	pdf.Ln(4)
+	top := pdf.GetY()
+	writePDFParty(pdf, tr, "Bill to", doc.BillTo, 15, top)
+	billBottom := pdf.GetY()
+	writePDFParty(pdf, tr, "Ship to", doc.ShipTo, 105, top)
+	if billBottom > pdf.GetY() {
+		pdf.SetY(billBottom)
+	}
+	pdf.Ln(6)
+
+	// item table
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for _, column := range pdfColumns {
+		pdf.CellFormat(column.width, 7, column.title, "1", 0, column.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	for i, line := range doc.Lines {
+		taxRate := fmt.Sprintf("%.2f", line.TaxRate)
+		if line.TaxInclusive {
+			taxRate += " incl."
+		}
+		cells := []string{
+			fmt.Sprint(i + 1),
+			tr(line.Description),
+			fmt.Sprint(line.Quantity),
+			amount(line.UnitPrice),
+			amount(line.Discount),
+			taxRate,
+			amount(line.TaxAmount),
+			amount(line.Total),
+		}
+		for j, column := range pdfColumns {
+			pdf.CellFormat(column.width, 7, fitText(pdf, cells[j], column.width-2), "1", 0, column.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// totals in the last two columns
+	for _, total := range totals(doc) {
+		style := ""
+		if total.strong {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(120, 6, "", "", 0, "L", false, 0, "")
+		pdf.CellFormat(35, 6, tr(total.label), "", 0, "L", false, 0, "")
+		pdf.CellFormat(25, 6, amount(total.value), "", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.MultiCell(180, 4, "Amounts are in INR. Tax marked incl. is part of the item price, other tax is added on top.", "", "L", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writePDFParty(pdf *fpdf.Fpdf, tr func(string) string, title string, party Party, x, y float64) {
+	pdf.SetXY(x, y)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(85, 5, title, "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range partyLines(party) {
+		pdf.CellFormat(85, 5, tr(line), "", 2, "L", false, 0, "")
+	}
+}
+
+// fitText cuts the text so it fits the width of a cell
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	for len(text) > 0 && pdf.GetStringWidth(text+"...") > width {
+		text = text[:len(text)-1]
+	}
+	return text + "..."
+}
@@ -2,14 +2,18 @@ package jwt
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 )
 
+// AccessTokenTTL is kept short, clients use the refresh token to get a new one
+const AccessTokenTTL = 15 * time.Minute
+
 var (
 	ErrExpiredToken = errors.New("token is expired")
-	jwtKey          = []byte("wA2I7VqLMbKP5RtUoD7M1jsJYWD9edxBS6cOgFXElwo=")
+	ErrUnknownKey   = errors.New("token is signed with an unknown key")
 )
 
 type Claims struct {
@@ -19,9 +23,9 @@ type Claims struct {
 	jwt.StandardClaims
 }
 
-// GenerateToken generates a new JWT token
+// GenerateToken generates a new JWT token signed with the current signing key
 func GenerateToken(userID int64, username string, isadmin bool) (string, time.Time, error) {
-	expirationTime := time.Now().Add(3 * time.Hour) // Token valid for 3 hours
+	expirationTime := time.Now().Add(AccessTokenTTL)
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
@@ -31,30 +35,43 @@ func GenerateToken(userID int64, username string, isadmin bool) (string, time.Ti
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	key := currentKeySet().signing
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", time.Time{}, err
 	}
 	return tokenString, expirationTime, nil
 }
 
-// ValidateToken validates the JWT token and checks for expiration
+// ValidateToken validates the JWT token against the active keys and checks for expiration
 func ValidateToken(tokenStr string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
+		kid, _ := token.Header["kid"].(string)
+		key, ok := currentKeySet().keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// the algorithm must be the one of the key, otherwise a public key could be used as HMAC secret
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", token.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
 	})
 
 	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return nil, err
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrExpiredToken
 		}
 		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, err
+		return nil, errors.New("token is invalid")
 	}
 
 	// Check if the token has expired
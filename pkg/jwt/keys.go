@@ -0,0 +1,254 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Environment variables used to configure the keys
+const (
+	// EnvAlgorithm is the algorithm of the signing key: HS256, RS256 or EdDSA
+	EnvAlgorithm = "JWT_ALGORITHM"
+
+	// EnvKeyID is the kid of the signing key, written to the token header
+	EnvKeyID = "JWT_KEY_ID"
+
+	// EnvSecret is the HMAC secret, used with HS256
+	EnvSecret = "JWT_SECRET"
+
+	// EnvPrivateKeyFile is the PEM private key file, used with RS256 and EdDSA
+	EnvPrivateKeyFile = "JWT_PRIVATE_KEY_FILE"
+
+	// EnvEphemeralKey set to true signs with a random HS256 key when no key is configured, tokens then
+	// stop working on restart and differ between instances, so it is only meant for local development
+	EnvEphemeralKey = "JWT_EPHEMERAL_KEY"
+
+	// EnvVerifyKeys lists the older keys which are still accepted while rotating,
+	// as comma separated kid=ALG:file entries. The file has the PEM public key,
+	// or the secret for HS256. eg: old=HS256:/run/secrets/old,rsa-1=RS256:/keys/rsa-1.pub
+	EnvVerifyKeys = "JWT_VERIFY_KEYS"
+)
+
+const defaultKeyID = "default"
+
+// Key is a key identified by its kid, verify only keys have no signKey
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeySet has the key used to sign new tokens and every key accepted for validation
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+var (
+	keySet   *KeySet
+	keySetMu sync.RWMutex
+)
+
+// NewKeySet creates a key set signing with the given key and also accepting the verify keys
+func NewKeySet(signing *Key, verify ...*Key) (*KeySet, error) {
+	if signing == nil || signing.signKey == nil {
+		return nil, fmt.Errorf("signing key is required")
+	}
+
+	set := &KeySet{
+		signing: signing,
+		keys:    map[string]*Key{signing.ID: signing},
+	}
+	for _, key := range verify {
+		if _, exists := set.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	return set, nil
+}
+
+// SetKeySet replaces the keys used to sign and validate tokens
+func SetKeySet(set *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	keySet = set
+}
+
+// LoadKeys loads the keys from the environment and makes them the active keys. A missing
+// key is an error unless EnvEphemeralKey asks for a random one
+func LoadKeys() error {
+	set, err := loadKeySetFromEnv()
+	if err != nil {
+		return err
+	}
+	SetKeySet(set)
+	return nil
+}
+
+// currentKeySet returns the active keys, a random key is used by tests and tools that never load them
+func currentKeySet() *KeySet {
+	keySetMu.RLock()
+	set := keySet
+	keySetMu.RUnlock()
+	if set != nil {
+		return set
+	}
+
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	if keySet == nil {
+		keySet = ephemeralKeySet()
+	}
+	return keySet
+}
+
+func ephemeralKeySet() *KeySet {
+	key, err := ephemeralKey(defaultKeyID)
+	if err != nil {
+		log.Fatalf("failed to generate JWT secret: %v", err)
+	}
+	return &KeySet{signing: key, keys: map[string]*Key{key.ID: key}}
+}
+
+// ephemeralKey creates a random HS256 key which only lives as long as the process
+func ephemeralKey(kid string) (*Key, error) {
+	log.Println("WARNING: no JWT signing key configured, using a random key for this process only")
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	return NewHMACKey(kid, []byte(secret)), nil
+}
+
+func loadKeySetFromEnv() (*KeySet, error) {
+	alg := strings.TrimSpace(os.Getenv(EnvAlgorithm))
+	kid := strings.TrimSpace(os.Getenv(EnvKeyID))
+	if kid == "" {
+		kid = defaultKeyID
+	}
+
+	var signing *Key
+	var err error
+	switch alg {
+	case "", jwt.SigningMethodHS256.Alg():
+		secret := os.Getenv(EnvSecret)
+		if secret != "" {
+			signing = NewHMACKey(kid, []byte(secret))
+			break
+		}
+		ephemeral, parseErr := strconv.ParseBool(strings.TrimSpace(os.Getenv(EnvEphemeralKey)))
+		if parseErr != nil || !ephemeral {
+			return nil, fmt.Errorf("%s is required for HS256, set %s=true to use a random key in local development", EnvSecret, EnvEphemeralKey)
+		}
+		signing, err = ephemeralKey(kid)
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		signing, err = loadPrivateKey(kid, alg, os.Getenv(EnvPrivateKeyFile))
+	default:
+		err = fmt.Errorf("unsupported %s %q", EnvAlgorithm, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	verify, err := parseVerifyKeys(os.Getenv(EnvVerifyKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySet(signing, verify...)
+}
+
+// NewHMACKey creates a HS256 key, the secret is used to both sign and verify
+func NewHMACKey(kid string, secret []byte) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey creates a RS256 signing key
+func NewRSAKey(kid string, private *rsa.PrivateKey) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodRS256, signKey: private, verifyKey: &private.PublicKey}
+}
+
+// NewEdDSAKey creates an EdDSA signing key
+func NewEdDSAKey(kid string, private ed25519.PrivateKey) *Key {
+	return &Key{ID: kid, Method: jwt.SigningMethodEdDSA, signKey: private, verifyKey: private.Public()}
+}
+
+func loadPrivateKey(kid, alg, file string) (*Key, error) {
+	if file == "" {
+		return nil, fmt.Errorf("%s is required for %s", EnvPrivateKeyFile, alg)
+	}
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	if alg == jwt.SigningMethodRS256.Alg() {
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		return NewRSAKey(kid, private), nil
+	}
+
+	private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse EdDSA private key: %w", err)
+	}
+	edPrivate, ok := private.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an Ed25519 key")
+	}
+	return NewEdDSAKey(kid, edPrivate), nil
+}
+
+// parseVerifyKeys parses the kid=ALG:file entries of EnvVerifyKeys
+func parseVerifyKeys(value string) ([]*Key, error) {
+	var keys []*Key
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, rest, ok := strings.Cut(entry, "=")
+		alg, file, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || kid == "" || file == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, expected kid=ALG:file", EnvVerifyKeys, entry)
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", kid, err)
+		}
+
+		key := &Key{ID: kid}
+		switch alg {
+		case jwt.SigningMethodHS256.Alg():
+			key.Method = jwt.SigningMethodHS256
+			key.verifyKey = []byte(strings.TrimSpace(string(data)))
+		case jwt.SigningMethodRS256.Alg():
+			key.Method = jwt.SigningMethodRS256
+			key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		case jwt.SigningMethodEdDSA.Alg():
+			key.Method = jwt.SigningMethodEdDSA
+			key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(data)
+		default:
+			err = fmt.Errorf("unsupported algorithm %q", alg)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", kid, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,272 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useKeySet makes the set active for the test and puts the previous keys back after it
+func useKeySet(t *testing.T, set *KeySet) {
+	t.Helper()
+	keySetMu.RLock()
+	previous := keySet
+	keySetMu.RUnlock()
+	SetKeySet(set)
+	t.Cleanup(func() { SetKeySet(previous) })
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return file
+}
+
+func newRSAPrivateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return private
+}
+
+func newEdDSAPrivateKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return private
+}
+
+func tokenKeyID(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+	require.NoError(t, err)
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestLoadKeysFromEnv(t *testing.T) {
+	useKeySet(t, nil)
+
+	rsaPrivate := newRSAPrivateKey(t)
+	privateFile := writePEM(t, "rsa-2.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaPrivate))
+
+	edPrivate := newEdDSAPrivateKey(t)
+	edPublic, err := x509.MarshalPKIXPublicKey(edPrivate.Public())
+	require.NoError(t, err)
+	edFile := writePEM(t, "ed-1.pub", "PUBLIC KEY", edPublic)
+
+	secretFile := filepath.Join(t.TempDir(), "old")
+	require.NoError(t, os.WriteFile(secretFile, []byte("old-secret\n"), 0o600))
+
+	t.Setenv(EnvAlgorithm, "RS256")
+	t.Setenv(EnvKeyID, "rsa-2")
+	t.Setenv(EnvPrivateKeyFile, privateFile)
+	t.Setenv(EnvVerifyKeys, "old=HS256:"+secretFile+", ed-1=EdDSA:"+edFile)
+	require.NoError(t, LoadKeys())
+
+	token, _, err := GenerateToken(7, "alice", false)
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-2", tokenKeyID(t, token))
+
+	claims, err := ValidateToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), claims.UserID)
+
+	// the secret file is trimmed, so a token of the old HS256 key still validates
+	oldToken := signWith(t, NewHMACKey("old", []byte("old-secret")), 8)
+	claims, err = ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), claims.UserID)
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		cases := []struct {
+			name string
+			env  map[string]string
+		}{
+			{"unsupported algorithm", map[string]string{EnvAlgorithm: "ES256"}},
+			{"missing private key file", map[string]string{EnvPrivateKeyFile: ""}},
+			{"unreadable private key", map[string]string{EnvPrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}},
+			{"wrong private key type", map[string]string{EnvAlgorithm: "EdDSA"}},
+			{"malformed verify entry", map[string]string{EnvVerifyKeys: "old:" + secretFile}},
+			{"unsupported verify algorithm", map[string]string{EnvVerifyKeys: "old=ES256:" + secretFile}},
+			{"duplicate key id", map[string]string{EnvVerifyKeys: "rsa-2=HS256:" + secretFile}},
+			{"HS256 without secret", map[string]string{EnvAlgorithm: "HS256", EnvSecret: ""}},
+			{"no key configured", map[string]string{EnvAlgorithm: "", EnvSecret: "", EnvVerifyKeys: ""}},
+			{"invalid ephemeral flag", map[string]string{EnvAlgorithm: "", EnvSecret: "", EnvEphemeralKey: "sometimes"}},
+		}
+		for _, c := range cases {
+			t.Run(c.name, func(t *testing.T) {
+				for name, value := range c.env {
+					t.Setenv(name, value)
+				}
+				assert.Error(t, LoadKeys())
+
+				// a failed load keeps the keys which are already active
+				_, err := ValidateToken(token)
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("random key for local development", func(t *testing.T) {
+		t.Setenv(EnvAlgorithm, "")
+		t.Setenv(EnvKeyID, "dev")
+		t.Setenv(EnvSecret, "")
+		t.Setenv(EnvEphemeralKey, "true")
+		require.NoError(t, LoadKeys())
+
+		// the verify keys are still accepted next to the random key
+		_, err := ValidateToken(oldToken)
+		assert.NoError(t, err)
+		_, err = ValidateToken(token)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+}
+
+func signWith(t *testing.T, key *Key, userID int64) string {
+	t.Helper()
+	claims := &Claims{
+		UserID:         userID,
+		StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(AccessTokenTTL).Unix()},
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.signKey)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := NewHMACKey("old", []byte("old-secret"))
+	newKey := NewRSAKey("rsa-1", newRSAPrivateKey(t))
+
+	set, err := NewKeySet(oldKey)
+	require.NoError(t, err)
+	useKeySet(t, set)
+	oldToken, _, err := GenerateToken(1, "alice", false)
+	require.NoError(t, err)
+
+	// while rotating the new key signs and the old key is still accepted
+	set, err = NewKeySet(newKey, &Key{ID: oldKey.ID, Method: oldKey.Method, verifyKey: oldKey.verifyKey})
+	require.NoError(t, err)
+	SetKeySet(set)
+
+	newToken, _, err := GenerateToken(2, "bob", true)
+	require.NoError(t, err)
+	assert.Equal(t, "rsa-1", tokenKeyID(t, newToken))
+
+	claims, err := ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), claims.UserID)
+	claims, err = ValidateToken(newToken)
+	require.NoError(t, err)
+	assert.True(t, claims.IsAdmin)
+
+	// once the old key is dropped its tokens are rejected
+	set, err = NewKeySet(newKey)
+	require.NoError(t, err)
+	SetKeySet(set)
+
+	_, err = ValidateToken(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	_, err = ValidateToken(newToken)
+	assert.NoError(t, err)
+
+	_, err = NewKeySet(&Key{ID: "verify-only", Method: jwt.SigningMethodHS256, verifyKey: []byte("x")})
+	assert.Error(t, err, "a verify only key cannot sign")
+}
+
+func TestValidateTokenRejectsUnknownKeys(t *testing.T) {
+	rsaKey := NewRSAKey("rsa-1", newRSAPrivateKey(t))
+	set, err := NewKeySet(rsaKey)
+	require.NoError(t, err)
+	useKeySet(t, set)
+
+	unknown := signWith(t, NewHMACKey("other", []byte("secret")), 1)
+	_, err = ValidateToken(unknown)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	noKid := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1})
+	signed, err := noKid.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = ValidateToken(signed)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// a HS256 token using the published RSA key as secret must not pass as the RSA key
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.verifyKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	confused := signWith(t, NewHMACKey("rsa-1", publicPEM), 1)
+	_, err = ValidateToken(confused)
+	assert.Error(t, err)
+
+	expired := &Claims{UserID: 1, StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}}
+	token := jwt.NewWithClaims(rsaKey.Method, expired)
+	token.Header["kid"] = rsaKey.ID
+	signed, err = token.SignedString(rsaKey.signKey)
+	require.NoError(t, err)
+	_, err = ValidateToken(signed)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	rsaPrivate := newRSAPrivateKey(t)
+	edPrivate := newEdDSAPrivateKey(t)
+	set, err := NewKeySet(
+		NewRSAKey("rsa-1", rsaPrivate),
+		&Key{ID: "ed-1", Method: jwt.SigningMethodEdDSA, verifyKey: edPrivate.Public()},
+		NewHMACKey("old", []byte("old-secret")),
+	)
+	require.NoError(t, err)
+	useKeySet(t, set)
+
+	rec := httptest.NewRecorder()
+	JWKSHandler(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+
+	var jwks JWKS
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+	// sorted by kid and the HMAC secret is not published
+	require.Len(t, jwks.Keys, 2)
+
+	ed := jwks.Keys[0]
+	assert.Equal(t, JWK{Kty: "OKP", Kid: "ed-1", Alg: "EdDSA", Use: "sig", Crv: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(edPrivate.Public().(ed25519.PublicKey))}, ed)
+
+	rsaJWK := jwks.Keys[1]
+	assert.Equal(t, "RSA", rsaJWK.Kty)
+	assert.Equal(t, "rsa-1", rsaJWK.Kid)
+	assert.Equal(t, "RS256", rsaJWK.Alg)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	require.NoError(t, err)
+	assert.Equal(t, rsaPrivate.N.Bytes(), n)
+	assert.Equal(t, "AQAB", rsaJWK.E)
+
+	t.Run("HMAC only", func(t *testing.T) {
+		set, err := NewKeySet(NewHMACKey("default", []byte("secret")))
+		require.NoError(t, err)
+		useKeySet(t, set)
+
+		rec := httptest.NewRecorder()
+		JWKSHandler(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+		assert.JSONEq(t, `{"keys":[]}`, rec.Body.String())
+	})
+}
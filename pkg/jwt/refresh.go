@@ -0,0 +1,40 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshTokenTTL is how long a refresh token can be used to get a new access token
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// GenerateRefreshToken generates a new opaque refresh token along with its expiry
+func GenerateRefreshToken() (string, time.Time, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(RefreshTokenTTL), nil
+}
+
+// GenerateTokenFamilyID generates the id shared by all refresh tokens rotated from the same login
+func GenerateTokenFamilyID() (string, error) {
+	return randomString(16)
+}
+
+// HashRefreshToken hashes the refresh token, only the hash is stored in the database
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
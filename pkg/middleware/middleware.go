@@ -14,46 +14,66 @@ const (
 	UserIDKey   contextKey = "userid"
 	UsernameKey contextKey = "username"
 	IsAdminKey  contextKey = "isadmin"
+	TokenKey    contextKey = "token"
 )
 
-// middleware for users routes
-func JWTAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get the token from the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			api.Fail(w, http.StatusUnauthorized, 401, "Authorization header is missing", "")
-			return
-		}
+// TokenStore tells whether a token is still the active login token of the user
+type TokenStore interface {
+	IsTokenActive(userID int64, token string) (bool, error)
+}
 
-		// Extract the token part (removing 'Bearer ' prefix) //have 2 part we wont take bearer part
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			api.Fail(w, http.StatusUnauthorized, 401, "Invalid token format", "")
-			return
-		}
+// middleware for users routes, tokens which are revoked or replaced by a newer login are rejected
+func JWTAuthMiddleware(store TokenStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the token from the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				api.Fail(w, http.StatusUnauthorized, 401, "Authorization header is missing", "")
+				return
+			}
 
-		// Validate the token
-		claims, err := jwt.ValidateToken(tokenString)
-		if err != nil {
-			if err == jwt.ErrExpiredToken {
-				api.Fail(w, http.StatusUnauthorized, 401, "Token expired, please log in again", "")
+			// Extract the token part (removing 'Bearer ' prefix) //have 2 part we wont take bearer part
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				api.Fail(w, http.StatusUnauthorized, 401, "Invalid token format", "")
 				return
 			}
 
-			api.Fail(w, http.StatusUnauthorized, 401, "Invalid token", err.Error())
-			return
-		}
+			// Validate the token
+			claims, err := jwt.ValidateToken(tokenString)
+			if err != nil {
+				if err == jwt.ErrExpiredToken {
+					api.Fail(w, http.StatusUnauthorized, 401, "Token expired, please log in again", "")
+					return
+				}
 
-		// Store userid and username in context,so it can be user on other layers from the ctx
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		ctx = context.WithValue(ctx, UsernameKey, claims.Username)
-		ctx = context.WithValue(ctx, IsAdminKey, claims.IsAdmin)
+				api.Fail(w, http.StatusUnauthorized, 401, "Invalid token", err.Error())
+				return
+			}
 
-		// updating and Passing the control to the next handler func we have
-		r = r.WithContext(ctx)
-		next.ServeHTTP(w, r)
-	})
+			// Check the token is not logged out or revoked
+			active, err := store.IsTokenActive(claims.UserID, tokenString)
+			if err != nil {
+				api.Fail(w, http.StatusInternalServerError, 500, "Failed to verify token", err.Error())
+				return
+			}
+			if !active {
+				api.Fail(w, http.StatusUnauthorized, 401, "Token revoked, please log in again", "")
+				return
+			}
+
+			// Store userid and username in context,so it can be user on other layers from the ctx
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, UsernameKey, claims.Username)
+			ctx = context.WithValue(ctx, IsAdminKey, claims.IsAdmin)
+			ctx = context.WithValue(ctx, TokenKey, tokenString)
+
+			// updating and Passing the control to the next handler func we have
+			r = r.WithContext(ctx)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // middleware for admin-only routes
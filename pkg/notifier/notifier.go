@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EnvLowStockWebhookURL is the URL the low stock events are posted to, they are only logged when it is not set
+const EnvLowStockWebhookURL = "LOW_STOCK_WEBHOOK_URL"
+
+// Event types
+const (
+	// EventLowStock : a product went to or below its reorder threshold
+	EventLowStock = "stock.low"
+)
+
+// Event is something that happened in the shop which someone outside of it wants to know about
+type Event struct {
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Payload    interface{} `json:"payload"`
+}
+
+// Notifier delivers events, implementations have to be safe for concurrent use
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// New returns a webhook notifier when a URL is given, otherwise a log notifier
+func New(webhookURL string) Notifier {
+	if webhookURL == "" {
+		return NewLogNotifier()
+	}
+	return NewWebhookNotifier(webhookURL)
+}
+
+// LogNotifier writes the events to the application log
+type LogNotifier struct{}
+
+func NewLogNotifier() Notifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(_ context.Context, event Event) error {
+	log.Warn().Str("event", event.Type).Time("occurred_at", event.OccurredAt).Interface("payload", event.Payload).Msg("notification")
+	return nil
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifierPostsEvent(t *testing.T) {
+	var got struct {
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+	var contentType, eventType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		contentType = r.Header.Get("Content-Type")
+		eventType = r.Header.Get("X-Event-Type")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	occurredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	err := NewWebhookNotifier(server.URL).Notify(context.Background(), Event{
+		Type:       EventLowStock,
+		OccurredAt: occurredAt,
+		Payload:    map[string]interface{}{"brand_id": 7, "available_stock": 2},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, EventLowStock, eventType)
+	assert.Equal(t, EventLowStock, got.Type)
+	assert.True(t, occurredAt.Equal(got.OccurredAt))
+	assert.JSONEq(t, `{"brand_id": 7, "available_stock": 2}`, string(got.Payload))
+}
+
+func TestWebhookNotifierFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier(server.URL).Notify(context.Background(), Event{Type: EventLowStock})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+}
+
+func TestWebhookNotifierTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// a slow receiver is given up on after the client timeout
+	slow := &WebhookNotifier{URL: server.URL, Client: &http.Client{Timeout: 50 * time.Millisecond}}
+	start := time.Now()
+	err := slow.Notify(context.Background(), Event{Type: EventLowStock})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// and so is one the caller stops waiting for
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = NewWebhookNotifier(server.URL).Notify(ctx, Event{Type: EventLowStock})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
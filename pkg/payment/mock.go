@@ -0,0 +1,124 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MockProviderName is the name of the in-process provider used in tests and local development, it
+// accepts any payment and is only used when PAYMENT_PROVIDER names it
+const MockProviderName = "mock"
+
+// MockProvider is a deterministic provider which never leaves the process. Ids are derived
+// from the secret and the request, so the same request always gives the same intent, and
+// webhooks are signed with HMAC-SHA256 of the body
+type MockProvider struct {
+	secret []byte
+}
+
+func NewMockProvider(secret string) *MockProvider {
+	return &MockProvider{secret: []byte(secret)}
+}
+
+// mockWebhook is the body of the webhooks of the mock provider
+type mockWebhook struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	IntentID   string    `json:"intent_id"`
+	Amount     float64   `json:"amount"`
+	Reason     string    `json:"reason,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (p *MockProvider) Name() string {
+	return MockProviderName
+}
+
+func (p *MockProvider) CreateIntent(_ context.Context, req IntentRequest) (*Intent, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	id := "mock_pi_" + p.digest(fmt.Sprintf("intent:%s:%.2f:%s", req.Reference, req.Amount, req.Currency))[:24]
+	return &Intent{
+		ID:           id,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		Status:       IntentRequiresConfirmation,
+		ClientSecret: id + "_secret_" + p.digest("secret:" + id)[:16],
+	}, nil
+}
+
+func (p *MockProvider) Capture(_ context.Context, intentID string, amount float64) (*Intent, error) {
+	if intentID == "" {
+		return nil, errors.New("intent id is required")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	return &Intent{ID: intentID, Amount: amount, Status: IntentSucceeded}, nil
+}
+
+func (p *MockProvider) Refund(_ context.Context, intentID string, amount float64, reference string) (*Refund, error) {
+	if intentID == "" {
+		return nil, errors.New("intent id is required")
+	}
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	return &Refund{
+		ID:       "mock_re_" + p.digest(fmt.Sprintf("refund:%s:%.2f:%s", intentID, amount, reference))[:24],
+		IntentID: intentID,
+		Amount:   amount,
+		Status:   IntentSucceeded,
+	}, nil
+}
+
+func (p *MockProvider) VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	expected := p.digest(string(payload))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var body mockWebhook
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, fmt.Errorf("invalid webhook body: %w", err)
+	}
+	return &WebhookEvent{
+		ID:         body.ID,
+		Type:       body.Type,
+		IntentID:   body.IntentID,
+		Amount:     body.Amount,
+		Reason:     body.Reason,
+		OccurredAt: body.OccurredAt,
+	}, nil
+}
+
+// Webhook builds a signed webhook as the provider would send it, used to confirm payments
+// in tests and local development
+func (p *MockProvider) Webhook(eventType, intentID string, amount float64, reason string) ([]byte, string, error) {
+	payload, err := json.Marshal(mockWebhook{
+		ID:         "mock_evt_" + p.digest(fmt.Sprintf("event:%s:%s", eventType, intentID))[:24],
+		Type:       eventType,
+		IntentID:   intentID,
+		Amount:     amount,
+		Reason:     reason,
+		OccurredAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.digest(string(payload)), nil
+}
+
+// digest is the hex HMAC-SHA256 of the value with the secret
+func (p *MockProvider) digest(value string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Environment variables used to pick and configure the provider
+const (
+	// EnvProvider is the name of the payment provider, only "mock" for now. It has to be set,
+	// the mock provider is never picked by default
+	EnvProvider = "PAYMENT_PROVIDER"
+
+	// EnvWebhookSecret is the secret the provider signs its webhooks with
+	EnvWebhookSecret = "PAYMENT_WEBHOOK_SECRET"
+
+	// EnvCurrency is the ISO currency code payments are taken in
+	EnvCurrency = "PAYMENT_CURRENCY"
+)
+
+// DefaultCurrency is used when no currency is configured
+const DefaultCurrency = "INR"
+
+// Intent statuses
+const (
+	// IntentRequiresConfirmation : created, the customer still has to pay
+	IntentRequiresConfirmation = "requires_confirmation"
+	// IntentRequiresCapture : the customer paid, the money is held until it is captured
+	IntentRequiresCapture = "requires_capture"
+	// IntentSucceeded : the money is captured
+	IntentSucceeded = "succeeded"
+	// IntentFailed : the payment was declined
+	IntentFailed = "failed"
+)
+
+// Webhook event types
+const (
+	// EventPaymentAuthorized : the customer paid, the payment can be captured
+	EventPaymentAuthorized = "payment.authorized"
+	// EventPaymentFailed : the payment was declined
+	EventPaymentFailed = "payment.failed"
+)
+
+// SignatureHeader is the header the webhook signature is sent in
+const SignatureHeader = "X-Payment-Signature"
+
+// ErrInvalidSignature is returned when a webhook is not signed by the provider
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// IntentRequest asks the provider to start a payment
+type IntentRequest struct {
+	Amount   float64
+	Currency string
+	// Reference identifies what is paid for, eg: the order id
+	Reference string
+}
+
+// Intent is a payment at the provider
+type Intent struct {
+	ID       string
+	Amount   float64
+	Currency string
+	Status   string
+	// ClientSecret lets the client finish the payment with the provider
+	ClientSecret string
+}
+
+// Refund is money given back at the provider
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   float64
+	Status   string
+}
+
+// WebhookEvent is a verified notification from the provider
+type WebhookEvent struct {
+	ID         string
+	Type       string
+	IntentID   string
+	Amount     float64
+	Reason     string
+	OccurredAt time.Time
+}
+
+// PaymentProvider is a payment gateway
+type PaymentProvider interface {
+	// Name identifies the provider on the stored payments
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	// Capture takes the held money of an authorized intent
+	Capture(ctx context.Context, intentID string, amount float64) (*Intent, error)
+	// Refund gives back part or all of a captured intent, the reference makes retries idempotent
+	Refund(ctx context.Context, intentID string, amount float64, reference string) (*Refund, error)
+	// VerifyWebhook checks the signature of a webhook body and decodes it
+	VerifyWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// NewFromEnv creates the provider configured in the environment. Both the provider and the webhook
+// secret have to be set, webhooks mark orders paid so there is no default to fall back to
+func NewFromEnv() (PaymentProvider, error) {
+	name := os.Getenv(EnvProvider)
+	secret := os.Getenv(EnvWebhookSecret)
+
+	if name == "" {
+		return nil, fmt.Errorf("%s is not set", EnvProvider)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%s is not set", EnvWebhookSecret)
+	}
+
+	switch name {
+	case MockProviderName:
+		return NewMockProvider(secret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider %q", name)
+}
+
+// Currency returns the configured currency
+func Currency() string {
+	if currency := os.Getenv(EnvCurrency); currency != "" {
+		return currency
+	}
+	return DefaultCurrency
+}